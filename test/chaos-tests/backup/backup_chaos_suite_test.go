@@ -10,6 +10,7 @@ import (
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
 	runtimeClient "sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/anynines/a8s-deployment/test/framework"
@@ -24,7 +25,9 @@ var (
 	err                                                               error
 	testingNamespace, kubeconfigPath, dataservice, instanceNamePrefix string
 
-	k8sClient runtimeClient.Client
+	// restConfig is the config of the API server that k8sClient and the port forwards share.
+	restConfig *rest.Config
+	k8sClient  runtimeClient.Client
 )
 
 func TestChaos(t *testing.T) {
@@ -46,8 +49,11 @@ var _ = BeforeSuite(func() {
 	// Add ChaosMesh definitions
 	Expect(chmv1alpha1.AddToScheme(scheme.Scheme)).To(Succeed())
 
+	restConfig, err = framework.RESTConfig(kubeconfigPath)
+	Expect(err).To(BeNil(), "failed to build the config of the Kubernetes API server")
+
 	// Create Kubernetes client for interacting with the Kubernetes API
-	k8sClient, err = dsi.NewK8sClient(dataservice, kubeconfigPath)
+	k8sClient, err = dsi.NewK8sClientForConfig(dataservice, restConfig)
 	Expect(err).To(BeNil(),
 		fmt.Sprintf("error creating Kubernetes client for dataservice %s", dataservice))
 
@@ -2,22 +2,19 @@ package backup
 
 import (
 	"fmt"
-	"strconv"
 	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 
 	backupv1beta3 "github.com/anynines/a8s-backup-manager/api/v1beta3"
-	sbv1beta3 "github.com/anynines/a8s-service-binding-controller/api/v1beta3"
 
 	"github.com/anynines/a8s-deployment/test/framework"
 	bkp "github.com/anynines/a8s-deployment/test/framework/backup"
 	"github.com/anynines/a8s-deployment/test/framework/chaos"
 	"github.com/anynines/a8s-deployment/test/framework/dsi"
+	"github.com/anynines/a8s-deployment/test/framework/fixture"
 	"github.com/anynines/a8s-deployment/test/framework/postgresql"
-	"github.com/anynines/a8s-deployment/test/framework/secret"
-	"github.com/anynines/a8s-deployment/test/framework/servicebinding"
 )
 
 const (
@@ -30,89 +27,30 @@ const (
 	// entity is a generic term to describe where data services store their data (e.g., a table in
 	// a PostgreSQL database).
 	entity = "test_entity"
-
-	// backupPollingInterval specifies the polling interval for a backup for s3.
-	s3PollingInterval = 100 * time.Millisecond
-
-	// asyncOpsTimeout is the amount of minutes after which assertions fail if the condition
-	// they check has not become true. Needed because some conditions might become true only after
-	// some time, so we need to check them asynchronously.
-	// TODO: Make asyncOpsTimeout an invocation parameter.
-	asyncOpsTimeout = time.Minute * 5
-	// backupTimeout is the amount of minutes after which assertions fail waiting for a backup
-	// to complete. This should be adjusted once we have backups capable of recovering from crashes.
-	backupTimeout = time.Minute * 10
 )
 
 var (
-	// portForwardStopCh is the channel to close to terminate a port forward.
-	portForwardStopCh chan struct{}
-	localPort         int
-
-	sb                 *sbv1beta3.ServiceBinding
-	backup             *backupv1beta3.Backup
-	serviceBindingData secret.SecretData
-	instance           *postgresql.Postgresql
-	client             dsi.DSIClient
+	testDSI  *fixture.DSI
+	backup   *backupv1beta3.Backup
+	instance *postgresql.Postgresql
+	client   dsi.DSIClient
 )
 
 var _ = Describe("Backup Chaos Tests", func() {
 	BeforeEach(func() {
-		// Create Dataservice instance and wait for instance readiness
 		instance = postgresql.New(
 			testingNamespace,
 			framework.GenerateName(instanceNamePrefix, GinkgoParallelProcess(), suffixLength),
 			replicas)
 
-		Expect(k8sClient.Create(ctx, instance.GetClientObject())).
-			To(Succeed(), fmt.Sprintf("failed to create instance %s/%s",
-				instance.GetNamespace(), instance.GetName()))
-		dsi.WaitForReadiness(ctx, instance.GetClientObject(), k8sClient)
-
-		// Portforward to access instance from outside cluster.
-		portForwardStopCh, localPort, err = framework.PortForward(
-			ctx, instancePort, kubeconfigPath, instance, k8sClient)
-		Expect(err).To(BeNil(),
-			fmt.Sprintf("failed to establish portforward to DSI %s/%s",
-				instance.GetNamespace(), instance.GetName()))
-
-		// Create service binding for instance.
-		sb = servicebinding.New(
-			servicebinding.SetNamespacedName(instance.GetClientObject()),
-			servicebinding.SetInstanceRef(instance.GetClientObject()),
-		)
-		Expect(k8sClient.Create(ctx, sb)).
-			To(Succeed(), fmt.Sprintf("failed to create new servicebinding for DSI %s/%s",
-				instance.GetNamespace(), instance.GetName()))
-		servicebinding.WaitForReadiness(ctx, sb, k8sClient)
-		serviceBindingData, err = secret.Data(
-			ctx, k8sClient, servicebinding.SecretName(sb.Name), testingNamespace)
-		Expect(err).To(BeNil(),
-			fmt.Sprintf("failed to parse secret data for service binding %s/%s",
-				sb.GetNamespace(), sb.GetName()))
-
-		// Create client for interacting with the new instance.
-		client, err = dsi.NewClient(dataservice, strconv.Itoa(localPort), serviceBindingData)
-		Expect(err).To(BeNil(), "failed to create new dsi client")
-	})
-
-	AfterEach(func() {
-		defer func() { close(portForwardStopCh) }()
-
-		Expect(k8sClient.Delete(ctx, backup)).To(Succeed(),
-			fmt.Sprintf("failed to delete backup %s/%s",
-				backup.GetNamespace(), backup.GetName()))
-		bkp.WaitForDeletion(ctx, backup, k8sClient)
-
-		Expect(k8sClient.Delete(ctx, instance.GetClientObject())).To(Succeed(),
-			fmt.Sprintf("failed to delete instance %s/%s",
-				instance.GetNamespace(), instance.GetName()))
-
-		Expect(k8sClient.Delete(ctx, sb)).To(Succeed(),
-			fmt.Sprintf("failed to delete service binding %s/%s",
-				sb.GetNamespace(), sb.GetName()))
-		dsi.WaitForDeletion(ctx, instance.GetClientObject(), k8sClient)
-		// TODO: Wait for deletion for all secondary objects
+		testDSI = fixture.NewDSI(ctx, fixture.Options{
+			Client:      k8sClient,
+			RESTConfig:  restConfig,
+			DataService: dataservice,
+			Port:        instancePort,
+			Instance:    instance,
+		})
+		client = testDSI.Client
 	})
 
 	It("Backup agent crashes while processing a backup", func() {
@@ -172,7 +110,7 @@ var _ = Describe("Backup Chaos Tests", func() {
 				}
 
 				return ready
-			}, asyncOpsTimeout).Should(BeTrue(),
+			}, framework.ActiveConfig().Chaos.InjectionTimeout).Should(BeTrue(),
 				fmt.Sprintf("timeout reached waiting for chaos to apply to DSI %s/%s",
 					instance.GetNamespace(),
 					instance.GetName()),
@@ -184,9 +122,7 @@ var _ = Describe("Backup Chaos Tests", func() {
 				bkp.SetNamespacedName(instance),
 				bkp.SetInstanceRef(instance.GetClientObject()),
 			)
-			Expect(k8sClient.Create(ctx, backup)).To(Succeed(),
-				fmt.Sprintf("failed to create backup for DSI %s/%s",
-					instance.GetNamespace(), instance.GetName()))
+			testDSI.Create(ctx, backup)
 		})
 
 		var masterStop chaos.ChaosObject
@@ -210,7 +146,7 @@ var _ = Describe("Backup Chaos Tests", func() {
 				}
 
 				return ready
-			}, asyncOpsTimeout).Should(BeTrue(),
+			}, framework.ActiveConfig().Chaos.InjectionTimeout).Should(BeTrue(),
 				fmt.Sprintf("timeout reached waiting for Pod Chaos to apply to DSI %s/%s",
 					instance.GetNamespace(),
 					instance.GetName()),
@@ -218,7 +154,7 @@ var _ = Describe("Backup Chaos Tests", func() {
 		})
 
 		// Sleep to ensure the backup fails.
-		time.Sleep(time.Second * 10)
+		time.Sleep(framework.ActiveConfig().Chaos.FailureDuration)
 
 		By("Restart master by deleting PodChaos", func() {
 			Expect(k8sClient.Delete(ctx, masterStop.KubernetesObject())).To(Succeed(),
@@ -237,7 +173,7 @@ var _ = Describe("Backup Chaos Tests", func() {
 		})
 
 		By("Ensure the backup is eventually successful", func() {
-			bkp.WaitForReadiness(ctx, backup, backupTimeout, k8sClient)
+			bkp.WaitForReadiness(ctx, backup, framework.BackupTimeout(), k8sClient)
 		})
 	})
 
@@ -294,9 +230,7 @@ var _ = Describe("Backup Chaos Tests", func() {
 			// whether a failed backup has been cleaned up.
 			bkp.MaxRetries("0"),
 		)
-		Expect(k8sClient.Create(ctx, backup)).To(Succeed(),
-			fmt.Sprintf("failed to create backup for DSI %s/%s",
-				instance.GetNamespace(), instance.GetName()))
+		testDSI.Create(ctx, backup)
 
 		// Wait for backup to begin before going further.
 		Eventually(func() bool {
@@ -306,7 +240,7 @@ var _ = Describe("Backup Chaos Tests", func() {
 			}
 
 			return hasPartialData
-		}, asyncOpsTimeout, s3PollingInterval).Should(BeTrue(),
+		}, framework.AsyncOpsTimeout(), framework.ActiveConfig().Chaos.S3PollingPeriod).Should(BeTrue(),
 			"timeout reached waiting for backup to begin")
 
 		// Chaos Mesh takes awhile to apply the Chaos objects in which the backup could have already
@@ -329,7 +263,7 @@ var _ = Describe("Backup Chaos Tests", func() {
 				}
 
 				return !hasPartialData
-			}, asyncOpsTimeout, s3PollingInterval).Should(BeTrue(),
+			}, framework.AsyncOpsTimeout(), framework.ActiveConfig().Chaos.S3PollingPeriod).Should(BeTrue(),
 				"timeout reached waiting for cleanup of partial data")
 		})
 	})
@@ -357,7 +291,7 @@ func applyPodChaos(pgChaosInjector chaos.PgInjector) chaos.ChaosObject { //nolin
 		}
 
 		return ready
-	}, asyncOpsTimeout).Should(BeTrue(),
+	}, framework.ActiveConfig().Chaos.InjectionTimeout).Should(BeTrue(),
 		fmt.Sprintf("timeout reached waiting for Pod Chaos to apply to DSI %s/%s",
 			instance.GetNamespace(),
 			instance.GetName()),
@@ -11,10 +11,8 @@ import (
 	"github.com/anynines/a8s-deployment/test/framework"
 	"github.com/anynines/a8s-deployment/test/framework/chaos"
 	"github.com/anynines/a8s-deployment/test/framework/dsi"
+	"github.com/anynines/a8s-deployment/test/framework/fixture"
 	"github.com/anynines/a8s-deployment/test/framework/postgresql"
-	"github.com/anynines/a8s-deployment/test/framework/secret"
-	"github.com/anynines/a8s-deployment/test/framework/servicebinding"
-	sbv1beta3 "github.com/anynines/a8s-service-binding-controller/api/v1beta3"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
 )
@@ -27,74 +25,31 @@ const (
 	// entity is a generic term to describe where data services store their data.
 	entity = "test_entity"
 
-	// asyncOpsTimeoutMins is the amount of minutes after which assertions fail if the condition
-	// they check has not become true. Needed because some conditions might become true only after
-	// some time, so we need to check them asynchronously.
-	// TODO: Make asyncOpsTimeoutMins an invocation parameter.
-	asyncOpsTimeoutMins = time.Minute * 5
+	// maxLagOnFailover is the default maximum_lag_on_failover of the Spilo image, in bytes.
+	maxLagOnFailover = 33554432
 )
 
 var (
-	// portForwardStopCh is the channel to close to terminate a port forward
-	portForwardStopCh chan struct{}
-	localPort         int
-
-	sb                 *sbv1beta3.ServiceBinding
-	serviceBindingData secret.SecretData
-	instance           *postgresql.Postgresql
-	client             dsi.DSIClient
+	testDSI  *fixture.DSI
+	instance *postgresql.Postgresql
+	client   dsi.DSIClient
 )
 
 var _ = Describe("PostgreSQL Chaos tests", func() {
 	BeforeEach(func() {
-		// Create Dataservice instance and wait for instance readiness
 		instance = postgresql.New(
 			testingNamespace,
 			framework.GenerateName(instanceNamePrefix, GinkgoParallelProcess(), suffixLength),
 			replicas, postgresql.WithVolumeSize("2Gi"))
 
-		Expect(k8sClient.Create(ctx, instance.GetClientObject())).
-			To(Succeed(), fmt.Sprintf("failed to create instance %s/%s",
-				instance.GetNamespace(), instance.GetName()))
-		dsi.WaitForReadiness(ctx, instance.GetClientObject(), k8sClient)
-
-		// Portforward to access instance from outside cluster.
-		portForwardStopCh, localPort, err = framework.PortForward(
-			ctx, instancePort, kubeconfigPath, instance, k8sClient)
-		Expect(err).To(BeNil(),
-			fmt.Sprintf("failed to establish portforward to DSI %s/%s",
-				instance.GetNamespace(), instance.GetName()))
-
-		// Create service binding for instance.
-		sb = servicebinding.New(
-			servicebinding.SetNamespacedName(instance.GetClientObject()),
-			servicebinding.SetInstanceRef(instance.GetClientObject()),
-		)
-		Expect(k8sClient.Create(ctx, sb)).
-			To(Succeed(), fmt.Sprintf("failed to create new servicebinding for DSI %s/%s",
-				instance.GetNamespace(), instance.GetName()))
-		servicebinding.WaitForReadiness(ctx, sb, k8sClient)
-		serviceBindingData, err = secret.Data(
-			ctx, k8sClient, servicebinding.SecretName(sb.Name), testingNamespace)
-		Expect(err).To(BeNil(),
-			fmt.Sprintf("failed to parse secret data for service binding %s/%s",
-				sb.GetNamespace(), sb.GetName()))
-
-		// Create client for interacting with the new instance.
-		client, err = dsi.NewClient(dataservice, strconv.Itoa(localPort), serviceBindingData)
-		Expect(err).To(BeNil(), "failed to create new dsi client")
-	})
-
-	AfterEach(func() {
-		defer func() { close(portForwardStopCh) }()
-		Expect(k8sClient.Delete(ctx, instance.GetClientObject())).To(Succeed(),
-			fmt.Sprintf("failed to delete instance %s/%s",
-				instance.GetNamespace(), instance.GetName()))
-		Expect(k8sClient.Delete(ctx, sb)).To(Succeed(),
-			fmt.Sprintf("failed to delete service binding %s/%s",
-				sb.GetNamespace(), sb.GetName()))
-		dsi.WaitForDeletion(ctx, instance.GetClientObject(), k8sClient)
-		//TODO: Wait for deletion for all secondary objects
+		testDSI = fixture.NewDSI(ctx, fixture.Options{
+			Client:      k8sClient,
+			RESTConfig:  restConfig,
+			DataService: dataservice,
+			Port:        instancePort,
+			Instance:    instance,
+		})
+		client = testDSI.Client
 	})
 
 	It("No failover to replica with critical replication lag", func() {
@@ -114,7 +69,7 @@ var _ = Describe("PostgreSQL Chaos tests", func() {
 					return false
 				}
 				return ready
-			}, asyncOpsTimeoutMins).Should(BeTrue(),
+			}, framework.AsyncOpsTimeout()).Should(BeTrue(),
 				fmt.Sprintf("timeout reached waiting for labels to be assigned to "+
 					"instance %s/%s: %s",
 					instance.GetNamespace(),
@@ -147,13 +102,26 @@ var _ = Describe("PostgreSQL Chaos tests", func() {
 				}
 
 				return ready
-			}, asyncOpsTimeoutMins).Should(BeTrue(),
+			}, framework.ActiveConfig().Chaos.InjectionTimeout).Should(BeTrue(),
 				fmt.Sprintf("timeout reached waiting for chaos to apply to DSI %s/%s",
 					instance.GetNamespace(),
 					instance.GetName()),
 			)
 		})
 
+		// The replicas are stopped, so they can't replay the WAL beyond the position of the
+		// master before the data is written.
+		var startLSN postgresql.LSN
+		By("Recording the WAL position of the master before writing data", func() {
+			state, err := instance.Replication(ctx, k8sClient, restConfig)
+			Expect(err).To(BeNil(),
+				fmt.Sprintf("failed to get replication state of DSI %s/%s",
+					instance.GetNamespace(),
+					instance.GetName()),
+			)
+			startLSN = state.CurrentLSN
+		})
+
 		// Create critical replication lag
 		// Spilo image default maximum_lag_on_failover: 33554432
 		// Source:
@@ -212,13 +180,47 @@ var _ = Describe("PostgreSQL Chaos tests", func() {
 
 		// This timing is critical : We need to ensure the replicas have enough
 		// time to connect to the master and get their replication delay while
-		// simultaneously not giving them enough time to catch up.
+		// simultaneously not giving them enough time to catch up. Querying the
+		// replication state gives them that time.
 		//
 		// If this becomes a source of flakiness, you can increase the amount of
-		// data that is written and increase the delay. Otherwise limiting the
-		// bandwidth to the replicas with the  help of NetworkChaos would be an
-		// option.
-		time.Sleep(10 * time.Millisecond)
+		// data that is written. Otherwise limiting the bandwidth to the replicas
+		// with the  help of NetworkChaos would be an option.
+		By("Ensuring the replicas reached critical replication lag", func() {
+			state, err := instance.Replication(ctx, k8sClient, restConfig)
+			Expect(err).To(BeNil(),
+				fmt.Sprintf("failed to get replication state of DSI %s/%s",
+					instance.GetNamespace(),
+					instance.GetName()),
+			)
+			Expect(state.Replicas).To(HaveLen(replicas - 1))
+			for _, r := range state.Replicas {
+				Expect(r.Err).To(BeNil(),
+					fmt.Sprintf("failed to query replica %s of DSI %s/%s: %s",
+						r.Pod,
+						instance.GetNamespace(),
+						instance.GetName(),
+						state),
+				)
+				Expect(r.State).ToNot(BeEmpty(),
+					fmt.Sprintf("replica %s of DSI %s/%s isn't connected to the master: %s",
+						r.Pod,
+						instance.GetNamespace(),
+						instance.GetName(),
+						state),
+				)
+				// Only the data written while the replica was stopped counts, even if it
+				// reports an older replay position.
+				replayed := max(startLSN, r.ReplayedLSN, r.ReplayLSN)
+				Expect(state.CurrentLSN).To(BeNumerically(">", replayed+maxLagOnFailover),
+					fmt.Sprintf("replica %s of DSI %s/%s has no critical replication lag: %s",
+						r.Pod,
+						instance.GetNamespace(),
+						instance.GetName(),
+						state),
+				)
+			}
+		})
 
 		// Stop the master before replicas can catch up
 		var masterStop chaos.ChaosObject
@@ -270,9 +272,7 @@ var _ = Describe("PostgreSQL Chaos tests", func() {
 		dsi.WaitForReplicaReadiness(ctx, instance.GetClientObject(), k8sClient, replicas)
 
 		// Wait for propagation of data to the replicas
-		// TODO alternative: check replication lag in the replica with specific
-		// pg client
-		time.Sleep(40 * time.Second)
+		instance.WaitForReplicasCaughtUp(ctx, k8sClient, restConfig)
 
 		// Check replica data propagation
 		By("Ensuring data was propagated to replicas", func() {
@@ -294,7 +294,7 @@ var _ = Describe("PostgreSQL Chaos tests", func() {
 
 			replicaPod := &replicaPods.Items[0]
 			replicaPortForwardStopCh, replicaLocalPort, err := framework.PortForwardPod(
-				ctx, instancePort, kubeconfigPath, replicaPod, k8sClient)
+				ctx, instancePort, restConfig, replicaPod)
 			defer func() { close(replicaPortForwardStopCh) }()
 
 			Expect(err).To(BeNil(),
@@ -304,7 +304,7 @@ var _ = Describe("PostgreSQL Chaos tests", func() {
 			)
 
 			replicaClient, err := dsi.NewClient(dataservice,
-				strconv.Itoa(replicaLocalPort), serviceBindingData)
+				strconv.Itoa(replicaLocalPort), testDSI.Credentials)
 			Expect(err).To(BeNil(),
 				fmt.Sprintf("failed to create client to DSI %s/%s connecting to replica",
 					instance.GetNamespace(),
@@ -405,7 +405,7 @@ var _ = Describe("PostgreSQL Chaos tests", func() {
 						instance.GetName()),
 				)
 				return !dsi.IsPodReady(masterPod)
-			}, asyncOpsTimeoutMins).Should(BeTrue(),
+			}, framework.ActiveConfig().Chaos.InjectionTimeout).Should(BeTrue(),
 				fmt.Sprintf("timeout reached waiting for chaos to apply to DSI %s/%s: %s",
 					instance.GetNamespace(),
 					instance.GetName(),
@@ -426,7 +426,7 @@ var _ = Describe("PostgreSQL Chaos tests", func() {
 				)
 
 				return dsi.NPodsReady(masterPods)
-			}, asyncOpsTimeoutMins).Should(BeEquivalentTo(1),
+			}, framework.AsyncOpsTimeout()).Should(BeEquivalentTo(1),
 				fmt.Sprintf("timeout reached while waiting for new master of DSI %s/%s"+
 					"to be elected",
 					instance.GetNamespace(),
@@ -437,8 +437,6 @@ var _ = Describe("PostgreSQL Chaos tests", func() {
 		// recreate port forward to new master
 		// TODO: After rework of port forwarding logic, this step should be unnecessary
 		By("Recreating port forward for new master", func() {
-			close(portForwardStopCh)
-
 			masterPods, err := dsi.GetPodsWithLabels(ctx, k8sClient, instance.GetNamespace(),
 				instance.GetMasterLabels())
 			Expect(err).To(BeNil(),
@@ -454,20 +452,8 @@ var _ = Describe("PostgreSQL Chaos tests", func() {
 				}
 			}
 
-			portForwardStopCh, localPort, err = framework.PortForwardPod(
-				ctx, instancePort, kubeconfigPath, newMasterPod, k8sClient)
-			Expect(err).To(BeNil(),
-				fmt.Sprintf("failed to recreate port forward to DSI %s/%s",
-					instance.GetNamespace(),
-					instance.GetName()),
-			)
-
-			client, err = dsi.NewClient(dataservice, strconv.Itoa(localPort), serviceBindingData)
-			Expect(err).To(BeNil(),
-				fmt.Sprintf("failed to recreate dsi client to DSI %s/%s",
-					instance.GetNamespace(),
-					instance.GetName()),
-			)
+			testDSI.ForwardToPod(ctx, newMasterPod)
+			client = testDSI.Client
 		})
 
 		By("Writing more random data to new master", func() {
@@ -520,7 +506,7 @@ var _ = Describe("PostgreSQL Chaos tests", func() {
 						instance.GetName()),
 				)
 				return !postgresql.IsMaster(masterPod)
-			}, asyncOpsTimeoutMins).Should(BeTrue(),
+			}, framework.AsyncOpsTimeout()).Should(BeTrue(),
 				fmt.Sprintf("timed out while waiting for former master pod %s of DSI %s/%s "+
 					"to rejoin the cluster as replica",
 					masterPod.Name,
@@ -535,7 +521,7 @@ var _ = Describe("PostgreSQL Chaos tests", func() {
 
 		By("Ensuring data is readable from master", func() {
 			masterPortForwardStopCh, masterLocalPort, err := framework.PortForwardPod(
-				ctx, instancePort, kubeconfigPath, masterPod, k8sClient)
+				ctx, instancePort, restConfig, masterPod)
 			defer func() { close(masterPortForwardStopCh) }()
 
 			Expect(err).To(BeNil(),
@@ -546,7 +532,7 @@ var _ = Describe("PostgreSQL Chaos tests", func() {
 			)
 
 			replicaClient, err := dsi.NewClient(dataservice, strconv.Itoa(masterLocalPort),
-				serviceBindingData)
+				testDSI.Credentials)
 			Expect(err).To(BeNil(),
 				fmt.Sprintf("failed to create DSI client for pod %s of DSI %s/%s",
 					masterPod.Name,
@@ -7,11 +7,14 @@ import (
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"k8s.io/client-go/rest"
 	runtimeClient "sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/anynines/a8s-deployment/test/framework"
 	"github.com/anynines/a8s-deployment/test/framework/dsi"
 	"github.com/anynines/a8s-deployment/test/framework/namespace"
+	// Register the data services the suite can be run against.
+	_ "github.com/anynines/a8s-deployment/test/framework/postgresql"
 )
 
 var (
@@ -20,7 +23,9 @@ var (
 	err                                                               error
 	testingNamespace, kubeconfigPath, dataservice, instanceNamePrefix string
 
-	k8sClient runtimeClient.Client
+	// restConfig is the config of the API server that k8sClient and the port forwards share.
+	restConfig *rest.Config
+	k8sClient  runtimeClient.Client
 )
 
 func TestBackupAndRestore(t *testing.T) {
@@ -37,8 +42,11 @@ var _ = BeforeSuite(func() {
 	kubeconfigPath, instanceNamePrefix, dataservice, testingNamespace =
 		framework.ConfigToVars(config)
 
+	restConfig, err = framework.RESTConfig(kubeconfigPath)
+	Expect(err).To(BeNil(), "failed to build the config of the Kubernetes API server")
+
 	// Create kubernetes client for interacting with the Kubernetes API
-	k8sClient, err = dsi.NewK8sClient(dataservice, kubeconfigPath)
+	k8sClient, err = dsi.NewK8sClientForConfig(dataservice, restConfig)
 	Expect(err).To(BeNil(),
 		fmt.Sprintf("error creating Kubernetes client for dataservice %s", dataservice))
 
@@ -1,8 +1,6 @@
 package backup
 
 import (
-	"fmt"
-	"strconv"
 	"strings"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -12,16 +10,13 @@ import (
 	"github.com/anynines/a8s-deployment/test/framework"
 	bkp "github.com/anynines/a8s-deployment/test/framework/backup"
 	"github.com/anynines/a8s-deployment/test/framework/dsi"
+	"github.com/anynines/a8s-deployment/test/framework/fixture"
 	rst "github.com/anynines/a8s-deployment/test/framework/restore"
-	"github.com/anynines/a8s-deployment/test/framework/secret"
-	"github.com/anynines/a8s-deployment/test/framework/servicebinding"
-	sbv1beta3 "github.com/anynines/a8s-service-binding-controller/api/v1beta3"
 )
 
 const (
 	instancePort = 5432
 	replicas     = 1
-	suffixLength = 5
 
 	// TODO: Make configurable and generalizable using Data interface
 	// testInput is data input used for testing data service functionality.
@@ -31,76 +26,25 @@ const (
 )
 
 var (
-	// portForwardStopCh is the channel to close to terminate a port forward
-	portForwardStopCh chan struct{}
-	localPort         int
-
-	sb       *sbv1beta3.ServiceBinding
 	backup   *backupv1beta3.Backup
 	restore  *backupv1beta3.Restore
 	instance dsi.Object
 	client   dsi.DSIClient
+	testDSI  *fixture.DSI
 )
 
 var _ = Describe("Backup", func() {
 	BeforeEach(func() {
-		// Create Dataservice instance and wait for instance readiness
-		instance, err = dsi.New(
-			dataservice,
-			testingNamespace,
-			framework.GenerateName(instanceNamePrefix, GinkgoParallelProcess(), suffixLength),
-			replicas,
-		)
-		Expect(err).To(BeNil(), "failed to generate new DSI resource")
-
-		Expect(k8sClient.Create(ctx, instance.GetClientObject())).
-			To(Succeed(), fmt.Sprintf("failed to create instance %s/%s",
-				instance.GetNamespace(), instance.GetName()))
-		dsi.WaitForReadiness(ctx, instance.GetClientObject(), k8sClient)
-
-		// Portforward to access instance from outside cluster.
-		portForwardStopCh, localPort, err = framework.PortForward(
-			ctx, instancePort, kubeconfigPath, instance, k8sClient)
-		Expect(err).To(BeNil(),
-			fmt.Sprintf("failed to establish portforward to DSI %s/%s",
-				instance.GetNamespace(), instance.GetName()))
-
-		// Create service binding for instance.
-		sb = servicebinding.New(
-			servicebinding.SetNamespacedName(instance.GetClientObject()),
-			servicebinding.SetInstanceRef(instance.GetClientObject()),
-		)
-		Expect(k8sClient.Create(ctx, sb)).
-			To(Succeed(), fmt.Sprintf("failed to create new servicebinding for DSI %s/%s",
-				instance.GetNamespace(), instance.GetName()))
-		servicebinding.WaitForReadiness(ctx, sb, k8sClient)
-		serviceBindingData, err := secret.Data(
-			ctx, k8sClient, servicebinding.SecretName(sb.Name), testingNamespace)
-		Expect(err).To(BeNil(),
-			fmt.Sprintf("failed to parse secret data for service binding %s/%s",
-				sb.GetNamespace(), sb.GetName()))
-
-		// Create client for interacting with the new instance.
-		client, err = dsi.NewClient(dataservice, strconv.Itoa(localPort), serviceBindingData)
-		Expect(err).To(BeNil(), "failed to create new dsi client")
-	})
-
-	AfterEach(func() {
-		defer func() { close(portForwardStopCh) }()
-		Expect(k8sClient.Delete(ctx, instance.GetClientObject())).To(Succeed(),
-			fmt.Sprintf("failed to delete instance %s/%s",
-				instance.GetNamespace(), instance.GetName()))
-		Expect(k8sClient.Delete(ctx, sb)).To(Succeed(),
-			fmt.Sprintf("failed to delete service binding %s/%s",
-				sb.GetNamespace(), sb.GetName()))
-		Expect(k8sClient.Delete(ctx, backup)).To(Succeed(),
-			fmt.Sprintf("failed to delete backup %s/%s",
-				backup.GetNamespace(), backup.GetName()))
-		Expect(k8sClient.Delete(ctx, restore)).To(Succeed(),
-			fmt.Sprintf("failed to delete restore %s/%s",
-				restore.GetNamespace(), restore.GetName()))
-		dsi.WaitForDeletion(ctx, instance.GetClientObject(), k8sClient)
-		// TODO: Wait for deletion for all secondary objects
+		testDSI = fixture.NewDSI(ctx, fixture.Options{
+			Client:      k8sClient,
+			RESTConfig:  restConfig,
+			DataService: dataservice,
+			Namespace:   testingNamespace,
+			NamePrefix:  instanceNamePrefix,
+			Replicas:    replicas,
+			Port:        instancePort,
+		})
+		instance, client = testDSI.Instance, testDSI.Client
 	})
 
 	It("Performs backup and restore of instance", func() {
@@ -122,10 +66,8 @@ var _ = Describe("Backup", func() {
 				bkp.SetNamespacedName(instance),
 				bkp.SetInstanceRef(instance.GetClientObject()),
 			)
-			Expect(k8sClient.Create(ctx, backup)).To(Succeed(),
-				fmt.Sprintf("failed to create backup for DSI %s/%s",
-					instance.GetNamespace(), instance.GetName()))
-			bkp.WaitForReadiness(ctx, backup, framework.AsyncOpsTimeoutMins, k8sClient)
+			testDSI.Create(ctx, backup)
+			bkp.WaitForReadiness(ctx, backup, framework.BackupTimeout(), k8sClient)
 		})
 
 		By("Writing more data", func() {
@@ -146,15 +88,20 @@ var _ = Describe("Backup", func() {
 			}
 		})
 
+		By("Deleting the data", func() {
+			Expect(client.DeleteEntity(ctx, entity)).
+				To(Succeed(), "failed to delete data")
+			_, err := client.Read(ctx, entity)
+			Expect(err).To(MatchError(dsi.ErrNotFound), "read deleted data")
+		})
+
 		By("Restoring the instance from a backup", func() {
 			restore = rst.New(
 				rst.SetInstanceRef(instance.GetClientObject()),
 				rst.SetNamespacedName(instance),
 				rst.SetBackupName(backup.GetName()),
 			)
-			Expect(k8sClient.Create(ctx, restore)).To(Succeed(),
-				fmt.Sprintf("failed to create restore for %s/%s",
-					restore.GetNamespace(), restore.GetName()))
+			testDSI.Create(ctx, restore)
 			rst.WaitForReadiness(ctx, restore, k8sClient)
 		})
 
@@ -7,6 +7,7 @@ import (
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"k8s.io/client-go/rest"
 	runtimeClient "sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/anynines/a8s-deployment/test/framework"
@@ -20,7 +21,9 @@ var (
 	err                                                               error
 	testingNamespace, kubeconfigPath, dataservice, instanceNamePrefix string
 
-	k8sClient runtimeClient.Client
+	// restConfig is the config of the API server that k8sClient and the port forwards share.
+	restConfig *rest.Config
+	k8sClient  runtimeClient.Client
 )
 
 const expectedDataservice = "PostgreSQL"
@@ -47,8 +50,11 @@ var _ = BeforeSuite(func() {
 	Expect(dataservice).To(Equal(expectedDataservice), "this suite can run only for dataservice "+
 		expectedDataservice)
 
+	restConfig, err = framework.RESTConfig(kubeconfigPath)
+	Expect(err).To(BeNil(), "failed to build the config of the Kubernetes API server")
+
 	// Create kubernetes client for interacting with the Kubernetes API
-	k8sClient, err = dsi.NewK8sClient(dataservice, kubeconfigPath)
+	k8sClient, err = dsi.NewK8sClientForConfig(dataservice, restConfig)
 	Expect(err).To(BeNil(),
 		fmt.Sprintf("error creating Kubernetes client for dataservice %s", dataservice))
 
@@ -3,8 +3,6 @@ package patroni
 import (
 	"fmt"
 	"strconv"
-	"strings"
-	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -15,16 +13,16 @@ import (
 
 	"github.com/anynines/a8s-deployment/test/framework"
 	"github.com/anynines/a8s-deployment/test/framework/dsi"
+	"github.com/anynines/a8s-deployment/test/framework/fixture"
+	"github.com/anynines/a8s-deployment/test/framework/patroni"
 	"github.com/anynines/a8s-deployment/test/framework/postgresql"
-	"github.com/anynines/a8s-deployment/test/framework/secret"
 	"github.com/anynines/postgresql-operator/api/v1beta3"
 )
 
 const (
-	instancePort       = 5432
-	replicas           = 1
-	suffixLength       = 5
-	patroniMonitorPort = 8008
+	instancePort = 5432
+	replicas     = 1
+	suffixLength = 5
 
 	// PostgreSQL configuration naming style
 	ArchiveTimeout        = "archive_timeout"
@@ -50,99 +48,60 @@ const (
 )
 
 var (
-	// portForwardStopCh is the channel used to manage the lifecycle of a port forward.
-	portForwardStopCh chan struct{}
-	localPort         int
-	ok                bool
-
-	instance        dsi.Object
-	client          dsi.DSIClient
-	pg              *v1beta3.Postgresql
-	adminSecretData secret.SecretData
+	testDSI  *fixture.DSI
+	instance dsi.Object
+	client   dsi.DSIClient
+	pg       *postgresql.Postgresql
 )
 
 var _ = Describe("Patroni end-to-end Tests", func() {
 	Context("Patroni Configuration", func() {
-		AfterEach(func() {
-			defer func() { close(portForwardStopCh) }()
-			Expect(k8sClient.Delete(ctx, instance.GetClientObject())).To(Succeed(),
-				fmt.Sprintf("failed to delete instance %s/%s",
-					instance.GetNamespace(), instance.GetName()))
-			dsi.WaitForDeletion(ctx, instance.GetClientObject(), k8sClient)
-		})
-
 		It("Sets default configuration when deploying a PostgreSQL instance without explicit configuration", func() {
 			const (
-				// The representations between values given to fields
-				// in the PostgreSQL CR and values of these parameters in PostgreSQL
-				// itself do not always match. So we provide here what we expect in
-				// PostgreSQL itself and not in the PostgreSQL CR.
-				defaultArchiveTimeoutSeconds  = 0
+				// Values with a unit are given in the unit of the corresponding field of
+				// the PostgreSQL CR and compared semantically to the value in PostgreSQL.
+				defaultArchiveTimeout         = "0s"
 				defaultClientMinMessages      = "notice"
 				defaultLogErrorVerbosity      = "default"
 				defaultLogMinErrorStatement   = "error"
 				defaultLogMinMessages         = "warning"
 				defaultLogStatement           = "none"
-				defaultMaxConnections         = 100
-				defaultMaxReplicationSlots    = 10
-				defaultMaxWALSenders          = 10
-				defaultSharedBuffers          = "100MB" // 1024 is converted to 100MB.
+				defaultMaxConnections         = "100"
+				defaultMaxReplicationSlots    = "10"
+				defaultMaxWALSenders          = "10"
+				defaultSharedBuffers          = "100MB"
 				defaultSSLCiphers             = "HIGH:MEDIUM:+3DES:!aNULL"
 				defaultSSLMinProtocolVersion  = "TLSv1.2"
-				defaultStatementTimeoutMillis = 0
+				defaultStatementTimeout       = "0ms"
 				defaultSynchronousCommit      = "on"
-				defaultTempFileLimitKiloBytes = -1
+				defaultTempFileLimit          = "-1kB"
 				defaultTrackIOTiming          = "off"
-				defaultWalWriterDelayMillis   = 200 // Needs ms
-				defaultMaxLocksPerTransaction = 100
+				defaultWalWriterDelay         = "200ms"
+				defaultMaxLocksPerTransaction = "100"
 			)
 
 			By("creating a PostgreSQL instance with implicit defaults", func() {
-				instance, err = dsi.New(
-					dataservice,
-					testingNamespace,
-					framework.GenerateName(instanceNamePrefix,
-						GinkgoParallelProcess(),
-						suffixLength),
-					replicas,
-				)
-				Expect(err).To(BeNil(), "failed to generate new DSI resource")
-
-				Expect(k8sClient.Create(ctx, instance.GetClientObject())).
-					To(Succeed(), fmt.Sprintf("failed to create instance %s/%s",
-						instance.GetNamespace(), instance.GetName()))
-				dsi.WaitForReadiness(ctx, instance.GetClientObject(), k8sClient)
-
-				// Portforward to access instance from outside cluster.
-				portForwardStopCh, localPort, err = framework.PortForward(
-					ctx, instancePort, kubeconfigPath, instance, k8sClient)
-				Expect(err).To(BeNil(),
-					fmt.Sprintf("failed to establish portforward to DSI %s/%s",
-						instance.GetNamespace(), instance.GetName()))
-
-				// Fetch admin secret for privileged DSIClient. We need a
-				// privileged client since some config parameters such as
-				// SSLCiphers can not be fetched by service binding users.
-				adminSecretData, err = secret.AdminSecretData(
-					ctx, k8sClient, instance.GetName(), instance.GetNamespace())
-				Expect(err).To(BeNil(),
-					fmt.Sprintf("failed to parse secret data of admin credentials for %s/%s",
-						instance.GetNamespace(), instance.GetName()))
-
-				// Create client for interacting with the new instance.
-				client, err = dsi.NewClient(
-					dataservice, strconv.Itoa(localPort), adminSecretData)
-				Expect(err).To(BeNil(), "failed to create new dsi client")
+				testDSI = fixture.NewDSI(ctx, fixture.Options{
+					Client:      k8sClient,
+					RESTConfig:  restConfig,
+					DataService: dataservice,
+					Namespace:   testingNamespace,
+					NamePrefix:  instanceNamePrefix,
+					Replicas:    replicas,
+					Port:        instancePort,
+					// We need a privileged client since some config parameters such as
+					// SSLCiphers can not be fetched by service binding users.
+					WithoutServiceBinding: true,
+				})
+				instance, client = testDSI.Instance, testDSI.Client
 			})
 
 			By("checking that the defaults are set correctly", func() {
-				expectedConfig := []struct {
-					parameter, value string
-				}{
-					{ArchiveTimeout, strconv.Itoa(defaultArchiveTimeoutSeconds)},
-					{TempFileLimit, strconv.Itoa(defaultTempFileLimitKiloBytes)},
+				expectedConfig := []parameterValue{
+					{ArchiveTimeout, defaultArchiveTimeout},
+					{TempFileLimit, defaultTempFileLimit},
 					{TrackIOTiming, defaultTrackIOTiming},
-					{StatementTimeout, strconv.Itoa(defaultStatementTimeoutMillis)},
+					{StatementTimeout, defaultStatementTimeout},
 					{ClientMinMessages, defaultClientMinMessages},
 					{LogMinMessages, defaultLogMinMessages},
 					{LogMinErrorStatement, defaultLogMinErrorStatement},
@@ -150,15 +109,15 @@ var _ = Describe("Patroni end-to-end Tests", func() {
 					{LogErrorVerbosity, defaultLogErrorVerbosity},
 					{SSLCiphers, defaultSSLCiphers},
 					{SSLMinProtocolVersion, defaultSSLMinProtocolVersion},
-					{WALWriterDelay, strconv.Itoa(defaultWalWriterDelayMillis) + "ms"},
+					{WALWriterDelay, defaultWalWriterDelay},
 					{SynchronousCommit, defaultSynchronousCommit},
-					{MaxConnections, strconv.Itoa(defaultMaxConnections)},
+					{MaxConnections, defaultMaxConnections},
 					// SharedBuffers is not being set or updated.
 					// https://github.com/anynines/postgresql-operator/issues/75
 					// {SharedBuffers, defaultSharedBuffers},
-					{MaxReplicationSlots, strconv.Itoa(defaultMaxReplicationSlots)},
-					{MaxWALSenders, strconv.Itoa(defaultMaxWALSenders)},
-					{MaxLocksPerTransaction, strconv.Itoa(defaultMaxLocksPerTransaction)},
+					{MaxReplicationSlots, defaultMaxReplicationSlots},
+					{MaxWALSenders, defaultMaxWALSenders},
+					{MaxLocksPerTransaction, defaultMaxLocksPerTransaction},
 				}
 
 				for _, setting := range expectedConfig {
@@ -175,78 +134,36 @@ var _ = Describe("Patroni end-to-end Tests", func() {
 
 		It("Sets configuration when creating an instance with an explicit custom PostgreSQL configuration", func() {
 			By("applying custom configuration to PostgreSQL resource", func() {
-				instance, err = dsi.New(
-					dataservice,
+				pg = postgresql.New(
 					testingNamespace,
 					framework.GenerateName(instanceNamePrefix,
 						GinkgoParallelProcess(),
 						suffixLength),
 					replicas,
+					postgresql.WithParameters(customParameters(v1beta3.PostgresqlParameters{})),
 				)
-				Expect(err).To(BeNil(), "failed to generate new DSI resource")
-
-				// Cast interface to concrete struct so that we can access fields
-				// directly
-				pg, ok = instance.GetClientObject().(*v1beta3.Postgresql)
-				Expect(ok).To(BeTrue(),
-					"failed to cast object interface to PostgreSQL struct")
-
-				setCustomPostgresConfig(pg)
+				instance = pg
 			})
 
 			By("creating a PostgreSQL instance with custom configuration", func() {
-				Expect(k8sClient.Create(ctx, pg)).
-					To(Succeed(), fmt.Sprintf("failed to create instance %s/%s",
-						instance.GetNamespace(), instance.GetName()))
-				dsi.WaitForReadiness(ctx, instance.GetClientObject(), k8sClient)
-
-				// Portforward to access instance from outside cluster.
-				portForwardStopCh, localPort, err = framework.PortForward(
-					ctx, instancePort, kubeconfigPath, instance, k8sClient)
-				Expect(err).To(BeNil(),
-					fmt.Sprintf("failed to establish portforward to DSI %s/%s",
-						instance.GetNamespace(), instance.GetName()))
-
-				// Fetch admin secret for privileged DSIClient. We need a
-				// privileged client since some parameters such as SSLCiphers can
-				// not be fetched by service binding users.
-				adminSecretData, err = secret.AdminSecretData(
-					ctx, k8sClient, instance.GetName(), instance.GetNamespace())
-				Expect(err).To(BeNil(),
-					fmt.Sprintf("failed to parse secret data of admin credentials for %s/%s",
-						instance.GetNamespace(), instance.GetName()))
-
-				// Create client for interacting with the new instance.
-				client, err = dsi.NewClient(
-					dataservice, strconv.Itoa(localPort), adminSecretData)
-				Expect(err).To(BeNil(), "failed to create new dsi client")
+				testDSI = fixture.NewDSI(ctx, fixture.Options{
+					Client:      k8sClient,
+					RESTConfig:  restConfig,
+					DataService: dataservice,
+					Namespace:   testingNamespace,
+					NamePrefix:  instanceNamePrefix,
+					Replicas:    replicas,
+					Port:        instancePort,
+					Instance:    instance,
+					// We need a privileged client since some config parameters such as
+					// SSLCiphers can not be fetched by service binding users.
+					WithoutServiceBinding: true,
+				})
+				instance, client = testDSI.Instance, testDSI.Client
 			})
 
 			By("checking that the custom configuration is set correctly", func() {
-				expectedConfig := []struct {
-					parameter, value string
-				}{
-					{ArchiveTimeout, strconv.Itoa(pg.Spec.Parameters.ArchiveTimeoutSeconds) + "s"},
-					{TempFileLimit, strconv.Itoa(pg.Spec.Parameters.TempFileLimitKiloBytes)},
-					{TrackIOTiming, pg.Spec.Parameters.TrackIOTiming},
-					{StatementTimeout, strconv.Itoa(pg.Spec.Parameters.StatementTimeoutMillis) + "ms"},
-					{ClientMinMessages, pg.Spec.Parameters.ClientMinMessages},
-					{LogMinMessages, pg.Spec.Parameters.LogMinMessages},
-					{LogMinErrorStatement, pg.Spec.Parameters.LogMinErrorStatement},
-					{LogStatement, pg.Spec.Parameters.LogStatement},
-					{LogErrorVerbosity, strings.ToLower(pg.Spec.Parameters.LogErrorVerbosity)},
-					{SSLCiphers, pg.Spec.Parameters.SSLCiphers},
-					{SSLMinProtocolVersion, pg.Spec.Parameters.SSLMinProtocolVersion},
-					{WALWriterDelay, strconv.Itoa(pg.Spec.Parameters.WALWriterDelayMillis) + "ms"},
-					{SynchronousCommit, pg.Spec.Parameters.SynchronousCommit},
-					{MaxConnections, strconv.Itoa(pg.Spec.Parameters.MaxConnections)},
-					// SharedBuffers is not being set or updated.
-					// https://github.com/anynines/postgresql-operator/issues/75
-					// {SharedBuffers, "200MB"}, // 2024 is converted to 200MB
-					{MaxReplicationSlots, strconv.Itoa(pg.Spec.Parameters.MaxReplicationSlots)},
-					{MaxWALSenders, strconv.Itoa(pg.Spec.Parameters.MaxWALSenders)},
-					{MaxLocksPerTransaction, strconv.Itoa(pg.Spec.Parameters.MaxLocksPerTransaction)},
-				}
+				expectedConfig := expectedParameters(pg.Spec.Parameters)
 
 				for _, setting := range expectedConfig {
 					Expect(client.CheckParameter(
@@ -262,41 +179,19 @@ var _ = Describe("Patroni end-to-end Tests", func() {
 
 		It("Custom configuration can be updated on a running PostgreSQL instance", func() {
 			By("creating a PostgreSQL instance with implicit defaults", func() {
-				instance, err = dsi.New(
-					dataservice,
-					testingNamespace,
-					framework.GenerateName(instanceNamePrefix,
-						GinkgoParallelProcess(),
-						suffixLength),
-					replicas,
-				)
-				Expect(err).To(BeNil(), "failed to generate new DSI resource")
-
-				Expect(k8sClient.Create(ctx, instance.GetClientObject())).
-					To(Succeed(), fmt.Sprintf("failed to create instance %s/%s",
-						instance.GetNamespace(), instance.GetName()))
-				dsi.WaitForReadiness(ctx, instance.GetClientObject(), k8sClient)
-
-				// Portforward to access instance from outside cluster.
-				portForwardStopCh, localPort, err = framework.PortForward(
-					ctx, instancePort, kubeconfigPath, instance, k8sClient)
-				Expect(err).To(BeNil(),
-					fmt.Sprintf("failed to establish portforward to DSI %s/%s",
-						instance.GetNamespace(), instance.GetName()))
-
-				// Fetch admin secret for privileged DSIClient. We need a
-				// privileged client since some parameters such as SSLCiphers can
-				// not be fetched by service binding users.
-				adminSecretData, err = secret.AdminSecretData(
-					ctx, k8sClient, instance.GetName(), instance.GetNamespace())
-				Expect(err).To(BeNil(),
-					fmt.Sprintf("failed to parse secret data of admin credentials for %s/%s",
-						instance.GetNamespace(), instance.GetName()))
-
-				// Create client for interacting with the new instance.
-				client, err = dsi.NewClient(
-					dataservice, strconv.Itoa(localPort), adminSecretData)
-				Expect(err).To(BeNil(), "failed to create new dsi client")
+				testDSI = fixture.NewDSI(ctx, fixture.Options{
+					Client:      k8sClient,
+					RESTConfig:  restConfig,
+					DataService: dataservice,
+					Namespace:   testingNamespace,
+					NamePrefix:  instanceNamePrefix,
+					Replicas:    replicas,
+					Port:        instancePort,
+					// We need a privileged client since some config parameters such as
+					// SSLCiphers can not be fetched by service binding users.
+					WithoutServiceBinding: true,
+				})
+				instance, client = testDSI.Instance, testDSI.Client
 			})
 
 			By("setting custom parameters of the retrieved PostgreSQL object", func() {
@@ -309,15 +204,12 @@ var _ = Describe("Patroni end-to-end Tests", func() {
 					To(Succeed(), fmt.Sprintf("failed to get instance %s/%s",
 						instance.GetNamespace(), instance.GetName()))
 
-				pg, ok = newInstance.GetClientObject().(*v1beta3.Postgresql)
-				Expect(ok).To(BeTrue(),
-					"failed to cast object interface to PostgreSQL struct")
-
-				setCustomPostgresConfig(pg)
+				pg = &newInstance
+				pg.Spec.Parameters = customParameters(pg.Spec.Parameters)
 			})
 
 			By("updating the live PostgreSQL instance with custom configuration", func() {
-				Expect(k8sClient.Update(ctx, pg)).
+				Expect(k8sClient.Update(ctx, pg.GetClientObject())).
 					To(Succeed(), fmt.Sprintf("failed to update instance %s/%s",
 						instance.GetNamespace(), instance.GetName()))
 			})
@@ -332,86 +224,62 @@ var _ = Describe("Patroni end-to-end Tests", func() {
 			// possibility of flaky tests.
 			By("ensuring the PostgreSQL process has restarted", func() {
 				Eventually(func() error {
-					portForwardStopCh, localPort, err = framework.PortForward(
-						ctx, instancePort, kubeconfigPath, instance, k8sClient)
-					Expect(err).To(BeNil(),
-						fmt.Sprintf("failed to establish portforward to DSI %s/%s",
-							instance.GetNamespace(), instance.GetName()))
-
-					// Create client for interacting with the new instance.
-					client, err = dsi.NewClient(
-						dataservice, strconv.Itoa(localPort), adminSecretData)
-					Expect(err).To(BeNil(), "failed to create new dsi client")
+					if err := testDSI.Reconnect(ctx); err != nil {
+						return err
+					}
+					client = testDSI.Client
 
 					// This check is simply a probe to ensure that the PostgreSQL
 					// process has restarted. We still explicitly check this
 					// parameter in the table driven tests below for the sake
 					// of verbosity.
-					probeErr := client.CheckParameter(ctx, ArchiveTimeout,
-						strconv.Itoa(pg.Spec.Parameters.ArchiveTimeoutSeconds)+"s")
-
-					if probeErr != nil {
-						close(portForwardStopCh)
-						return probeErr
-					}
-					return probeErr
-				}, framework.AsyncOpsTimeoutMins, 1*time.Second).Should(Succeed(),
+					return client.CheckParameter(ctx, ArchiveTimeout,
+						seconds(pg.Spec.Parameters.ArchiveTimeoutSeconds))
+				}, framework.AsyncOpsTimeout(), framework.PollingPeriod()).Should(Succeed(),
 					fmt.Sprintf("unable to wait for PostgreSQL process restart for %s/%s",
 						instance.GetNamespace(), instance.GetName()))
 			})
 
+			By("checking via the REST API of Patroni that no restart is pending", func() {
+				pod, err := framework.GetPrimaryPodUsingServiceSelector(ctx, instance, k8sClient)
+				Expect(err).To(BeNil(), fmt.Sprintf("failed to get primary pod of %s/%s",
+					instance.GetNamespace(), instance.GetName()))
+				patroniClient, stopCh, err := patroni.PortForward(ctx, restConfig, pod)
+				Expect(err).To(BeNil(), fmt.Sprintf("failed to port forward to Patroni of %s/%s",
+					instance.GetNamespace(), instance.GetName()))
+				defer close(stopCh)
+
+				Eventually(func() (patroni.Cluster, error) {
+					return patroniClient.Cluster(ctx)
+				}, framework.AsyncOpsTimeout(), framework.PollingPeriod()).Should(And(
+					HaveField("Members", HaveLen(replicas)),
+					HaveField("Members", HaveEach(And(
+						HaveField("State", BeElementOf(patroni.StateRunning,
+							patroni.StateStreaming)),
+						HaveField("PendingRestart", BeFalse()),
+					))),
+				), fmt.Sprintf("Patroni of %s/%s still has members with pending restarts",
+					instance.GetNamespace(), instance.GetName()))
+			})
+
 			By("checking that the custom config is set correctly", func() {
-				expectedConfig := []struct {
-					parameter, value string
-				}{
-					{ArchiveTimeout, strconv.Itoa(pg.Spec.Parameters.ArchiveTimeoutSeconds) + "s"},
-					{TempFileLimit, strconv.Itoa(pg.Spec.Parameters.TempFileLimitKiloBytes)},
-					{TrackIOTiming, pg.Spec.Parameters.TrackIOTiming},
-					{StatementTimeout, strconv.Itoa(pg.Spec.Parameters.StatementTimeoutMillis) + "ms"},
-					{ClientMinMessages, pg.Spec.Parameters.ClientMinMessages},
-					{LogMinMessages, pg.Spec.Parameters.LogMinMessages},
-					{LogMinErrorStatement, pg.Spec.Parameters.LogMinErrorStatement},
-					{LogStatement, pg.Spec.Parameters.LogStatement},
-					{LogErrorVerbosity, strings.ToLower(pg.Spec.Parameters.LogErrorVerbosity)},
-					{SSLCiphers, pg.Spec.Parameters.SSLCiphers},
-					{SSLMinProtocolVersion, pg.Spec.Parameters.SSLMinProtocolVersion},
-					{WALWriterDelay, strconv.Itoa(pg.Spec.Parameters.WALWriterDelayMillis) + "ms"},
-					{SynchronousCommit, pg.Spec.Parameters.SynchronousCommit},
-					{MaxConnections, strconv.Itoa(pg.Spec.Parameters.MaxConnections)},
-					// SharedBuffers is not being set or updated.
-					// https://github.com/anynines/postgresql-operator/issues/75
-					// {SharedBuffers, "200MB"}, // 2024 is converted to 200MB
-					{MaxReplicationSlots, strconv.Itoa(pg.Spec.Parameters.MaxReplicationSlots)},
-					{MaxWALSenders, strconv.Itoa(pg.Spec.Parameters.MaxWALSenders)},
-					{MaxLocksPerTransaction, strconv.Itoa(pg.Spec.Parameters.MaxLocksPerTransaction)},
-				}
+				expectedConfig := expectedParameters(pg.Spec.Parameters)
 
 				for _, setting := range expectedConfig {
 					// Eventually is used to avoid failing when PostgreSQL is
 					// still in the process of restarting due to parameters that
 					// require restart.
 					Eventually(func() error {
-						portForwardStopCh, localPort, err = framework.PortForward(
-							ctx, instancePort, kubeconfigPath, instance, k8sClient)
-						Expect(err).To(BeNil(),
-							fmt.Sprintf("failed to establish portforward to DSI %s/%s",
-								instance.GetNamespace(), instance.GetName()))
-
-						// Create client for interacting with the new instance.
-						client, err = dsi.NewClient(
-							dataservice, strconv.Itoa(localPort), adminSecretData)
-						Expect(err).To(BeNil(), "failed to create new dsi client")
-
-						probeErr := client.CheckParameter(
+						if err := testDSI.Reconnect(ctx); err != nil {
+							return err
+						}
+						client = testDSI.Client
+
+						return client.CheckParameter(
 							ctx,
 							setting.parameter,
 							setting.value)
-						if probeErr != nil {
-							close(portForwardStopCh)
-							return probeErr
-						}
-						return probeErr
-					}, framework.AsyncOpsTimeoutMins, 1*time.Second).Should(Succeed(),
+					}, framework.AsyncOpsTimeout(), framework.PollingPeriod()).Should(Succeed(),
 						fmt.Sprintf("unable to check custom config is set correctly on update for %s/%s",
 							instance.GetNamespace(), instance.GetName()))
 				}
@@ -433,7 +301,7 @@ var _ = Describe("Patroni end-to-end Tests", func() {
 						"failed to list events emitted for the config update of the DSI")
 
 					return len(events.Items) > 0
-				}, framework.AsyncOpsTimeoutMins, 1*time.Second).Should(BeTrue(),
+				}, framework.AsyncOpsTimeout(), framework.PollingPeriod()).Should(BeTrue(),
 					fmt.Sprintf("failed to list events emitted for the config update of %s/%s",
 						instance.GetNamespace(), instance.GetName()))
 
@@ -459,31 +327,70 @@ var _ = Describe("Patroni end-to-end Tests", func() {
 	})
 })
 
-func setCustomPostgresConfig(pg *v1beta3.Postgresql) {
+// parameterValue is the value that a parameter of PostgreSQL is expected to have.
+type parameterValue struct {
+	parameter, value string
+}
+
+// expectedParameters returns the values that PostgreSQL is expected to have when configured with
+// parameters. Values with a unit are given in the unit of the corresponding field of parameters.
+func expectedParameters(parameters v1beta3.PostgresqlParameters) []parameterValue {
+	return []parameterValue{
+		{ArchiveTimeout, seconds(parameters.ArchiveTimeoutSeconds)},
+		{TempFileLimit, strconv.Itoa(parameters.TempFileLimitKiloBytes) + "kB"},
+		{TrackIOTiming, parameters.TrackIOTiming},
+		{StatementTimeout, strconv.Itoa(parameters.StatementTimeoutMillis) + "ms"},
+		{ClientMinMessages, parameters.ClientMinMessages},
+		{LogMinMessages, parameters.LogMinMessages},
+		{LogMinErrorStatement, parameters.LogMinErrorStatement},
+		{LogStatement, parameters.LogStatement},
+		{LogErrorVerbosity, parameters.LogErrorVerbosity},
+		{SSLCiphers, parameters.SSLCiphers},
+		{SSLMinProtocolVersion, parameters.SSLMinProtocolVersion},
+		{WALWriterDelay, strconv.Itoa(parameters.WALWriterDelayMillis) + "ms"},
+		{SynchronousCommit, parameters.SynchronousCommit},
+		{MaxConnections, strconv.Itoa(parameters.MaxConnections)},
+		// SharedBuffers is not being set or updated.
+		// https://github.com/anynines/postgresql-operator/issues/75
+		// {SharedBuffers, strconv.Itoa(parameters.SharedBuffers) + "MB"},
+		{MaxReplicationSlots, strconv.Itoa(parameters.MaxReplicationSlots)},
+		{MaxWALSenders, strconv.Itoa(parameters.MaxWALSenders)},
+		{MaxLocksPerTransaction, strconv.Itoa(parameters.MaxLocksPerTransaction)},
+	}
+}
+
+func seconds(s int) string {
+	return strconv.Itoa(s) + "s"
+}
+
+// customParameters returns parameters with the custom values that the tests set and expect.
+func customParameters(parameters v1beta3.PostgresqlParameters) v1beta3.PostgresqlParameters {
 	maxLocksPerTransaction := 120
 
-	pg.Spec.Parameters.MaxConnections = 101
-	pg.Spec.Parameters.MaxLocksPerTransaction = maxLocksPerTransaction
+	parameters.MaxConnections = 101
+	parameters.MaxLocksPerTransaction = maxLocksPerTransaction
 	// SharedBuffers is not being set or updated.
 	// https://github.com/anynines/postgresql-operator/issues/75
-	pg.Spec.Parameters.SharedBuffers = 200
-	pg.Spec.Parameters.MaxReplicationSlots = 11
-	pg.Spec.Parameters.MaxWALSenders = 11
-	pg.Spec.Parameters.StatementTimeoutMillis = 2147483647
+	parameters.SharedBuffers = 200
+	parameters.MaxReplicationSlots = 11
+	parameters.MaxWALSenders = 11
+	parameters.StatementTimeoutMillis = 2147483647
 	// There is a list of SSL cipher suites that are allowed to be used by SSL connections
 	// https://www.postgresql.org/docs/14/runtime-config-connection.html#GUC-SSL-CIPHERS
 	// non-allowed values cause validation errors
 	// https://github.com/postgres/postgres/blob/REL_14_STABLE/src/backend/libpq/be-secure-openssl.c#L270
-	pg.Spec.Parameters.SSLCiphers = "!aNULL:HIGH"
-	pg.Spec.Parameters.SSLMinProtocolVersion = "TLSv1.2"
-	pg.Spec.Parameters.TempFileLimitKiloBytes = 0
-	pg.Spec.Parameters.WALWriterDelayMillis = 201
-	pg.Spec.Parameters.SynchronousCommit = "off"
-	pg.Spec.Parameters.TrackIOTiming = "on"
-	pg.Spec.Parameters.ArchiveTimeoutSeconds = 10
-	pg.Spec.Parameters.ClientMinMessages = "warning"
-	pg.Spec.Parameters.LogMinMessages = "notice"
-	pg.Spec.Parameters.LogMinErrorStatement = "warning"
-	pg.Spec.Parameters.LogStatement = "all"
-	pg.Spec.Parameters.LogErrorVerbosity = "DEFAULT"
+	parameters.SSLCiphers = "!aNULL:HIGH"
+	parameters.SSLMinProtocolVersion = "TLSv1.2"
+	parameters.TempFileLimitKiloBytes = 0
+	parameters.WALWriterDelayMillis = 201
+	parameters.SynchronousCommit = "off"
+	parameters.TrackIOTiming = "on"
+	parameters.ArchiveTimeoutSeconds = 10
+	parameters.ClientMinMessages = "warning"
+	parameters.LogMinMessages = "notice"
+	parameters.LogMinErrorStatement = "warning"
+	parameters.LogStatement = "all"
+	parameters.LogErrorVerbosity = "DEFAULT"
+
+	return parameters
 }
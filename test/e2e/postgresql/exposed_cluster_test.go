@@ -9,10 +9,9 @@ import (
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
 
-	pgv1beta3 "github.com/anynines/postgresql-operator/api/v1beta3"
-
 	"github.com/anynines/a8s-deployment/test/framework"
 	"github.com/anynines/a8s-deployment/test/framework/dsi"
+	"github.com/anynines/a8s-deployment/test/framework/postgresql"
 	"github.com/anynines/a8s-deployment/test/framework/secret"
 	"github.com/anynines/a8s-deployment/test/framework/servicebinding"
 )
@@ -30,26 +29,15 @@ var _ = Describe("end-to-end tests for exposed instances", Label("ExternalLoadba
 
 		It("Provisions the exposed PostgreSQL instance", func() {
 			By("Accepting instance creation")
-			instance, err = dsi.New(
-				dataservice,
+			pg = postgresql.New(
 				testingNamespace,
 				framework.GenerateName(instanceNamePrefix,
 					GinkgoParallelProcess(), suffixLength),
 				3,
+				postgresql.WithExpose("LoadBalancer"),
+				postgresql.WithReadOnlyService("replicas"),
 			)
-			Expect(err).To(BeNil(), "failed to generate new DSI resource")
-
-			// Cast interface to concrete struct so that we can access fields
-			// directly
-			pg, ok = instance.GetClientObject().(*pgv1beta3.Postgresql)
-			Expect(ok).To(BeTrue(),
-				"failed to cast object interface to PostgreSQL struct")
-
-			// We break the framework abstraction here to access a feature
-			// that is available in the pg data service. If we make access from outside the cluster a
-			// feature throughout the framework, we should adjust our testing framework instead
-			pg.Spec.Expose = "LoadBalancer"
-			pg.Spec.EnableReadOnlyService = true
+			instance = pg
 
 			Expect(k8sClient.Create(ctx, instance.GetClientObject())).
 				To(Succeed(), fmt.Sprintf("failed to create instance %s/%s",
@@ -92,7 +80,7 @@ var _ = Describe("end-to-end tests for exposed instances", Label("ExternalLoadba
 				},
 					&connInfo,
 				)
-			}, asyncOpsTimeoutMins, 1*time.Second).Should(Succeed())
+			}, framework.AsyncOpsTimeout(), framework.PollingPeriod()).Should(Succeed())
 
 			Expect(connInfo.Data).To(HaveKey("primary"),
 				"connInfo does not contain information about primary database")
@@ -160,5 +148,19 @@ var _ = Describe("end-to-end tests for exposed instances", Label("ExternalLoadba
 				g.Expect(entityData).To(Equal(testInput), "data service returned unexpected entry")
 			}, 5*time.Minute).Should(Succeed())
 		})
+
+		It("routes connections via the read-only service only to replicas", func() {
+			roClient := postgresql.NewClient(serviceBindingData, connInfo.Data["readOnly"],
+				connInfo.Data["port"], SSLModeRequired)
+
+			By("spreading connections across the replicas")
+			routing, err := postgresql.MeasureReadOnlyRouting(ctx, roClient, 30)
+			Expect(err).To(BeNil(), "failed to connect via the read-only service")
+			Expect(routing.CheckReplicasOnly(2)).To(Succeed())
+			GinkgoWriter.Printf("read-only service routing: %s\n", routing)
+
+			By("rejecting writes via the read-only service")
+			Expect(roClient.Write(ctx, entity, testInput)).To(MatchError(dsi.ErrReadOnly))
+		})
 	})
 })
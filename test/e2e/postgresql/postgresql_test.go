@@ -2,9 +2,12 @@ package postgresql
 
 import (
 	"fmt"
+	"io"
 	"log"
+	"net/http"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -12,7 +15,6 @@ import (
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
-	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	k8sresource "k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/types"
@@ -20,10 +22,15 @@ import (
 	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/anynines/a8s-deployment/test/framework"
+	"github.com/anynines/a8s-deployment/test/framework/dataset"
 	"github.com/anynines/a8s-deployment/test/framework/dsi"
+	"github.com/anynines/a8s-deployment/test/framework/fixture"
+	"github.com/anynines/a8s-deployment/test/framework/patroni"
 	"github.com/anynines/a8s-deployment/test/framework/postgresql"
 	"github.com/anynines/a8s-deployment/test/framework/secret"
 	"github.com/anynines/a8s-deployment/test/framework/servicebinding"
+	"github.com/anynines/a8s-deployment/test/framework/timeline"
+	"github.com/anynines/a8s-deployment/test/framework/workload"
 	sbv1beta3 "github.com/anynines/a8s-service-binding-controller/api/v1beta3"
 	"github.com/anynines/postgresql-operator/api/v1beta3"
 	pgv1beta3 "github.com/anynines/postgresql-operator/api/v1beta3"
@@ -45,20 +52,24 @@ const (
 	testInput = "test_input"
 	// entity is a generic term to describe where data services store their data.
 	entity = "test_entity"
-	// asyncOpsTimeoutMins...
-	asyncOpsTimeoutMins = time.Minute * 5
+
+	// maxTimeWithoutPrimary is how long a fail over may leave an instance without a ready
+	// primary.
+	maxTimeWithoutPrimary = 60 * time.Second
+	// maxFailoverRTO is how long a fail over may keep a workload from writing to an instance. It
+	// exceeds maxTimeWithoutPrimary by the time the workload takes to reconnect to the new primary.
+	maxFailoverRTO = 75 * time.Second
 )
 
 var (
 	// portForwardStopCh is the channel used to manage the lifecycle of a port forward.
 	portForwardStopCh chan struct{}
 	localPort         int
-	ok                bool
 
 	sb       *sbv1beta3.ServiceBinding
 	instance dsi.Object
 	client   dsi.DSIClient
-	pg       *pgv1beta3.Postgresql
+	pg       *postgresql.Postgresql
 )
 
 var _ = Describe("PostgreSQL Operator end-to-end tests", func() {
@@ -70,20 +81,13 @@ var _ = Describe("PostgreSQL Operator end-to-end tests", func() {
 
 		It("Provisions the PostgreSQL instance", func() {
 			By("creating a dataservice instance", func() {
-				instance, err = dsi.New(
-					dataservice,
+				pg = postgresql.New(
 					testingNamespace,
 					framework.GenerateName(instanceNamePrefix,
 						GinkgoParallelProcess(), suffixLength),
 					replicas,
 				)
-				Expect(err).To(BeNil(), "failed to generate new DSI resource")
-
-				// Cast interface to concrete struct so that we can access fields
-				// directly
-				pg, ok = instance.GetClientObject().(*pgv1beta3.Postgresql)
-				Expect(ok).To(BeTrue(),
-					"failed to cast object interface to PostgreSQL struct")
+				instance = pg
 
 				Expect(k8sClient.Create(ctx, instance.GetClientObject())).
 					To(Succeed(), fmt.Sprintf("failed to create instance %s/%s",
@@ -540,7 +544,7 @@ var _ = Describe("PostgreSQL Operator end-to-end tests", func() {
 					},
 				}
 				g.Expect(k8sClient.Update(ctx, &old)).To(Succeed())
-			}, asyncOpsTimeoutMins, 1*time.Second).Should(Succeed())
+			}, framework.AsyncOpsTimeout(), framework.PollingPeriod()).Should(Succeed())
 
 			Eventually(func() *corev1.ResourceRequirements {
 				sts := &appsv1.StatefulSet{}
@@ -556,7 +560,7 @@ var _ = Describe("PostgreSQL Operator end-to-end tests", func() {
 					return nil
 				}
 				return &sts.Spec.Template.Spec.Containers[0].Resources
-			}, asyncOpsTimeoutMins, 1*time.Second).Should(Equal(old.Spec.Resources))
+			}, framework.AsyncOpsTimeout(), framework.PollingPeriod()).Should(Equal(old.Spec.Resources))
 		})
 
 		It("Updates replicas", func() {
@@ -572,7 +576,7 @@ var _ = Describe("PostgreSQL Operator end-to-end tests", func() {
 
 				old.Spec.Replicas = pointer.Int32(3)
 				g.Expect(k8sClient.Update(ctx, &old)).To(Succeed())
-			}, asyncOpsTimeoutMins, 1*time.Second).Should(Succeed())
+			}, framework.AsyncOpsTimeout(), framework.PollingPeriod()).Should(Succeed())
 
 			Eventually(func() *int32 {
 				sts := &appsv1.StatefulSet{}
@@ -588,7 +592,7 @@ var _ = Describe("PostgreSQL Operator end-to-end tests", func() {
 					return nil
 				}
 				return sts.Spec.Replicas
-			}, asyncOpsTimeoutMins, 1*time.Second).Should(Equal(pointer.Int32(3)))
+			}, framework.AsyncOpsTimeout(), framework.PollingPeriod()).Should(Equal(pointer.Int32(3)))
 		})
 
 		It("Updates labels", func() {
@@ -612,7 +616,7 @@ var _ = Describe("PostgreSQL Operator end-to-end tests", func() {
 				}
 
 				g.Expect(k8sClient.Update(ctx, &currDSI)).To(Succeed())
-			}, asyncOpsTimeoutMins, 1*time.Second).Should(Succeed())
+			}, framework.AsyncOpsTimeout(), framework.PollingPeriod()).Should(Succeed())
 
 			By("Ensuring StatefulSet labels are updated", func() {
 				Eventually(func(g Gomega) {
@@ -654,7 +658,7 @@ var _ = Describe("PostgreSQL Operator end-to-end tests", func() {
 							g.Expect(len(sts.Spec.Selector.MatchLabels)).To(Equal(numA8SLabels))
 						},
 					)
-				}, asyncOpsTimeoutMins, 1*time.Second).Should(Succeed())
+				}, framework.AsyncOpsTimeout(), framework.PollingPeriod()).Should(Succeed())
 			})
 
 			By("Ensuring master service labels are updated", func() {
@@ -685,7 +689,7 @@ var _ = Describe("PostgreSQL Operator end-to-end tests", func() {
 						To(HaveKeyWithValue("a8s.a9s/dsi-group", "postgresql.anynines.com"))
 					g.Expect(svc.Spec.Selector).To(HaveKeyWithValue("a8s.a9s/dsi-kind", "Postgresql"))
 					g.Expect(len(svc.Spec.Selector)).To(Equal(numA8SLabels + 1))
-				}, asyncOpsTimeoutMins, 1*time.Second).Should(Succeed())
+				}, framework.AsyncOpsTimeout(), framework.PollingPeriod()).Should(Succeed())
 			})
 
 			By("Ensuring patroni service labels are updated", func() {
@@ -715,7 +719,7 @@ var _ = Describe("PostgreSQL Operator end-to-end tests", func() {
 						To(HaveKeyWithValue("a8s.a9s/dsi-group", "postgresql.anynines.com"))
 					g.Expect(svc.Spec.Selector).To(HaveKeyWithValue("a8s.a9s/dsi-kind", "Postgresql"))
 					g.Expect(len(svc.Spec.Selector)).To(Equal(numA8SLabels + 1))
-				}, asyncOpsTimeoutMins, 1*time.Second).Should(Succeed())
+				}, framework.AsyncOpsTimeout(), framework.PollingPeriod()).Should(Succeed())
 			})
 
 			By("Ensuring ServiceAccount labels are updated", func() {
@@ -737,7 +741,7 @@ var _ = Describe("PostgreSQL Operator end-to-end tests", func() {
 						To(HaveKeyWithValue("a8s.a9s/dsi-group", "postgresql.anynines.com"))
 					g.Expect(sa.Labels).To(HaveKeyWithValue("a8s.a9s/dsi-kind", "Postgresql"))
 					g.Expect(len(sa.Labels)).To(Equal(numA8SLabels + 2))
-				}, asyncOpsTimeoutMins, 1*time.Second).Should(Succeed())
+				}, framework.AsyncOpsTimeout(), framework.PollingPeriod()).Should(Succeed())
 			})
 
 			By("Ensuring RoleBinding labels are updated", func() {
@@ -759,7 +763,7 @@ var _ = Describe("PostgreSQL Operator end-to-end tests", func() {
 						To(HaveKeyWithValue("a8s.a9s/dsi-group", "postgresql.anynines.com"))
 					g.Expect(rb.Labels).To(HaveKeyWithValue("a8s.a9s/dsi-kind", "Postgresql"))
 					g.Expect(len(rb.Labels)).To(Equal(numA8SLabels + 2))
-				}, asyncOpsTimeoutMins, 1*time.Second).Should(Succeed())
+				}, framework.AsyncOpsTimeout(), framework.PollingPeriod()).Should(Succeed())
 			})
 
 			By("Ensuring admin user secret labels are updated", func() {
@@ -781,7 +785,7 @@ var _ = Describe("PostgreSQL Operator end-to-end tests", func() {
 						To(HaveKeyWithValue("a8s.a9s/dsi-group", "postgresql.anynines.com"))
 					g.Expect(adminSecret.Labels).To(HaveKeyWithValue("a8s.a9s/dsi-kind", "Postgresql"))
 					g.Expect(len(adminSecret.Labels)).To(Equal(numA8SLabels + 2))
-				}, asyncOpsTimeoutMins, 1*time.Second).Should(Succeed())
+				}, framework.AsyncOpsTimeout(), framework.PollingPeriod()).Should(Succeed())
 			})
 
 			By("Ensuring standby user secret labels are updated", func() {
@@ -804,7 +808,7 @@ var _ = Describe("PostgreSQL Operator end-to-end tests", func() {
 					g.Expect(standbySecret.Labels).
 						To(HaveKeyWithValue("a8s.a9s/dsi-kind", "Postgresql"))
 					g.Expect(len(standbySecret.Labels)).To(Equal(numA8SLabels + 2))
-				}, asyncOpsTimeoutMins, 1*time.Second).Should(Succeed())
+				}, framework.AsyncOpsTimeout(), framework.PollingPeriod()).Should(Succeed())
 			})
 		})
 	})
@@ -812,18 +816,13 @@ var _ = Describe("PostgreSQL Operator end-to-end tests", func() {
 	Context("PostgreSQL Instance deletion", func() {
 		BeforeEach(func() {
 			// Create Dataservice instance and wait for instance readiness
-			instance, err = dsi.New(
-				dataservice,
+			pg = postgresql.New(
 				testingNamespace,
 				framework.GenerateName(
 					instanceNamePrefix, GinkgoParallelProcess(), suffixLength),
 				replicas,
 			)
-			Expect(err).To(BeNil(), "failed to generate new DSI resource")
-
-			pg, ok = instance.GetClientObject().(*pgv1beta3.Postgresql)
-			Expect(ok).To(BeTrue(),
-				"failed to cast instance object interface to PostgreSQL struct")
+			instance = pg
 
 			Expect(k8sClient.Create(ctx, instance.GetClientObject())).
 				To(Succeed(), fmt.Sprintf("failed to create instance %s/%s",
@@ -838,136 +837,9 @@ var _ = Describe("PostgreSQL Operator end-to-end tests", func() {
 				dsi.WaitForDeletion(ctx, instance.GetClientObject(), k8sClient)
 			})
 
-			By("removing the StatefulSet", func() {
-				Eventually(func() bool {
-					sts := &appsv1.StatefulSet{}
-					err := k8sClient.Get(ctx,
-						types.NamespacedName{
-							Name:      instance.GetName(),
-							Namespace: instance.GetNamespace(),
-						},
-						sts)
-					return err != nil && k8serrors.IsNotFound(err)
-				}, asyncOpsTimeoutMins).Should(BeTrue())
-			})
-
-			By("removing the service that points to the primary for writes", func() {
-				Eventually(func() bool {
-					err := k8sClient.Get(ctx,
-						types.NamespacedName{
-							Name:      postgresql.MasterService(instance.GetName()),
-							Namespace: instance.GetNamespace(),
-						},
-						&corev1.Service{})
-					return err != nil && k8serrors.IsNotFound(err)
-				}, asyncOpsTimeoutMins).Should(BeTrue())
-			})
-
-			By("removing the service that points to the patroni API", func() {
-				Eventually(func() bool {
-					err := k8sClient.Get(ctx,
-						types.NamespacedName{
-							Name:      postgresql.PatroniService(instance.GetName()),
-							Namespace: instance.GetNamespace(),
-						},
-						&corev1.Service{})
-					return err != nil && k8serrors.IsNotFound(err)
-				}, asyncOpsTimeoutMins).Should(BeTrue())
-			})
-
-			By("removing the RoleBinding between the PostgreSQL instance service account and the Spilo role", func() {
-				Eventually(func() bool {
-					err := k8sClient.Get(
-						ctx,
-						types.NamespacedName{
-							Name:      instance.GetName(),
-							Namespace: instance.GetNamespace(),
-						},
-						&rbacv1.RoleBinding{},
-					)
-					return err != nil && k8serrors.IsNotFound(err)
-				}, asyncOpsTimeoutMins).Should(BeTrue())
-			})
-
-			By("removing the ServiceAccount", func() {
-				Eventually(func() bool {
-					err := k8sClient.Get(
-						ctx,
-						types.NamespacedName{
-							Name:      instance.GetName(),
-							Namespace: instance.GetNamespace(),
-						},
-						&corev1.ServiceAccount{})
-					return err != nil && k8serrors.IsNotFound(err)
-				}, asyncOpsTimeoutMins).Should(BeTrue())
-			})
-
-			By("removing the Secret with the credentials of the admin role", func() {
-				Eventually(func() bool {
-					err := k8sClient.Get(
-						ctx,
-						types.NamespacedName{
-							Name: postgresql.AdminRoleSecretName(
-								instance.GetName()),
-							Namespace: instance.GetNamespace(),
-						},
-						&corev1.Secret{},
-					)
-					return err != nil && k8serrors.IsNotFound(err)
-				}, asyncOpsTimeoutMins).Should(BeTrue())
-			})
-
-			By("removing the Secret with the credentials of the Standby role for streaming replication", func() {
-				Eventually(func() bool {
-					err := k8sClient.Get(
-						ctx,
-						types.NamespacedName{
-							Name: postgresql.StandbyRoleSecretName(
-								instance.GetName()),
-							Namespace: instance.GetNamespace(),
-						},
-						&corev1.Secret{},
-					)
-					return err != nil && k8serrors.IsNotFound(err)
-				}, asyncOpsTimeoutMins).Should(BeTrue())
-			})
-
-			By("removing the PersistentVolumeClaims of the replicas", func() {
-				Eventually(func() bool {
-					for i := 0; i < int(*pg.Spec.Replicas); i++ {
-						err := k8sClient.Get(
-							ctx,
-							types.NamespacedName{
-								Name: postgresql.PvcName(
-									instance.GetName(), i),
-								Namespace: instance.GetNamespace(),
-							},
-							&corev1.PersistentVolumeClaim{},
-						)
-						if err == nil || !k8serrors.IsNotFound(err) {
-							return false
-						}
-					}
-					return true
-				}, asyncOpsTimeoutMins).Should(BeTrue())
-			})
-
-			By("removing the Patroni leader election endpoint", func() {
-				Eventually(func() bool {
-					err := k8sClient.Get(
-						ctx,
-						types.NamespacedName{
-							Name:      instance.GetName(),
-							Namespace: instance.GetNamespace(),
-						},
-						&corev1.Endpoints{},
-					)
-					if err == nil || !k8serrors.IsNotFound(err) {
-						return false
-					}
-
-					return true
-				}, asyncOpsTimeoutMins).Should(BeTrue())
+			By("removing the StatefulSet, services, RoleBinding, ServiceAccount, secrets, "+
+				"PersistentVolumeClaims and Patroni leader election endpoint", func() {
+				dsi.WaitForSecondaryObjectsDeletion(ctx, instance, k8sClient)
 			})
 
 			By("emitting an event about the instance deletion", func() {
@@ -996,6 +868,39 @@ var _ = Describe("PostgreSQL Operator end-to-end tests", func() {
 						"wrong event involvedObject.apiVersion")
 			})
 		})
+
+		It("Recreates the PostgreSQL instance without adopting objects of the deleted one", func() {
+			By("deleting the PostgreSQL API object", func() {
+				Expect(k8sClient.Delete(ctx, instance.GetClientObject())).
+					To(Succeed(), "failed to delete PostgreSQL instance")
+				dsi.WaitForDeletion(ctx, instance.GetClientObject(), k8sClient)
+			})
+
+			By("recreating the PostgreSQL instance with the same name", func() {
+				pg = postgresql.New(instance.GetNamespace(), instance.GetName(), replicas)
+				instance = pg
+
+				Expect(k8sClient.Create(ctx, instance.GetClientObject())).
+					To(Succeed(), fmt.Sprintf("failed to recreate instance %s/%s",
+						instance.GetNamespace(), instance.GetName()))
+				DeferCleanup(func(ctx SpecContext) {
+					Expect(k8sClient.Delete(ctx, instance.GetClientObject())).
+						To(Succeed(), "failed to delete recreated PostgreSQL instance")
+					dsi.WaitForDeletion(ctx, instance.GetClientObject(), k8sClient)
+				})
+				dsi.WaitForReadiness(ctx, instance.GetClientObject(), k8sClient)
+			})
+
+			By("owning only objects created for the recreated instance", func() {
+				staleObjectsGetter, ok := instance.(dsi.StaleObjectsGetter)
+				Expect(ok).To(BeTrue(), "DSI doesn't implement dsi.StaleObjectsGetter")
+
+				stale, err := staleObjectsGetter.StaleObjects(ctx, k8sClient)
+				Expect(err).To(BeNil(), "failed to look up stale objects of the instance")
+				Expect(stale).To(BeEmpty(),
+					"recreated instance has objects of the deleted instance")
+			})
+		})
 	})
 
 	Context("PostgreSQL database operations", func() {
@@ -1019,7 +924,7 @@ var _ = Describe("PostgreSQL Operator end-to-end tests", func() {
 
 			// Portforward to access instance from outside cluster.
 			portForwardStopCh, localPort, err = framework.PortForward(
-				ctx, instancePort, kubeconfigPath, instance, k8sClient)
+				ctx, instancePort, restConfig, instance, k8sClient)
 			Expect(err).To(BeNil(),
 				fmt.Sprintf("failed to establish portforward to DSI %s/%s",
 					instance.GetNamespace(), instance.GetName()))
@@ -1096,7 +1001,7 @@ var _ = Describe("PostgreSQL Operator end-to-end tests", func() {
 				Eventually(func(g Gomega) {
 					// Portforward to access new primary pod from outside cluster.
 					portForwardStopCh, localPort, err = framework.PortForward(
-						ctx, instancePort, kubeconfigPath,
+						ctx, instancePort, restConfig,
 						instance, k8sClient)
 					g.Expect(err).To(BeNil(),
 						fmt.Sprintf("failed to establish portforward to DSI %s/%s",
@@ -1116,6 +1021,88 @@ var _ = Describe("PostgreSQL Operator end-to-end tests", func() {
 			})
 		})
 
+		It("Data can be updated, upserted and deleted", func() {
+			const updatedInput, upsertedInput = "updated_input", "upserted_input"
+
+			By("writing data", func() {
+				Expect(client.Write(ctx, entity, testInput)).To(Succeed(), "failed to insert data")
+			})
+
+			By("updating the written record", func() {
+				Expect(client.Update(ctx, entity, testInput, updatedInput)).
+					To(Succeed(), "failed to update data")
+				Expect(client.Read(ctx, entity)).To(Equal(updatedInput),
+					"read data does not match updated input")
+			})
+
+			By("failing to update a record that doesn't exist", func() {
+				Expect(client.Update(ctx, entity, testInput, updatedInput)).
+					To(MatchError(dsi.ErrNotFound), "updated a record that doesn't exist")
+			})
+
+			By("upserting a record that doesn't exist", func() {
+				Expect(client.Upsert(ctx, entity, testInput, upsertedInput)).
+					To(Succeed(), "failed to upsert data")
+				got, err := client.Read(ctx, entity)
+				Expect(err).To(BeNil(), "failed to read data")
+				// Read returns the records in no particular order.
+				Expect(strings.Split(got, "\n")).To(ConsistOf(updatedInput, upsertedInput),
+					"upsert didn't insert the record")
+			})
+
+			By("upserting an existing record", func() {
+				Expect(client.Upsert(ctx, entity, upsertedInput, testInput)).
+					To(Succeed(), "failed to upsert data")
+				got, err := client.Read(ctx, entity)
+				Expect(err).To(BeNil(), "failed to read data")
+				// Read returns the records in no particular order.
+				Expect(strings.Split(got, "\n")).To(ConsistOf(updatedInput, testInput),
+					"upsert didn't update the record")
+			})
+
+			By("deleting a record", func() {
+				Expect(client.Delete(ctx, entity, updatedInput)).
+					To(Succeed(), "failed to delete data")
+				Expect(client.Read(ctx, entity)).To(Equal(testInput),
+					"read data still contains the deleted record")
+				Expect(client.Delete(ctx, entity, updatedInput)).
+					To(MatchError(dsi.ErrNotFound), "deleted a record that doesn't exist")
+			})
+
+			By("deleting the entity", func() {
+				Expect(client.DeleteEntity(ctx, entity)).To(Succeed(), "failed to delete entity")
+				_, err := client.Read(ctx, entity)
+				Expect(err).To(MatchError(dsi.ErrNotFound), "read a deleted entity")
+				Expect(client.DeleteEntity(ctx, entity)).
+					To(MatchError(dsi.ErrNotFound), "deleted an entity that doesn't exist")
+			})
+		})
+
+		It("Entities with names that need quoting can be written, read and deleted", func() {
+			entities := []string{
+				framework.GenerateName("Test-Entity", GinkgoParallelProcess(), suffixLength),
+				"UPPER_case",
+				"select",
+				`quoted"; DROP TABLE ` + entity + `; --`,
+			}
+			for _, e := range entities {
+				By(fmt.Sprintf("writing to, reading from and deleting entity %q", e), func() {
+					Expect(client.Write(ctx, e, testInput)).To(Succeed(),
+						"failed to insert data")
+					Expect(client.Read(ctx, e)).To(Equal(testInput),
+						"read data does not match test input")
+					Expect(client.DeleteEntity(ctx, e)).To(Succeed(), "failed to delete entity")
+				})
+			}
+		})
+
+		It("Only existing configuration parameters can be checked", func() {
+			Expect(client.CheckParameter(ctx, "no_such_parameter", "on")).
+				To(MatchError(dsi.ErrNotFound), "checked a parameter that doesn't exist")
+			Expect(client.CheckParameter(ctx, "max_connections; SELECT 1", "100")).
+				To(MatchError(dsi.ErrNotFound), "checked a parameter that doesn't exist")
+		})
+
 		It("The default database and non-login role exist as required by service bindings", func() {
 			By("Creating a admin client", func() {
 				adminSecretData, err := secret.AdminSecretData(ctx,
@@ -1144,6 +1131,63 @@ var _ = Describe("PostgreSQL Operator end-to-end tests", func() {
 					fmt.Sprintf("failed to find existing user %s", user))
 			})
 		})
+
+		It("Service binding users are confined to the default database", func() {
+			var otherData secret.SecretData
+			By("creating another service binding", func() {
+				other := servicebinding.New(
+					servicebinding.SetNamespacedName(instance.GetClientObject()),
+					servicebinding.SetInstanceRef(instance.GetClientObject()),
+				)
+				Expect(k8sClient.Create(ctx, other)).To(Succeed(),
+					fmt.Sprintf("failed to create new servicebinding for DSI %s/%s",
+						instance.GetNamespace(), instance.GetName()))
+				DeferCleanup(func() {
+					Expect(ctrlruntimeclient.IgnoreNotFound(k8sClient.Delete(ctx, other))).
+						To(Succeed(), fmt.Sprintf("failed to delete service binding %s/%s",
+							other.GetNamespace(), other.GetName()))
+				})
+				servicebinding.WaitForReadiness(ctx, other, k8sClient)
+				otherData, err = secret.Data(
+					ctx, k8sClient, servicebinding.SecretName(other.Name), testingNamespace)
+				Expect(err).To(BeNil(),
+					fmt.Sprintf("failed to parse secret data for service binding %s/%s",
+						other.GetNamespace(), other.GetName()))
+			})
+			sbClients := []postgresql.Client{
+				postgresql.NewClientOverPortForwarding(serviceBindingData, strconv.Itoa(localPort)),
+				postgresql.NewClientOverPortForwarding(otherData, strconv.Itoa(localPort)),
+			}
+
+			By("granting no role attributes beyond login", func() {
+				for _, c := range sbClients {
+					privileges, err := c.Privileges(ctx)
+					Expect(err).To(BeNil(), "failed to get privileges of service binding user")
+					log.Println("Privileges of service binding user:", privileges)
+					Expect(privileges.CheckUnprivileged()).To(Succeed())
+				}
+			})
+
+			By("not allowing to create roles", func() {
+				for _, c := range sbClients {
+					Expect(c.CheckCannotCreateRoles(ctx)).To(Succeed())
+				}
+			})
+
+			By("not allowing to connect to other databases", func() {
+				for _, c := range sbClients {
+					Expect(c.CheckCannotConnectToOtherDatabases(ctx)).To(Succeed())
+				}
+			})
+
+			By("sharing the objects of the default database between service bindings", func() {
+				Expect(sbClients[0].Write(ctx, entity, testInput)).To(Succeed())
+				// Service bindings of an instance aren't isolated from each other, see
+				// docs/current_limitations.md. Update the docs if this fails.
+				Expect(sbClients[1].Read(ctx, entity)).To(Equal(testInput),
+					"service bindings are expected to share the objects of the default database")
+			})
+		})
 	})
 
 	Context("PostgreSQL high availability", func() {
@@ -1151,14 +1195,14 @@ var _ = Describe("PostgreSQL Operator end-to-end tests", func() {
 		BeforeEach(func() {
 			// Create high availability instance and wait for instance readiness
 			haReplicas := int32(3)
-			instance, err = dsi.New(
-				dataservice,
+			pg = postgresql.New(
 				testingNamespace,
 				framework.GenerateName(
 					instanceNamePrefix, GinkgoParallelProcess(), suffixLength),
 				haReplicas,
+				postgresql.WithReadOnlyService("replicas"),
 			)
-			Expect(err).To(BeNil(), "failed to generate new DSI resource")
+			instance = pg
 			Expect(k8sClient.Create(ctx, instance.GetClientObject())).
 				To(Succeed(), fmt.Sprintf("failed to create instance %s/%s",
 					instance.GetNamespace(), instance.GetName()))
@@ -1166,7 +1210,7 @@ var _ = Describe("PostgreSQL Operator end-to-end tests", func() {
 
 			// Portforward to access instance from outside cluster.
 			portForwardStopCh, localPort, err = framework.PortForward(
-				ctx, instancePort, kubeconfigPath, instance, k8sClient)
+				ctx, instancePort, restConfig, instance, k8sClient)
 			Expect(err).To(BeNil(),
 				fmt.Sprintf("failed to establish portforward to DSI %s/%s",
 					instance.GetNamespace(), instance.GetName()))
@@ -1206,6 +1250,17 @@ var _ = Describe("PostgreSQL Operator end-to-end tests", func() {
 		It("Failover occurs when primary pod is gone without data loss", func() {
 			pod := &corev1.Pod{}
 			var readData string
+			var recorder *timeline.Recorder
+			// The seed is logged so that a failure can be reproduced with the same records.
+			seed := time.Now().UnixNano()
+			log.Println("Seed of the data set written before the fail over:", seed)
+			records := dataset.Generate(entity+"_records", 1000, seed)
+			By("recording the timeline of the instance", func() {
+				sources, ok := instance.(dsi.TimelineSourcesGetter)
+				Expect(ok).To(BeTrue(), "DSI doesn't implement dsi.TimelineSourcesGetter")
+				recorder = fixture.RecordTimeline(ctx, k8sClient, sources.TimelineSources()...)
+			})
+
 			By("checking if we have a primary pod", func() {
 				pod, err = framework.GetPrimaryPodUsingServiceSelector(
 					ctx, instance, k8sClient)
@@ -1218,10 +1273,37 @@ var _ = Describe("PostgreSQL Operator end-to-end tests", func() {
 					BeNil(), fmt.Sprintln("failed to insert data"))
 			})
 
+			By("inserting a data set", func() {
+				Expect(client.WriteDataSet(ctx, records)).To(Succeed(),
+					"failed to insert data set")
+			})
+
 			By("ensuring that the data exists", func() {
 				readData, err = client.Read(ctx, entity)
 				Expect(err).To(BeNil(), "failed to read data")
 				Expect(readData).To(Equal(testInput), "read data does not match test input")
+
+				readRecords, err := client.ReadDataSet(ctx, records.Entity)
+				Expect(err).To(BeNil(), "failed to read data set")
+				Expect(dataset.Compare(records, readRecords)).To(Succeed(),
+					"read data set does not match the written one")
+			})
+
+			var driver *workload.Driver
+			connector := &workload.PrimaryServiceConnector{
+				Client:      k8sClient,
+				RESTConfig:  restConfig,
+				Instance:    instance,
+				DataService: dataservice,
+				Port:        instancePort,
+				Credentials: serviceBindingData,
+			}
+			DeferCleanup(connector.Close)
+			By("starting a workload that writes continuously through the primary service", func() {
+				driver = workload.Start(ctx, workload.Options{
+					Entity:  entity + "_workload",
+					Connect: connector.Connect,
+				})
 			})
 
 			By("deleting the primary pod to prompt a fail over", func() {
@@ -1250,6 +1332,19 @@ var _ = Describe("PostgreSQL Operator end-to-end tests", func() {
 				}
 			})
 
+			By("making a new primary ready only after the deletion of the old one began", func() {
+				Expect(recorder.InOrder(
+					timeline.HasState(postgresql.PodKind, postgresql.StateDeleting, "true").
+						Named(pod.GetName()),
+					postgresql.BecameReadyMaster,
+				)).To(Succeed())
+				Expect(recorder.LongestWhile(func(s timeline.Snapshot) bool {
+					return !postgresql.HasReadyMaster(s)
+				})).To(BeNumerically("<", maxTimeWithoutPrimary),
+					fmt.Sprintf("the instance was without a ready primary for too long:\n%s",
+						recorder))
+			})
+
 			By("ensuring that the data was replicated to the new primary", func() {
 				// TODO: This is only a temporary solution to an issue that was introduced
 				// by the PostgreSQL extensions feature. In order to install PostgreSQL extensions
@@ -1264,7 +1359,7 @@ var _ = Describe("PostgreSQL Operator end-to-end tests", func() {
 				Eventually(func(g Gomega) {
 					// Portforward to access new primary pod from outside cluster.
 					portForwardStopCh, localPort, err = framework.PortForward(
-						ctx, instancePort, kubeconfigPath, instance, k8sClient)
+						ctx, instancePort, restConfig, instance, k8sClient)
 					g.Expect(err).To(BeNil(),
 						fmt.Sprintf("failed to establish portforward to DSI %s/%s",
 							instance.GetNamespace(), instance.GetName()))
@@ -1280,8 +1375,135 @@ var _ = Describe("PostgreSQL Operator end-to-end tests", func() {
 					g.Expect(err).To(BeNil(), "failed to read data")
 					g.Expect(readData).To(Equal(replicatedData),
 						"read data does not match data replicated in new primary")
+
+					replicatedRecords, err := client.ReadDataSet(ctx, records.Entity)
+					g.Expect(err).To(BeNil(), "failed to read data set")
+					g.Expect(dataset.Compare(records, replicatedRecords)).To(Succeed(),
+						"data set replicated in new primary does not match the written one")
 				}, 60*time.Second).Should(Succeed())
 			})
+
+			By("losing no acknowledged writes of the workload during the fail over", func() {
+				report, err := driver.Stop(ctx)
+				Expect(err).To(BeNil(), "failed to read back the records of the workload")
+				log.Println("Workload during the fail over:", report)
+				Expect(report.Lost).To(BeEmpty(), fmt.Sprintf("acknowledged writes were lost "+
+					"during the fail over: %s", report))
+				Expect(report.Windows).ToNot(BeEmpty(),
+					"the workload should notice the unavailability of the primary")
+				Expect(report.RTO()).To(BeNumerically("<", maxFailoverRTO),
+					fmt.Sprintf("the primary was unavailable for too long: %s", report))
+			})
+		})
+
+		It("Switchover to a replica keeps all data", func() {
+			var oldPrimary *corev1.Pod
+			// The seed is logged so that a failure can be reproduced with the same records.
+			seed := time.Now().UnixNano()
+			log.Println("Seed of the data set written before the switchover:", seed)
+			records := dataset.Generate(entity+"_records", 1000, seed)
+
+			By("inserting a data set", func() {
+				Expect(client.WriteDataSet(ctx, records)).To(Succeed(),
+					"failed to insert data set")
+			})
+
+			By("getting the primary pod", func() {
+				oldPrimary, err = framework.GetPrimaryPodUsingServiceSelector(
+					ctx, instance, k8sClient)
+				Expect(err).To(BeNil())
+			})
+
+			By("switching over to the healthiest replica", func() {
+				switchover := pg.WaitForSwitchover(ctx, k8sClient, restConfig, "",
+					serviceBindingData, records)
+				Expect(switchover.OldPrimary).To(Equal(oldPrimary.Name))
+				Expect(switchover.NewPrimary).ToNot(Equal(oldPrimary.Name),
+					"the primary should be a former replica after the switchover")
+				log.Println("Time until the master service selected the new primary:",
+					switchover.Duration)
+			})
+
+			By("keeping the pod of the old primary", func() {
+				pod := &corev1.Pod{}
+				Expect(k8sClient.Get(ctx, ctrlruntimeclient.ObjectKeyFromObject(oldPrimary),
+					pod)).To(Succeed())
+				Expect(pod.GetUID()).To(Equal(oldPrimary.GetUID()),
+					"the pod of the old primary should not be recreated by a switchover")
+			})
+
+			By("making the old primary a replica of the new one", func() {
+				pg.WaitForReplicasCaughtUp(ctx, k8sClient, restConfig)
+			})
+		})
+
+		It("Read-only service routes only to replicas", func() {
+			// The replicas may still be bootstrapping although the instance is ready.
+			Eventually(func() error {
+				return pg.CheckReadOnlyService(ctx, k8sClient, restConfig, serviceBindingData)
+			}, framework.AsyncOpsTimeout(), framework.PollingPeriod()).Should(Succeed(),
+				"the read-only service should route only to replicas that reject writes")
+		})
+
+		It("Port forwards to Patroni, the metrics exporter and each replica", func() {
+			var primary *framework.PortForwarder
+			By("forwarding PostgreSQL, Patroni and the metrics exporter of the primary", func() {
+				primary, err = framework.NewPortForwarder(ctx, restConfig, k8sClient,
+					pg.PrimaryTarget(postgresql.Port, patroni.Port, postgresql.MetricsPort))
+				Expect(err).To(BeNil(), fmt.Sprintf("failed to port forward to the primary of %s/%s",
+					instance.GetNamespace(), instance.GetName()))
+				DeferCleanup(primary.Close)
+			})
+
+			By("writing to the primary", func() {
+				primaryClient, err := dsi.NewClient(dataservice,
+					strconv.Itoa(primary.LocalPort(postgresql.Port)), serviceBindingData)
+				Expect(err).To(BeNil(), "failed to create new dsi client")
+				Expect(primaryClient.Write(ctx, entity, testInput)).To(Succeed(),
+					"failed to insert data")
+			})
+
+			By("calling the REST API of Patroni of the primary", func() {
+				cluster, err := patroni.NewClientForPortForwarder(primary).Cluster(ctx)
+				Expect(err).To(BeNil(), "failed to get the Patroni cluster")
+				leader, ok := cluster.Leader()
+				Expect(ok).To(BeTrue(), "the Patroni cluster has no leader")
+				Expect(leader.Name).To(Equal(primary.Pod().Name))
+			})
+
+			By("scraping the metrics exporter of the primary", func() {
+				url := fmt.Sprintf("http://localhost:%d/metrics",
+					primary.LocalPort(postgresql.MetricsPort))
+				Eventually(func() (string, error) {
+					resp, err := http.Get(url)
+					if err != nil {
+						return "", err
+					}
+					defer resp.Body.Close()
+					body, err := io.ReadAll(resp.Body)
+					return string(body), err
+				}, framework.AsyncOpsTimeout(), framework.PollingPeriod()).
+					Should(ContainSubstring("pg_up"))
+			})
+
+			By("reading from each replica", func() {
+				for i := 0; i < int(*pg.Spec.Replicas); i++ {
+					fw, err := framework.NewPortForwarder(ctx, restConfig, k8sClient,
+						pg.ReplicaTarget(i))
+					Expect(err).To(BeNil(), fmt.Sprintf("failed to port forward to replica %d "+
+						"of %s/%s", i, instance.GetNamespace(), instance.GetName()))
+					DeferCleanup(fw.Close)
+					replicaClient, err := dsi.NewClient(dataservice,
+						strconv.Itoa(fw.LocalPort(postgresql.Port)), serviceBindingData)
+					Expect(err).To(BeNil(), "failed to create new dsi client")
+					// The replicas replicate asynchronously.
+					Eventually(func() (string, error) {
+						return replicaClient.Read(ctx, entity)
+					}, framework.AsyncOpsTimeout(), framework.PollingPeriod()).
+						Should(Equal(testInput), fmt.Sprintf("failed to read from pod %s",
+							fw.Pod().Name))
+				}
+			})
 		})
 	})
 
@@ -1324,21 +1546,16 @@ var _ = Describe("PostgreSQL Operator end-to-end tests", func() {
 			Skip("Skip MobilityDB related tests until we support arbitrary extensions")
 			extensions := []string{"mobilitydb"}
 
-			instance, err = dsi.New(
-				dataservice,
+			pg = postgresql.New(
 				testingNamespace,
 				framework.GenerateName(
 					instanceNamePrefix, GinkgoParallelProcess(), suffixLength),
 				singleReplica,
+				postgresql.WithExtensions(extensions...),
 			)
-			Expect(err).To(BeNil(), "failed to generate new DSI resource")
-
-			pg, ok = instance.GetClientObject().(*pgv1beta3.Postgresql)
-			Expect(ok).To(BeTrue(),
-				"failed to cast object interface to PostgreSQL struct")
-			pg.Spec.Extensions = extensions
+			instance = pg
 
-			Expect(k8sClient.Create(ctx, pg)).
+			Expect(k8sClient.Create(ctx, instance.GetClientObject())).
 				To(Succeed(), fmt.Sprintf("failed to create instance %s/%s",
 					instance.GetNamespace(), instance.GetName()))
 			dsi.WaitForReadiness(ctx, instance.GetClientObject(), k8sClient)
@@ -1367,21 +1584,16 @@ var _ = Describe("PostgreSQL Operator end-to-end tests", func() {
 		`)
 
 			extensions := []string{"mobilitydb", "pg-qualstats"}
-			instance, err = dsi.New(
-				dataservice,
+			pg = postgresql.New(
 				testingNamespace,
 				framework.GenerateName(
 					instanceNamePrefix, GinkgoParallelProcess(), suffixLength),
 				singleReplica,
+				postgresql.WithExtensions(extensions...),
 			)
-			Expect(err).To(BeNil(), "failed to generate new DSI resource")
+			instance = pg
 
-			pg, ok = instance.GetClientObject().(*pgv1beta3.Postgresql)
-			Expect(ok).To(BeTrue(),
-				"failed to cast object interface to PostgreSQL struct")
-			pg.Spec.Extensions = extensions
-
-			Expect(k8sClient.Create(ctx, pg)).
+			Expect(k8sClient.Create(ctx, instance.GetClientObject())).
 				To(Succeed(), fmt.Sprintf("failed to create instance %s/%s",
 					instance.GetNamespace(), instance.GetName()))
 			dsi.WaitForReadiness(ctx, instance.GetClientObject(), k8sClient)
@@ -1431,7 +1643,7 @@ var _ = Describe("PostgreSQL Operator end-to-end tests", func() {
 
 				currDSI.Spec.Extensions = []string{"mobilitydb"}
 				return k8sClient.Update(ctx, &currDSI)
-			}, asyncOpsTimeoutMins, 1*time.Second).Should(BeNil())
+			}, framework.AsyncOpsTimeout(), framework.PollingPeriod()).Should(BeNil())
 
 			Eventually(func(g Gomega) {
 				sts := &appsv1.StatefulSet{}
@@ -1444,7 +1656,7 @@ var _ = Describe("PostgreSQL Operator end-to-end tests", func() {
 
 				g.Expect(len(sts.Spec.Template.Spec.InitContainers)).To(Equal(1))
 				g.Expect(sts.Spec.Template.Spec.InitContainers[0].Name).To(Equal("mobilitydb"))
-			}, asyncOpsTimeoutMins, 1*time.Second).Should(Succeed())
+			}, framework.AsyncOpsTimeout(), framework.PollingPeriod()).Should(Succeed())
 		})
 
 		It("Adds multiple PostgreSQL extensions on update", func() {
@@ -1478,7 +1690,7 @@ var _ = Describe("PostgreSQL Operator end-to-end tests", func() {
 
 				currDSI.Spec.Extensions = []string{"mobilitydb", "pg-qualstats"}
 				return k8sClient.Update(ctx, &currDSI)
-			}, asyncOpsTimeoutMins, 1*time.Second).Should(BeNil())
+			}, framework.AsyncOpsTimeout(), framework.PollingPeriod()).Should(BeNil())
 
 			Eventually(func(g Gomega) {
 				sts := &appsv1.StatefulSet{}
@@ -1492,7 +1704,7 @@ var _ = Describe("PostgreSQL Operator end-to-end tests", func() {
 				g.Expect(len(sts.Spec.Template.Spec.InitContainers)).To(Equal(2))
 				g.Expect(sts.Spec.Template.Spec.InitContainers[0].Name).To(Equal("mobilitydb"))
 				g.Expect(sts.Spec.Template.Spec.InitContainers[1].Name).To(Equal("pg-qualstats"))
-			}, asyncOpsTimeoutMins, 1*time.Second).Should(Succeed())
+			}, framework.AsyncOpsTimeout(), framework.PollingPeriod()).Should(Succeed())
 		})
 
 		It("Removes one PostgreSQL extension on update", func() {
@@ -1502,21 +1714,16 @@ var _ = Describe("PostgreSQL Operator end-to-end tests", func() {
 		`)
 
 			extensions := []string{"mobilitydb", "pg-qualstats"}
-			instance, err = dsi.New(
-				dataservice,
+			pg = postgresql.New(
 				testingNamespace,
 				framework.GenerateName(
 					instanceNamePrefix, GinkgoParallelProcess(), suffixLength),
 				singleReplica,
+				postgresql.WithExtensions(extensions...),
 			)
-			Expect(err).To(BeNil(), "failed to generate new DSI resource")
-
-			pg, ok = instance.GetClientObject().(*pgv1beta3.Postgresql)
-			Expect(ok).To(BeTrue(),
-				"failed to cast object interface to PostgreSQL struct")
-			pg.Spec.Extensions = extensions
+			instance = pg
 
-			Expect(k8sClient.Create(ctx, pg)).
+			Expect(k8sClient.Create(ctx, instance.GetClientObject())).
 				To(Succeed(), fmt.Sprintf("failed to create instance %s/%s",
 					instance.GetNamespace(), instance.GetName()))
 			dsi.WaitForReadiness(ctx, instance.GetClientObject(), k8sClient)
@@ -1532,7 +1739,7 @@ var _ = Describe("PostgreSQL Operator end-to-end tests", func() {
 
 				currDSI.Spec.Extensions = []string{"mobilitydb"}
 				return k8sClient.Update(ctx, &currDSI)
-			}, asyncOpsTimeoutMins, 1*time.Second).Should(BeNil())
+			}, framework.AsyncOpsTimeout(), framework.PollingPeriod()).Should(BeNil())
 
 			Eventually(func(g Gomega) {
 				sts := &appsv1.StatefulSet{}
@@ -1545,27 +1752,22 @@ var _ = Describe("PostgreSQL Operator end-to-end tests", func() {
 
 				g.Expect(len(sts.Spec.Template.Spec.InitContainers)).To(Equal(1))
 				g.Expect(sts.Spec.Template.Spec.InitContainers[0].Name).To(Equal("mobilitydb"))
-			}, asyncOpsTimeoutMins, 1*time.Second).Should(Succeed())
+			}, framework.AsyncOpsTimeout(), framework.PollingPeriod()).Should(Succeed())
 		})
 
 		It("Removes all PostgreSQL extensions on update", func() {
 			Skip("Skip MobilityDB related tests until we support arbitrary extensions")
 			extensions := []string{"mobilitydb"}
-			instance, err = dsi.New(
-				dataservice,
+			pg = postgresql.New(
 				testingNamespace,
 				framework.GenerateName(
 					instanceNamePrefix, GinkgoParallelProcess(), suffixLength),
 				singleReplica,
+				postgresql.WithExtensions(extensions...),
 			)
-			Expect(err).To(BeNil(), "failed to generate new DSI resource")
-
-			pg, ok = instance.GetClientObject().(*pgv1beta3.Postgresql)
-			Expect(ok).To(BeTrue(),
-				"failed to cast object interface to PostgreSQL struct")
-			pg.Spec.Extensions = extensions
+			instance = pg
 
-			Expect(k8sClient.Create(ctx, pg)).
+			Expect(k8sClient.Create(ctx, instance.GetClientObject())).
 				To(Succeed(), fmt.Sprintf("failed to create instance %s/%s",
 					instance.GetNamespace(), instance.GetName()))
 			dsi.WaitForReadiness(ctx, instance.GetClientObject(), k8sClient)
@@ -1581,7 +1783,7 @@ var _ = Describe("PostgreSQL Operator end-to-end tests", func() {
 
 				currDSI.Spec.Extensions = []string{}
 				return k8sClient.Update(ctx, &currDSI)
-			}, asyncOpsTimeoutMins, 1*time.Second).Should(BeNil())
+			}, framework.AsyncOpsTimeout(), framework.PollingPeriod()).Should(BeNil())
 
 			Eventually(func(g Gomega) {
 				sts := &appsv1.StatefulSet{}
@@ -1596,7 +1798,7 @@ var _ = Describe("PostgreSQL Operator end-to-end tests", func() {
 				// of the statefulSet so that the extension related files are removed from the
 				// persistentVolume.
 				g.Expect(len(sts.Spec.Template.Spec.InitContainers)).To(Equal(0))
-			}, asyncOpsTimeoutMins, 1*time.Second).Should(Succeed())
+			}, framework.AsyncOpsTimeout(), framework.PollingPeriod()).Should(Succeed())
 		})
 	})
 })
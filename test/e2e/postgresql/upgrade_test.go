@@ -0,0 +1,110 @@
+package postgresql
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/anynines/a8s-deployment/test/framework"
+	"github.com/anynines/a8s-deployment/test/framework/dataset"
+	"github.com/anynines/a8s-deployment/test/framework/fixture"
+	"github.com/anynines/a8s-deployment/test/framework/postgresql"
+)
+
+const (
+	// oldVersion and newVersion are the supported major versions of PostgreSQL that instances are
+	// upgraded from and to.
+	oldVersion = 13
+	newVersion = 14
+
+	upgradeReplicas = 3
+)
+
+var _ = Describe("PostgreSQL major version upgrades", func() {
+	// provision provisions an instance with the given major version of PostgreSQL and a service
+	// binding.
+	provision := func(version int) (*postgresql.Postgresql, *fixture.DSI) {
+		upgraded := postgresql.New(
+			testingNamespace,
+			framework.GenerateName(instanceNamePrefix, GinkgoParallelProcess(), suffixLength),
+			upgradeReplicas,
+			postgresql.WithVersion(version),
+		)
+		f := fixture.NewDSI(ctx, fixture.Options{
+			Client:      k8sClient,
+			RESTConfig:  restConfig,
+			DataService: dataservice,
+			Port:        instancePort,
+			Instance:    upgraded,
+		})
+		return upgraded, f
+	}
+
+	It("Upgrades an instance to the next major version without data loss", func() {
+		upgraded, f := provision(oldVersion)
+
+		By("running the old version on every pod", func() {
+			versions, err := upgraded.ServerVersions(ctx, k8sClient, restConfig)
+			Expect(err).To(BeNil(), "failed to get the server versions")
+			Expect(versions).To(HaveLen(upgradeReplicas))
+			for pod, v := range versions {
+				Expect(postgresql.MajorVersion(v)).To(Equal(oldVersion),
+					fmt.Sprintf("pod %s runs server version %d", pod, v))
+			}
+		})
+
+		// The seed is logged so that a failure can be reproduced with the same records.
+		seed := time.Now().UnixNano()
+		log.Println("Seed of the data set written before the upgrade:", seed)
+		records := dataset.Generate(entity+"_records", 1000, seed)
+		By("inserting a data set", func() {
+			Expect(f.Client.WriteDataSet(ctx, records)).To(Succeed(),
+				"failed to insert data set")
+		})
+
+		By("upgrading to the new version", func() {
+			u := upgraded.WaitForMajorVersionUpgrade(ctx, k8sClient, restConfig, newVersion,
+				f.Credentials, records)
+			log.Printf("Upgrade from %d to %d took %s, server versions %v\n", u.From, u.To,
+				u.Duration, u.ServerVersions)
+		})
+
+		By("accepting writes after the upgrade", func() {
+			Eventually(func() error {
+				if err := f.Reconnect(ctx); err != nil {
+					return err
+				}
+				return f.Client.Write(ctx, entity, testInput)
+			}, framework.AsyncOpsTimeout(), framework.PollingPeriod()).Should(Succeed())
+		})
+	})
+
+	It("Rejects downgrades to an older major version", func() {
+		downgraded, _ := provision(newVersion)
+
+		By("rejecting the update of the version", func() {
+			err := downgraded.SetVersion(ctx, k8sClient, oldVersion)
+			Expect(apierrors.IsInvalid(err) || apierrors.IsForbidden(err)).To(BeTrue(),
+				fmt.Sprintf("expected the downgrade to be rejected, got: %v", err))
+		})
+
+		By("keeping the new version", func() {
+			current := postgresql.NewEmpty()
+			Expect(k8sClient.Get(ctx, ctrlruntimeclient.ObjectKeyFromObject(
+				downgraded.GetClientObject()), current.Postgresql)).To(Succeed())
+			Expect(current.Spec.Version).To(Equal(newVersion))
+
+			versions, err := downgraded.ServerVersions(ctx, k8sClient, restConfig)
+			Expect(err).To(BeNil(), "failed to get the server versions")
+			for pod, v := range versions {
+				Expect(postgresql.MajorVersion(v)).To(Equal(newVersion),
+					fmt.Sprintf("pod %s runs server version %d", pod, v))
+			}
+		})
+	})
+})
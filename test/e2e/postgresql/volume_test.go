@@ -0,0 +1,67 @@
+package postgresql
+
+import (
+	"log"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/anynines/a8s-deployment/test/framework"
+	"github.com/anynines/a8s-deployment/test/framework/fixture"
+	"github.com/anynines/a8s-deployment/test/framework/postgresql"
+	"github.com/anynines/a8s-deployment/test/framework/workload"
+)
+
+const expandedReplicas = 2
+
+// The default StorageClass of Kind doesn't support volume expansion.
+var _ = Describe("Volume expansion", Label("VolumeExpansion", "KindIncompatible"), func() {
+	It("Expands the volumes of a running instance while it's written to", func() {
+		expanded := postgresql.New(
+			testingNamespace,
+			framework.GenerateName(instanceNamePrefix, GinkgoParallelProcess(), suffixLength),
+			expandedReplicas,
+		)
+		f := fixture.NewDSI(ctx, fixture.Options{
+			Client:      k8sClient,
+			RESTConfig:  restConfig,
+			DataService: dataservice,
+			Port:        instancePort,
+			Instance:    expanded,
+		})
+
+		var driver *workload.Driver
+		connector := &workload.PrimaryServiceConnector{
+			Client:      k8sClient,
+			RESTConfig:  restConfig,
+			Instance:    expanded.GetClientObject(),
+			DataService: dataservice,
+			Port:        instancePort,
+			Credentials: f.Credentials,
+		}
+		DeferCleanup(connector.Close)
+		By("starting a workload that writes continuously through the primary service", func() {
+			driver = workload.Start(ctx, workload.Options{
+				Entity:  entity + "_workload",
+				Connect: connector.Connect,
+			})
+		})
+
+		By("doubling the volume size", func() {
+			size := expanded.Spec.VolumeSize.DeepCopy()
+			size.Add(expanded.Spec.VolumeSize)
+			e := expanded.WaitForVolumeExpansion(ctx, k8sClient, restConfig, size)
+			log.Printf("Expansion from %s to %s took %s, filesystems %v before and %v after\n",
+				e.From.String(), e.To.String(), e.Duration, e.FilesystemsBefore, e.Filesystems)
+		})
+
+		By("not losing acknowledged writes of the workload", func() {
+			report, err := driver.Stop(ctx)
+			Expect(err).To(BeNil(), "failed to read the records of the workload")
+			log.Println("Workload during the volume expansion:", report)
+			Expect(report.Lost).To(BeEmpty(),
+				"acknowledged writes were lost during the volume expansion")
+			Expect(report.Acknowledged).To(BeNumerically(">", 0))
+		})
+	})
+})
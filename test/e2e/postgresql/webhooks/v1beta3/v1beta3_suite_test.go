@@ -15,8 +15,8 @@ import (
 	"github.com/anynines/a8s-deployment/test/framework"
 	"github.com/anynines/a8s-deployment/test/framework/dsi"
 	"github.com/anynines/a8s-deployment/test/framework/namespace"
+	"github.com/anynines/a8s-deployment/test/framework/postgresql"
 	pgv1beta3 "github.com/anynines/postgresql-operator/api/v1beta3"
-	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/types"
@@ -26,10 +26,7 @@ import (
 
 // These tests use Ginkgo (BDD-style Go testing framework). Refer to
 // http://onsi.github.io/ginkgo/ to learn more about Ginkgo.
-const (
-	suffixLength        = 5
-	asyncOpsTimeoutMins = time.Minute * 5
-)
+const suffixLength = 5
 
 var (
 	ctx                                                               context.Context
@@ -60,8 +57,11 @@ var _ = BeforeSuite(func() {
 	Expect(err).To(BeNil(), "failed to parse environmental variables as configuration")
 	kubeconfigPath, instanceNamePrefix, dataservice, testingNamespace = framework.ConfigToVars(config)
 
+	restConfig, err := framework.RESTConfig(kubeconfigPath)
+	Expect(err).To(BeNil(), "failed to build the config of the Kubernetes API server")
+
 	// Create Kubernetes client for interacting with the Kubernetes API
-	k8sClient, err = dsi.NewK8sClient(dataservice, kubeconfigPath)
+	k8sClient, err = dsi.NewK8sClientForConfig(dataservice, restConfig)
 	Expect(err).To(BeNil(),
 		fmt.Sprintf("error creating Kubernetes client for dataservice %s", dataservice))
 
@@ -122,31 +122,31 @@ var _ = Describe("Validating webhook", func() {
 
 	Context("Storage size validation on creation", func() {
 		It("Allows a DSI with storage size of 1Gi", func() {
-			dsi := newDSI(withName("dsi-1gi-pass"), withStorageSize("1Gi"))
+			dsi := newDSI(withName("dsi-1gi-pass"), postgresql.WithVolumeSize("1Gi"))
 			err := k8sClient.Create(ctx, dsi)
 			Expect(err).NotTo(HaveOccurred())
 		})
 
 		It("Allows a DSI with storage size of 42Gi", func() {
-			dsi := newDSI(withName("dsi-42gi-pass"), withStorageSize("42Gi"))
+			dsi := newDSI(withName("dsi-42gi-pass"), postgresql.WithVolumeSize("42Gi"))
 			err := k8sClient.Create(ctx, dsi)
 			Expect(err).NotTo(HaveOccurred())
 		})
 
 		It("Allows a DSI with storage size of 2000M", func() {
-			dsi := newDSI(withName("dsi-2000m-pass"), withStorageSize("2000M"))
+			dsi := newDSI(withName("dsi-2000m-pass"), postgresql.WithVolumeSize("2000M"))
 			err := k8sClient.Create(ctx, dsi)
 			Expect(err).NotTo(HaveOccurred())
 		})
 
 		It("Allows a DSI with storage size of 0.5Gi", func() {
-			dsi := newDSI(withName("dsi-0.5gi-pass"), withStorageSize("0.5Gi"))
+			dsi := newDSI(withName("dsi-0.5gi-pass"), postgresql.WithVolumeSize("0.5Gi"))
 			err := k8sClient.Create(ctx, dsi)
 			Expect(err).NotTo(HaveOccurred())
 		})
 
 		It("Rejects a DSI with storage size of 1Mi", func() {
-			dsi := newDSI(withName("dsi-1mi-fail"), withStorageSize("1Mi"))
+			dsi := newDSI(withName("dsi-1mi-fail"), postgresql.WithVolumeSize("1Mi"))
 			err := k8sClient.Create(ctx, dsi)
 			Expect(errors.IsInvalid(err)).To(BeTrue())
 			Expect(err.Error()).To(ContainSubstring("spec.volumeSize"),
@@ -158,7 +158,7 @@ var _ = Describe("Validating webhook", func() {
 		})
 
 		It("Rejects a DSI with storage size of 1k", func() {
-			dsi := newDSI(withName("dsi-1k-fail"), withStorageSize("1k"))
+			dsi := newDSI(withName("dsi-1k-fail"), postgresql.WithVolumeSize("1k"))
 			err := k8sClient.Create(ctx, dsi)
 			Expect(errors.IsInvalid(err)).To(BeTrue())
 			Expect(err.Error()).To(ContainSubstring("spec.volumeSize"),
@@ -179,13 +179,13 @@ var _ = Describe("Validating webhook", func() {
 			})
 
 			It("Allows a DSI with nil labels", func() {
-				dsi = newDSI(withLabels(nil))
+				dsi = newDSI(postgresql.WithLabels(nil))
 				Expect(k8sClient.Create(ctx, dsi)).To(Succeed(),
 					"failed to create DSI with nil labels even if it's allowed")
 			})
 
 			It("Allows a DSI with empty labels", func() {
-				dsi = newDSI(withLabels(map[string]string{}))
+				dsi = newDSI(postgresql.WithLabels(map[string]string{}))
 				Expect(k8sClient.Create(ctx, dsi)).To(Succeed(),
 					"failed to create DSI with empty labels even if it's allowed")
 			})
@@ -195,7 +195,7 @@ var _ = Describe("Validating webhook", func() {
 					"allowed-label-1": "val1",
 					"allowed-label-2": "val2",
 				}
-				dsi = newDSI(withLabels(labels))
+				dsi = newDSI(postgresql.WithLabels(labels))
 				Expect(k8sClient.Create(ctx, dsi)).To(Succeed(),
 					fmt.Sprintf("failed to create DSI with allowed labels %s", labels))
 			})
@@ -216,7 +216,7 @@ var _ = Describe("Validating webhook", func() {
 						reservedLabelKeyWithoutLastChar:             "val4",
 						reservedLabelKeyWithoutMiddleChar:           "val5",
 					}
-					dsi = newDSI(withLabels(labels))
+					dsi = newDSI(postgresql.WithLabels(labels))
 					Expect(k8sClient.Create(ctx, dsi)).To(Succeed(),
 						fmt.Sprintf("failed to create DSI with allowed labels %s", labels))
 				})
@@ -227,7 +227,7 @@ var _ = Describe("Validating webhook", func() {
 				labels := map[string]string{
 					reservedLabelsKeys[0]: "val1",
 				}
-				dsi = newDSI(withLabels(labels))
+				dsi = newDSI(postgresql.WithLabels(labels))
 
 				err := k8sClient.Create(ctx, dsi)
 
@@ -245,7 +245,7 @@ var _ = Describe("Validating webhook", func() {
 					reservedLabelsKeys[1]: "val1",
 					reservedLabelsKeys[2]: "val2",
 				}
-				dsi = newDSI(withLabels(labels))
+				dsi = newDSI(postgresql.WithLabels(labels))
 
 				err := k8sClient.Create(ctx, dsi)
 
@@ -265,7 +265,7 @@ var _ = Describe("Validating webhook", func() {
 				for i, k := range reservedLabelsKeys {
 					labels[k] = "val" + strconv.Itoa(i)
 				}
-				dsi = newDSI(withLabels(labels))
+				dsi = newDSI(postgresql.WithLabels(labels))
 
 				err := k8sClient.Create(ctx, dsi)
 
@@ -286,7 +286,7 @@ var _ = Describe("Validating webhook", func() {
 					"allowed-label-2":     "val2",
 					reservedLabelsKeys[3]: "val3",
 				}
-				dsi = newDSI(withLabels(labels))
+				dsi = newDSI(postgresql.WithLabels(labels))
 
 				err := k8sClient.Create(ctx, dsi)
 
@@ -307,7 +307,7 @@ var _ = Describe("Validating webhook", func() {
 				for i, k := range reservedLabelsKeys {
 					labels[k] = "val" + strconv.Itoa(i)
 				}
-				dsi = newDSI(withLabels(labels))
+				dsi = newDSI(postgresql.WithLabels(labels))
 
 				err := k8sClient.Create(ctx, dsi)
 
@@ -337,7 +337,7 @@ var _ = Describe("Validating webhook", func() {
 					"allowed-label-1": "val1",
 					"allowed-label-2": "val2",
 				}
-				dsi = newDSI(withLabels(labels))
+				dsi = newDSI(postgresql.WithLabels(labels))
 				Expect(k8sClient.Create(ctx, dsi)).To(Succeed())
 
 				Eventually(func() error {
@@ -352,14 +352,14 @@ var _ = Describe("Validating webhook", func() {
 					currDSI.Labels = nil
 
 					return k8sClient.Update(ctx, &currDSI)
-				}, asyncOpsTimeoutMins, 1*time.Second).Should(BeNil())
+				}, framework.AsyncOpsTimeout(), framework.PollingPeriod()).Should(BeNil())
 			})
 
 			It("Allows update from valid labels to empty ones", func() {
 				labels := map[string]string{
 					"allowed-label-1": "val1",
 				}
-				dsi = newDSI(withLabels(labels))
+				dsi = newDSI(postgresql.WithLabels(labels))
 				Expect(k8sClient.Create(ctx, dsi)).To(Succeed())
 
 				Eventually(func() error {
@@ -374,11 +374,11 @@ var _ = Describe("Validating webhook", func() {
 					currDSI.Labels = map[string]string{}
 
 					return k8sClient.Update(ctx, &currDSI)
-				}, asyncOpsTimeoutMins, 1*time.Second).Should(BeNil())
+				}, framework.AsyncOpsTimeout(), framework.PollingPeriod()).Should(BeNil())
 			})
 
 			It("Allows update from nil labels to valid ones", func() {
-				dsi = newDSI(withLabels(nil))
+				dsi = newDSI(postgresql.WithLabels(nil))
 				Expect(k8sClient.Create(ctx, dsi)).To(Succeed())
 
 				Eventually(func() error {
@@ -395,11 +395,11 @@ var _ = Describe("Validating webhook", func() {
 					}
 
 					return k8sClient.Update(ctx, &currDSI)
-				}, asyncOpsTimeoutMins, 1*time.Second).Should(BeNil())
+				}, framework.AsyncOpsTimeout(), framework.PollingPeriod()).Should(BeNil())
 			})
 
 			It("Allows update from empty labels to valid ones", func() {
-				dsi = newDSI(withLabels(map[string]string{}))
+				dsi = newDSI(postgresql.WithLabels(map[string]string{}))
 				Expect(k8sClient.Create(ctx, dsi)).To(Succeed())
 
 				Eventually(func() error {
@@ -417,14 +417,14 @@ var _ = Describe("Validating webhook", func() {
 					}
 
 					return k8sClient.Update(ctx, &currDSI)
-				}, asyncOpsTimeoutMins, 1*time.Second).Should(BeNil())
+				}, framework.AsyncOpsTimeout(), framework.PollingPeriod()).Should(BeNil())
 			})
 
 			It("Allows addition of valid labels", func() {
 				labels := map[string]string{
 					"allowed-label-1": "val1",
 				}
-				dsi = newDSI(withLabels(labels))
+				dsi = newDSI(postgresql.WithLabels(labels))
 				Expect(k8sClient.Create(ctx, dsi)).To(Succeed())
 
 				Eventually(func() error {
@@ -443,7 +443,7 @@ var _ = Describe("Validating webhook", func() {
 					}
 
 					return k8sClient.Update(ctx, &currDSI)
-				}, asyncOpsTimeoutMins, 1*time.Second).Should(BeNil())
+				}, framework.AsyncOpsTimeout(), framework.PollingPeriod()).Should(BeNil())
 			})
 
 			It("Allows removal of labels", func() {
@@ -452,7 +452,7 @@ var _ = Describe("Validating webhook", func() {
 					"allowed-label-2": "val2",
 					"allowed-label-3": "val3",
 				}
-				dsi = newDSI(withLabels(labels))
+				dsi = newDSI(postgresql.WithLabels(labels))
 				Expect(k8sClient.Create(ctx, dsi)).To(Succeed())
 
 				Eventually(func() error {
@@ -469,13 +469,13 @@ var _ = Describe("Validating webhook", func() {
 					}
 
 					return k8sClient.Update(ctx, &currDSI)
-				}, asyncOpsTimeoutMins, 1*time.Second).Should(BeNil())
+				}, framework.AsyncOpsTimeout(), framework.PollingPeriod()).Should(BeNil())
 			})
 		})
 
 		Context("Invalid labels", func() {
 			It("Rejects update from nil labels to reserved only labels", func() {
-				dsi = newDSI(withLabels(nil))
+				dsi = newDSI(postgresql.WithLabels(nil))
 				Expect(k8sClient.Create(ctx, dsi)).To(Succeed())
 
 				var err error
@@ -493,7 +493,7 @@ var _ = Describe("Validating webhook", func() {
 
 					err = k8sClient.Update(ctx, &currDSI)
 					g.Expect(errors.IsInvalid(err)).To(BeTrue())
-				}, asyncOpsTimeoutMins, 1*time.Second).Should(Succeed())
+				}, framework.AsyncOpsTimeout(), framework.PollingPeriod()).Should(Succeed())
 
 				Expect(err.Error()).To(ContainSubstring(reservedLabelsKeys[0]),
 					"got error that doesn't mention the reserved labels while it should")
@@ -504,7 +504,7 @@ var _ = Describe("Validating webhook", func() {
 			})
 
 			It("Rejects update from nil labels to reserved and valid labels", func() {
-				dsi = newDSI(withLabels(nil))
+				dsi = newDSI(postgresql.WithLabels(nil))
 				Expect(k8sClient.Create(ctx, dsi)).To(Succeed())
 
 				var err error
@@ -523,7 +523,7 @@ var _ = Describe("Validating webhook", func() {
 
 					err = k8sClient.Update(ctx, &currDSI)
 					g.Expect(errors.IsInvalid(err)).To(BeTrue())
-				}, asyncOpsTimeoutMins, 1*time.Second).Should(Succeed())
+				}, framework.AsyncOpsTimeout(), framework.PollingPeriod()).Should(Succeed())
 
 				Expect(err.Error()).To(ContainSubstring(reservedLabelsKeys[1]),
 					"got error that doesn't mention the reserved labels while it should")
@@ -534,7 +534,7 @@ var _ = Describe("Validating webhook", func() {
 			})
 
 			It("Rejects update from empty labels to reserved only labels", func() {
-				dsi = newDSI(withLabels(map[string]string{}))
+				dsi = newDSI(postgresql.WithLabels(map[string]string{}))
 				Expect(k8sClient.Create(ctx, dsi)).To(Succeed())
 
 				var err error
@@ -553,7 +553,7 @@ var _ = Describe("Validating webhook", func() {
 
 					err = k8sClient.Update(ctx, &currDSI)
 					g.Expect(errors.IsInvalid(err)).To(BeTrue())
-				}, asyncOpsTimeoutMins, 1*time.Second).Should(Succeed())
+				}, framework.AsyncOpsTimeout(), framework.PollingPeriod()).Should(Succeed())
 
 				Expect(err.Error()).To(ContainSubstring(reservedLabelsKeys[2]),
 					"got error that doesn't mention the reserved labels while it should")
@@ -566,7 +566,7 @@ var _ = Describe("Validating webhook", func() {
 			})
 
 			It("Rejects update from empty labels to reserved and valid labels", func() {
-				dsi = newDSI(withLabels(map[string]string{}))
+				dsi = newDSI(postgresql.WithLabels(map[string]string{}))
 				Expect(k8sClient.Create(ctx, dsi)).To(Succeed())
 
 				var err error
@@ -586,7 +586,7 @@ var _ = Describe("Validating webhook", func() {
 
 					err = k8sClient.Update(ctx, &currDSI)
 					g.Expect(errors.IsInvalid(err)).To(BeTrue())
-				}, asyncOpsTimeoutMins, 1*time.Second).Should(Succeed())
+				}, framework.AsyncOpsTimeout(), framework.PollingPeriod()).Should(Succeed())
 
 				Expect(err.Error()).To(ContainSubstring(reservedLabelsKeys[1]),
 					"got error that doesn't mention the reserved labels while it should")
@@ -601,7 +601,7 @@ var _ = Describe("Validating webhook", func() {
 					"allowed-label-1": "val1",
 					"allowed-label-2": "val2",
 				}
-				dsi = newDSI(withLabels(labels))
+				dsi = newDSI(postgresql.WithLabels(labels))
 				Expect(k8sClient.Create(ctx, dsi)).To(Succeed())
 
 				var err error
@@ -620,7 +620,7 @@ var _ = Describe("Validating webhook", func() {
 
 					err = k8sClient.Update(ctx, &currDSI)
 					g.Expect(errors.IsInvalid(err)).To(BeTrue())
-				}, asyncOpsTimeoutMins, 1*time.Second).Should(Succeed())
+				}, framework.AsyncOpsTimeout(), framework.PollingPeriod()).Should(Succeed())
 
 				Expect(err.Error()).To(ContainSubstring(reservedLabelsKeys[0]),
 					"got error that doesn't mention the reserved labels while it should")
@@ -636,7 +636,7 @@ var _ = Describe("Validating webhook", func() {
 				labels := map[string]string{
 					"allowed-label-1": "val1",
 				}
-				dsi = newDSI(withLabels(labels))
+				dsi = newDSI(postgresql.WithLabels(labels))
 				Expect(k8sClient.Create(ctx, dsi)).To(Succeed())
 
 				var err error
@@ -655,7 +655,7 @@ var _ = Describe("Validating webhook", func() {
 
 					err = k8sClient.Update(ctx, &currDSI)
 					g.Expect(errors.IsInvalid(err)).To(BeTrue())
-				}, asyncOpsTimeoutMins, 1*time.Second).Should(Succeed())
+				}, framework.AsyncOpsTimeout(), framework.PollingPeriod()).Should(Succeed())
 
 				Expect(err.Error()).To(ContainSubstring(reservedLabelsKeys[0]),
 					"got error that doesn't mention the reserved labels while it should")
@@ -676,8 +676,8 @@ var _ = Describe("Validating webhook", func() {
 					"allowed-label":       "val2",
 				}
 				dsi := newDSI(withNameOfLength(pgv1beta3.MaxNameLengthChars+2),
-					withStorageSize("1k"),
-					withLabels(labels))
+					postgresql.WithVolumeSize("1k"),
+					postgresql.WithLabels(labels))
 				err := k8sClient.Create(ctx, dsi)
 				Expect(errors.IsInvalid(err)).To(BeTrue())
 				Expect(err.Error()).To(ContainSubstring("metadata.name"),
@@ -704,8 +704,8 @@ var _ = Describe("Validating webhook", func() {
 	Context("Storage size update validation", func() {
 		It("Allows update with no adjustment to volume size", func() {
 			dsi := newDSI(withName("dsi-1gi-nochange-pass"),
-				withStorageSize("1Gi"),
-				withReplicas(1))
+				postgresql.WithVolumeSize("1Gi"),
+				postgresql.WithReplicas(1))
 			err := k8sClient.Create(ctx, dsi)
 			Expect(err).NotTo(HaveOccurred())
 
@@ -723,13 +723,13 @@ var _ = Describe("Validating webhook", func() {
 				currDSI.Spec.Replicas = pointer.Int32(3)
 
 				return k8sClient.Update(ctx, &currDSI)
-			}, asyncOpsTimeoutMins, 1*time.Second).Should(BeNil())
+			}, framework.AsyncOpsTimeout(), framework.PollingPeriod()).Should(BeNil())
 			Expect(err).NotTo(HaveOccurred())
 		})
 
 		It("Rejects scaling a DSI volume from 2Gi to 1Gi", func() {
 			var err error
-			dsi := newDSI(withName("dsi-2-to-1gi-fail"), withStorageSize("2Gi"))
+			dsi := newDSI(withName("dsi-2-to-1gi-fail"), postgresql.WithVolumeSize("2Gi"))
 			err = k8sClient.Create(ctx, dsi)
 			Expect(err).NotTo(HaveOccurred())
 
@@ -745,7 +745,7 @@ var _ = Describe("Validating webhook", func() {
 
 				err = k8sClient.Update(ctx, &currDSI)
 				g.Expect(errors.IsInvalid(err)).To(BeTrue())
-			}, asyncOpsTimeoutMins, 1*time.Second).Should(Succeed())
+			}, framework.AsyncOpsTimeout(), framework.PollingPeriod()).Should(Succeed())
 
 			Expect(err.Error()).To(ContainSubstring("spec.volumeSize"),
 				"error message doesn't mention name of the invalid field")
@@ -757,7 +757,7 @@ var _ = Describe("Validating webhook", func() {
 
 		It("Rejects scaling a DSI volume from 2Gi to 3k", func() {
 			var err error
-			dsi := newDSI(withName("dsi-2-to-3k-fail"), withStorageSize("2Gi"))
+			dsi := newDSI(withName("dsi-2-to-3k-fail"), postgresql.WithVolumeSize("2Gi"))
 			err = k8sClient.Create(ctx, dsi)
 			Expect(err).NotTo(HaveOccurred())
 
@@ -773,7 +773,7 @@ var _ = Describe("Validating webhook", func() {
 
 				err = k8sClient.Update(ctx, &currDSI)
 				g.Expect(errors.IsInvalid(err)).To(BeTrue())
-			}, asyncOpsTimeoutMins, 1*time.Second).Should(Succeed())
+			}, framework.AsyncOpsTimeout(), framework.PollingPeriod()).Should(Succeed())
 
 			Expect(err.Error()).To(ContainSubstring("spec.volumeSize"),
 				"error message doesn't mention name of the invalid field")
@@ -796,7 +796,7 @@ var _ = Describe("Validating webhook", func() {
 				"allowed-label-1": "val1",
 				"allowed-label-2": "val2",
 			}
-			dsi = newDSI(withStorageSize("4Gi"), withLabels(labels))
+			dsi = newDSI(postgresql.WithVolumeSize("4Gi"), postgresql.WithLabels(labels))
 			Expect(k8sClient.Create(ctx, dsi)).To(Succeed())
 
 			var err error
@@ -816,7 +816,7 @@ var _ = Describe("Validating webhook", func() {
 
 				err = k8sClient.Update(ctx, &currDSI)
 				g.Expect(errors.IsInvalid(err)).To(BeTrue())
-			}, asyncOpsTimeoutMins, 1*time.Second).Should(Succeed())
+			}, framework.AsyncOpsTimeout(), framework.PollingPeriod()).Should(Succeed())
 
 			Expect(err.Error()).To(ContainSubstring("spec.volumeSize"),
 				"error message doesn't mention name of the invalid field")
@@ -834,105 +834,27 @@ var _ = Describe("Validating webhook", func() {
 	})
 })
 
-func withCustomConfiguration(config pgv1beta3.PostgresqlParameters) func(*pgv1beta3.Postgresql) {
-	return func(dsi *pgv1beta3.Postgresql) {
-		dsi.Spec.Parameters = config
-	}
-}
-
-func withExtensions(extensions ...string) func(*pgv1beta3.Postgresql) {
-	return func(dsi *pgv1beta3.Postgresql) {
-		dsi.Spec.Extensions = extensions
-	}
-}
-
-func newDSI(opts ...func(*pgv1beta3.Postgresql)) *pgv1beta3.Postgresql {
-	instance, err := dsi.New(
-		dataservice,
+// newDSI returns a PostgreSQL instance with one replica and a generated name in the testing
+// namespace, configured by opts.
+func newDSI(opts ...postgresql.Option) *pgv1beta3.Postgresql {
+	return postgresql.New(
 		testingNamespace,
 		framework.GenerateName(
 			instanceNamePrefix, GinkgoParallelProcess(), suffixLength),
 		1,
-	)
-	Expect(err).To(BeNil(), "failed to generate new DSI resource")
-
-	dsi, ok := instance.GetClientObject().(*pgv1beta3.Postgresql)
-	Expect(ok).To(BeTrue(),
-		"failed to cast object interface to PostgreSQL struct")
-
-	for _, applyOption := range opts {
-		applyOption(dsi)
-	}
-	return dsi
-}
-
-func withCPURequest(cpu string) func(*pgv1beta3.Postgresql) {
-	return func(pg *pgv1beta3.Postgresql) {
-		initResourcesStructs(pg)
-		pg.Spec.Resources.Requests["cpu"] = resource.MustParse(cpu)
-	}
-}
-
-func withCPULimit(cpu string) func(*pgv1beta3.Postgresql) {
-	return func(pg *pgv1beta3.Postgresql) {
-		initResourcesStructs(pg)
-		pg.Spec.Resources.Limits["cpu"] = resource.MustParse(cpu)
-	}
-}
-
-func withMemoryRequest(mem string) func(*pgv1beta3.Postgresql) {
-	return func(pg *pgv1beta3.Postgresql) {
-		initResourcesStructs(pg)
-		pg.Spec.Resources.Requests["memory"] = resource.MustParse(mem)
-	}
-}
-
-func withMemoryLimit(mem string) func(*pgv1beta3.Postgresql) {
-	return func(pg *pgv1beta3.Postgresql) {
-		initResourcesStructs(pg)
-		pg.Spec.Resources.Limits["memory"] = resource.MustParse(mem)
-	}
-}
-
-func initResourcesStructs(pg *pgv1beta3.Postgresql) {
-	if pg.Spec.Resources == nil {
-		pg.Spec.Resources = &corev1.ResourceRequirements{}
-	}
-	if pg.Spec.Resources.Requests == nil {
-		pg.Spec.Resources.Requests = corev1.ResourceList{}
-	}
-	if pg.Spec.Resources.Limits == nil {
-		pg.Spec.Resources.Limits = corev1.ResourceList{}
-	}
-}
-
-func withName(name string) func(*pgv1beta3.Postgresql) {
-	return func(dsi *pgv1beta3.Postgresql) {
-		dsi.Name = name
-	}
-}
-
-func withNameOfLength(length int) func(*pgv1beta3.Postgresql) {
-	return func(dsi *pgv1beta3.Postgresql) {
-		dsi.Name = nameOfLength(length)
-	}
-}
-
-func withStorageSize(size string) func(*pgv1beta3.Postgresql) {
-	return func(dsi *pgv1beta3.Postgresql) {
-		dsi.Spec.VolumeSize = resource.MustParse(size)
-	}
+		opts...,
+	).Postgresql
 }
 
-func withReplicas(r int32) func(*pgv1beta3.Postgresql) {
-	return func(dsi *pgv1beta3.Postgresql) {
-		dsi.Spec.Replicas = &r
+func withName(name string) postgresql.Option {
+	return func(pg *postgresql.Postgresql) {
+		pg.Name = name
 	}
 }
 
-func withLabels(l map[string]string) func(*pgv1beta3.Postgresql) {
-	return func(dsi *pgv1beta3.Postgresql) {
-		dsi.Labels = l
+func withNameOfLength(length int) postgresql.Option {
+	return func(pg *postgresql.Postgresql) {
+		pg.Name = nameOfLength(length)
 	}
 }
 
@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
-	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -12,6 +11,7 @@ import (
 
 	"github.com/anynines/a8s-deployment/test/framework"
 	"github.com/anynines/a8s-deployment/test/framework/dsi"
+	"github.com/anynines/a8s-deployment/test/framework/fixture"
 	"github.com/anynines/a8s-deployment/test/framework/secret"
 	"github.com/anynines/a8s-deployment/test/framework/servicebinding"
 	sbv1beta3 "github.com/anynines/a8s-service-binding-controller/api/v1beta3"
@@ -41,56 +41,7 @@ var (
 
 var _ = Describe("Service binding", func() {
 	Context("Single ServiceBinding for a single DSI", func() {
-		BeforeEach(func() {
-			// Create Dataservice instance and wait for instance readiness
-			instance, err = dsi.New(
-				dataservice,
-				testingNamespace,
-				framework.GenerateName(instanceNamePrefix,
-					GinkgoParallelProcess(),
-					suffixLength),
-				replicas,
-			)
-			Expect(err).To(BeNil(), "failed to generate DSI object")
-
-			Expect(k8sClient.Create(ctx, instance.GetClientObject())).
-				To(Succeed(), "failed to create DSI")
-
-			dsi.WaitForReadiness(ctx, instance.GetClientObject(), k8sClient)
-
-			// Portforward to access DSI from outside cluster.
-			portForwardStopCh, localPort, err = framework.PortForward(
-				ctx, instancePort, kubeconfigPath, instance, k8sClient)
-			Expect(err).To(BeNil(),
-				fmt.Sprintf("failed to establish portforward to DSI %s/%s",
-					instance.GetNamespace(),
-					instance.GetName()))
-
-			adminSecret, err := secret.AdminSecretData(ctx,
-				k8sClient,
-				instance.GetName(),
-				testingNamespace)
-
-			Expect(err).To(BeNil(),
-				fmt.Sprintf("failed to parse secret data of admin credentials for DSI %s/%s",
-					instance.GetNamespace(),
-					instance.GetName()))
-
-			// Create DSIClient for interacting with the new DSI.
-			dsiAdminClient, err = dsi.NewClient(dataservice,
-				strconv.Itoa(localPort),
-				adminSecret)
-
-			Expect(err).To(BeNil(), "failed to create DSI client")
-		})
-
-		AfterEach(func() {
-			defer func() { close(portForwardStopCh) }()
-			Expect(k8sClient.Delete(ctx, instance.GetClientObject())).To(Succeed(),
-				fmt.Sprintf("failed to delete DSI %s/%s",
-					instance.GetNamespace(),
-					instance.GetName()))
-		})
+		BeforeEach(provisionDSI)
 
 		It("Performs basic service binding lifecycle operations", func() {
 			// Create service binding for DSI.
@@ -181,7 +132,7 @@ var _ = Describe("Service binding", func() {
 					userExists, _ := dsiAdminClient.UserExists(ctx,
 						serviceBindingData[DbAdminUsernameKey])
 					return userExists
-				}, framework.AsyncOpsTimeoutMins, 1*time.Second).
+				}, framework.AsyncOpsTimeout(), framework.PollingPeriod()).
 					Should(BeFalse(),
 						fmt.Sprintf("timeout reached waiting for deletion of the SB user for DSI %s/%s",
 							instance.GetNamespace(),
@@ -196,7 +147,7 @@ var _ = Describe("Service binding", func() {
 						testingNamespace)
 
 					return s
-				}, framework.AsyncOpsTimeoutMins, 1*time.Second).
+				}, framework.AsyncOpsTimeout(), framework.PollingPeriod()).
 					Should(Equal(v1.Secret{}),
 						"timeout reached waiting for deletion of the SB secret")
 			})
@@ -204,55 +155,7 @@ var _ = Describe("Service binding", func() {
 	})
 
 	Context("Multiple ServiceBindings for a single DSI", func() {
-		BeforeEach(func() {
-			// Create Dataservice instance and wait for instance readiness
-			instance, err = dsi.New(
-				dataservice,
-				testingNamespace,
-				framework.GenerateName(instanceNamePrefix,
-					GinkgoParallelProcess(),
-					suffixLength),
-				replicas,
-			)
-			Expect(err).To(BeNil(), "failed to generate DSI object")
-
-			Expect(k8sClient.Create(ctx, instance.GetClientObject())).
-				To(Succeed(), "failed to create DSI")
-
-			dsi.WaitForReadiness(ctx, instance.GetClientObject(), k8sClient)
-
-			// Portforward to access DSI from outside cluster.
-			portForwardStopCh, localPort, err = framework.PortForward(
-				ctx, instancePort, kubeconfigPath, instance, k8sClient)
-			Expect(err).To(BeNil(),
-				fmt.Sprintf("failed to establish portforward to DSI %s/%s",
-					instance.GetNamespace(),
-					instance.GetName()))
-
-			adminSecret, err := secret.AdminSecretData(ctx,
-				k8sClient,
-				instance.GetName(),
-				testingNamespace)
-			Expect(err).To(BeNil(),
-				fmt.Sprintf("failed to parse secret data of admin credentials for DSI %s/%s",
-					instance.GetNamespace(),
-					instance.GetName()))
-
-			// Create DSIClient for interacting with the new DSI.
-			dsiAdminClient, err = dsi.NewClient(dataservice,
-				strconv.Itoa(localPort),
-				adminSecret)
-
-			Expect(err).To(BeNil(), "failed to create DSI client")
-		})
-
-		AfterEach(func() {
-			defer func() { close(portForwardStopCh) }()
-			Expect(k8sClient.Delete(ctx, instance.GetClientObject())).To(Succeed(),
-				fmt.Sprintf("failed to delete DSI %s/%s",
-					instance.GetNamespace(),
-					instance.GetName()))
-		})
+		BeforeEach(provisionDSI)
 
 		It("Performs basic ServiceBinding lifecycle operations", func() {
 			sbs := make([]*sbv1beta3.ServiceBinding, sbAmount)
@@ -382,7 +285,7 @@ var _ = Describe("Service binding", func() {
 							sbData[DbAdminUsernameKey])
 
 						return userExists
-					}, framework.AsyncOpsTimeoutMins, 1*time.Second).
+					}, framework.AsyncOpsTimeout(), framework.PollingPeriod()).
 						Should(BeFalse(),
 							fmt.Sprintf("timeout reached waiting for deletion of the SB user for DSI %s/%s",
 								instance.GetNamespace(),
@@ -399,7 +302,7 @@ var _ = Describe("Service binding", func() {
 							testingNamespace)
 
 						return s
-					}, framework.AsyncOpsTimeoutMins, 1*time.Second).
+					}, framework.AsyncOpsTimeout(), framework.PollingPeriod()).
 						Should(Equal(v1.Secret{}),
 							"timeout reached waiting for deletion of the SB secret")
 				}
@@ -439,7 +342,7 @@ var _ = Describe("Service binding", func() {
 
 				// Portforward to access DSI from outside cluster.
 				portForwardStopCh, localPort, err = framework.PortForward(
-					ctx, instancePort, kubeconfigPath, instance, k8sClient)
+					ctx, instancePort, restConfig, instance, k8sClient)
 				Expect(err).To(BeNil(),
 					fmt.Sprintf("failed to establish portforward to DSI %s/%s",
 						instance.GetNamespace(),
@@ -612,7 +515,7 @@ var _ = Describe("Service binding", func() {
 							userExists, _ := dsiAdminClient.UserExists(ctx,
 								sbData[DbAdminUsernameKey])
 							return userExists
-						}, framework.AsyncOpsTimeoutMins, 1*time.Second).
+						}, framework.AsyncOpsTimeout(), framework.PollingPeriod()).
 							Should(BeFalse(),
 								fmt.Sprintf("timeout reached waiting for deletion of the SB user for DSI %s/%s",
 									instance.GetNamespace(),
@@ -629,7 +532,7 @@ var _ = Describe("Service binding", func() {
 								testingNamespace)
 
 							return s
-						}, framework.AsyncOpsTimeoutMins, 1*time.Second).
+						}, framework.AsyncOpsTimeout(), framework.PollingPeriod()).
 							Should(Equal(v1.Secret{}),
 								"timeout reached waiting for deletion of the SB secret")
 					}
@@ -638,3 +541,19 @@ var _ = Describe("Service binding", func() {
 		})
 	})
 })
+
+// provisionDSI provisions a DSI without service bindings and a client that connects to it with
+// the admin credentials.
+func provisionDSI() {
+	testDSI := fixture.NewDSI(ctx, fixture.Options{
+		Client:                k8sClient,
+		RESTConfig:            restConfig,
+		DataService:           dataservice,
+		Namespace:             testingNamespace,
+		NamePrefix:            instanceNamePrefix,
+		Replicas:              replicas,
+		Port:                  instancePort,
+		WithoutServiceBinding: true,
+	})
+	instance, localPort, dsiAdminClient = testDSI.Instance, testDSI.LocalPort, testDSI.Client
+}
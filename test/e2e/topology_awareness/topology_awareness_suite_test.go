@@ -7,12 +7,15 @@ import (
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"k8s.io/client-go/rest"
 	runtimeClient "sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/anynines/a8s-deployment/test/framework"
 	"github.com/anynines/a8s-deployment/test/framework/dsi"
 	"github.com/anynines/a8s-deployment/test/framework/namespace"
 	"github.com/anynines/a8s-deployment/test/framework/node"
+	// Register the data services the suite can be run against.
+	_ "github.com/anynines/a8s-deployment/test/framework/postgresql"
 )
 
 var (
@@ -20,8 +23,11 @@ var (
 	cancel                                                            context.CancelFunc
 	testingNamespace, kubeconfigPath, dataservice, instanceNamePrefix string
 
-	k8sClient runtimeClient.Client
-	nodes     NodesClient
+	// restConfig is the config of the API server that k8sClient, nodes and the port forwards
+	// share.
+	restConfig *rest.Config
+	k8sClient  runtimeClient.Client
+	nodes      NodesClient
 )
 
 const minNbrWorkerNodes = 3
@@ -40,8 +46,11 @@ var _ = BeforeSuite(func() {
 	kubeconfigPath, instanceNamePrefix, dataservice, testingNamespace =
 		framework.ConfigToVars(config)
 
+	restConfig, err = framework.RESTConfig(kubeconfigPath)
+	Expect(err).To(BeNil(), "failed to build the config of the Kubernetes API server")
+
 	// Generate a convenience object for dealing with K8s cluster nodes
-	nodes, err = node.NewClientFromKubecfg(kubeconfigPath)
+	nodes, err = node.NewClientForConfig(restConfig)
 	Expect(err).To(BeNil())
 
 	// This test suite requires a minimum number of K8s worker nodes to run, so we verify this
@@ -49,7 +58,7 @@ var _ = BeforeSuite(func() {
 	Expect(verifyK8SClusterHasEnoughWorkerNodes(nodes, minNbrWorkerNodes)).To(Succeed())
 
 	// Create Kubernetes client for interacting with the Kubernetes API
-	k8sClient, err = dsi.NewK8sClient(dataservice, kubeconfigPath)
+	k8sClient, err = dsi.NewK8sClientForConfig(dataservice, restConfig)
 	Expect(err).To(BeNil(),
 		fmt.Sprintf("error creating Kubernetes client for dataservice %s", dataservice))
 
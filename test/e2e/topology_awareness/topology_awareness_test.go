@@ -160,7 +160,7 @@ var _ = Describe("DSIs topology awareness", func() {
 
 					// Create a portforwarding to write to the DSI from out of cluster
 					portForwardStopCh, localPort, err = framework.PortForward(
-						ctx, instancePort, kubeconfigPath, instance, k8sClient)
+						ctx, instancePort, restConfig, instance, k8sClient)
 					Expect(err).To(BeNil(), "failed to establish portforward to DSI "+instanceNSN)
 
 					// Generate a client to the DSI using the credentials and the portforwarding
@@ -239,7 +239,7 @@ var _ = Describe("DSIs topology awareness", func() {
 
 					// Create a portforwarding to write to the DSI from out of cluster
 					portForwardStopCh, localPort, err = framework.PortForward(
-						ctx, instancePort, kubeconfigPath, instance, k8sClient)
+						ctx, instancePort, restConfig, instance, k8sClient)
 					Expect(err).To(BeNil(), "failed to establish portforward to DSI "+instanceNSN)
 
 					// Generate a client to the DSI using the credentials and the portforwarding
@@ -352,7 +352,7 @@ var _ = Describe("DSIs topology awareness", func() {
 
 					// Create a portforwarding to write to the DSI from out of cluster
 					portForwardStopCh, localPort, err = framework.PortForward(
-						ctx, instancePort, kubeconfigPath, instance, k8sClient)
+						ctx, instancePort, restConfig, instance, k8sClient)
 					Expect(err).To(BeNil(), "failed to establish portforward to DSI "+instanceNSN)
 
 					// Generate a client to the DSI using the credentials and the portforwarding
@@ -431,7 +431,7 @@ var _ = Describe("DSIs topology awareness", func() {
 
 					// Create a portforwarding to write to the DSI from out of cluster
 					portForwardStopCh, localPort, err = framework.PortForward(
-						ctx, instancePort, kubeconfigPath, instance, k8sClient)
+						ctx, instancePort, restConfig, instance, k8sClient)
 					Expect(err).To(BeNil(), "failed to establish portforward to DSI "+instanceNSN)
 
 					// Generate a client to the DSI using the credentials and the portforwarding
@@ -571,7 +571,7 @@ var _ = Describe("DSIs topology awareness", func() {
 
 					// Create a portforwarding to write to the DSI from out of cluster
 					portForwardStopCh, localPort, err = framework.PortForward(
-						ctx, instancePort, kubeconfigPath, instance, k8sClient)
+						ctx, instancePort, restConfig, instance, k8sClient)
 					Expect(err).To(BeNil(), "failed to establish portforward to DSI "+instanceNSN)
 
 					// Generate a client to the DSI using the credentials and the portforwarding
@@ -703,7 +703,7 @@ var _ = Describe("DSIs topology awareness", func() {
 
 					// Create a portforwarding to write to the DSI from out of cluster
 					portForwardStopCh, localPort, err = framework.PortForward(
-						ctx, instancePort, kubeconfigPath, instance, k8sClient)
+						ctx, instancePort, restConfig, instance, k8sClient)
 					Expect(err).To(BeNil(), "failed to establish portforward to DSI "+instanceNSN)
 
 					// Generate a client to the DSI using the credentials and the portforwarding
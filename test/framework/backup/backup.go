@@ -6,24 +6,16 @@ import (
 	"time"
 
 	. "github.com/onsi/gomega"
-	"k8s.io/apimachinery/pkg/api/errors"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/types"
 	runtimeClient "sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/anynines/a8s-backup-manager/api/v1beta3"
 	"github.com/anynines/a8s-deployment/test/framework"
+	"github.com/anynines/a8s-deployment/test/framework/timeline"
+	"github.com/anynines/a8s-deployment/test/framework/wait"
 )
 
-const (
-	// asyncOpsTimeoutMins is the amount of minutes after which assertions fail if the condition
-	// they check has not become true. Needed because some conditions might become true only
-	// after some time, so we need to check them asynchronously.
-	// TODO: Make asyncOpsTimeoutMins an invocation parameter.
-	asyncOpsTimeoutMins = time.Minute * 5
-	suffixLength        = 6
-	pollingPeriod       = 1 * time.Second
-)
+const suffixLength = 6
 
 // Option represents a functional option for backup objects. To learn what a functional option is,
 // read here: https://dave.cheney.net/2014/10/17/functional-options-for-friendly-apis
@@ -62,57 +54,80 @@ func backupPrefix(dsiName string) string {
 	return fmt.Sprintf("%s-backup", dsiName)
 }
 
-// WaitForReadiness waits for the backup object status condition of type "Complete" to indicate
-// true.
+// AwaitReadiness waits for the backup object status condition of type "Complete" to indicate
+// true. It returns a *wait.TimeoutError carrying the last observed conditions if that doesn't
+// happen within timeout.
+func AwaitReadiness(ctx context.Context, backup *v1beta3.Backup, timeout time.Duration,
+	c runtimeClient.Client,
+) error {
+	return wait.ForObject(ctx, c, target(backup), timeout, framework.PollingPeriod(),
+		fmt.Sprintf("backup %s/%s readiness", backup.GetNamespace(), backup.GetName()),
+		func(o runtimeClient.Object) (bool, any, error) {
+			backupCreated, ok := o.(*v1beta3.Backup)
+			if !ok {
+				return false, "not found", nil
+			}
+			return conditionTrue(backupCreated.Status.Conditions, "Complete"),
+				backupCreated.Status.Conditions, nil
+		},
+	)
+}
+
+// WaitForReadiness is the Gomega adapter of AwaitReadiness.
 func WaitForReadiness(ctx context.Context, backup *v1beta3.Backup, timeoutMins time.Duration,
 	c runtimeClient.Client,
 ) {
-	var err error
-	EventuallyWithOffset(1, func() bool {
-		backupCreated := New()
-		if err = c.Get(
-			ctx,
-			types.NamespacedName{
-				Name:      backup.GetName(),
-				Namespace: backup.GetNamespace(),
-			},
-			backupCreated,
-		); err != nil {
-			return false
-		}
+	ExpectWithOffset(1, AwaitReadiness(ctx, backup, timeoutMins, c)).To(Succeed())
+}
 
-		for _, c := range backupCreated.Status.Conditions {
-			if c.Type == "Complete" && c.Status == v1.ConditionTrue {
-				return true
-			}
-		}
-		return false
-	}, timeoutMins, pollingPeriod).Should(BeTrue(),
-		fmt.Sprintf("timeout reached waiting for backup %s/%s readiness: %s",
-			backup.GetNamespace(),
-			backup.GetName(),
-			err,
-		),
+// AwaitDeletion waits for the backup object to be deleted from the API server.
+func AwaitDeletion(ctx context.Context, backup *v1beta3.Backup, c runtimeClient.Client) error {
+	return wait.ForObject(ctx, c, target(backup), framework.AsyncOpsTimeout(),
+		framework.PollingPeriod(),
+		fmt.Sprintf("backup %s/%s deletion", backup.GetNamespace(), backup.GetName()),
+		func(o runtimeClient.Object) (bool, any, error) {
+			return o == nil, nil, nil
+		},
 	)
 }
 
-// WaitForDeletion waits for the backup object to be deleted from the API server.
+// WaitForDeletion is the Gomega adapter of AwaitDeletion.
 func WaitForDeletion(ctx context.Context, backup *v1beta3.Backup, c runtimeClient.Client) {
-	var err error
-	EventuallyWithOffset(1, func() bool {
-		b := New()
-		err = c.Get(
-			ctx,
-			types.NamespacedName{
-				Name:      backup.GetName(),
-				Namespace: backup.GetNamespace(),
-			}, b)
-		return errors.IsNotFound(err)
-	}, asyncOpsTimeoutMins, pollingPeriod).Should(BeTrue(),
-		fmt.Sprintf("timeout reached waiting for backup %s/%s deletion: %s",
-			backup.GetNamespace(),
-			backup.GetName(),
-			err,
-		),
-	)
+	ExpectWithOffset(1, AwaitDeletion(ctx, backup, c)).To(Succeed())
+}
+
+func conditionTrue(conditions []v1.Condition, conditionType string) bool {
+	for _, c := range conditions {
+		if c.Type == conditionType && c.Status == v1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
+// target returns an empty backup with the name and namespace of backup, to be waited on.
+func target(backup *v1beta3.Backup) *v1beta3.Backup {
+	b := New()
+	b.Name, b.Namespace = backup.GetName(), backup.GetNamespace()
+	return b
+}
+
+// TimelineSource returns the source to record the conditions of the backups of dsi in a timeline.
+func TimelineSource(dsi runtimeClient.Object) timeline.Source {
+	return timeline.Source{
+		Kind:        "Backup",
+		NewList:     func() runtimeClient.ObjectList { return &v1beta3.BackupList{} },
+		ListOptions: []runtimeClient.ListOption{runtimeClient.InNamespace(dsi.GetNamespace())},
+		Filter: func(o runtimeClient.Object) bool {
+			b, ok := o.(*v1beta3.Backup)
+			return ok && b.Spec.ServiceInstance.Name == dsi.GetName()
+		},
+		State: func(o runtimeClient.Object) timeline.State {
+			b, ok := o.(*v1beta3.Backup)
+			if !ok {
+				return nil
+			}
+			return timeline.ConditionsState(b.Status.Conditions)
+		},
+	}
 }
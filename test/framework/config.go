@@ -0,0 +1,143 @@
+package framework
+
+import (
+	"sync"
+	"time"
+
+	"github.com/anynines/a8s-deployment/test/framework/wait"
+)
+
+const (
+	defaultAsyncOpsTimeout = time.Minute * 5
+	defaultBackupTimeout   = time.Minute * 10
+	defaultPollingPeriod   = 1 * time.Second
+
+	defaultPostgreSQLVersion    = 14
+	defaultPostgreSQLCPU        = "500m"
+	defaultPostgreSQLMemory     = "500Mi"
+	defaultPostgreSQLVolumeSize = "1G"
+
+	defaultChaosInjectionTimeout = time.Minute * 5
+	defaultChaosFailureDuration  = 10 * time.Second
+	defaultChaosS3PollingPeriod  = 100 * time.Millisecond
+)
+
+var (
+	activeConfigMu sync.RWMutex
+	activeConfig   = DefaultConfig()
+)
+
+// TestRunConfig is the configuration of a test run. It's built by ParseEnv from (in increasing
+// order of precedence) the defaults returned by DefaultConfig, an optional YAML config file, the
+// environment variables and the command line flags. The YAML keys are the ones in the struct tags.
+type TestRunConfig struct {
+	// KubeconfigPath is the path to the kube config to be used by the Kubernetes client
+	KubeconfigPath string `yaml:"kubeconfigPath"`
+	// Dataservice is the dataservice the tests are to be performed on
+	Dataservice string `yaml:"dataservice"`
+	// DSINamePrefix provides a name that the DSI object will take in the cluster
+	DSINamePrefix string `yaml:"dsiNamePrefix"`
+	// Namespace provides the target namespace to be used for testing. If not given then a
+	// unique namespace is created.
+	Namespace string `yaml:"namespace"`
+
+	Timeouts   TimeoutsConfig   `yaml:"timeouts"`
+	PostgreSQL PostgreSQLConfig `yaml:"postgresql"`
+	Chaos      ChaosConfig      `yaml:"chaos"`
+}
+
+// TimeoutsConfig groups the durations used by the framework when waiting for asynchronous
+// operations. Durations are written in the format accepted by time.ParseDuration (e.g. "5m").
+type TimeoutsConfig struct {
+	// AsyncOps is the time after which assertions fail if the condition they check has not become
+	// true. Needed because some conditions might become true only after some time, so we need to
+	// check them asynchronously.
+	AsyncOps time.Duration `yaml:"asyncOps"`
+	// Backup is the time after which assertions fail waiting for a backup to complete.
+	Backup time.Duration `yaml:"backup"`
+	// PollingPeriod is the interval between two checks of an asynchronous condition.
+	PollingPeriod time.Duration `yaml:"pollingPeriod"`
+	// MaxWatches is the maximum number of watches the framework keeps open at the same time to
+	// wait for status transitions. Waits beyond it poll instead, 0 disables watches.
+	MaxWatches int `yaml:"maxWatches"`
+}
+
+// PostgreSQLConfig holds the defaults used when creating PostgreSQL instances.
+type PostgreSQLConfig struct {
+	// Version is the PostgreSQL major version of the instances.
+	Version int `yaml:"version"`
+	// CPU is the CPU request and limit of each PostgreSQL container, as a Kubernetes quantity.
+	CPU string `yaml:"cpu"`
+	// Memory is the memory request and limit of each PostgreSQL container, as a Kubernetes
+	// quantity.
+	Memory string `yaml:"memory"`
+	// VolumeSize is the size of the persistent volume of each replica, as a Kubernetes quantity.
+	VolumeSize string `yaml:"volumeSize"`
+}
+
+// ChaosConfig holds the settings of the chaos tests.
+type ChaosConfig struct {
+	// InjectionTimeout is the time Chaos Mesh is given to apply a chaos object.
+	InjectionTimeout time.Duration `yaml:"injectionTimeout"`
+	// FailureDuration is how long an injected failure is kept in place before it is removed.
+	FailureDuration time.Duration `yaml:"failureDuration"`
+	// S3PollingPeriod is the interval between two checks of the backup data stored in S3.
+	S3PollingPeriod time.Duration `yaml:"s3PollingPeriod"`
+}
+
+// DefaultConfig returns the configuration values used for every setting that is not explicitly
+// configured.
+func DefaultConfig() TestRunConfig {
+	return TestRunConfig{
+		Timeouts: TimeoutsConfig{
+			AsyncOps:      defaultAsyncOpsTimeout,
+			Backup:        defaultBackupTimeout,
+			PollingPeriod: defaultPollingPeriod,
+			MaxWatches:    wait.DefaultMaxWatches,
+		},
+		PostgreSQL: PostgreSQLConfig{
+			Version:    defaultPostgreSQLVersion,
+			CPU:        defaultPostgreSQLCPU,
+			Memory:     defaultPostgreSQLMemory,
+			VolumeSize: defaultPostgreSQLVolumeSize,
+		},
+		Chaos: ChaosConfig{
+			InjectionTimeout: defaultChaosInjectionTimeout,
+			FailureDuration:  defaultChaosFailureDuration,
+			S3PollingPeriod:  defaultChaosS3PollingPeriod,
+		},
+	}
+}
+
+// ActiveConfig returns the configuration of the current test run, that is the one most recently
+// stored via SetActiveConfig (ParseEnv does that on success), or DefaultConfig if there's none.
+// Framework packages read their timeouts and defaults from it.
+func ActiveConfig() TestRunConfig {
+	activeConfigMu.RLock()
+	defer activeConfigMu.RUnlock()
+	return activeConfig
+}
+
+// SetActiveConfig makes c the configuration returned by ActiveConfig and applies its process-wide
+// settings (e.g. the maximum number of watches).
+func SetActiveConfig(c TestRunConfig) {
+	activeConfigMu.Lock()
+	defer activeConfigMu.Unlock()
+	activeConfig = c
+	wait.SetMaxWatches(c.Timeouts.MaxWatches)
+}
+
+// AsyncOpsTimeout returns the timeout for asynchronous operations of the active configuration.
+func AsyncOpsTimeout() time.Duration {
+	return ActiveConfig().Timeouts.AsyncOps
+}
+
+// BackupTimeout returns the timeout for backups of the active configuration.
+func BackupTimeout() time.Duration {
+	return ActiveConfig().Timeouts.Backup
+}
+
+// PollingPeriod returns the polling period of the active configuration.
+func PollingPeriod() time.Duration {
+	return ActiveConfig().Timeouts.PollingPeriod
+}
@@ -0,0 +1,187 @@
+// Package dataset provides data sets of typed records that tests write to DSIs and compare with
+// what they read back, e.g. after a failover or a restore.
+package dataset
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxReportedRecords is the maximum number of records of each kind of difference that Difference
+// reports, so that comparing large data sets doesn't produce huge messages.
+const maxReportedRecords = 10
+
+// Record is a record of a data set. ID identifies it within its entity.
+type Record struct {
+	ID     int64
+	Name   string
+	Amount int64
+	Active bool
+	// CreatedAt has microsecond precision and is in UTC, so that it's preserved by the data
+	// services.
+	CreatedAt time.Time
+}
+
+func (r Record) String() string {
+	return fmt.Sprintf("{id=%d name=%q amount=%d active=%t createdAt=%s}", r.ID, r.Name,
+		r.Amount, r.Active, r.CreatedAt.Format(time.RFC3339Nano))
+}
+
+// DataSet is a set of records of an entity of a DSI, e.g. a table of a PostgreSQL database.
+type DataSet struct {
+	Entity  string
+	Records []Record
+}
+
+// Generate returns a data set of n records for entity. The records are random, but the same seed
+// always produces the same records, so that a failing test can be reproduced. Their IDs are 1 to
+// n.
+func Generate(entity string, n int, seed int64) DataSet {
+	const nameChars = "abcdefghijklmnopqrstuvwxyz0123456789"
+	epoch := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	r := rand.New(rand.NewSource(seed))
+	ds := DataSet{Entity: entity, Records: make([]Record, 0, n)}
+	for id := int64(1); id <= int64(n); id++ {
+		name := make([]byte, 8+r.Intn(24))
+		for i := range name {
+			name[i] = nameChars[r.Intn(len(nameChars))]
+		}
+		amount := r.Int63n(2_000_000) - 1_000_000
+		active := r.Intn(2) == 0
+		createdAt := epoch.Add(time.Duration(r.Int63n(int64(365 * 24 * time.Hour))))
+		ds.Records = append(ds.Records, Record{
+			ID:        id,
+			Name:      string(name),
+			Amount:    amount,
+			Active:    active,
+			CreatedAt: createdAt.Truncate(time.Microsecond),
+		})
+	}
+	return ds
+}
+
+// Checksum returns a checksum of the records of d that doesn't depend on their order.
+func (d DataSet) Checksum() string {
+	h := sha256.New()
+	for _, r := range d.sorted() {
+		// Names are quoted so that the fields of different records can't be confused.
+		fmt.Fprintf(h, "%d,%s,%d,%t,%d\n", r.ID, strconv.Quote(r.Name), r.Amount, r.Active,
+			r.CreatedAt.UnixMicro())
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Corruption is a record whose ID was found but whose fields differ from the expected ones.
+type Corruption struct {
+	Want, Got Record
+}
+
+// Difference describes how a data set differs from the expected one.
+type Difference struct {
+	Entity string
+	// Missing are the expected records whose IDs weren't found.
+	Missing []Record
+	// Unexpected are the records found whose IDs weren't expected.
+	Unexpected []Record
+	// Corrupted are the records found whose fields differ from the expected ones.
+	Corrupted []Corruption
+	// Duplicated are the IDs found more than once.
+	Duplicated []int64
+}
+
+// Compare returns a *Difference if got doesn't have the same records as want, regardless of their
+// order, or nil otherwise.
+func Compare(want, got DataSet) error {
+	d := Diff(want, got)
+	if d.Empty() {
+		return nil
+	}
+	return d
+}
+
+// Diff returns how got differs from want, regardless of the order of their records.
+func Diff(want, got DataSet) *Difference {
+	d := &Difference{Entity: want.Entity}
+
+	found := make(map[int64]Record, len(got.Records))
+	for _, r := range got.sorted() {
+		if _, ok := found[r.ID]; ok {
+			if len(d.Duplicated) == 0 || d.Duplicated[len(d.Duplicated)-1] != r.ID {
+				d.Duplicated = append(d.Duplicated, r.ID)
+			}
+			continue
+		}
+		found[r.ID] = r
+	}
+
+	for _, w := range want.sorted() {
+		g, ok := found[w.ID]
+		switch {
+		case !ok:
+			d.Missing = append(d.Missing, w)
+		case !g.equal(w):
+			d.Corrupted = append(d.Corrupted, Corruption{Want: w, Got: g})
+		}
+		delete(found, w.ID)
+	}
+
+	for _, r := range got.sorted() {
+		if _, ok := found[r.ID]; ok {
+			d.Unexpected = append(d.Unexpected, r)
+			delete(found, r.ID)
+		}
+	}
+	return d
+}
+
+// Empty returns true if the data sets compared have the same records.
+func (d *Difference) Empty() bool {
+	return len(d.Missing) == 0 && len(d.Unexpected) == 0 && len(d.Corrupted) == 0 &&
+		len(d.Duplicated) == 0
+}
+
+// Error reports the number of missing, unexpected, corrupted and duplicated records, and the first
+// few of each.
+func (d *Difference) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "data set of entity %s differs from the expected one: %d missing, "+
+		"%d unexpected, %d corrupted and %d duplicated records", d.Entity, len(d.Missing),
+		len(d.Unexpected), len(d.Corrupted), len(d.Duplicated))
+
+	report := func(kind string, n int, record func(i int) string) {
+		for i := 0; i < n && i < maxReportedRecords; i++ {
+			fmt.Fprintf(&b, "\n%s: %s", kind, record(i))
+		}
+		if n > maxReportedRecords {
+			fmt.Fprintf(&b, "\n... and %d more %s records", n-maxReportedRecords, kind)
+		}
+	}
+	report("missing", len(d.Missing), func(i int) string { return d.Missing[i].String() })
+	report("unexpected", len(d.Unexpected), func(i int) string { return d.Unexpected[i].String() })
+	report("corrupted", len(d.Corrupted), func(i int) string {
+		return fmt.Sprintf("want %s, got %s", d.Corrupted[i].Want, d.Corrupted[i].Got)
+	})
+	report("duplicated", len(d.Duplicated), func(i int) string {
+		return fmt.Sprintf("id=%d", d.Duplicated[i])
+	})
+	return b.String()
+}
+
+// sorted returns the records of d sorted by ID, without modifying d.
+func (d DataSet) sorted() []Record {
+	records := append([]Record(nil), d.Records...)
+	sort.SliceStable(records, func(i, j int) bool { return records[i].ID < records[j].ID })
+	return records
+}
+
+func (r Record) equal(other Record) bool {
+	return r.ID == other.ID && r.Name == other.Name && r.Amount == other.Amount &&
+		r.Active == other.Active && r.CreatedAt.Equal(other.CreatedAt)
+}
@@ -0,0 +1,175 @@
+package dataset_test
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anynines/a8s-deployment/test/framework/dataset"
+)
+
+func TestGenerateIsDeterministic(t *testing.T) {
+	t.Parallel()
+
+	ds := dataset.Generate("entity", 100, 42)
+	if len(ds.Records) != 100 || ds.Entity != "entity" {
+		t.Fatalf("Expected 100 records of entity \"entity\", got %d of entity %q",
+			len(ds.Records), ds.Entity)
+	}
+	for i, r := range ds.Records {
+		if r.ID != int64(i+1) {
+			t.Fatalf("Expected record %d to have ID %d, got %d", i, i+1, r.ID)
+		}
+		if r.CreatedAt.Location() != time.UTC || r.CreatedAt.Nanosecond()%1000 != 0 {
+			t.Fatalf("Expected creation time in UTC with microsecond precision, got %s",
+				r.CreatedAt)
+		}
+	}
+
+	if again := dataset.Generate("entity", 100, 42); !reflect.DeepEqual(ds, again) {
+		t.Fatalf("Expected the same seed to generate the same records")
+	}
+	if other := dataset.Generate("entity", 100, 43); other.Checksum() == ds.Checksum() {
+		t.Fatalf("Expected different seeds to generate different records")
+	}
+}
+
+func TestChecksumDoesNotDependOnOrder(t *testing.T) {
+	t.Parallel()
+
+	ds := dataset.Generate("entity", 10, 1)
+	reversed := dataset.DataSet{Entity: ds.Entity}
+	for i := len(ds.Records) - 1; i >= 0; i-- {
+		reversed.Records = append(reversed.Records, ds.Records[i])
+	}
+	if ds.Checksum() != reversed.Checksum() {
+		t.Fatalf("Expected equal checksums for the same records in different order")
+	}
+
+	changed := dataset.Generate("entity", 10, 1)
+	changed.Records[3].Amount++
+	if ds.Checksum() == changed.Checksum() {
+		t.Fatalf("Expected different checksums for different records")
+	}
+}
+
+func TestCompare(t *testing.T) {
+	t.Parallel()
+
+	want := dataset.Generate("entity", 20, 7)
+
+	testCases := map[string]struct {
+		got func() dataset.DataSet
+		// wantErr are substrings of the error, or nil if no error is expected.
+		wantErr        []string
+		wantMissing    []int64
+		wantUnexpected []int64
+		wantCorrupted  []int64
+		wantDuplicated []int64
+	}{
+		"same_records_in_different_order": {
+			got: func() dataset.DataSet {
+				got := dataset.Generate("entity", 20, 7)
+				got.Records[0], got.Records[19] = got.Records[19], got.Records[0]
+				return got
+			},
+		},
+		"same_creation_times_in_different_locations": {
+			got: func() dataset.DataSet {
+				got := dataset.Generate("entity", 20, 7)
+				for i := range got.Records {
+					got.Records[i].CreatedAt = got.Records[i].CreatedAt.Local()
+				}
+				return got
+			},
+		},
+		"missing_unexpected_corrupted_and_duplicated_records": {
+			got: func() dataset.DataSet {
+				got := dataset.Generate("entity", 20, 7)
+				got.Records[4].Name = "corrupted"
+				got.Records = append(got.Records[:9], got.Records[10:]...)
+				got.Records = append(got.Records,
+					dataset.Record{ID: 21},
+					got.Records[0],
+				)
+				return got
+			},
+			wantErr: []string{
+				"entity", "1 missing", "1 unexpected", "1 corrupted", "1 duplicated",
+				`name="corrupted"`,
+			},
+			wantMissing:    []int64{10},
+			wantUnexpected: []int64{21},
+			wantCorrupted:  []int64{5},
+			wantDuplicated: []int64{1},
+		},
+		"report_is_truncated": {
+			got: func() dataset.DataSet { return dataset.DataSet{Entity: "entity"} },
+			wantErr: []string{
+				"20 missing", "... and 10 more missing records",
+			},
+			wantMissing: []int64{
+				1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20,
+			},
+		},
+	}
+
+	for tcName, tc := range testCases {
+		// Rebind tc into this lexical scope. Details on the why at
+		// https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		tc := tc
+		t.Run(tcName, func(t *testing.T) {
+			t.Parallel()
+
+			got := tc.got()
+			err := dataset.Compare(want, got)
+			if tc.wantErr == nil {
+				if err != nil {
+					t.Fatalf("Expected no error, got: \"%v\"", err)
+				}
+				return
+			}
+
+			var diff *dataset.Difference
+			if !errors.As(err, &diff) {
+				t.Fatalf("Expected a *dataset.Difference, got: \"%v\"", err)
+			}
+			for _, s := range tc.wantErr {
+				if !strings.Contains(err.Error(), s) {
+					t.Fatalf("Expected error to contain %q, got: \"%v\"", s, err)
+				}
+			}
+			if ids := recordIDs(diff.Missing); !equalIDs(ids, tc.wantMissing) {
+				t.Fatalf("Expected missing records %v, got %v", tc.wantMissing, ids)
+			}
+			if ids := recordIDs(diff.Unexpected); !equalIDs(ids, tc.wantUnexpected) {
+				t.Fatalf("Expected unexpected records %v, got %v", tc.wantUnexpected, ids)
+			}
+			corrupted := []int64{}
+			for _, c := range diff.Corrupted {
+				corrupted = append(corrupted, c.Want.ID)
+			}
+			if !equalIDs(corrupted, tc.wantCorrupted) {
+				t.Fatalf("Expected corrupted records %v, got %v", tc.wantCorrupted, corrupted)
+			}
+			if !equalIDs(diff.Duplicated, tc.wantDuplicated) {
+				t.Fatalf("Expected duplicated records %v, got %v", tc.wantDuplicated,
+					diff.Duplicated)
+			}
+		})
+	}
+}
+
+func recordIDs(records []dataset.Record) []int64 {
+	ids := []int64{}
+	for _, r := range records {
+		ids = append(ids, r.ID)
+	}
+	return ids
+}
+
+func equalIDs(got, want []int64) bool {
+	return len(got) == len(want) && (len(got) == 0 || reflect.DeepEqual(got, want))
+}
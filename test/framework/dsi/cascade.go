@@ -0,0 +1,173 @@
+package dsi
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	runtimeClient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+
+	"github.com/anynines/a8s-deployment/test/framework"
+	"github.com/anynines/a8s-deployment/test/framework/wait"
+)
+
+// SecondaryObjects are the objects that the operator of a DSI creates for it, and that must be
+// deleted together with it.
+type SecondaryObjects struct {
+	// Named are the secondary objects whose names are known in advance. Only their type,
+	// namespace and name are used.
+	Named []runtimeClient.Object
+	// Labeled are empty lists of the types of the secondary objects that are found via Labels in
+	// the namespace of the DSI, e.g. because their names aren't known in advance.
+	Labeled []runtimeClient.ObjectList
+	// Labels select the Labeled secondary objects. Labeled is ignored if Labels is empty.
+	Labels map[string]string
+}
+
+// SecondaryObjectsGetter is implemented by DSIs that know which secondary objects their operator
+// creates for them.
+type SecondaryObjectsGetter interface {
+	SecondaryObjects() SecondaryObjects
+}
+
+// LeftBehindError is returned when secondary objects of a DSI still exist after waiting for their
+// deletion.
+type LeftBehindError struct {
+	// Instance is the namespace and name of the DSI.
+	Instance string
+	// Objects describes the secondary objects that still exist, e.g. "StatefulSet ns/pg".
+	Objects []string
+	// Err is the error that ended the wait, a *wait.TimeoutError if the timeout expired.
+	Err error
+}
+
+func (e *LeftBehindError) Error() string {
+	return fmt.Sprintf("%d secondary objects of DSI %s left behind: [%s]: %v", len(e.Objects),
+		e.Instance, strings.Join(e.Objects, ", "), e.Err)
+}
+
+func (e *LeftBehindError) Unwrap() error {
+	return e.Err
+}
+
+// SecondaryObjectsLeft returns the descriptions of the secondary objects of instance that still
+// exist. instance must implement SecondaryObjectsGetter.
+func SecondaryObjectsLeft(ctx context.Context, instance Object,
+	c runtimeClient.Client,
+) ([]string, error) {
+	secondary, err := secondaryObjectsOf(instance)
+	if err != nil {
+		return nil, err
+	}
+
+	left := []string{}
+	for _, obj := range secondary.Named {
+		err := c.Get(ctx, runtimeClient.ObjectKeyFromObject(obj), obj)
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get secondary object %s: %w",
+				describe(obj, c), err)
+		}
+		left = append(left, describe(obj, c))
+	}
+
+	// Without labels all the objects of the namespace would be selected.
+	if len(secondary.Labels) == 0 {
+		return left, nil
+	}
+	for _, list := range secondary.Labeled {
+		if err := c.List(ctx, list,
+			runtimeClient.InNamespace(instance.GetNamespace()),
+			runtimeClient.MatchingLabels(secondary.Labels),
+		); err != nil {
+			return nil, fmt.Errorf("failed to list secondary objects of type %T: %w", list, err)
+		}
+		items, err := meta.ExtractList(list)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list secondary objects of type %T: %w", list, err)
+		}
+		for _, item := range items {
+			if obj, ok := item.(runtimeClient.Object); ok {
+				left = appendNew(left, describe(obj, c))
+			}
+		}
+	}
+	return left, nil
+}
+
+// AwaitSecondaryObjectsDeletion waits until all the secondary objects of instance are deleted,
+// e.g. after the deletion of instance. If some are left behind it returns a *LeftBehindError that
+// lists them. instance must implement SecondaryObjectsGetter.
+func AwaitSecondaryObjectsDeletion(ctx context.Context, instance Object,
+	c runtimeClient.Client,
+) error {
+	if _, err := secondaryObjectsOf(instance); err != nil {
+		return err
+	}
+
+	var left []string
+	err := wait.Poll(ctx, framework.AsyncOpsTimeout(), framework.PollingPeriod(),
+		fmt.Sprintf("deletion of the secondary objects of instance %s/%s",
+			instance.GetNamespace(), instance.GetName()),
+		func(ctx context.Context) (bool, any, error) {
+			var err error
+			left, err = SecondaryObjectsLeft(ctx, instance, c)
+			return err == nil && len(left) == 0, left, err
+		},
+	)
+	if err != nil && len(left) > 0 {
+		return &LeftBehindError{
+			Instance: fmt.Sprintf("%s/%s", instance.GetNamespace(), instance.GetName()),
+			Objects:  left,
+			Err:      err,
+		}
+	}
+	return err
+}
+
+// WaitForSecondaryObjectsDeletion is the Gomega adapter of AwaitSecondaryObjectsDeletion.
+func WaitForSecondaryObjectsDeletion(ctx context.Context, instance Object,
+	c runtimeClient.Client,
+) {
+	ExpectWithOffset(1, AwaitSecondaryObjectsDeletion(ctx, instance, c)).To(Succeed())
+}
+
+func secondaryObjectsOf(instance Object) (SecondaryObjects, error) {
+	getter, ok := instance.(SecondaryObjectsGetter)
+	if !ok {
+		return SecondaryObjects{}, fmt.Errorf("DSI %s/%s of type %T doesn't implement "+
+			"SecondaryObjectsGetter", instance.GetNamespace(), instance.GetName(), instance)
+	}
+	return getter.SecondaryObjects(), nil
+}
+
+// describe returns the kind, namespace and name of obj, e.g. "StatefulSet ns/pg", and whether it's
+// being deleted.
+func describe(obj runtimeClient.Object, c runtimeClient.Client) string {
+	kind := fmt.Sprintf("%T", obj)
+	if gvk, err := apiutil.GVKForObject(obj, c.Scheme()); err == nil {
+		kind = gvk.Kind
+	}
+	d := fmt.Sprintf("%s %s/%s", kind, obj.GetNamespace(), obj.GetName())
+	if obj.GetDeletionTimestamp() != nil {
+		d += fmt.Sprintf(" (%s)", deletionState(obj))
+	}
+	return d
+}
+
+// appendNew appends s to ss unless ss already contains it, since an object can be both named and
+// labeled.
+func appendNew(ss []string, s string) []string {
+	for _, existing := range ss {
+		if existing == s {
+			return ss
+		}
+	}
+	return append(ss, s)
+}
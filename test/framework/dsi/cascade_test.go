@@ -0,0 +1,136 @@
+package dsi_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtimeClient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/anynines/a8s-deployment/test/framework/dsi"
+	"github.com/anynines/a8s-deployment/test/framework/wait"
+)
+
+var dsiLabels = map[string]string{"dsi": "i0"}
+
+func TestSecondaryObjectsLeft(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		existing []runtimeClient.Object
+		wantLeft []string
+	}{
+		"nothing_left": {
+			existing: []runtimeClient.Object{
+				newSecret("unrelated", nil),
+				newSecret("other-dsi-secret", map[string]string{"dsi": "i1"}),
+			},
+			wantLeft: []string{},
+		},
+		"named_and_labeled_objects_left": {
+			existing: []runtimeClient.Object{
+				newSecret("i0-credentials", nil),
+				newSecret("i0-generated-abcde", dsiLabels),
+			},
+			wantLeft: []string{"Secret ns0/i0-credentials", "Secret ns0/i0-generated-abcde"},
+		},
+		"object_both_named_and_labeled_is_reported_once": {
+			existing: []runtimeClient.Object{newSecret("i0-credentials", dsiLabels)},
+			wantLeft: []string{"Secret ns0/i0-credentials"},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			// Rebind tc into this lexical scope. Details on the why at
+			// https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+			tc := tc
+
+			t.Parallel()
+
+			c := fake.NewClientBuilder().WithObjects(tc.existing...).Build()
+			left, err := dsi.SecondaryObjectsLeft(context.Background(), newCascadingDSI(), c)
+			if err != nil {
+				t.Fatalf("Expected no error, got: \"%v\"", err)
+			}
+			if !reflect.DeepEqual(left, tc.wantLeft) {
+				t.Fatalf("Expected left behind objects %v, got %v", tc.wantLeft, left)
+			}
+		})
+	}
+}
+
+func TestAwaitSecondaryObjectsDeletionReportsLeftBehindObjects(t *testing.T) {
+	t.Parallel()
+
+	c := fake.NewClientBuilder().WithObjects(newSecret("i0-credentials", nil)).Build()
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	err := dsi.AwaitSecondaryObjectsDeletion(ctx, newCascadingDSI(), c)
+	var leftBehindErr *dsi.LeftBehindError
+	if !errors.As(err, &leftBehindErr) {
+		t.Fatalf("Expected *dsi.LeftBehindError, got: \"%v\"", err)
+	}
+	if want := []string{"Secret ns0/i0-credentials"}; !reflect.DeepEqual(leftBehindErr.Objects,
+		want) {
+		t.Fatalf("Expected left behind objects %v, got %v", want, leftBehindErr.Objects)
+	}
+	if !wait.IsTimeout(err) {
+		t.Fatalf("Expected error to wrap a *wait.TimeoutError, got: \"%v\"", err)
+	}
+}
+
+func TestAwaitSecondaryObjectsDeletionSucceedsWhenAllAreGone(t *testing.T) {
+	t.Parallel()
+
+	c := fake.NewClientBuilder().Build()
+	if err := dsi.AwaitSecondaryObjectsDeletion(context.Background(), newCascadingDSI(),
+		c); err != nil {
+		t.Fatalf("Expected no error, got: \"%v\"", err)
+	}
+}
+
+func TestAwaitSecondaryObjectsDeletionRequiresSecondaryObjectsGetter(t *testing.T) {
+	t.Parallel()
+
+	instance := stubDSI{&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns0", Name: "i0"},
+	}}
+	err := dsi.AwaitSecondaryObjectsDeletion(context.Background(), instance,
+		fake.NewClientBuilder().Build())
+	if err == nil || wait.IsTimeout(err) {
+		t.Fatalf("Expected an error about the missing secondary objects, got: \"%v\"", err)
+	}
+}
+
+// cascadingDSI is a stub DSI whose secondary objects are the secret i0-credentials and the
+// secrets with the labels dsiLabels.
+type cascadingDSI struct {
+	stubDSI
+}
+
+func newCascadingDSI() cascadingDSI {
+	return cascadingDSI{stubDSI{&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns0", Name: "i0"},
+	}}}
+}
+
+func (cascadingDSI) SecondaryObjects() dsi.SecondaryObjects {
+	return dsi.SecondaryObjects{
+		Named:   []runtimeClient.Object{newSecret("i0-credentials", nil)},
+		Labeled: []runtimeClient.ObjectList{&corev1.SecretList{}},
+		Labels:  dsiLabels,
+	}
+}
+
+func newSecret(name string, labels map[string]string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns0", Name: name, Labels: labels},
+	}
+}
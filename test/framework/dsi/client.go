@@ -2,21 +2,45 @@ package dsi
 
 import (
 	"fmt"
-	"strings"
 
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
-	"github.com/anynines/a8s-deployment/test/framework/postgresql"
+	"github.com/anynines/a8s-deployment/test/framework"
 )
 
-func NewK8sClient(ds, kubeconfig string) (client.Client, error) {
-	switch strings.ToLower(ds) {
-	case "postgresql":
-		return postgresql.NewK8sClient(kubeconfig)
+// NewK8sClient returns a Kubernetes client for the cluster of kubeconfig whose scheme includes
+// the API types registered by data service ds. The client supports watches, which the wait helpers
+// of the framework use to observe status transitions. If kubeconfig is empty, the client uses the
+// in-cluster config of the pod that the tests run in.
+func NewK8sClient(ds, kubeconfig string) (client.WithWatch, error) {
+	cfg, err := framework.RESTConfig(kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("unable to build config from kubeconfig path: %w", err)
 	}
-	return nil, fmt.Errorf(
-		"kubernetes client factory received request to create kubernetes client for unknown data service %s; only supported data services are %s",
-		ds,
-		supportedDataServices(),
-	)
+	return NewK8sClientForConfig(ds, cfg)
+}
+
+// NewK8sClientForConfig is like NewK8sClient, but it connects to the API server with cfg, which
+// can then be shared with the port forwards of the test suite.
+func NewK8sClientForConfig(ds string, cfg *rest.Config) (client.WithWatch, error) {
+	dataService, err := Lookup(ds)
+	if err != nil {
+		return nil, fmt.Errorf("kubernetes client factory failed to create kubernetes client: %w",
+			err)
+	}
+
+	for _, addToScheme := range dataService.SchemeInstallers {
+		if err := addToScheme(scheme.Scheme); err != nil {
+			return nil, fmt.Errorf("unable to add %s API types to scheme: %w",
+				dataService.Name, err)
+		}
+	}
+
+	k8sClient, err := client.NewWithWatch(cfg, client.Options{Scheme: scheme.Scheme})
+	if err != nil {
+		return nil, fmt.Errorf("unable to create new Kubernetes client for tests: %w", err)
+	}
+	return k8sClient, nil
 }
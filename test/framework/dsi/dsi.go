@@ -3,28 +3,20 @@ package dsi
 import (
 	"context"
 	"fmt"
-	"log"
-	"strings"
-	"time"
 
 	. "github.com/onsi/gomega"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/labels"
-	"k8s.io/apimachinery/pkg/types"
 	runtimeClient "sigs.k8s.io/controller-runtime/pkg/client"
 
-	"github.com/anynines/a8s-deployment/test/framework/postgresql"
+	"github.com/anynines/a8s-deployment/test/framework"
+	"github.com/anynines/a8s-deployment/test/framework/timeline"
+	"github.com/anynines/a8s-deployment/test/framework/wait"
 )
 
 const (
 	clusterStatusRunning = "Running"
-	// asyncOpsTimeoutMins is the amount of minutes after which assertions fail if the condition
-	// they check has not become true. Needed because some conditions might become true only after
-	// some time, so we need to check them asynchronously.
-	// TODO: Make asyncOpsTimeoutMins an invocation parameter.
-	asyncOpsTimeoutMins = time.Minute * 5
 )
 
 type Object interface {
@@ -50,139 +42,162 @@ type PodsGetter interface {
 	Pods(context.Context, runtimeClient.Client) ([]corev1.Pod, error)
 }
 
+// TimelineSourcesGetter is implemented by DSIs that can record the state changes of their objects in
+// a timeline (see package timeline).
+type TimelineSourcesGetter interface {
+	TimelineSources() []timeline.Source
+}
+
+// StaleObjectsGetter is implemented by DSIs that can tell apart the objects that belong to them from
+// the ones left over by a deleted DSI with the same namespace and name.
+type StaleObjectsGetter interface {
+	StaleObjects(context.Context, runtimeClient.Client) ([]runtimeClient.Object, error)
+}
+
 // This package does not use functional options like others in the framework since we need to
 // access the properties of structs. We would need to implement methods to expose these properties
 // which would negate some of the value of functional options.
 
 func New(ds, namespace, name string, replicas int32) (Object, error) {
-	switch strings.ToLower(ds) {
-	case "postgresql":
-		return postgresql.New(namespace, name, replicas), nil
+	dataService, err := Lookup(ds)
+	if err != nil {
+		return nil, fmt.Errorf("dsi factory failed to create dsi: %w", err)
 	}
-	return nil, fmt.Errorf(
-		"dsi factory received request to create dsi for unknown data service %s; only supported data services are %s",
-		ds,
-		supportedDataServices(),
-	)
+	return dataService.New(namespace, name, replicas), nil
 }
 
-func newEmpty(ds string) (Object, error) {
-	switch strings.ToLower(ds) {
-	case "postgresql":
-		return postgresql.NewEmpty(), nil
+// watchTarget returns the registered data service of instance and an empty object of its kind with
+// the name and namespace of instance, to be waited on with wait.ForObject.
+func watchTarget(instance runtimeClient.Object) (DataService, runtimeClient.Object, error) {
+	dataService, err := Lookup(instance.GetObjectKind().GroupVersionKind().Kind)
+	if err != nil {
+		return DataService{}, nil, fmt.Errorf("failed to wait for instance %s/%s: %w",
+			instance.GetNamespace(), instance.GetName(), err)
 	}
-	return nil, fmt.Errorf(
-		"dsi factory received request to create empty dsi for unknown data service %s; only supported data services are %s",
-		ds,
-		supportedDataServices(),
-	)
-}
-
-func supportedDataServices() string {
-	return "PostgreSQL"
+	target := dataService.NewEmpty().GetClientObject()
+	target.SetName(instance.GetName())
+	target.SetNamespace(instance.GetNamespace())
+	return dataService, target, nil
 }
 
 // TODO: rather than having all these functions here, consider switching to an OOP approach where
 // each instance object exposes these functions for itself as methods.
+
+// AwaitReadiness waits until instance reports the "Running" cluster status. It returns a
+// *wait.TimeoutError carrying the last observed cluster status if that doesn't happen within the
+// configured timeout.
 // This function does not check if replicas have properly started and have
 // their replication role defined in the labels, thus it might not enough for
 // all test cases, especially chaos tests.
+func AwaitReadiness(ctx context.Context, instance runtimeClient.Object,
+	c runtimeClient.Client,
+) error {
+	dataService, target, err := watchTarget(instance)
+	if err != nil {
+		return err
+	}
+	return wait.ForObject(ctx, c, target, framework.AsyncOpsTimeout(), framework.PollingPeriod(),
+		fmt.Sprintf("instance %s/%s readiness", instance.GetNamespace(), instance.GetName()),
+		func(o runtimeClient.Object) (bool, any, error) {
+			if o == nil {
+				return false, "not found", nil
+			}
+			instanceCreated, err := dataService.FromClientObject(o)
+			if err != nil {
+				return false, nil, wait.Terminal(err)
+			}
+			status := instanceCreated.ClusterStatus()
+			return status == clusterStatusRunning, fmt.Sprintf("cluster status %q", status), nil
+		},
+	)
+}
 
+// WaitForReadiness is the Gomega adapter of AwaitReadiness: it fails the running spec if the
+// instance doesn't become ready.
 func WaitForReadiness(ctx context.Context, instance runtimeClient.Object, c runtimeClient.Client) {
-	var err error
-	EventuallyWithOffset(1, func() string {
-		instanceCreated, err := newEmpty(instance.GetObjectKind().GroupVersionKind().Kind)
-		if err != nil {
-			return fmt.Sprintf("%v+", err)
-		}
-		if err = c.Get(
-			ctx,
-			types.NamespacedName{
-				Name: instance.GetName(), Namespace: instance.GetNamespace()},
-			instanceCreated.GetClientObject(),
-		); err != nil {
-			return fmt.Sprintf("%v+", err)
-		}
-		return instanceCreated.ClusterStatus()
-	}, asyncOpsTimeoutMins, 1*time.Second).Should(Equal(clusterStatusRunning),
-		fmt.Sprintf("timeout reached waiting for instance %s/%s readiness: %s",
-			instance.GetNamespace(),
-			instance.GetName(),
-			err,
-		),
+	ExpectWithOffset(1, AwaitReadiness(ctx, instance, c)).To(Succeed())
+}
+
+// AwaitReplicaReadiness waits until the given number of pods of instance report as ready.
+func AwaitReplicaReadiness(ctx context.Context, instance runtimeClient.Object,
+	c runtimeClient.Client, replicas int,
+) error {
+	return wait.Poll(ctx, framework.AsyncOpsTimeout(), framework.PollingPeriod(),
+		fmt.Sprintf("%d ready replicas of instance %s/%s", replicas, instance.GetNamespace(),
+			instance.GetName()),
+		func(ctx context.Context) (bool, any, error) {
+			dsiPods, err := GetPodsWithLabels(ctx, c, instance.GetNamespace(),
+				map[string]string{
+					"a8s.a9s/dsi-name": instance.GetName(),
+				})
+			if err != nil {
+				return false, nil, err
+			}
+			ready := NPodsReady(dsiPods)
+			return ready == replicas, fmt.Sprintf("%d ready pods", ready), nil
+		},
 	)
 }
 
-// WaitForReplicaReadiness waits until the given number of ReplicaPods report as ready.
+// WaitForReplicaReadiness is the Gomega adapter of AwaitReplicaReadiness.
 func WaitForReplicaReadiness(ctx context.Context, instance runtimeClient.Object,
 	c runtimeClient.Client, replicas int) {
 
-	var err error
-	EventuallyWithOffset(1, func() bool {
-		dsiPods, err := GetPodsWithLabels(ctx, c, instance.GetNamespace(),
-			map[string]string{
-				"a8s.a9s/dsi-name": instance.GetName(),
-			})
-		if err != nil {
-			return false
-		}
+	ExpectWithOffset(1, AwaitReplicaReadiness(ctx, instance, c, replicas)).To(Succeed())
+}
 
-		return NPodsReady(dsiPods) == replicas
-	}, asyncOpsTimeoutMins, 1*time.Second).Should(BeTrue(),
-		fmt.Sprintf("timeout reached waiting for instance %s/%s readiness: %s",
-			instance.GetNamespace(),
-			instance.GetName(),
-			err,
-		),
+// AwaitDeletion waits until instance is not found in the API server.
+func AwaitDeletion(ctx context.Context, instance runtimeClient.Object,
+	c runtimeClient.Client,
+) error {
+	_, target, err := watchTarget(instance)
+	if err != nil {
+		return err
+	}
+	return wait.ForObject(ctx, c, target, framework.AsyncOpsTimeout(), framework.PollingPeriod(),
+		fmt.Sprintf("instance %s/%s deletion", instance.GetNamespace(), instance.GetName()),
+		func(o runtimeClient.Object) (bool, any, error) {
+			if o == nil {
+				return true, nil, nil
+			}
+			return false, deletionState(o), nil
+		},
 	)
 }
 
+// WaitForDeletion is the Gomega adapter of AwaitDeletion.
 func WaitForDeletion(ctx context.Context, instance runtimeClient.Object, c runtimeClient.Client) {
-	var err error
-	EventuallyWithOffset(1, func() bool {
-		instanceCreated, err := newEmpty(instance.GetObjectKind().GroupVersionKind().Kind)
-		if err != nil {
-			log.Println("failed to generate empty instance for dataservice while waiting for deletion")
-			// Return early since this err is unrecoverable.
-			return true
-		}
-		err = c.Get(
-			ctx,
-			types.NamespacedName{
-				Name: instance.GetName(), Namespace: instance.GetNamespace()},
-			instanceCreated.GetClientObject(),
-		)
-		return err != nil && errors.IsNotFound(err)
-	}, asyncOpsTimeoutMins, 1*time.Second).Should(BeTrue(),
-		fmt.Sprintf("timeout reached waiting for instance %s/%s deletion: %s",
-			instance.GetNamespace(),
-			instance.GetName(),
-			err,
-		),
+	ExpectWithOffset(1, AwaitDeletion(ctx, instance, c)).To(Succeed())
+}
+
+// AwaitPodDeletion waits until the pod with the name and namespace of pod is found without a
+// deletion timestamp, that is until pod has been deleted and recreated by its StatefulSet.
+func AwaitPodDeletion(ctx context.Context, pod *corev1.Pod, c runtimeClient.Client) error {
+	target := &corev1.Pod{}
+	target.SetName(pod.GetName())
+	target.SetNamespace(pod.GetNamespace())
+	return wait.ForObject(ctx, c, target, framework.AsyncOpsTimeout(), framework.PollingPeriod(),
+		fmt.Sprintf("pod %s/%s deletion", pod.GetNamespace(), pod.GetName()),
+		func(o runtimeClient.Object) (bool, any, error) {
+			if o == nil {
+				return false, "not found", nil
+			}
+			return o.GetDeletionTimestamp() == nil, deletionState(o), nil
+		},
 	)
 }
 
+// WaitForPodDeletion is the Gomega adapter of AwaitPodDeletion.
 func WaitForPodDeletion(ctx context.Context, pod *corev1.Pod, c runtimeClient.Client) {
-	var err error
-	EventuallyWithOffset(1, func() bool {
-		podCreated := &corev1.Pod{}
-		if err = c.Get(
-			ctx,
-			types.NamespacedName{
-				Name: pod.GetName(), Namespace: pod.GetNamespace()},
-			podCreated,
-		); err != nil {
-			log.Println("failed to wait for pod to be deleted")
-			return false
-		}
-		return podCreated.DeletionTimestamp == nil
-	}, asyncOpsTimeoutMins, 1*time.Second).Should(BeTrue(),
-		fmt.Sprintf("timeout reached waiting for pod %s/%s deletion: %s",
-			pod.GetNamespace(),
-			pod.GetName(),
-			err,
-		),
-	)
+	ExpectWithOffset(1, AwaitPodDeletion(ctx, pod, c)).To(Succeed())
+}
+
+func deletionState(o runtimeClient.Object) string {
+	if o.GetDeletionTimestamp() == nil {
+		return "not being deleted"
+	}
+	return fmt.Sprintf("being deleted since %s with finalizers %v",
+		o.GetDeletionTimestamp(), o.GetFinalizers())
 }
 
 func GetPodsWithLabels(ctx context.Context, c runtimeClient.Client,
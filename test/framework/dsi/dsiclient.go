@@ -2,17 +2,26 @@ package dsi
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"strings"
 
-	"github.com/anynines/a8s-deployment/test/framework/postgresql"
+	"github.com/anynines/a8s-deployment/test/framework/dataset"
 )
 
-// TODO: Create implementations for Data interface to generalize test data input
+// ErrNotFound is wrapped by the errors that DSIClients return when the entity, the records or the
+// configuration parameter to access don't exist.
+var ErrNotFound = errors.New("not found")
+
+// ErrReadOnly is wrapped by the errors that DSIClients return when they write to a DSI, or a
+// member of it, that only accepts reads, e.g. a replica behind a read-only service.
+var ErrReadOnly = errors.New("read-only")
+
 type DSIClient interface {
 	DSIDeleter
 	DSIReader
 	DSIWriter
+	DSIDataSetReader
+	DSIDataSetWriter
 	DSIAccountValidator
 	DSICollectionValidator
 	DSIConfigurationValidator
@@ -23,11 +32,39 @@ type DSIReader interface {
 }
 
 type DSIWriter interface {
+	// Write appends a record with data to entity, creating entity if it doesn't exist. It
+	// returns an error wrapping ErrReadOnly if the DSI doesn't accept writes.
 	Write(ctx context.Context, entity, data string) error
+	// Update replaces the data of the records of entity whose data is oldData with newData. It
+	// returns an error wrapping ErrNotFound if there are no such records.
+	Update(ctx context.Context, entity, oldData, newData string) error
+	// Upsert replaces the data of the records of entity whose data is oldData with newData, or
+	// appends a record with newData if there are no such records. It creates entity if it
+	// doesn't exist.
+	Upsert(ctx context.Context, entity, oldData, newData string) error
+}
+
+// DSIDataSetReader reads data sets. Compare them with the written ones via dataset.Compare.
+type DSIDataSetReader interface {
+	// ReadDataSet returns the records of entity. It returns an error wrapping ErrNotFound if
+	// entity doesn't exist.
+	ReadDataSet(ctx context.Context, entity string) (dataset.DataSet, error)
+}
+
+// DSIDataSetWriter writes data sets, e.g. the ones generated via dataset.Generate.
+type DSIDataSetWriter interface {
+	// WriteDataSet adds the records of ds to its entity, creating the entity if it doesn't
+	// exist. The entity must not already have records with the same IDs.
+	WriteDataSet(ctx context.Context, ds dataset.DataSet) error
 }
 
 type DSIDeleter interface {
+	// Delete deletes the records of entity whose data is data. It returns an error wrapping
+	// ErrNotFound if there are no such records.
 	Delete(ctx context.Context, entity, data string) error
+	// DeleteEntity deletes entity with all its records. It returns an error wrapping ErrNotFound
+	// if entity doesn't exist.
+	DeleteEntity(ctx context.Context, entity string) error
 }
 
 type DSIAccountValidator interface {
@@ -39,29 +76,23 @@ type DSICollectionValidator interface {
 }
 
 type DSIConfigurationValidator interface {
+	// CheckParameter returns an error if parameter isn't set to value. The error wraps
+	// ErrNotFound if the data service has no such parameter.
 	CheckParameter(ctx context.Context, parameter, value string) error
 }
 
 func NewClient(ds, port string, sbData map[string]string) (DSIClient, error) {
-	switch strings.ToLower(ds) {
-	case "postgresql":
-		return postgresql.NewClientOverPortForwarding(sbData, port), nil
+	dataService, err := Lookup(ds)
+	if err != nil {
+		return nil, fmt.Errorf("dsi client factory failed to create dsi client: %w", err)
 	}
-	return nil, fmt.Errorf(
-		"dsi client factory received request to create dsi client for unknown data service %s; only supported data services are %s",
-		ds,
-		supportedDataServices(),
-	)
+	return dataService.NewClient(port, sbData), nil
 }
 
 func NewClientForURL(ds, host, port, sslmode string, sbData map[string]string) (DSIClient, error) {
-	switch strings.ToLower(ds) {
-	case "postgresql":
-		return postgresql.NewClient(sbData, host, port, sslmode), nil
+	dataService, err := Lookup(ds)
+	if err != nil {
+		return nil, fmt.Errorf("dsi client factory failed to create dsi client: %w", err)
 	}
-	return nil, fmt.Errorf(
-		"dsi client factory received request to create dsi client for unknown data service %s; only supported data services are %s",
-		ds,
-		supportedDataServices(),
-	)
+	return dataService.NewClientForURL(host, port, sslmode, sbData), nil
 }
@@ -0,0 +1,176 @@
+package dsi
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Capability is an optional feature of a data service that some test suites depend on.
+type Capability string
+
+const (
+	CapabilityBackup          Capability = "Backup"
+	CapabilityRestore         Capability = "Restore"
+	CapabilityServiceBinding  Capability = "ServiceBinding"
+	CapabilityTolerations     Capability = "Tolerations"
+	CapabilityPodAntiAffinity Capability = "PodAntiAffinity"
+	CapabilityReplication     Capability = "Replication"
+)
+
+// DataService holds everything the framework needs to know to test a data service. Each data
+// service package registers its DataService once, typically from an init function, via
+// MustRegister. The framework functions that take a data service name (e.g. New, NewClient,
+// NewK8sClient) look it up in the registry, so test binaries must import the package of the
+// data service they test (a blank import is enough).
+type DataService struct {
+	// Name is the name of the data service (e.g. "PostgreSQL"). Lookups are case-insensitive.
+	Name string
+	// Kind is the kind of the DSI custom resource (e.g. "Postgresql"). Lookups by kind are
+	// case-insensitive too.
+	Kind string
+
+	// New returns a DSI object with the given namespace, name and number of replicas.
+	New func(namespace, name string, replicas int32) Object
+	// NewEmpty returns a DSI object with only the type meta set, suitable to be the target of a
+	// Get.
+	NewEmpty func() Object
+	// FromClientObject wraps a client object of the data service's kind (e.g. one received from
+	// a watch) into an Object.
+	FromClientObject func(runtime.Object) (Object, error)
+	// NewClient returns a client that connects to a DSI via a local port forward to port.
+	NewClient func(port string, sbData map[string]string) DSIClient
+	// NewClientForURL returns a client that connects to a DSI at host:port.
+	NewClientForURL func(host, port, sslmode string, sbData map[string]string) DSIClient
+	// SchemeInstallers add the API types of the data service and of its auxiliary objects (e.g.
+	// service bindings and backups) to a scheme.
+	SchemeInstallers []func(*runtime.Scheme) error
+	// Capabilities lists the optional features supported by the data service.
+	Capabilities []Capability
+}
+
+// Supports returns true if c is one of the capabilities of ds.
+func (ds DataService) Supports(c Capability) bool {
+	for _, capability := range ds.Capabilities {
+		if capability == c {
+			return true
+		}
+	}
+	return false
+}
+
+func (ds DataService) validate() error {
+	errs := []string{}
+	if ds.Name == "" {
+		errs = append(errs, "Name is empty")
+	}
+	if ds.Kind == "" {
+		errs = append(errs, "Kind is empty")
+	}
+	if ds.New == nil {
+		errs = append(errs, "New is nil")
+	}
+	if ds.NewEmpty == nil {
+		errs = append(errs, "NewEmpty is nil")
+	}
+	if ds.FromClientObject == nil {
+		errs = append(errs, "FromClientObject is nil")
+	}
+	if ds.NewClient == nil {
+		errs = append(errs, "NewClient is nil")
+	}
+	if ds.NewClientForURL == nil {
+		errs = append(errs, "NewClientForURL is nil")
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+var (
+	registryMu sync.RWMutex
+	// registry maps the lower case name and kind of each registered data service to it.
+	registry = map[string]DataService{}
+)
+
+// Register adds ds to the registered data services. It fails if ds misses a required field or if
+// a data service with the same name or kind is already registered.
+func Register(ds DataService) error {
+	if err := ds.validate(); err != nil {
+		return fmt.Errorf("invalid data service %q: %w", ds.Name, err)
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	name, kind := strings.ToLower(ds.Name), strings.ToLower(ds.Kind)
+	for _, key := range []string{name, kind} {
+		if existing, found := registry[key]; found {
+			return fmt.Errorf("cannot register data service %s: data service %s is already "+
+				"registered with the same name or kind", ds.Name, existing.Name)
+		}
+	}
+	registry[name] = ds
+	registry[kind] = ds
+	return nil
+}
+
+// MustRegister is like Register but panics on failure. It's meant to be invoked from init
+// functions.
+func MustRegister(ds DataService) {
+	if err := Register(ds); err != nil {
+		panic(err)
+	}
+}
+
+// Lookup returns the registered data service whose name or kind is ds (case-insensitive).
+func Lookup(ds string) (DataService, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	if dataService, found := registry[strings.ToLower(ds)]; found {
+		return dataService, nil
+	}
+	return DataService{}, fmt.Errorf("unknown data service %q; registered data services are: %s",
+		ds, registeredDataServices())
+}
+
+// RegisteredDataServices returns the sorted names of the registered data services.
+func RegisteredDataServices() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	return registeredNames()
+}
+
+// Supports returns true if the data service named ds is registered and supports c.
+func Supports(ds string, c Capability) bool {
+	dataService, err := Lookup(ds)
+	return err == nil && dataService.Supports(c)
+}
+
+func registeredDataServices() string {
+	names := registeredNames()
+	if len(names) == 0 {
+		return "none (the package of the data service must be imported to register it)"
+	}
+	return strings.Join(names, ", ")
+}
+
+func registeredNames() []string {
+	names := []string{}
+	seen := map[string]bool{}
+	for _, ds := range registry {
+		if !seen[ds.Name] {
+			seen[ds.Name] = true
+			names = append(names, ds.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,264 @@
+package dsi_test
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	runtimeClient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/anynines/a8s-deployment/test/framework/dataset"
+	"github.com/anynines/a8s-deployment/test/framework/dsi"
+)
+
+// The registry is global, so each test registers data services with names and kinds that no other
+// test uses.
+
+func TestRegisteredDataServiceIsUsedByFactories(t *testing.T) {
+	t.Parallel()
+
+	ds := newStubDataService("StubDB", "StubDBKind")
+	if err := dsi.Register(ds); err != nil {
+		t.Fatalf("Expected no error registering data service, got: \"%v\"", err)
+	}
+
+	for _, name := range []string{"StubDB", "stubdb", "STUBDBKIND"} {
+		got, err := dsi.Lookup(name)
+		if err != nil {
+			t.Fatalf("Expected no error looking up data service %q, got: \"%v\"", name, err)
+		}
+		if got.Name != "StubDB" {
+			t.Fatalf("Expected lookup of %q to return data service StubDB, got %s", name,
+				got.Name)
+		}
+	}
+
+	instance, err := dsi.New("stubdb", "ns0", "i0", 3)
+	if err != nil {
+		t.Fatalf("Expected no error creating dsi, got: \"%v\"", err)
+	}
+	if instance.GetNamespace() != "ns0" || instance.GetName() != "i0" {
+		t.Fatalf("Expected dsi ns0/i0, got %s/%s", instance.GetNamespace(), instance.GetName())
+	}
+
+	client, err := dsi.NewClient("stubdb", "5432", map[string]string{"username": "u"})
+	if err != nil {
+		t.Fatalf("Expected no error creating dsi client, got: \"%v\"", err)
+	}
+	wantClient := stubClient{port: "5432", sbData: map[string]string{"username": "u"}}
+	if !reflect.DeepEqual(client, wantClient) {
+		t.Fatalf("Expected client %#+v, got %#+v", wantClient, client)
+	}
+
+	client, err = dsi.NewClientForURL("stubdb", "host", "5433", "require", nil)
+	if err != nil {
+		t.Fatalf("Expected no error creating dsi client for URL, got: \"%v\"", err)
+	}
+	wantClient = stubClient{host: "host", port: "5433", sslmode: "require"}
+	if !reflect.DeepEqual(client, wantClient) {
+		t.Fatalf("Expected client %#+v, got %#+v", wantClient, client)
+	}
+
+	if !dsi.Supports("stubdb", dsi.CapabilityBackup) {
+		t.Fatalf("Expected data service StubDB to support capability %s",
+			dsi.CapabilityBackup)
+	}
+	if dsi.Supports("stubdb", dsi.CapabilityReplication) {
+		t.Fatalf("Expected data service StubDB not to support capability %s",
+			dsi.CapabilityReplication)
+	}
+}
+
+func TestRegisterRejectsDuplicates(t *testing.T) {
+	t.Parallel()
+
+	if err := dsi.Register(newStubDataService("DupDB", "DupDBKind")); err != nil {
+		t.Fatalf("Expected no error registering data service, got: \"%v\"", err)
+	}
+
+	testCases := map[string]dsi.DataService{
+		"same_name":              newStubDataService("DupDB", "OtherDupDBKind"),
+		"same_name_another_case": newStubDataService("dupdb", "OtherDupDBKind"),
+		"same_kind":              newStubDataService("OtherDupDB", "DupDBKind"),
+		"name_equal_to_kind":     newStubDataService("DupDBKind", "OtherDupDBKind"),
+	}
+
+	for name, ds := range testCases {
+		t.Run(name, func(t *testing.T) {
+			// Rebind ds into this lexical scope. Details on the why at
+			// https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+			ds := ds
+
+			t.Parallel()
+
+			if err := dsi.Register(ds); err == nil {
+				t.Fatalf("Expected error registering duplicate data service, got none")
+			}
+		})
+	}
+}
+
+func TestRegisterRejectsIncompleteDataServices(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		modify       func(*dsi.DataService)
+		wantErrField string
+	}{
+		"missing_name": {
+			modify:       func(ds *dsi.DataService) { ds.Name = "" },
+			wantErrField: "Name",
+		},
+		"missing_kind": {
+			modify:       func(ds *dsi.DataService) { ds.Kind = "" },
+			wantErrField: "Kind",
+		},
+		"missing_object_factory": {
+			modify:       func(ds *dsi.DataService) { ds.New = nil },
+			wantErrField: "New",
+		},
+		"missing_empty_object_factory": {
+			modify:       func(ds *dsi.DataService) { ds.NewEmpty = nil },
+			wantErrField: "NewEmpty",
+		},
+		"missing_client_object_wrapper": {
+			modify:       func(ds *dsi.DataService) { ds.FromClientObject = nil },
+			wantErrField: "FromClientObject",
+		},
+		"missing_client_factory": {
+			modify:       func(ds *dsi.DataService) { ds.NewClient = nil },
+			wantErrField: "NewClient",
+		},
+		"missing_url_client_factory": {
+			modify:       func(ds *dsi.DataService) { ds.NewClientForURL = nil },
+			wantErrField: "NewClientForURL",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			// Rebind tc into this lexical scope. Details on the why at
+			// https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+			tc := tc
+
+			t.Parallel()
+
+			ds := newStubDataService("IncompleteDB-"+name, "IncompleteDBKind-"+name)
+			tc.modify(&ds)
+
+			err := dsi.Register(ds)
+			if err == nil {
+				t.Fatalf("Expected error registering incomplete data service, got none")
+			}
+			if !strings.Contains(err.Error(), tc.wantErrField) {
+				t.Fatalf("Expected error to mention %s, got: \"%v\"", tc.wantErrField, err)
+			}
+			if _, err := dsi.Lookup("IncompleteDBKind-" + name); err == nil {
+				t.Fatalf("Expected incomplete data service not to be registered")
+			}
+		})
+	}
+}
+
+func TestUnknownDataServiceErrorListsRegisteredDataServices(t *testing.T) {
+	t.Parallel()
+
+	if err := dsi.Register(newStubDataService("ListedDB", "ListedDBKind")); err != nil {
+		t.Fatalf("Expected no error registering data service, got: \"%v\"", err)
+	}
+
+	_, err := dsi.New("unknowndb", "ns0", "i0", 1)
+	if err == nil {
+		t.Fatalf("Expected error creating dsi of unknown data service, got none")
+	}
+	if !strings.Contains(err.Error(), "unknowndb") || !strings.Contains(err.Error(), "ListedDB") {
+		t.Fatalf("Expected error to mention the unknown and the registered data services, "+
+			"got: \"%v\"", err)
+	}
+
+	found := false
+	for _, name := range dsi.RegisteredDataServices() {
+		if name == "ListedDB" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("Expected ListedDB among registered data services, got %v",
+			dsi.RegisteredDataServices())
+	}
+}
+
+func newStubDataService(name, kind string) dsi.DataService {
+	return dsi.DataService{
+		Name: name,
+		Kind: kind,
+		New: func(namespace, name string, replicas int32) dsi.Object {
+			return stubDSI{&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+			}}
+		},
+		NewEmpty: func() dsi.Object {
+			return stubDSI{&corev1.ConfigMap{}}
+		},
+		FromClientObject: func(o runtime.Object) (dsi.Object, error) {
+			cm, ok := o.(*corev1.ConfigMap)
+			if !ok {
+				return nil, fmt.Errorf("%T is not a ConfigMap", o)
+			}
+			return stubDSI{cm}, nil
+		},
+		NewClient: func(port string, sbData map[string]string) dsi.DSIClient {
+			return stubClient{port: port, sbData: sbData}
+		},
+		NewClientForURL: func(host, port, sslmode string, sbData map[string]string) dsi.DSIClient {
+			return stubClient{host: host, port: port, sslmode: sslmode, sbData: sbData}
+		},
+		Capabilities: []dsi.Capability{dsi.CapabilityBackup},
+	}
+}
+
+type stubDSI struct {
+	*corev1.ConfigMap
+}
+
+func (s stubDSI) ClusterStatus() string {
+	return "Running"
+}
+
+func (s stubDSI) GetClientObject() runtimeClient.Object {
+	return s.ConfigMap
+}
+
+type stubClient struct {
+	host, port, sslmode string
+	sbData              map[string]string
+}
+
+func (stubClient) Read(context.Context, string) (string, error) { return "", nil }
+
+func (stubClient) Write(context.Context, string, string) error { return nil }
+
+func (stubClient) Update(context.Context, string, string, string) error { return nil }
+
+func (stubClient) Upsert(context.Context, string, string, string) error { return nil }
+
+func (stubClient) Delete(context.Context, string, string) error { return nil }
+
+func (stubClient) ReadDataSet(context.Context, string) (dataset.DataSet, error) {
+	return dataset.DataSet{}, nil
+}
+
+func (stubClient) WriteDataSet(context.Context, dataset.DataSet) error { return nil }
+
+func (stubClient) DeleteEntity(context.Context, string) error { return nil }
+
+func (stubClient) UserExists(context.Context, string) (bool, error) { return true, nil }
+
+func (stubClient) CollectionExists(context.Context, string) bool { return true }
+
+func (stubClient) CheckParameter(context.Context, string, string) error { return nil }
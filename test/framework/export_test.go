@@ -0,0 +1,18 @@
+package framework
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/rest"
+)
+
+// WithForward makes a PortForwarder establish its port forwards with forward rather than via the
+// API server, so that tests can fake losing the connection to a pod.
+func WithForward(forward func(config *rest.Config,
+	pod *corev1.Pod,
+	ports map[int]int,
+	stopCh <-chan struct{},
+) (map[int]int, <-chan error, error)) PortForwarderOption {
+	return func(p *PortForwarder) {
+		p.forward = forward
+	}
+}
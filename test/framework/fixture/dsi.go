@@ -0,0 +1,224 @@
+// Package fixture provides Ginkgo fixtures that provision the objects a spec needs and register
+// their cleanup, so that suites don't have to repeat the same BeforeEach and AfterEach blocks.
+package fixture
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/rest"
+	runtimeClient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/anynines/a8s-deployment/test/framework"
+	"github.com/anynines/a8s-deployment/test/framework/dsi"
+	"github.com/anynines/a8s-deployment/test/framework/secret"
+	"github.com/anynines/a8s-deployment/test/framework/servicebinding"
+	"github.com/anynines/a8s-deployment/test/framework/wait"
+	sbv1beta3 "github.com/anynines/a8s-service-binding-controller/api/v1beta3"
+)
+
+const (
+	defaultReplicas = 1
+	suffixLength    = 5
+)
+
+// Options configures the DSI provisioned by NewDSI.
+type Options struct {
+	// Client is the Kubernetes client used to create, read and delete objects. Required.
+	Client runtimeClient.Client
+	// RESTConfig is the config of the API server used to port forward to the DSI, e.g. the one
+	// that Client was created with. Required.
+	RESTConfig *rest.Config
+	// DataService is the name of the data service of the DSI. Required.
+	DataService string
+	// Namespace is the namespace of the DSI. Required unless Instance is set.
+	Namespace string
+	// NamePrefix is the prefix of the generated name of the DSI. Required unless Instance is set.
+	NamePrefix string
+	// Replicas is the number of replicas of the DSI. Defaults to 1.
+	Replicas int32
+	// Port is the port of the DSI to forward to. Required.
+	Port int
+	// Instance, if set, is the DSI to create instead of the one generated from Namespace,
+	// NamePrefix and Replicas. Use it to customize the DSI (e.g. its parameters).
+	Instance dsi.Object
+	// WithoutServiceBinding disables the creation of a service binding. The client then connects
+	// with the admin credentials of the DSI.
+	WithoutServiceBinding bool
+}
+
+// DSI is a ready data service instance with a port forward to its primary and a client connected
+// through it. All the objects it creates are deleted, and waited for deletion, by Ginkgo
+// DeferCleanup nodes. The cleanup of the DSI also waits for the deletion of its secondary objects
+// if it knows them (see dsi.SecondaryObjectsGetter).
+type DSI struct {
+	// Instance is the DSI.
+	Instance dsi.Object
+	// ServiceBinding is the service binding of the DSI, nil if Options.WithoutServiceBinding is
+	// set.
+	ServiceBinding *sbv1beta3.ServiceBinding
+	// Credentials are the credentials used by Client.
+	Credentials secret.SecretData
+	// LocalPort is the local port forwarded to the DSI.
+	LocalPort int
+	// Client is connected to the DSI via LocalPort.
+	Client dsi.DSIClient
+
+	opts              Options
+	portForwardStopCh chan struct{}
+}
+
+// NewDSI provisions a DSI as configured by opts: it creates the DSI and waits for its readiness,
+// port forwards to its primary, creates a service binding, waits for its readiness and creates a
+// client that uses its credentials. It fails the running spec if any step fails.
+// It must be invoked from a setup node or a spec (e.g. BeforeEach or It), as it registers the
+// cleanup of everything it creates via DeferCleanup.
+func NewDSI(ctx context.Context, opts Options) *DSI {
+	if opts.Replicas == 0 {
+		opts.Replicas = defaultReplicas
+	}
+	f := &DSI{opts: opts, Instance: opts.Instance}
+
+	if f.Instance == nil {
+		var err error
+		f.Instance, err = dsi.New(
+			opts.DataService,
+			opts.Namespace,
+			framework.GenerateName(opts.NamePrefix, GinkgoParallelProcess(), suffixLength),
+			opts.Replicas,
+		)
+		ExpectWithOffset(1, err).To(BeNil(), "failed to generate new DSI resource")
+	}
+
+	ExpectWithOffset(1, opts.Client.Create(ctx, f.Instance.GetClientObject())).
+		To(Succeed(), fmt.Sprintf("failed to create instance %s", f))
+	DeferCleanup(func(ctx SpecContext) {
+		deleteObject(ctx, opts.Client, f.Instance.GetClientObject())
+		dsi.WaitForDeletion(ctx, f.Instance.GetClientObject(), opts.Client)
+		// Make sure that the DSI doesn't leave anything behind, e.g. for the next spec.
+		if _, ok := f.Instance.(dsi.SecondaryObjectsGetter); ok {
+			dsi.WaitForSecondaryObjectsDeletion(ctx, f.Instance, opts.Client)
+		}
+	})
+	dsi.WaitForReadiness(ctx, f.Instance.GetClientObject(), opts.Client)
+
+	if opts.WithoutServiceBinding {
+		var err error
+		f.Credentials, err = secret.AdminSecretData(ctx, opts.Client, f.Instance.GetName(),
+			f.Instance.GetNamespace())
+		ExpectWithOffset(1, err).To(BeNil(),
+			fmt.Sprintf("failed to parse secret data of admin credentials for DSI %s", f))
+	} else {
+		f.ServiceBinding = servicebinding.New(
+			servicebinding.SetNamespacedName(f.Instance.GetClientObject()),
+			servicebinding.SetInstanceRef(f.Instance.GetClientObject()),
+		)
+		// The service binding controller deletes the secret of a service binding when the
+		// service binding is deleted. Cleanup nodes run in reverse order of registration, so this
+		// one runs after the deletion of the service binding registered by Create.
+		DeferCleanup(func(ctx SpecContext) {
+			sbSecret := &corev1.Secret{}
+			sbSecret.SetName(servicebinding.SecretName(f.ServiceBinding.GetName()))
+			sbSecret.SetNamespace(f.ServiceBinding.GetNamespace())
+			Expect(awaitDeletion(ctx, opts.Client, sbSecret)).To(Succeed())
+		})
+		f.Create(ctx, f.ServiceBinding)
+		servicebinding.WaitForReadiness(ctx, f.ServiceBinding, opts.Client)
+
+		var err error
+		f.Credentials, err = secret.Data(ctx, opts.Client,
+			servicebinding.SecretName(f.ServiceBinding.GetName()),
+			f.ServiceBinding.GetNamespace())
+		ExpectWithOffset(1, err).To(BeNil(),
+			fmt.Sprintf("failed to parse secret data for service binding %s/%s",
+				f.ServiceBinding.GetNamespace(), f.ServiceBinding.GetName()))
+	}
+
+	DeferCleanup(f.closePortForward)
+	ExpectWithOffset(1, f.Reconnect(ctx)).To(Succeed())
+
+	return f
+}
+
+// Create creates obj, typically an object that refers to the DSI like a backup or a restore, and
+// registers via DeferCleanup its deletion and the wait for it. It fails the running spec if the
+// creation fails.
+func (f *DSI) Create(ctx context.Context, obj runtimeClient.Object) {
+	ExpectWithOffset(1, f.opts.Client.Create(ctx, obj)).To(Succeed(),
+		fmt.Sprintf("failed to create %T %s/%s for DSI %s", obj, obj.GetNamespace(),
+			obj.GetName(), f))
+	DeferCleanup(func(ctx SpecContext) {
+		deleteObject(ctx, f.opts.Client, obj)
+		Expect(awaitDeletion(ctx, f.opts.Client, obj)).To(Succeed())
+	})
+}
+
+// Reconnect replaces the port forward of the DSI with one to its current primary and recreates
+// Client to connect through it. Unlike ForwardToPod it returns an error rather than failing the
+// running spec, so that it can be retried (e.g. within Eventually) while the DSI restarts.
+func (f *DSI) Reconnect(ctx context.Context) error {
+	pod, err := framework.GetPrimaryPodUsingServiceSelector(ctx, f.Instance, f.opts.Client)
+	if err != nil {
+		return fmt.Errorf("failed to find the primary pod of DSI %s: %w", f, err)
+	}
+	return f.forwardToPod(ctx, pod)
+}
+
+// ForwardToPod replaces the port forward of the DSI with one to pod (e.g. the new primary after a
+// failover) and recreates Client to connect through it. It fails the running spec on failure.
+func (f *DSI) ForwardToPod(ctx context.Context, pod *corev1.Pod) {
+	ExpectWithOffset(1, f.forwardToPod(ctx, pod)).To(Succeed())
+}
+
+func (f *DSI) forwardToPod(ctx context.Context, pod *corev1.Pod) error {
+	f.closePortForward()
+
+	var err error
+	f.portForwardStopCh, f.LocalPort, err = framework.PortForwardPod(
+		ctx, f.opts.Port, f.opts.RESTConfig, pod)
+	if err != nil {
+		return fmt.Errorf("failed to establish port forward to DSI %s: %w", f, err)
+	}
+
+	f.Client, err = dsi.NewClient(f.opts.DataService, strconv.Itoa(f.LocalPort), f.Credentials)
+	if err != nil {
+		return fmt.Errorf("failed to create client for DSI %s: %w", f, err)
+	}
+	return nil
+}
+
+func (f *DSI) closePortForward() {
+	if f.portForwardStopCh != nil {
+		close(f.portForwardStopCh)
+		f.portForwardStopCh = nil
+	}
+}
+
+// String returns the namespaced name of the DSI.
+func (f *DSI) String() string {
+	return fmt.Sprintf("%s/%s", f.Instance.GetNamespace(), f.Instance.GetName())
+}
+
+// deleteObject deletes obj, if it still exists.
+func deleteObject(ctx context.Context, c runtimeClient.Client, obj runtimeClient.Object) {
+	err := c.Delete(ctx, obj)
+	if apierrors.IsNotFound(err) {
+		return
+	}
+	ExpectWithOffset(1, err).To(BeNil(), fmt.Sprintf("failed to delete %T %s/%s", obj,
+		obj.GetNamespace(), obj.GetName()))
+}
+
+func awaitDeletion(ctx context.Context, c runtimeClient.Client, obj runtimeClient.Object) error {
+	return wait.ForObject(ctx, c, obj, framework.AsyncOpsTimeout(), framework.PollingPeriod(),
+		fmt.Sprintf("%T %s/%s deletion", obj, obj.GetNamespace(), obj.GetName()),
+		func(o runtimeClient.Object) (bool, any, error) {
+			return o == nil, nil, nil
+		},
+	)
+}
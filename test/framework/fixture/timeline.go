@@ -0,0 +1,62 @@
+package fixture
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	runtimeClient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/anynines/a8s-deployment/test/framework/dsi"
+	"github.com/anynines/a8s-deployment/test/framework/timeline"
+)
+
+// RecordTimeline starts recording the timeline of the objects of sources for the rest of the
+// running spec. The recording stops in a DeferCleanup node, which also writes the timeline to the
+// GinkgoWriter if the spec failed. ctx must not end before the spec, so it shouldn't be the
+// SpecContext of a setup node. It fails the running spec if the recording can't start.
+func RecordTimeline(ctx context.Context,
+	c runtimeClient.Client,
+	sources ...timeline.Source,
+) *timeline.Recorder {
+	return recordTimeline(ctx, 1, c, sources, "failed to start recording the timeline")
+}
+
+// RecordTimeline records the timeline of the DSI, if it supports it (see
+// dsi.TimelineSourcesGetter), and of the objects of the extra sources (e.g. the backups of the
+// DSI). See the RecordTimeline function.
+func (f *DSI) RecordTimeline(ctx context.Context, extra ...timeline.Source) *timeline.Recorder {
+	var sources []timeline.Source
+	if getter, ok := f.Instance.(dsi.TimelineSourcesGetter); ok {
+		sources = getter.TimelineSources()
+	}
+	return recordTimeline(ctx, 1, f.opts.Client, append(sources, extra...),
+		"failed to start recording the timeline of DSI %s", f)
+}
+
+// recordTimeline starts recording the timeline of the objects of sources and stops it in a
+// DeferCleanup node. It fails the running spec with description if the recording can't start,
+// reporting the caller offset levels up the stack.
+func recordTimeline(ctx context.Context,
+	offset int,
+	c runtimeClient.Client,
+	sources []timeline.Source,
+	description ...any,
+) *timeline.Recorder {
+	r, err := timeline.Start(ctx, c, sources...)
+	ExpectWithOffset(offset+1, err).To(BeNil(), description...)
+	DeferCleanup(stopTimeline, r)
+	return r
+}
+
+// stopTimeline stops r and writes the timeline to the GinkgoWriter if the running spec failed.
+func stopTimeline(r *timeline.Recorder) {
+	r.Stop()
+	if !CurrentSpecReport().Failed() {
+		return
+	}
+	GinkgoWriter.Print(r)
+	if err := r.Err(); err != nil {
+		GinkgoWriter.Printf("the timeline might be incomplete: %v\n", err)
+	}
+}
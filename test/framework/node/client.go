@@ -9,8 +9,9 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/errors"
 	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
-	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/rest"
 
+	"github.com/anynines/a8s-deployment/test/framework"
 	"github.com/anynines/a8s-deployment/test/framework/log"
 )
 
@@ -72,12 +73,17 @@ func (e ErrNodeGet) Unwrap() error {
 }
 
 func NewClientFromKubecfg(kubecfg string) (Client, error) {
-	cfg, err := clientcmd.BuildConfigFromFlags("", kubecfg)
+	cfg, err := framework.RESTConfig(kubecfg)
 	if err != nil {
 		return Client{}, fmt.Errorf("failed to create client config for K8s nodes client from "+
 			"kubeconig %s: %w", kubecfg, err)
 	}
+	return NewClientForConfig(cfg)
+}
 
+// NewClientForConfig is like NewClientFromKubecfg, but it connects to the API server with cfg,
+// e.g. the one that the Kubernetes client of the test suite was created with.
+func NewClientForConfig(cfg *rest.Config) (Client, error) {
 	cv1Client, err := corev1client.NewForConfig(cfg)
 	if err != nil {
 		return Client{},
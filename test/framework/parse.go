@@ -1,59 +1,252 @@
 package framework
 
 import (
+	"bytes"
 	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"os"
+	"strconv"
+	"time"
 
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/api/resource"
 	k8serrors "k8s.io/apimachinery/pkg/util/errors"
 )
 
 const (
 	testingNamespacePrefix = "a8s-e2e-tests"
 	suffixLength           = 5
+
+	// configFileEnv and configFileFlag select the YAML config file of the test run.
+	configFileEnv  = "CONFIG_FILE"
+	configFileFlag = "a8s.config"
+
+	// inClusterEnv is set in the containers of every Kubernetes pod. If it's set, the kubeconfig
+	// path is optional: without one the in-cluster config of the pod is used, see RESTConfig.
+	inClusterEnv = "KUBERNETES_SERVICE_HOST"
 )
 
-type TestRunConfig struct {
-	// KubeconfigPath is the path to the kube config to be used by the Kubernetes client
-	KubeconfigPath string
-	// Dataservice is the dataservice the tests are to be performed on
-	Dataservice string
-	// DSINamePrefix provides a name that the DSI object will take in the cluster
-	DSINamePrefix string
-	// Namespace provides the target namespace to be used for testing. If not given then a
-	// unique namespace is created.
-	Namespace string
+// setting is a configuration value that can be overridden via an environment variable and a
+// command line flag.
+type setting struct {
+	env   string
+	flag  string
+	usage string
+	apply func(c *TestRunConfig, value string) error
+}
+
+var settings = []setting{
+	{"KUBECONFIGPATH", "a8s.kubeconfig", "path to the kubeconfig of the cluster to test",
+		setString(func(c *TestRunConfig) *string { return &c.KubeconfigPath })},
+	{"DATASERVICE", "a8s.dataservice", "data service the tests are to be performed on",
+		setString(func(c *TestRunConfig) *string { return &c.Dataservice })},
+	{"DSI_NAME_PREFIX", "a8s.dsi-name-prefix", "prefix of the names of the DSIs",
+		setString(func(c *TestRunConfig) *string { return &c.DSINamePrefix })},
+	{"NAMESPACE", "a8s.namespace", "namespace to deploy test objects to",
+		setString(func(c *TestRunConfig) *string { return &c.Namespace })},
+	{"ASYNC_OPS_TIMEOUT", "a8s.async-ops-timeout", "timeout of asynchronous operations",
+		setDuration(func(c *TestRunConfig) *time.Duration { return &c.Timeouts.AsyncOps })},
+	{"BACKUP_TIMEOUT", "a8s.backup-timeout", "timeout for backups to complete",
+		setDuration(func(c *TestRunConfig) *time.Duration { return &c.Timeouts.Backup })},
+	{"POLLING_PERIOD", "a8s.polling-period", "interval between checks of asynchronous conditions",
+		setDuration(func(c *TestRunConfig) *time.Duration { return &c.Timeouts.PollingPeriod })},
+	{"MAX_WATCHES", "a8s.max-watches", "maximum number of watches open at the same time",
+		setInt(func(c *TestRunConfig) *int { return &c.Timeouts.MaxWatches })},
+	{"PG_VERSION", "a8s.pg-version", "PostgreSQL version of the instances",
+		setInt(func(c *TestRunConfig) *int { return &c.PostgreSQL.Version })},
+	{"PG_CPU", "a8s.pg-cpu", "CPU request and limit of the PostgreSQL containers",
+		setString(func(c *TestRunConfig) *string { return &c.PostgreSQL.CPU })},
+	{"PG_MEMORY", "a8s.pg-memory", "memory request and limit of the PostgreSQL containers",
+		setString(func(c *TestRunConfig) *string { return &c.PostgreSQL.Memory })},
+	{"PG_VOLUME_SIZE", "a8s.pg-volume-size", "volume size of the PostgreSQL replicas",
+		setString(func(c *TestRunConfig) *string { return &c.PostgreSQL.VolumeSize })},
+	{"CHAOS_INJECTION_TIMEOUT", "a8s.chaos-injection-timeout", "timeout for chaos to apply",
+		setDuration(func(c *TestRunConfig) *time.Duration { return &c.Chaos.InjectionTimeout })},
+	{"CHAOS_FAILURE_DURATION", "a8s.chaos-failure-duration", "how long injected failures last",
+		setDuration(func(c *TestRunConfig) *time.Duration { return &c.Chaos.FailureDuration })},
+	{"CHAOS_S3_POLLING_PERIOD", "a8s.chaos-s3-polling-period", "interval between checks of S3",
+		setDuration(func(c *TestRunConfig) *time.Duration { return &c.Chaos.S3PollingPeriod })},
+}
+
+func init() {
+	RegisterFlags(flag.CommandLine)
 }
 
-// TODO: Use marshalling approach to provide more fine grained feedback on missing environment
-// variables.
+// RegisterFlags registers on fs the command line flags that override the configuration. It's
+// invoked on flag.CommandLine at package initialization, so the flags can be passed to any test
+// binary that imports the framework (e.g. `go test ./e2e/... -args -a8s.async-ops-timeout=10m`).
+func RegisterFlags(fs *flag.FlagSet) {
+	fs.String(configFileFlag, "", "path to the YAML config file of the test run")
+	for _, s := range settings {
+		fs.String(s.flag, "", fmt.Sprintf("%s (overrides env var %s)", s.usage, s.env))
+	}
+}
+
+// ParseEnv builds the configuration of the test run from the config file, the environment
+// variables and the command line flags, validates it and, if it's valid, makes it the active
+// configuration.
 func ParseEnv() (TestRunConfig, error) {
-	config := TestRunConfig{
-		KubeconfigPath: os.Getenv("KUBECONFIGPATH"),
-		Dataservice:    os.Getenv("DATASERVICE"),
-		DSINamePrefix:  os.Getenv("DSI_NAME_PREFIX"),
-		Namespace:      os.Getenv("NAMESPACE"),
+	config, err := ParseConfig(os.LookupEnv, flag.CommandLine)
+	if err != nil {
+		return config, err
+	}
+	SetActiveConfig(config)
+	return config, nil
+}
+
+// ParseConfig builds and validates the configuration of the test run. It starts from
+// DefaultConfig, then applies the YAML config file whose path is given by the a8s.config flag or
+// the CONFIG_FILE env var (if any), then the env vars looked up via lookupEnv and finally the flags
+// explicitly set in fs. fs MUST have been passed to RegisterFlags.
+func ParseConfig(lookupEnv func(string) (string, bool), fs *flag.FlagSet) (TestRunConfig, error) {
+	config := DefaultConfig()
+
+	path, _ := lookupEnv(configFileEnv)
+	if f := fs.Lookup(configFileFlag); f != nil && f.Value.String() != "" {
+		path = f.Value.String()
+	}
+	if path != "" {
+		if err := loadConfigFile(path, &config); err != nil {
+			return config, err
+		}
+	}
+
+	errs := []error{}
+	for _, s := range settings {
+		if v, ok := lookupEnv(s.env); ok && v != "" {
+			if err := s.apply(&config, v); err != nil {
+				errs = append(errs, fmt.Errorf("invalid value for env var %s: %w", s.env, err))
+			}
+		}
+	}
+	fs.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if s.flag == f.Name {
+				if err := s.apply(&config, f.Value.String()); err != nil {
+					errs = append(errs, fmt.Errorf("invalid value for flag -%s: %w", f.Name, err))
+				}
+			}
+		}
+	})
+	if len(errs) > 0 {
+		return config, k8serrors.NewAggregate(errs)
 	}
+
 	// Use dynmically generated name for Namespace if none is provided.
 	if config.Namespace == "" {
 		config.Namespace = UniqueName(testingNamespacePrefix, suffixLength)
 	}
-	return config, validateConfig(config)
+	_, inCluster := lookupEnv(inClusterEnv)
+	return config, validateConfig(config, inCluster)
+}
+
+func loadConfigFile(path string, c *TestRunConfig) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(content))
+	// Fail on unknown keys so that typos don't silently result in default values being used.
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return nil
 }
 
-func validateConfig(c TestRunConfig) error {
-	errs := make([]error, 0, 3)
+func validateConfig(c TestRunConfig, inCluster bool) error {
+	errs := make([]error, 0)
 	if c.DSINamePrefix == "" {
-		errs = append(errs, errors.New("DSI_NAME_PREFIX env var is not set and MUST be set"))
+		errs = append(errs, errors.New("dsiNamePrefix is not set and MUST be set "+
+			"(DSI_NAME_PREFIX env var)"))
 	}
-	if c.KubeconfigPath == "" {
-		errs = append(errs, errors.New("KUBECONFIGPATH env var is not set and MUST be set"))
+	if c.KubeconfigPath == "" && !inCluster {
+		errs = append(errs, errors.New("kubeconfigPath is not set and MUST be set "+
+			"(KUBECONFIGPATH env var) unless the tests run in a Kubernetes pod"))
 	}
 	if c.Dataservice == "" {
-		errs = append(errs, errors.New("DATASERVICE env var is not set and MUST be set"))
+		errs = append(errs, errors.New("dataservice is not set and MUST be set "+
+			"(DATASERVICE env var)"))
+	}
+
+	errs = append(errs,
+		validatePositive("timeouts.asyncOps", c.Timeouts.AsyncOps),
+		validatePositive("timeouts.backup", c.Timeouts.Backup),
+		validatePositive("timeouts.pollingPeriod", c.Timeouts.PollingPeriod),
+		validatePositive("chaos.injectionTimeout", c.Chaos.InjectionTimeout),
+		validatePositive("chaos.failureDuration", c.Chaos.FailureDuration),
+		validatePositive("chaos.s3PollingPeriod", c.Chaos.S3PollingPeriod),
+		validateQuantity("postgresql.cpu", c.PostgreSQL.CPU),
+		validateQuantity("postgresql.memory", c.PostgreSQL.Memory),
+		validateQuantity("postgresql.volumeSize", c.PostgreSQL.VolumeSize),
+	)
+	if c.Timeouts.PollingPeriod > c.Timeouts.AsyncOps {
+		errs = append(errs, fmt.Errorf("timeouts.pollingPeriod (%s) MUST NOT be greater than "+
+			"timeouts.asyncOps (%s)", c.Timeouts.PollingPeriod, c.Timeouts.AsyncOps))
 	}
+	if c.Timeouts.MaxWatches < 0 {
+		errs = append(errs, fmt.Errorf("timeouts.maxWatches MUST NOT be negative, got %d",
+			c.Timeouts.MaxWatches))
+	}
+	if c.PostgreSQL.Version <= 0 {
+		errs = append(errs, fmt.Errorf("postgresql.version MUST be a positive integer, got %d",
+			c.PostgreSQL.Version))
+	}
+
+	// NewAggregate filters out nil errors.
 	return k8serrors.NewAggregate(errs)
 }
 
+func validatePositive(field string, d time.Duration) error {
+	if d <= 0 {
+		return fmt.Errorf("%s MUST be a positive duration, got %s", field, d)
+	}
+	return nil
+}
+
+func validateQuantity(field, q string) error {
+	parsed, err := resource.ParseQuantity(q)
+	if err != nil {
+		return fmt.Errorf("%s MUST be a Kubernetes quantity, got %q: %w", field, q, err)
+	}
+	if parsed.Sign() <= 0 {
+		return fmt.Errorf("%s MUST be positive, got %q", field, q)
+	}
+	return nil
+}
+
+func setString(field func(*TestRunConfig) *string) func(*TestRunConfig, string) error {
+	return func(c *TestRunConfig, v string) error {
+		*field(c) = v
+		return nil
+	}
+}
+
+func setInt(field func(*TestRunConfig) *int) func(*TestRunConfig, string) error {
+	return func(c *TestRunConfig, v string) error {
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", v)
+		}
+		*field(c) = i
+		return nil
+	}
+}
+
+func setDuration(field func(*TestRunConfig) *time.Duration) func(*TestRunConfig, string) error {
+	return func(c *TestRunConfig, v string) error {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration: %w", v, err)
+		}
+		*field(c) = d
+		return nil
+	}
+}
+
 func ConfigToVars(c TestRunConfig) (kubeconfigPath, dsiNamePrefix, dataservice, namespace string) {
 	return c.KubeconfigPath, c.DSINamePrefix, c.Dataservice, c.Namespace
 }
@@ -0,0 +1,253 @@
+package framework_test
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anynines/a8s-deployment/test/framework"
+)
+
+const requiredSettingsYAML = `
+kubeconfigPath: /kube/config
+dataservice: postgresql
+dsiNamePrefix: sample-pg
+namespace: test-ns
+`
+
+func TestParseConfig(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		file    string
+		env     map[string]string
+		flags   []string
+		modify  func(c *framework.TestRunConfig)
+		wantErr []string
+	}{
+		"defaults_are_used_for_settings_that_are_not_configured": {
+			file:   requiredSettingsYAML,
+			modify: func(c *framework.TestRunConfig) {},
+		},
+
+		"file_values_override_defaults": {
+			file: requiredSettingsYAML + `
+timeouts:
+  asyncOps: 7m
+  pollingPeriod: 2s
+postgresql:
+  version: 13
+  volumeSize: 3Gi
+chaos:
+  failureDuration: 20s
+`,
+			modify: func(c *framework.TestRunConfig) {
+				c.Timeouts.AsyncOps = 7 * time.Minute
+				c.Timeouts.PollingPeriod = 2 * time.Second
+				c.PostgreSQL.Version = 13
+				c.PostgreSQL.VolumeSize = "3Gi"
+				c.Chaos.FailureDuration = 20 * time.Second
+			},
+		},
+
+		"env_vars_override_file_values": {
+			file: requiredSettingsYAML + `
+timeouts:
+  backup: 7m
+`,
+			env: map[string]string{
+				"BACKUP_TIMEOUT": "15m",
+				"NAMESPACE":      "env-ns",
+				"PG_CPU":         "1",
+				"MAX_WATCHES":    "0",
+			},
+			modify: func(c *framework.TestRunConfig) {
+				c.Timeouts.MaxWatches = 0
+				c.Timeouts.Backup = 15 * time.Minute
+				c.Namespace = "env-ns"
+				c.PostgreSQL.CPU = "1"
+			},
+		},
+
+		"flags_override_env_vars": {
+			file: requiredSettingsYAML,
+			env: map[string]string{
+				"ASYNC_OPS_TIMEOUT": "15m",
+				"PG_VERSION":        "13",
+			},
+			flags: []string{"-a8s.async-ops-timeout=20m"},
+			modify: func(c *framework.TestRunConfig) {
+				c.Timeouts.AsyncOps = 20 * time.Minute
+				c.PostgreSQL.Version = 13
+			},
+		},
+
+		"required_settings_can_be_provided_via_env_vars_only": {
+			env: map[string]string{
+				"KUBECONFIGPATH":  "/kube/config",
+				"DATASERVICE":     "postgresql",
+				"DSI_NAME_PREFIX": "sample-pg",
+				"NAMESPACE":       "test-ns",
+			},
+			modify: func(c *framework.TestRunConfig) {},
+		},
+
+		"kubeconfig_path_is_optional_in_a_kubernetes_pod": {
+			env: map[string]string{
+				"KUBERNETES_SERVICE_HOST": "10.96.0.1",
+				"DATASERVICE":             "postgresql",
+				"DSI_NAME_PREFIX":         "sample-pg",
+				"NAMESPACE":               "test-ns",
+			},
+			modify: func(c *framework.TestRunConfig) {
+				c.KubeconfigPath = ""
+			},
+		},
+
+		"missing_required_settings_are_all_reported": {
+			wantErr: []string{"dsiNamePrefix", "kubeconfigPath", "dataservice"},
+		},
+
+		"invalid_env_var_values_are_reported_with_the_env_var_name": {
+			file: requiredSettingsYAML,
+			env: map[string]string{
+				"POLLING_PERIOD": "often",
+				"PG_VERSION":     "fourteen",
+			},
+			wantErr: []string{"POLLING_PERIOD", "PG_VERSION"},
+		},
+
+		"invalid_flag_values_are_reported_with_the_flag_name": {
+			file:    requiredSettingsYAML,
+			flags:   []string{"-a8s.backup-timeout=soon"},
+			wantErr: []string{"a8s.backup-timeout"},
+		},
+
+		"invalid_values_are_reported_per_field": {
+			file: requiredSettingsYAML + `
+timeouts:
+  asyncOps: -1m
+  maxWatches: -1
+postgresql:
+  version: 0
+  memory: lots
+chaos:
+  s3PollingPeriod: 0s
+`,
+			wantErr: []string{
+				"timeouts.asyncOps",
+				"timeouts.maxWatches",
+				"postgresql.version",
+				"postgresql.memory",
+				"chaos.s3PollingPeriod",
+			},
+		},
+
+		"polling_period_greater_than_timeout_is_rejected": {
+			file: requiredSettingsYAML + `
+timeouts:
+  asyncOps: 1s
+  pollingPeriod: 2s
+`,
+			wantErr: []string{"timeouts.pollingPeriod"},
+		},
+
+		"unknown_keys_in_the_file_are_rejected": {
+			file: requiredSettingsYAML + `
+timeout:
+  asyncOps: 1m
+`,
+			wantErr: []string{"timeout"},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			// Rebind tc into this lexical scope. Details on the why at
+			// https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+			tc := tc
+
+			t.Parallel()
+
+			env := map[string]string{}
+			for k, v := range tc.env {
+				env[k] = v
+			}
+			if tc.file != "" {
+				path := filepath.Join(t.TempDir(), "config.yaml")
+				if err := os.WriteFile(path, []byte(tc.file), 0o600); err != nil {
+					t.Fatalf("Expected no error writing config file, got: \"%v\"", err)
+				}
+				env["CONFIG_FILE"] = path
+			}
+			lookupEnv := func(key string) (string, bool) {
+				v, ok := env[key]
+				return v, ok
+			}
+
+			fs := flag.NewFlagSet(name, flag.ContinueOnError)
+			framework.RegisterFlags(fs)
+			if err := fs.Parse(tc.flags); err != nil {
+				t.Fatalf("Expected no error parsing flags, got: \"%v\"", err)
+			}
+
+			// Invoke the function under test
+			got, err := framework.ParseConfig(lookupEnv, fs)
+
+			if len(tc.wantErr) > 0 {
+				if err == nil {
+					t.Fatalf("Expected error mentioning %v, got none", tc.wantErr)
+				}
+				for _, want := range tc.wantErr {
+					if !strings.Contains(err.Error(), want) {
+						t.Fatalf("Expected error to mention %q, got: \"%v\"", want, err)
+					}
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expected no error parsing config, got: \"%v\"", err)
+			}
+			want := framework.DefaultConfig()
+			want.KubeconfigPath = "/kube/config"
+			want.Dataservice = "postgresql"
+			want.DSINamePrefix = "sample-pg"
+			want.Namespace = "test-ns"
+			tc.modify(&want)
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("Got config doesn't match the expected one"+
+					"\n\n\tgot:  %#+v\n\n\twant: %#+v\n\n", got, want)
+			}
+		})
+	}
+}
+
+func TestParseConfigGeneratesNamespaceIfNotSet(t *testing.T) {
+	t.Parallel()
+
+	env := map[string]string{
+		"KUBECONFIGPATH":  "/kube/config",
+		"DATASERVICE":     "postgresql",
+		"DSI_NAME_PREFIX": "sample-pg",
+	}
+	lookupEnv := func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	framework.RegisterFlags(fs)
+
+	got, err := framework.ParseConfig(lookupEnv, fs)
+	if err != nil {
+		t.Fatalf("Expected no error parsing config, got: \"%v\"", err)
+	}
+	if !strings.HasPrefix(got.Namespace, "a8s-e2e-tests-") {
+		t.Fatalf("Expected generated namespace with prefix \"a8s-e2e-tests-\", got: %q",
+			got.Namespace)
+	}
+}
@@ -0,0 +1,413 @@
+// Package patroni provides a client for the REST API of Patroni, which manages the PostgreSQL
+// processes of the pods of a PostgreSQL instance. The client reads the cluster membership, the
+// timeline history and the dynamic configuration and triggers switchovers, failovers and restarts,
+// see https://patroni.readthedocs.io/en/latest/rest_api.html
+package patroni
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/rest"
+
+	"github.com/anynines/a8s-deployment/test/framework"
+)
+
+// Port is the port that the REST API of Patroni listens on in the pods of a PostgreSQL instance.
+const Port = 8008
+
+// Roles of the members of a Patroni cluster as reported by /cluster.
+const (
+	RoleLeader        = "leader"
+	RoleReplica       = "replica"
+	RoleSyncStandby   = "sync_standby"
+	RoleStandbyLeader = "standby_leader"
+)
+
+// States of the members of a Patroni cluster as reported by /cluster.
+const (
+	StateRunning   = "running"
+	StateStreaming = "streaming"
+	StateStopped   = "stopped"
+)
+
+// UnknownLag is the Lag of a member whose lag Patroni can't determine.
+const UnknownLag Lag = -1
+
+// Lag is the replication lag of a member of a Patroni cluster in bytes, or UnknownLag.
+type Lag int64
+
+// UnmarshalJSON parses a lag in bytes or Patroni's "unknown".
+func (l *Lag) UnmarshalJSON(data []byte) error {
+	if string(data) == `"unknown"` {
+		*l = UnknownLag
+		return nil
+	}
+	n, err := strconv.ParseInt(string(data), 10, 64)
+	if err != nil {
+		return fmt.Errorf("failed to parse lag %s: %w", data, err)
+	}
+	*l = Lag(n)
+	return nil
+}
+
+// Cluster is the response of /cluster.
+type Cluster struct {
+	Members []Member `json:"members"`
+	// Pause is true if the cluster is in maintenance mode, i.e. Patroni doesn't fail over.
+	Pause bool `json:"pause"`
+}
+
+// Leader returns the member with role RoleLeader, and whether there's one.
+func (c Cluster) Leader() (Member, bool) {
+	for _, m := range c.Members {
+		if m.Role == RoleLeader || m.Role == RoleStandbyLeader {
+			return m, true
+		}
+	}
+	return Member{}, false
+}
+
+// Member returns the member with name name, and whether there's one.
+func (c Cluster) Member(name string) (Member, bool) {
+	for _, m := range c.Members {
+		if m.Name == name {
+			return m, true
+		}
+	}
+	return Member{}, false
+}
+
+// HealthiestReplica returns the replica that streams from the leader with the least known lag,
+// and whether there's one.
+func (c Cluster) HealthiestReplica() (Member, bool) {
+	var best *Member
+	for i, m := range c.Members {
+		if m.Role != RoleReplica && m.Role != RoleSyncStandby {
+			continue
+		}
+		if m.State != StateStreaming && m.State != StateRunning || m.Lag == UnknownLag {
+			continue
+		}
+		if best == nil || m.Lag < best.Lag {
+			best = &c.Members[i]
+		}
+	}
+	if best == nil {
+		return Member{}, false
+	}
+	return *best, true
+}
+
+// Member is a member of a Patroni cluster. Its name is the name of its pod.
+type Member struct {
+	Name           string `json:"name"`
+	Role           string `json:"role"`
+	State          string `json:"state"`
+	APIURL         string `json:"api_url"`
+	Host           string `json:"host"`
+	Port           int    `json:"port"`
+	Timeline       int    `json:"timeline"`
+	Lag            Lag    `json:"lag"`
+	PendingRestart bool   `json:"pending_restart"`
+}
+
+// Status is the response of /patroni, the status of the member that the client is connected to.
+type Status struct {
+	State          string `json:"state"`
+	Role           string `json:"role"`
+	ServerVersion  int    `json:"server_version"`
+	Timeline       int    `json:"timeline"`
+	PendingRestart bool   `json:"pending_restart"`
+	// PostmasterStartTime is when PostgreSQL was started, e.g. "2024-01-02 15:04:05.999+00:00".
+	PostmasterStartTime string `json:"postmaster_start_time"`
+	Xlog                Xlog   `json:"xlog"`
+	Patroni             struct {
+		Version string `json:"version"`
+		Scope   string `json:"scope"`
+	} `json:"patroni"`
+}
+
+// Xlog are the WAL positions of a member. Location is set on the leader, the other fields on
+// replicas.
+type Xlog struct {
+	Location         uint64 `json:"location"`
+	ReceivedLocation uint64 `json:"received_location"`
+	ReplayedLocation uint64 `json:"replayed_location"`
+	Paused           bool   `json:"paused"`
+}
+
+// Config is the response of /config, the dynamic configuration of a Patroni cluster.
+type Config struct {
+	TTL                  int   `json:"ttl"`
+	LoopWait             int   `json:"loop_wait"`
+	RetryTimeout         int   `json:"retry_timeout"`
+	MaximumLagOnFailover int64 `json:"maximum_lag_on_failover"`
+	SynchronousMode      bool  `json:"synchronous_mode"`
+	PostgreSQL           struct {
+		UsePgRewind bool `json:"use_pg_rewind"`
+		UseSlots    bool `json:"use_slots"`
+		// Parameters are the PostgreSQL parameters by name. Their values are strings, numbers or
+		// booleans as in the configuration.
+		Parameters map[string]any `json:"parameters"`
+	} `json:"postgresql"`
+}
+
+// HistoryEntry is an entry of the response of /history, the timeline history of a Patroni
+// cluster.
+type HistoryEntry struct {
+	// Timeline is the timeline that ended.
+	Timeline int
+	// LSN is the position of the WAL where the timeline ended.
+	LSN uint64
+	// Reason is why the timeline ended, as logged by PostgreSQL.
+	Reason string
+	// Time is when the timeline ended. It's zero for entries that Patroni didn't record it for.
+	Time time.Time
+	// Leader is the member that became leader. It's empty for entries that Patroni didn't record
+	// it for.
+	Leader string
+}
+
+// UnmarshalJSON parses an entry of /history, an array of the timeline, LSN, reason and optionally
+// time and new leader.
+func (h *HistoryEntry) UnmarshalJSON(data []byte) error {
+	var fields []json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return fmt.Errorf("failed to parse history entry %s: %w", data, err)
+	}
+	if len(fields) < 3 {
+		return fmt.Errorf("failed to parse history entry %s: expected at least 3 fields", data)
+	}
+	var entry HistoryEntry
+	targets := []any{&entry.Timeline, &entry.LSN, &entry.Reason}
+	var timestamp string
+	if len(fields) > 3 {
+		targets = append(targets, &timestamp)
+	}
+	if len(fields) > 4 {
+		targets = append(targets, &entry.Leader)
+	}
+	for i, target := range targets {
+		if err := json.Unmarshal(fields[i], target); err != nil {
+			return fmt.Errorf("failed to parse field %d of history entry %s: %w", i, data, err)
+		}
+	}
+	if timestamp != "" {
+		t, err := time.Parse(time.RFC3339Nano, timestamp)
+		if err != nil {
+			return fmt.Errorf("failed to parse time of history entry %s: %w", data, err)
+		}
+		entry.Time = t
+	}
+	*h = entry
+	return nil
+}
+
+// RestartRequest is the body of a request to /restart. The zero value restarts PostgreSQL
+// unconditionally.
+type RestartRequest struct {
+	// RestartPending restarts PostgreSQL only if a restart is pending.
+	RestartPending bool `json:"restart_pending,omitempty"`
+	// Role restarts PostgreSQL only if the member has this role.
+	Role string `json:"role,omitempty"`
+}
+
+// ErrUnexpectedStatus is the error type returned when the REST API of Patroni answers with an
+// unexpected status code.
+type ErrUnexpectedStatus struct {
+	Method, Path string
+	StatusCode   int
+	// Body is the body of the response, which explains the error.
+	Body string
+}
+
+func (e ErrUnexpectedStatus) Error() string {
+	return fmt.Sprintf("%s %s returned status %d: %s", e.Method, e.Path, e.StatusCode, e.Body)
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient makes the client send its requests with httpClient rather than with
+// http.DefaultClient.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithBasicAuth makes the client authenticate with username and password, which Patroni requires
+// for the endpoints that change the cluster if restapi.authentication is configured.
+func WithBasicAuth(username, password string) Option {
+	return func(c *Client) {
+		c.username, c.password = username, password
+	}
+}
+
+// Client is a client for the REST API of a member of a Patroni cluster.
+type Client struct {
+	baseURL            string
+	httpClient         *http.Client
+	username, password string
+}
+
+// NewClient returns a client for the REST API of Patroni at baseURL, e.g. "http://localhost:8008".
+func NewClient(baseURL string, opts ...Option) Client {
+	c := Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+// ServiceURL returns the URL of the REST API of Patroni behind the service namespace/name, e.g.
+// the PatroniService of a PostgreSQL instance. It only resolves inside the Kubernetes cluster.
+func ServiceURL(namespace, name string) string {
+	return fmt.Sprintf("http://%s.%s.svc:%d", name, namespace, Port)
+}
+
+// PortForward returns a client for the REST API of Patroni in pod via a port forward, which
+// connects to the API server with config. To terminate the port forward, close the returned
+// channel.
+func PortForward(ctx context.Context,
+	config *rest.Config,
+	pod *corev1.Pod,
+	opts ...Option,
+) (Client, chan struct{}, error) {
+	stopCh, localPort, err := framework.PortForwardPod(ctx, Port, config, pod)
+	if err != nil {
+		return Client{}, nil, fmt.Errorf("failed to port forward to Patroni in pod %s/%s: %w",
+			pod.Namespace, pod.Name, err)
+	}
+	return NewClient(fmt.Sprintf("http://localhost:%d", localPort), opts...), stopCh, nil
+}
+
+// NewClientForPortForwarder returns a client for the REST API of Patroni via fw, which must forward
+// Port, e.g. a framework.PortForwarder with the target postgresql.Postgresql.PrimaryTarget(Port).
+func NewClientForPortForwarder(fw *framework.PortForwarder, opts ...Option) Client {
+	return NewClient(fmt.Sprintf("http://localhost:%d", fw.LocalPort(Port)), opts...)
+}
+
+// Cluster returns the members of the cluster.
+func (c Client) Cluster(ctx context.Context) (Cluster, error) {
+	var cluster Cluster
+	err := c.do(ctx, http.MethodGet, "/cluster", nil, &cluster, http.StatusOK)
+	return cluster, err
+}
+
+// Status returns the status of the member.
+func (c Client) Status(ctx context.Context) (Status, error) {
+	var status Status
+	// /patroni answers with 503 if PostgreSQL isn't running, but still with the status.
+	err := c.do(ctx, http.MethodGet, "/patroni", nil, &status, http.StatusOK,
+		http.StatusServiceUnavailable)
+	return status, err
+}
+
+// Config returns the dynamic configuration of the cluster.
+func (c Client) Config(ctx context.Context) (Config, error) {
+	var config Config
+	err := c.do(ctx, http.MethodGet, "/config", nil, &config, http.StatusOK)
+	return config, err
+}
+
+// History returns the timeline history of the cluster, oldest timeline first.
+func (c Client) History(ctx context.Context) ([]HistoryEntry, error) {
+	var history []HistoryEntry
+	err := c.do(ctx, http.MethodGet, "/history", nil, &history, http.StatusOK)
+	return history, err
+}
+
+// Switchover makes candidate the leader instead of leader, which must be the current leader.
+// If candidate is empty Patroni picks the healthiest replica.
+func (c Client) Switchover(ctx context.Context, leader, candidate string) error {
+	body := map[string]string{"leader": leader}
+	if candidate != "" {
+		body["candidate"] = candidate
+	}
+	return c.do(ctx, http.MethodPost, "/switchover", body, nil, http.StatusOK)
+}
+
+// Failover makes candidate the leader, even if the cluster has no healthy leader.
+func (c Client) Failover(ctx context.Context, candidate string) error {
+	return c.do(ctx, http.MethodPost, "/failover", map[string]string{"candidate": candidate}, nil,
+		http.StatusOK)
+}
+
+// Restart restarts PostgreSQL in the member if the conditions of req hold.
+func (c Client) Restart(ctx context.Context, req RestartRequest) error {
+	return c.do(ctx, http.MethodPost, "/restart", req, nil, http.StatusOK)
+}
+
+// do sends a request with the JSON encoding of body, if not nil, and decodes the response into
+// out, if not nil. It fails if the status code of the response isn't one of okStatus.
+func (c Client) do(ctx context.Context,
+	method, path string,
+	body, out any,
+	okStatus ...int,
+) error {
+	var reqBody io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode body of %s %s: %w", method, path, err)
+		}
+		reqBody = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to create request %s %s: %w", method, path, err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request %s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response of %s %s: %w", method, path, err)
+	}
+
+	if !statusIn(resp.StatusCode, okStatus) {
+		return ErrUnexpectedStatus{
+			Method:     method,
+			Path:       path,
+			StatusCode: resp.StatusCode,
+			Body:       strings.TrimSpace(string(respBody)),
+		}
+	}
+	if out != nil {
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return fmt.Errorf("failed to decode response of %s %s: %w", method, path, err)
+		}
+	}
+	return nil
+}
+
+func statusIn(status int, statuses []int) bool {
+	for _, s := range statuses {
+		if status == s {
+			return true
+		}
+	}
+	return false
+}
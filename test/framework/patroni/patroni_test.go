@@ -0,0 +1,302 @@
+package patroni_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/anynines/a8s-deployment/test/framework/patroni"
+)
+
+const (
+	clusterResponse = `{
+		"members": [
+			{"name": "pg-0", "role": "leader", "state": "running", "api_url":
+				"http://10.0.0.1:8008/patroni", "host": "10.0.0.1", "port": 5432, "timeline": 2},
+			{"name": "pg-1", "role": "replica", "state": "streaming", "host": "10.0.0.2",
+				"port": 5432, "timeline": 2, "lag": 16, "pending_restart": true},
+			{"name": "pg-2", "role": "replica", "state": "stopped", "lag": "unknown"}
+		],
+		"pause": true
+	}`
+	statusResponse = `{
+		"state": "running", "role": "replica", "server_version": 140005, "timeline": 2,
+		"postmaster_start_time": "2024-01-02 15:04:05.999+00:00",
+		"xlog": {"received_location": 100, "replayed_location": 90, "paused": false},
+		"patroni": {"version": "3.0.1", "scope": "pg"}
+	}`
+	configResponse = `{
+		"ttl": 30, "loop_wait": 10, "retry_timeout": 10,
+		"maximum_lag_on_failover": 33554432,
+		"postgresql": {"use_pg_rewind": true, "use_slots": true,
+			"parameters": {"max_connections": 100, "wal_level": "replica"}}
+	}`
+	historyResponse = `[
+		[1, 25623960, "no recovery target specified"],
+		[2, 50331808, "no recovery target specified", "2024-01-02T15:04:05.5+00:00", "pg-1"]
+	]`
+)
+
+func TestClientReads(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		switch r.URL.Path {
+		case "/cluster":
+			io.WriteString(w, clusterResponse)
+		case "/patroni":
+			// Patroni answers with 503 on replicas that are not ready, but with the status.
+			w.WriteHeader(http.StatusServiceUnavailable)
+			io.WriteString(w, statusResponse)
+		case "/config":
+			io.WriteString(w, configResponse)
+		case "/history":
+			io.WriteString(w, historyResponse)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	c := patroni.NewClient(server.URL + "/")
+	ctx := context.Background()
+
+	cluster, err := c.Cluster(ctx)
+	if err != nil {
+		t.Fatalf("Expected no error reading the cluster, got: \"%v\"", err)
+	}
+	if len(cluster.Members) != 3 || !cluster.Pause {
+		t.Fatalf("Expected 3 members of a paused cluster, got %+v", cluster)
+	}
+	if leader, ok := cluster.Leader(); !ok || leader.Name != "pg-0" || leader.Timeline != 2 {
+		t.Fatalf("Expected leader pg-0 on timeline 2, got %+v", leader)
+	}
+	if m, _ := cluster.Member("pg-1"); m.Lag != 16 || !m.PendingRestart ||
+		m.State != patroni.StateStreaming {
+		t.Fatalf("Expected streaming member pg-1 with lag 16 and pending restart, got %+v", m)
+	}
+	if m, _ := cluster.Member("pg-2"); m.Lag != patroni.UnknownLag {
+		t.Fatalf("Expected member pg-2 with unknown lag, got %+v", m)
+	}
+
+	status, err := c.Status(ctx)
+	if err != nil {
+		t.Fatalf("Expected no error reading the status, got: \"%v\"", err)
+	}
+	if status.Role != patroni.RoleReplica || status.ServerVersion != 140005 ||
+		status.Xlog.ReplayedLocation != 90 || status.Patroni.Scope != "pg" {
+		t.Fatalf("Expected the status of the response, got %+v", status)
+	}
+
+	config, err := c.Config(ctx)
+	if err != nil {
+		t.Fatalf("Expected no error reading the config, got: \"%v\"", err)
+	}
+	if config.MaximumLagOnFailover != 33554432 || !config.PostgreSQL.UseSlots ||
+		config.PostgreSQL.Parameters["max_connections"] != float64(100) ||
+		config.PostgreSQL.Parameters["wal_level"] != "replica" {
+		t.Fatalf("Expected the config of the response, got %+v", config)
+	}
+
+	history, err := c.History(ctx)
+	if err != nil {
+		t.Fatalf("Expected no error reading the history, got: \"%v\"", err)
+	}
+	wantHistory := []patroni.HistoryEntry{
+		{Timeline: 1, LSN: 25623960, Reason: "no recovery target specified"},
+		{
+			Timeline: 2,
+			LSN:      50331808,
+			Reason:   "no recovery target specified",
+			Time:     time.Date(2024, 1, 2, 15, 4, 5, 500000000, time.UTC),
+			Leader:   "pg-1",
+		},
+	}
+	if len(history) != 2 || !history[1].Time.Equal(wantHistory[1].Time) {
+		t.Fatalf("Expected history %+v, got %+v", wantHistory, history)
+	}
+	history[1].Time = wantHistory[1].Time
+	if !reflect.DeepEqual(history, wantHistory) {
+		t.Fatalf("Expected history %+v, got %+v", wantHistory, history)
+	}
+}
+
+func TestClientActions(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		do       func(context.Context, patroni.Client) error
+		status   int
+		wantPath string
+		wantBody map[string]any
+		wantErr  bool
+	}{
+		"switchover_to_candidate": {
+			do: func(ctx context.Context, c patroni.Client) error {
+				return c.Switchover(ctx, "pg-0", "pg-1")
+			},
+			status:   http.StatusOK,
+			wantPath: "/switchover",
+			wantBody: map[string]any{"leader": "pg-0", "candidate": "pg-1"},
+		},
+		"switchover_to_any_replica": {
+			do: func(ctx context.Context, c patroni.Client) error {
+				return c.Switchover(ctx, "pg-0", "")
+			},
+			status:   http.StatusOK,
+			wantPath: "/switchover",
+			wantBody: map[string]any{"leader": "pg-0"},
+		},
+		"failed_switchover": {
+			do: func(ctx context.Context, c patroni.Client) error {
+				return c.Switchover(ctx, "pg-1", "pg-2")
+			},
+			status:   http.StatusPreconditionFailed,
+			wantPath: "/switchover",
+			wantBody: map[string]any{"leader": "pg-1", "candidate": "pg-2"},
+			wantErr:  true,
+		},
+		"failover": {
+			do: func(ctx context.Context, c patroni.Client) error {
+				return c.Failover(ctx, "pg-2")
+			},
+			status:   http.StatusOK,
+			wantPath: "/failover",
+			wantBody: map[string]any{"candidate": "pg-2"},
+		},
+		"restart_if_pending": {
+			do: func(ctx context.Context, c patroni.Client) error {
+				return c.Restart(ctx, patroni.RestartRequest{RestartPending: true})
+			},
+			status:   http.StatusOK,
+			wantPath: "/restart",
+			wantBody: map[string]any{"restart_pending": true},
+		},
+		"unconditional_restart": {
+			do: func(ctx context.Context, c patroni.Client) error {
+				return c.Restart(ctx, patroni.RestartRequest{})
+			},
+			status:   http.StatusOK,
+			wantPath: "/restart",
+			wantBody: map[string]any{},
+		},
+	}
+
+	for tcName, tc := range testCases {
+		// Rebind tc into this lexical scope. Details on the why at
+		// https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		tc := tc
+		t.Run(tcName, func(t *testing.T) {
+			t.Parallel()
+
+			var gotMethod, gotPath, gotUser, gotPassword string
+			var gotBody map[string]any
+			server := httptest.NewServer(http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					gotMethod, gotPath = r.Method, r.URL.Path
+					gotUser, gotPassword, _ = r.BasicAuth()
+					if err := json.NewDecoder(r.Body).Decode(&gotBody); err != nil {
+						w.WriteHeader(http.StatusBadRequest)
+						return
+					}
+					w.WriteHeader(tc.status)
+					io.WriteString(w, "explanation\n")
+				}))
+			defer server.Close()
+
+			c := patroni.NewClient(server.URL, patroni.WithBasicAuth("user", "password"))
+			err := tc.do(context.Background(), c)
+			if tc.wantErr {
+				var statusErr patroni.ErrUnexpectedStatus
+				if !errors.As(err, &statusErr) || statusErr.StatusCode != tc.status ||
+					statusErr.Body != "explanation" {
+					t.Fatalf("Expected ErrUnexpectedStatus with status %d and the body, got: "+
+						"\"%v\"", tc.status, err)
+				}
+			} else if err != nil {
+				t.Fatalf("Expected no error, got: \"%v\"", err)
+			}
+			if gotMethod != http.MethodPost || gotPath != tc.wantPath {
+				t.Fatalf("Expected POST %s, got %s %s", tc.wantPath, gotMethod, gotPath)
+			}
+			if !reflect.DeepEqual(gotBody, tc.wantBody) {
+				t.Fatalf("Expected body %v, got %v", tc.wantBody, gotBody)
+			}
+			if gotUser != "user" || gotPassword != "password" {
+				t.Fatalf("Expected basic authentication as user, got %q and %q", gotUser,
+					gotPassword)
+			}
+		})
+	}
+}
+
+func TestClusterHealthiestReplica(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		members []patroni.Member
+		want    string
+		wantOK  bool
+	}{
+		"replica_with_least_lag": {
+			members: []patroni.Member{
+				{Name: "pg-0", Role: patroni.RoleLeader, State: patroni.StateRunning},
+				{Name: "pg-1", Role: patroni.RoleReplica, State: patroni.StateStreaming, Lag: 20},
+				{Name: "pg-2", Role: patroni.RoleSyncStandby, State: patroni.StateStreaming,
+					Lag: 10},
+			},
+			want:   "pg-2",
+			wantOK: true,
+		},
+		"stopped_replicas_and_unknown_lag_are_skipped": {
+			members: []patroni.Member{
+				{Name: "pg-0", Role: patroni.RoleLeader, State: patroni.StateRunning},
+				{Name: "pg-1", Role: patroni.RoleReplica, State: patroni.StateStopped},
+				{Name: "pg-2", Role: patroni.RoleReplica, State: patroni.StateStreaming,
+					Lag: patroni.UnknownLag},
+				{Name: "pg-3", Role: patroni.RoleReplica, State: patroni.StateRunning, Lag: 99},
+			},
+			want:   "pg-3",
+			wantOK: true,
+		},
+		"no_healthy_replica": {
+			members: []patroni.Member{
+				{Name: "pg-0", Role: patroni.RoleLeader, State: patroni.StateRunning},
+				{Name: "pg-1", Role: patroni.RoleReplica, State: patroni.StateStopped},
+			},
+		},
+	}
+
+	for tcName, tc := range testCases {
+		// Rebind tc into this lexical scope. Details on the why at
+		// https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		tc := tc
+		t.Run(tcName, func(t *testing.T) {
+			t.Parallel()
+
+			got, ok := patroni.Cluster{Members: tc.members}.HealthiestReplica()
+			if ok != tc.wantOK || got.Name != tc.want {
+				t.Fatalf("Expected replica %q and %t, got %q and %t", tc.want, tc.wantOK,
+					got.Name, ok)
+			}
+		})
+	}
+}
+
+func TestServiceURL(t *testing.T) {
+	t.Parallel()
+
+	want := "http://pg-patroni.ns.svc:8008"
+	if got := patroni.ServiceURL("ns", "pg-patroni"); got != want {
+		t.Fatalf("Expected %s, got %s", want, got)
+	}
+}
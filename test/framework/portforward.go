@@ -2,45 +2,44 @@ package framework
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
-	"net/url"
 	"os"
-	"strings"
-	"time"
+	"path"
+	"sort"
 
-	. "github.com/onsi/gomega"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/client-go/rest"
-	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/tools/portforward"
 	"k8s.io/client-go/transport/spdy"
 	runtimeClient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/anynines/a8s-deployment/test/framework/wait"
 )
 
 const (
-	minPort             = 1024
-	maxPort             = 65535
-	AsyncOpsTimeoutMins = time.Minute * 5
+	minPort = 1024
+	maxPort = 65535
 )
 
-// TODO: This portforward logic contains some data service specific implementation details such as the
-// name of the service, ect. It would make sense for port forward logic to be implemented for each
-// data service so that we can hide these implementation details beneath abstraction.
+// TODO: PortForward and GetPrimaryPodUsingServiceSelector contain data service specific
+// implementation details such as the name of the primary service. New code should use a
+// PortForwarder with a Target of the data service package instead, e.g.
+// postgresql.Postgresql.PrimaryTarget.
 
 type portForwardAPodRequest struct {
 	// restConfig is the kubernetes config
 	restConfig *rest.Config
 	// pod is the selected pod for this port forwarding
 	pod corev1.Pod
-	// localPort is the local port that will be selected to expose the TargetPort
-	localPort int
-	// targetPort is the target port for the pod
-	targetPort int
+	// ports maps the target ports of the pod to the local ports that will be selected to expose
+	// them. Local port 0 causes a random port to be chosen.
+	ports map[int]int
 	// streams configures where to write or read input from
 	streams genericclioptions.IOStreams
 	// StopCh is the channel to close to terminate the port-forwarding
@@ -53,9 +52,10 @@ type portForwardAPodRequest struct {
 // of `dsi`.
 // To terminate the port-forward, close the returned channel.
 // The other return arguments are the selected local port, and an error in case of failure.
+// It connects to the API server with config, e.g. the one that c was created with.
 func PortForward(ctx context.Context,
 	targetPort int,
-	pathToKubeConfig string,
+	config *rest.Config,
 	dsi runtimeClient.Object,
 	c runtimeClient.Client,
 ) (chan struct{}, int, error) {
@@ -64,7 +64,7 @@ func PortForward(ctx context.Context,
 		return nil, -1, err
 	}
 
-	return PortForwardPod(ctx, targetPort, pathToKubeConfig, pod, c)
+	return PortForwardPod(ctx, targetPort, config, pod)
 }
 
 // PortForwardPod d establishes a port-forward from a randomly selected local port to port `targetPort`
@@ -72,20 +72,48 @@ func PortForward(ctx context.Context,
 //
 //	To terminate the port-forward, close the returned channel.
 //	The other return arguments are the selected local port, and an error in case of failure.
+//
+// It connects to the API server with config, e.g. the one that the Kubernetes client of the test
+// suite was created with. The port forward ends when the connection to pod is lost, e.g. because
+// it restarts. Use a PortForwarder to survive that.
 func PortForwardPod(ctx context.Context,
 	targetPort int,
-	pathToKubeConfig string,
+	config *rest.Config,
 	pod *corev1.Pod,
-	c runtimeClient.Client,
 ) (chan struct{}, int, error) {
-	config, err := clientcmd.BuildConfigFromFlags("", pathToKubeConfig)
-	if err != nil {
-		panic(err)
-	}
-
 	// stopCh control the port forwarding lifecycle. When it gets closed the
 	// port forward will terminate
 	stopCh := make(chan struct{})
+	// Local port 0 causes a random port to be chosen.
+	localPorts, errCh, err := forwardPod(config, pod, map[int]int{targetPort: 0}, stopCh)
+	if err != nil {
+		close(stopCh)
+		return nil, 0, err
+	}
+
+	go func() {
+		// Recent versions of client-go will return err when the port forward loses connection to
+		// pod, e.g. when the PostgreSQL server restarts due to a configuration change.
+		// https://github.com/kubernetes/client-go/commit/d0842249d3b92ea67c446fe273f84fe74ebaed9f
+		// It's only logged, since callers find out on their next connection attempt.
+		if err := <-errCh; err != nil {
+			log.Printf("Port forward for pod %s/%s port %d ended: %s", pod.Namespace, pod.Name,
+				targetPort, err)
+		}
+	}()
+
+	return stopCh, localPorts[targetPort], nil
+}
+
+// forwardPod forwards each target port of pod in ports from the local port it's mapped to, or a
+// randomly selected local port if that's 0, until stopCh is closed or the connection to pod is
+// lost. It waits until the port forward is ready and returns the local port of each target port
+// and a channel that receives the error that ended the port forward, nil if stopCh was closed.
+func forwardPod(config *rest.Config,
+	pod *corev1.Pod,
+	ports map[int]int,
+	stopCh <-chan struct{},
+) (map[int]int, <-chan error, error) {
 	// readyCh communicate when the port forward is ready to get traffic
 	readyCh := make(chan struct{})
 	// stream is used to tell the port forwarder where to place its output or
@@ -96,65 +124,72 @@ func PortForwardPod(ctx context.Context,
 		ErrOut: os.Stderr,
 	}
 
-	var fw *portforward.PortForwarder
-
-	fw, err = portForwardAPod(portForwardAPodRequest{
+	fw, err := portForwardAPod(portForwardAPodRequest{
 		restConfig: config,
 		pod:        *pod,
-		localPort:  0, // causes a random port to be chosen
-		targetPort: targetPort,
+		ports:      ports,
 		streams:    stream,
 		stopCh:     stopCh,
 		readyCh:    readyCh,
 	})
 	if err != nil {
-		return nil, 0, fmt.Errorf("failed to configure port forward for pod %s/%s port %d",
-			pod.Namespace, pod.Name, targetPort)
+		return nil, nil, fmt.Errorf("failed to configure port forward for pod %s/%s ports %v: %w",
+			pod.Namespace, pod.Name, targetPorts(ports), err)
 	}
 
-	go func() {
-		err := fw.ForwardPorts()
-		// Recent versions of client-go will return err when the port forward loses connection to
-		// pod.
-		// https://github.com/kubernetes/client-go/commit/d0842249d3b92ea67c446fe273f84fe74ebaed9f
-		// Handle potential errors from port forwarding.:
-		// 1. If we lose the connection to the pod (e.g., the PostgreSQL server restarts due to a
-		// configuration change), we can attempt to recover since this is a known and recoverable error.
-		// 2. For any other errors, we panic and log the details since they are unexpected and
-		// non-recoverable.
-		if err == portforward.ErrLostConnectionToPod {
-			log.Printf("lost connection to pod while port forwarding for pod %s/%s port %d: %s. The error is recoverable",
-				pod.Namespace, pod.Name, targetPort, err)
-		} else if err != nil {
-			panic(fmt.Sprintf("An error occurred during port forwarding for pod %s/%s port %d: %s",
-				pod.Namespace, pod.Name, targetPort, err))
+	errCh := make(chan error, 1)
+	go func() { errCh <- fw.ForwardPorts() }()
+	select {
+	case <-readyCh:
+	case err := <-errCh:
+		if err == nil {
+			err = errors.New("stopped before it was ready")
 		}
-	}()
-
-	<-readyCh
+		return nil, nil, fmt.Errorf("failed to port forward to pod %s/%s ports %v: %w",
+			pod.Namespace, pod.Name, targetPorts(ports), err)
+	}
 
 	portList, err := fw.GetPorts()
 	if err != nil {
-		return nil, 0, fmt.Errorf("failed to get local port of port forward for pod %s/%s port %d",
-			pod.Namespace, pod.Name, targetPort)
+		return nil, nil, fmt.Errorf("failed to get local ports of port forward for pod %s/%s "+
+			"ports %v: %w", pod.Namespace, pod.Name, targetPorts(ports), err)
 	}
 
-	if len(portList) != 1 {
-		return nil, 0, fmt.Errorf("unexpected number of forwarded ports %d, only"+
-			" one should be forwarded for pod %s/%s",
-			len(portList), pod.Namespace, pod.Name)
+	if len(portList) != len(ports) {
+		return nil, nil, fmt.Errorf("unexpected number of forwarded ports %d, %d should be"+
+			" forwarded for pod %s/%s",
+			len(portList), len(ports), pod.Namespace, pod.Name)
+	}
+	localPorts := make(map[int]int, len(portList))
+	for _, port := range portList {
+		localPorts[int(port.Remote)] = int(port.Local)
+		log.Printf("Forwarding pod %s/%s port %d on local port %d",
+			pod.Namespace, pod.Name, port.Remote, port.Local)
 	}
-	localPort := int(portList[0].Local)
-	log.Printf("Forwarding pod %s/%s port %d on local port %d",
-		pod.Namespace, pod.Name, targetPort, localPort)
 
-	return stopCh, localPort, err
+	return localPorts, errCh, nil
+}
+
+// targetPorts returns the target ports of ports, sorted.
+func targetPorts(ports map[int]int) []int {
+	sorted := make([]int, 0, len(ports))
+	for targetPort := range ports {
+		sorted = append(sorted, targetPort)
+	}
+	sort.Ints(sorted)
+	return sorted
 }
 
 func portForwardAPod(req portForwardAPodRequest) (*portforward.PortForwarder, error) {
-	path := fmt.Sprintf("/api/v1/namespaces/%s/pods/%s/portforward",
-		req.pod.Namespace, req.pod.Name)
-	hostIP := strings.TrimLeft(req.restConfig.Host, "htps:/")
+	// The host of the config can have a path prefix, e.g. if a proxy in front of the API server
+	// relocates its endpoints, and a scheme other than https.
+	hostURL, _, err := rest.DefaultServerUrlFor(req.restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("invalid host %q of the API server: %w", req.restConfig.Host, err)
+	}
+	portForwardURL := *hostURL
+	portForwardURL.Path = path.Join(hostURL.Path, "/api/v1/namespaces", req.pod.Namespace,
+		"pods", req.pod.Name, "portforward")
 
 	transport, upgrader, err := spdy.RoundTripperFor(req.restConfig)
 	if err != nil {
@@ -164,10 +199,13 @@ func portForwardAPod(req portForwardAPodRequest) (*portforward.PortForwarder, er
 	dialer := spdy.NewDialer(upgrader,
 		&http.Client{Transport: transport},
 		http.MethodPost,
-		&url.URL{Scheme: "https", Path: path, Host: hostIP})
+		&portForwardURL)
 
-	fw, err := portforward.New(dialer,
-		[]string{fmt.Sprintf("%d:%d", req.localPort, req.targetPort)},
+	ports := make([]string, 0, len(req.ports))
+	for _, targetPort := range targetPorts(req.ports) {
+		ports = append(ports, fmt.Sprintf("%d:%d", req.ports[targetPort], targetPort))
+	}
+	fw, err := portforward.New(dialer, ports,
 		req.stopCh, req.readyCh, req.streams.Out, req.streams.ErrOut)
 	if err != nil {
 		return nil, err
@@ -197,9 +235,10 @@ func primarySvcSelector(ctx context.Context,
 
 // TODO: Wait for primary pod explicitly in test setup rather than here. Asynchronous assertions
 // are better exposed at the top level of the setup than hidden within the port forward logic itself.
-// GetPrimaryPodUsingServiceSelector requires an eventually assertion since Patroni only applies the master
-// label once quorum has been achieved. We must wait for this before we can know which pod to
-// portforward to using the service selector.
+// GetPrimaryPodUsingServiceSelector needs to wait since Patroni only applies the master label once
+// quorum has been achieved. We must wait for this before we can know which pod to portforward to
+// using the service selector. If there's no single primary pod within the configured timeout, the
+// returned error is a *wait.TimeoutError.
 func GetPrimaryPodUsingServiceSelector(ctx context.Context,
 	dsi runtimeClient.Object,
 	c runtimeClient.Client,
@@ -210,25 +249,27 @@ func GetPrimaryPodUsingServiceSelector(ctx context.Context,
 	}
 
 	var primaryPod *corev1.Pod
-	EventuallyWithOffset(1, func() error {
-		primaryPodList := &corev1.PodList{}
-		if err := c.List(ctx, primaryPodList, &runtimeClient.ListOptions{
-			Namespace:     dsi.GetNamespace(),
-			LabelSelector: *svcSelector,
-		}); err != nil {
-			return err
-		}
-		if len(primaryPodList.Items) != 1 {
-			return fmt.Errorf("found %d primary pods, expected only 1",
-				len(primaryPodList.Items))
-		}
-		primaryPod = &primaryPodList.Items[0]
-		return nil
-	}, AsyncOpsTimeoutMins, 1*time.Second).
-		Should(
-			BeNil(),
-			"timeout reached to get primary pod using service selector for dsi %s/%s",
-			dsi.GetNamespace(), dsi.GetName(),
-		)
+	err = wait.Poll(ctx, AsyncOpsTimeout(), PollingPeriod(),
+		fmt.Sprintf("primary pod of dsi %s/%s using service selector", dsi.GetNamespace(),
+			dsi.GetName()),
+		func(ctx context.Context) (bool, any, error) {
+			primaryPodList := &corev1.PodList{}
+			if err := c.List(ctx, primaryPodList, &runtimeClient.ListOptions{
+				Namespace:     dsi.GetNamespace(),
+				LabelSelector: *svcSelector,
+			}); err != nil {
+				return false, nil, err
+			}
+			if len(primaryPodList.Items) != 1 {
+				return false, nil, fmt.Errorf("found %d primary pods, expected only 1",
+					len(primaryPodList.Items))
+			}
+			primaryPod = &primaryPodList.Items[0]
+			return true, nil, nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
 	return primaryPod, nil
 }
@@ -0,0 +1,70 @@
+package framework_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+
+	"github.com/anynines/a8s-deployment/test/framework"
+)
+
+func TestPortForwardPodURL(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		prefix   string
+		wantPath string
+	}{
+		"without path prefix": {
+			prefix:   "",
+			wantPath: "/api/v1/namespaces/ns0/pods/pg-0/portforward",
+		},
+		"with path prefix": {
+			prefix:   "/k8s/clusters/c-1",
+			wantPath: "/k8s/clusters/c-1/api/v1/namespaces/ns0/pods/pg-0/portforward",
+		},
+	}
+
+	for tcName, tc := range testCases {
+		// Rebind tc into this lexical scope. Details on the why at
+		// https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		tc := tc
+		t.Run(tcName, func(t *testing.T) {
+			t.Parallel()
+
+			paths := make(chan string, 1)
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter,
+				r *http.Request) {
+				select {
+				case paths <- r.URL.Path:
+				default:
+				}
+				http.Error(w, "not an API server", http.StatusNotFound)
+			}))
+			defer srv.Close()
+
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "ns0", Name: "pg-0"}}
+			config := &rest.Config{Host: srv.URL + tc.prefix}
+			stopCh, _, err := framework.PortForwardPod(context.Background(), 5432,
+				config, pod)
+			if err == nil {
+				close(stopCh)
+				t.Fatalf("Expected an error since the server can't port forward, got none")
+			}
+
+			select {
+			case got := <-paths:
+				if got != tc.wantPath {
+					t.Fatalf("Expected request to %s, got %s", tc.wantPath, got)
+				}
+			default:
+				t.Fatalf("Expected a request to %s, got none", tc.wantPath)
+			}
+		})
+	}
+}
@@ -0,0 +1,315 @@
+package framework
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	runtimeClient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/anynines/a8s-deployment/test/framework/wait"
+)
+
+// Target is what a PortForwarder forwards to: ports of a pod that is resolved again each time the
+// PortForwarder reconnects. Data service packages provide targets for their DSIs, e.g. their
+// primary.
+type Target struct {
+	// Description describes the target in errors, e.g. "primary of ns/pg".
+	Description string
+	// Pod returns the pod to forward to.
+	Pod func(ctx context.Context, c runtimeClient.Client) (*corev1.Pod, error)
+	// Ports are the ports of the pod to forward.
+	Ports []int
+}
+
+// PodTarget targets ports of the pod namespace/name, e.g. the pod of a replica of a StatefulSet.
+// It's resolved by name, so it keeps targeting the pod when it's recreated.
+func PodTarget(namespace, name string, ports ...int) Target {
+	return Target{
+		Description: fmt.Sprintf("pod %s/%s", namespace, name),
+		Pod: func(ctx context.Context, c runtimeClient.Client) (*corev1.Pod, error) {
+			var pod corev1.Pod
+			key := types.NamespacedName{Namespace: namespace, Name: name}
+			if err := c.Get(ctx, key, &pod); err != nil {
+				return nil, fmt.Errorf("failed to get pod %s: %w", key, err)
+			}
+			if !isRunning(&pod) {
+				return nil, fmt.Errorf("pod %s is %s", key, pod.Status.Phase)
+			}
+			return &pod, nil
+		},
+		Ports: ports,
+	}
+}
+
+// ServiceTarget targets ports of a running pod that the service namespace/name selects. If it
+// selects several, e.g. a service that balances reads across replicas, it targets the first one
+// by name like kubectl does. The ports are the ones of the pod, not of the service. If the
+// service selects no running pod, the target waits for one within the configured timeout.
+func ServiceTarget(namespace, name string, ports ...int) Target {
+	return Target{
+		Description: fmt.Sprintf("service %s/%s", namespace, name),
+		Pod: func(ctx context.Context, c runtimeClient.Client) (*corev1.Pod, error) {
+			var svc corev1.Service
+			key := types.NamespacedName{Namespace: namespace, Name: name}
+			if err := c.Get(ctx, key, &svc); err != nil {
+				return nil, fmt.Errorf("failed to get service %s: %w", key, err)
+			}
+			if len(svc.Spec.Selector) == 0 {
+				return nil, fmt.Errorf("service %s has no selector", key)
+			}
+
+			var pod *corev1.Pod
+			err := wait.Poll(ctx, AsyncOpsTimeout(), PollingPeriod(),
+				fmt.Sprintf("running pod selected by service %s", key),
+				func(ctx context.Context) (bool, any, error) {
+					var pods corev1.PodList
+					if err := c.List(ctx, &pods,
+						runtimeClient.InNamespace(namespace),
+						runtimeClient.MatchingLabels(svc.Spec.Selector),
+					); err != nil {
+						return false, nil, err
+					}
+					sort.Slice(pods.Items, func(i, j int) bool {
+						return pods.Items[i].Name < pods.Items[j].Name
+					})
+					for i := range pods.Items {
+						if isRunning(&pods.Items[i]) {
+							pod = &pods.Items[i]
+							return true, nil, nil
+						}
+					}
+					return false, fmt.Sprintf("%d pods selected, none running",
+						len(pods.Items)), nil
+				},
+			)
+			return pod, err
+		},
+		Ports: ports,
+	}
+}
+
+func (t Target) String() string {
+	return fmt.Sprintf("%s ports %v", t.Description, t.Ports)
+}
+
+func isRunning(pod *corev1.Pod) bool {
+	return pod.Status.Phase == corev1.PodRunning && pod.DeletionTimestamp == nil
+}
+
+// PortForwarder forwards local ports to the ports of a Target. Unlike the port forwards of
+// PortForward it survives restarts and failovers: once the connection to the pod is lost, it
+// resolves the pod of the target again and forwards the same local ports to it. Clients
+// connecting in the meantime fail, so they should retry.
+type PortForwarder struct {
+	config  *rest.Config
+	client  runtimeClient.Client
+	target  Target
+	onError func(error)
+	// forward establishes each port forward, it's forwardPod unless tests replace it.
+	forward forwardFunc
+
+	cancel    context.CancelFunc
+	stopCh    chan struct{}
+	closeOnce sync.Once
+	// done is closed once the goroutine that reconnects has ended.
+	done chan struct{}
+
+	mu sync.Mutex
+	// localPorts maps the ports of the target to the local ports that forward them.
+	localPorts map[int]int
+	pod        *corev1.Pod
+	// ready is closed while the port forward is established.
+	ready chan struct{}
+}
+
+// forwardFunc is the signature of forwardPod.
+type forwardFunc func(config *rest.Config,
+	pod *corev1.Pod,
+	ports map[int]int,
+	stopCh <-chan struct{},
+) (map[int]int, <-chan error, error)
+
+// PortForwarderOption represents a functional option for PortForwarders.
+type PortForwarderOption func(*PortForwarder)
+
+// WithErrorHandler makes a PortForwarder report its errors, e.g. the loss of the connection to a
+// pod or a failed attempt to reconnect, to handle rather than logging them. handle is called from
+// the goroutine of the PortForwarder, so it must not block.
+func WithErrorHandler(handle func(error)) PortForwarderOption {
+	return func(p *PortForwarder) {
+		p.onError = handle
+	}
+}
+
+// WithLocalPort makes a PortForwarder forward targetPort of its target from localPort rather
+// than from a randomly selected local port.
+func WithLocalPort(targetPort, localPort int) PortForwarderOption {
+	return func(p *PortForwarder) {
+		p.localPorts[targetPort] = localPort
+	}
+}
+
+// NewPortForwarder establishes a port forward from local ports to the ports of target and keeps
+// it up until Close is called or ctx is done. It connects to the API server with config, e.g. the
+// one that c was created with, and resolves the pod of target with c. It returns an error if the
+// first port forward fails, reconnecting is only attempted once it has been established.
+func NewPortForwarder(ctx context.Context,
+	config *rest.Config,
+	c runtimeClient.Client,
+	target Target,
+	opts ...PortForwarderOption,
+) (*PortForwarder, error) {
+	if len(target.Ports) == 0 {
+		return nil, fmt.Errorf("no ports to forward to %s", target.Description)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	p := &PortForwarder{
+		config:     config,
+		client:     c,
+		target:     target,
+		onError:    func(err error) { log.Println(err) },
+		forward:    forwardPod,
+		cancel:     cancel,
+		stopCh:     make(chan struct{}),
+		done:       make(chan struct{}),
+		localPorts: make(map[int]int, len(target.Ports)),
+		ready:      make(chan struct{}),
+	}
+	for _, port := range target.Ports {
+		p.localPorts[port] = 0
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	errCh, err := p.connect(ctx)
+	if err != nil {
+		p.stop()
+		return nil, err
+	}
+	go p.run(ctx, errCh)
+	return p, nil
+}
+
+// LocalPort returns the local port that forwards targetPort, 0 if targetPort isn't forwarded. It
+// doesn't change when reconnecting.
+func (p *PortForwarder) LocalPort(targetPort int) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.localPorts[targetPort]
+}
+
+// Pod returns the pod that the local ports are forwarded to, or were until the connection to it
+// was lost.
+func (p *PortForwarder) Pod() *corev1.Pod {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.pod
+}
+
+// Ready returns a channel that is closed while the port forward is established. After the
+// connection to the pod has been lost it returns a new channel, which is closed once the port
+// forward has been established again.
+func (p *PortForwarder) Ready() <-chan struct{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.ready
+}
+
+// Close terminates the port forward and waits until it has ended. It can be called more than
+// once.
+func (p *PortForwarder) Close() {
+	p.stop()
+	<-p.done
+}
+
+func (p *PortForwarder) stop() {
+	p.closeOnce.Do(func() {
+		p.cancel()
+		close(p.stopCh)
+	})
+}
+
+// run reconnects each time errCh reports the end of the current port forward, until p is closed
+// or ctx is done.
+func (p *PortForwarder) run(ctx context.Context, errCh <-chan error) {
+	defer close(p.done)
+	for {
+		select {
+		case <-ctx.Done():
+			p.stop()
+			return
+		case err := <-errCh:
+			if ctx.Err() != nil {
+				continue
+			}
+			if err == nil {
+				err = errors.New("port forward ended")
+			}
+			p.mu.Lock()
+			p.ready = make(chan struct{})
+			pod := p.pod
+			p.mu.Unlock()
+			p.onError(fmt.Errorf("lost port forward to %s in pod %s/%s, reconnecting: %w",
+				p.target, pod.Namespace, pod.Name, err))
+
+			if errCh = p.reconnect(ctx); errCh == nil {
+				p.stop()
+				return
+			}
+		}
+	}
+}
+
+// reconnect tries to connect until it succeeds or ctx is done, in which case it returns nil.
+func (p *PortForwarder) reconnect(ctx context.Context) <-chan error {
+	for {
+		errCh, err := p.connect(ctx)
+		if err == nil {
+			return errCh
+		}
+		p.onError(err)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(PollingPeriod()):
+		}
+	}
+}
+
+// connect forwards the local ports of p to the current pod of its target and marks p as ready. It
+// returns a channel that receives the error that ended the port forward.
+func (p *PortForwarder) connect(ctx context.Context) (<-chan error, error) {
+	pod, err := p.target.Pod(ctx, p.client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the pod of %s: %w", p.target.Description, err)
+	}
+
+	p.mu.Lock()
+	ports := make(map[int]int, len(p.localPorts))
+	for targetPort, localPort := range p.localPorts {
+		ports[targetPort] = localPort
+	}
+	p.mu.Unlock()
+	localPorts, errCh, err := p.forward(p.config, pod, ports, p.stopCh)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.localPorts = localPorts
+	p.pod = pod
+	close(p.ready)
+	return errCh, nil
+}
@@ -0,0 +1,246 @@
+package framework_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	runtimeClient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/anynines/a8s-deployment/test/framework"
+)
+
+const fakeTimeout = 5 * time.Second
+
+var errLostConnection = errors.New("lost connection to pod")
+
+// forwardCall is a port forward that a PortForwarder establishes with fakeForward. The test
+// answers it on reply.
+type forwardCall struct {
+	pod    *corev1.Pod
+	ports  map[int]int
+	stopCh <-chan struct{}
+	reply  chan forwardReply
+}
+
+type forwardReply struct {
+	localPorts map[int]int
+	errCh      <-chan error
+	err        error
+}
+
+// fakeForward returns a replacement of the port forwards of a PortForwarder that sends each port
+// forward to calls and returns the reply of the test.
+func fakeForward(calls chan<- forwardCall) func(*rest.Config,
+	*corev1.Pod,
+	map[int]int,
+	<-chan struct{},
+) (map[int]int, <-chan error, error) {
+	return func(_ *rest.Config,
+		pod *corev1.Pod,
+		ports map[int]int,
+		stopCh <-chan struct{},
+	) (map[int]int, <-chan error, error) {
+		call := forwardCall{pod: pod, ports: ports, stopCh: stopCh, reply: make(chan forwardReply)}
+		calls <- call
+		r := <-call.reply
+		return r.localPorts, r.errCh, r.err
+	}
+}
+
+// podsTarget targets ports 5432 and 8008 of the pods named names, one after another each time it's
+// resolved.
+func podsTarget(names ...string) framework.Target {
+	pods := make(chan *corev1.Pod, len(names))
+	for _, name := range names {
+		pods <- &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "ns0", Name: name}}
+	}
+	return framework.Target{
+		Description: "fake pods",
+		Pod: func(ctx context.Context, c runtimeClient.Client) (*corev1.Pod, error) {
+			select {
+			case pod := <-pods:
+				return pod, nil
+			default:
+				return nil, errors.New("no more pods")
+			}
+		},
+		Ports: []int{5432, 8008},
+	}
+}
+
+func receive[T any](t *testing.T, ch <-chan T, what string) T {
+	t.Helper()
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(fakeTimeout):
+		t.Fatalf("Expected %s within %s", what, fakeTimeout)
+	}
+	var zero T
+	return zero
+}
+
+// startPortForwarder starts a PortForwarder for target that forwards with fakeForward and reports
+// its errors to errs. It establishes the first port forward with local port 40000 for 5432 and
+// returns the PortForwarder, the channel of its further port forwards and the channel that ends
+// the first one.
+func startPortForwarder(t *testing.T,
+	target framework.Target,
+	errs chan<- error,
+) (*framework.PortForwarder, <-chan forwardCall, chan<- error) {
+	t.Helper()
+
+	calls := make(chan forwardCall)
+	type result struct {
+		p   *framework.PortForwarder
+		err error
+	}
+	results := make(chan result, 1)
+	go func() {
+		p, err := framework.NewPortForwarder(context.Background(), &rest.Config{}, nil, target,
+			framework.WithForward(fakeForward(calls)),
+			framework.WithErrorHandler(func(err error) { errs <- err }),
+			framework.WithLocalPort(8008, 18008),
+		)
+		results <- result{p: p, err: err}
+	}()
+
+	call := receive(t, calls, "the first port forward")
+	if want := map[int]int{5432: 0, 8008: 18008}; !reflect.DeepEqual(call.ports, want) {
+		t.Fatalf("Expected port forward of %v, got %v", want, call.ports)
+	}
+	lost := make(chan error, 1)
+	call.reply <- forwardReply{localPorts: map[int]int{5432: 40000, 8008: 18008}, errCh: lost}
+
+	r := receive(t, results, "NewPortForwarder to return")
+	if r.err != nil {
+		t.Fatalf("Expected no error, got: \"%v\"", r.err)
+	}
+	t.Cleanup(r.p.Close)
+	return r.p, calls, lost
+}
+
+func TestPortForwarderReconnectsOnSameLocalPorts(t *testing.T) {
+	t.Parallel()
+
+	errs := make(chan error, 10)
+	p, calls, lost := startPortForwarder(t, podsTarget("pg-0", "pg-1"), errs)
+	receive(t, p.Ready(), "the port forward to be ready")
+	if got := p.Pod().Name; got != "pg-0" {
+		t.Fatalf("Expected port forward to pg-0, got %s", got)
+	}
+
+	lost <- errLostConnection
+	call := receive(t, calls, "a port forward after losing the connection")
+	if err := receive(t, errs, "the lost connection to be reported"); !errors.Is(err,
+		errLostConnection) {
+		t.Fatalf("Expected the error that ended the port forward, got: \"%v\"", err)
+	}
+	if call.pod.Name != "pg-1" {
+		t.Fatalf("Expected reconnecting to the pod resolved again, pg-1, got %s", call.pod.Name)
+	}
+	if want := map[int]int{5432: 40000, 8008: 18008}; !reflect.DeepEqual(call.ports, want) {
+		t.Fatalf("Expected reconnecting on the same local ports %v, got %v", want, call.ports)
+	}
+	select {
+	case <-p.Ready():
+		t.Fatalf("Expected a new ready channel that isn't closed while reconnecting")
+	default:
+	}
+
+	call.reply <- forwardReply{localPorts: call.ports, errCh: make(chan error)}
+	receive(t, p.Ready(), "the port forward to be ready again")
+	if got := p.Pod().Name; got != "pg-1" {
+		t.Fatalf("Expected port forward to pg-1, got %s", got)
+	}
+	if got := p.LocalPort(5432); got != 40000 {
+		t.Fatalf("Expected local port 40000, got %d", got)
+	}
+}
+
+func TestPortForwarderReportsFailedReconnects(t *testing.T) {
+	t.Parallel()
+
+	errs := make(chan error, 10)
+	p, calls, lost := startPortForwarder(t, podsTarget("pg-0"), errs)
+
+	// The target has no more pods, so reconnecting fails until it's closed.
+	lost <- errLostConnection
+	receive(t, errs, "the lost connection to be reported")
+	err := receive(t, errs, "the failed reconnect to be reported")
+	if want := "failed to find the pod of fake pods"; err == nil ||
+		!strings.HasPrefix(err.Error(), want) {
+		t.Fatalf("Expected an error starting with %q, got: \"%v\"", want, err)
+	}
+	select {
+	case call := <-calls:
+		t.Fatalf("Expected no port forward without a pod, got one to %s", call.pod.Name)
+	default:
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		p.Close()
+		close(closed)
+	}()
+	receive(t, closed, "Close to return")
+}
+
+func TestPortForwarderCloseWhileReconnecting(t *testing.T) {
+	t.Parallel()
+
+	errs := make(chan error, 10)
+	p, calls, lost := startPortForwarder(t, podsTarget("pg-0", "pg-1"), errs)
+
+	lost <- errLostConnection
+	call := receive(t, calls, "a port forward after losing the connection")
+
+	closed := make(chan struct{})
+	go func() {
+		p.Close()
+		close(closed)
+	}()
+	receive(t, call.stopCh, "the port forward to be stopped")
+	select {
+	case <-closed:
+		t.Fatalf("Expected Close to wait for the port forward that is being established")
+	default:
+	}
+
+	call.reply <- forwardReply{err: fmt.Errorf("stopped: %w", context.Canceled)}
+	receive(t, closed, "Close to return")
+	select {
+	case <-p.Ready():
+		t.Fatalf("Expected the port forward not to be ready after Close")
+	default:
+	}
+	// Close can be called again, e.g. by a deferred call.
+	p.Close()
+}
+
+func TestNewPortForwarderFailsIfFirstPortForwardFails(t *testing.T) {
+	t.Parallel()
+
+	calls := make(chan forwardCall)
+	errs := make(chan error, 1)
+	go func() {
+		_, err := framework.NewPortForwarder(context.Background(), &rest.Config{}, nil,
+			podsTarget("pg-0"), framework.WithForward(fakeForward(calls)))
+		errs <- err
+	}()
+
+	call := receive(t, calls, "the first port forward")
+	call.reply <- forwardReply{err: errLostConnection}
+	if err := receive(t, errs, "NewPortForwarder to return"); !errors.Is(err, errLostConnection) {
+		t.Fatalf("Expected the error of the port forward, got: \"%v\"", err)
+	}
+	receive(t, call.stopCh, "the port forward to be stopped")
+}
@@ -0,0 +1,49 @@
+package postgresql
+
+import (
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtimeClient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/anynines/a8s-deployment/test/framework/dsi"
+)
+
+// SecondaryObjects returns the objects that the PostgreSQL operator creates for pg and must delete
+// together with it: the StatefulSet, the services, the RoleBinding and ServiceAccount, the secrets
+// of the admin and standby roles, the persistent volume claims of the replicas and the Patroni
+// leader election endpoint, plus any pod, service or persistent volume claim with the labels of
+// the DSI.
+func (pg Postgresql) SecondaryObjects() dsi.SecondaryObjects {
+	named := []runtimeClient.Object{
+		&appsv1.StatefulSet{ObjectMeta: pg.objectMeta(pg.Name)},
+		&corev1.Service{ObjectMeta: pg.objectMeta(MasterService(pg.Name))},
+		&corev1.Service{ObjectMeta: pg.objectMeta(PatroniService(pg.Name))},
+		&rbacv1.RoleBinding{ObjectMeta: pg.objectMeta(pg.Name)},
+		&corev1.ServiceAccount{ObjectMeta: pg.objectMeta(pg.Name)},
+		&corev1.Secret{ObjectMeta: pg.objectMeta(AdminRoleSecretName(pg.Name))},
+		&corev1.Secret{ObjectMeta: pg.objectMeta(StandbyRoleSecretName(pg.Name))},
+		&corev1.Endpoints{ObjectMeta: pg.objectMeta(pg.Name)},
+	}
+	if pg.Spec.Replicas != nil {
+		for i := 0; i < int(*pg.Spec.Replicas); i++ {
+			named = append(named,
+				&corev1.PersistentVolumeClaim{ObjectMeta: pg.objectMeta(PvcName(pg.Name, i))})
+		}
+	}
+
+	return dsi.SecondaryObjects{
+		Named: named,
+		Labeled: []runtimeClient.ObjectList{
+			&corev1.PodList{},
+			&corev1.ServiceList{},
+			&corev1.PersistentVolumeClaimList{},
+		},
+		Labels: pg.podsLabels(),
+	}
+}
+
+func (pg Postgresql) objectMeta(name string) metav1.ObjectMeta {
+	return metav1.ObjectMeta{Namespace: pg.Namespace, Name: name}
+}
@@ -7,7 +7,11 @@ import (
 	"log"
 	"strings"
 
+	"github.com/jackc/pgconn"
 	"github.com/jackc/pgx/v4"
+
+	"github.com/anynines/a8s-deployment/test/framework/dataset"
+	"github.com/anynines/a8s-deployment/test/framework/dsi"
 )
 
 const (
@@ -19,22 +23,44 @@ const (
 	DBUsernameKey = "username"
 	DBPasswordKey = "password"
 
-	// TODO: Make configurable at runtime when the need arises for tests with TLS. Warning:
-	// enabling SSLMODE breaks port forwarding.
-	sslmodeDisable = "disable"
+	// undefinedTableCode is the code of the errors that PostgreSQL returns when a table doesn't
+	// exist.
+	undefinedTableCode = "42P01"
+	// readOnlySQLTransactionCode is the code of the errors that PostgreSQL returns when a replica
+	// is written to.
+	readOnlySQLTransactionCode = "25006"
 )
 
-func NewClientOverPortForwarding(credentials map[string]string, port string) Client {
-	return NewClient(credentials, localhost, port, sslmodeDisable)
+// dataSetColumns are the columns of the tables that store data sets, in the order of the fields of
+// dataset.Record.
+var dataSetColumns = []string{"id", "name", "amount", "active", "created_at"}
+
+// ClientOption represents a functional option for Clients.
+type ClientOption func(*Client)
+
+// NewClientOverPortForwarding returns a client that connects to a port forward on localhost. It
+// doesn't use TLS unless configured via WithTLS.
+func NewClientOverPortForwarding(credentials map[string]string, port string,
+	opts ...ClientOption,
+) Client {
+	c := NewClient(credentials, localhost, port, SSLModeDisable, opts...)
+	c.portForward = true
+	return c
 }
 
-func NewClient(credentials map[string]string, host string, port string, sslmode string) Client {
-	return Client{
+func NewClient(credentials map[string]string, host string, port string, sslmode string,
+	opts ...ClientOption,
+) Client {
+	c := Client{
 		credentials: credentials,
 		port:        port,
 		hostname:    host,
 		sslmode:     sslmode,
 	}
+	for _, f := range opts {
+		f(&c)
+	}
+	return c
 }
 
 // Client is responsible for opening up connections to the DSI in order to test the dataservice's
@@ -47,6 +73,11 @@ type Client struct {
 	port     string
 	hostname string
 	sslmode  string
+	// tls configures TLS beyond the SSL mode, e.g. with a CA bundle. If it's nil pgx configures
+	// TLS according to sslmode.
+	tls *TLS
+	// portForward is true if the client connects via a port forward.
+	portForward bool
 }
 
 func (c Client) Write(ctx context.Context, tableName, data string) error {
@@ -57,11 +88,11 @@ func (c Client) Write(ctx context.Context, tableName, data string) error {
 	defer func() { closeConnection(ctx, dbConn) }()
 
 	if err := createTableIfNotExists(ctx, dbConn, tableName); err != nil {
-		return err
+		return wrapReadOnly(err)
 	}
 
 	if err := insertData(ctx, dbConn, tableName, data); err != nil {
-		return fmt.Errorf("failed to insert data: %w", err)
+		return wrapReadOnly(fmt.Errorf("failed to insert data: %w", err))
 	}
 	return nil
 }
@@ -73,11 +104,11 @@ func (c Client) Read(ctx context.Context, tableName string) (string, error) {
 	}
 	defer func() { closeConnection(ctx, dbConn) }()
 
-	query := fmt.Sprintf("SELECT * FROM %s;", tableName)
+	query := fmt.Sprintf("SELECT * FROM %s;", QuoteIdentifier(tableName))
 	rows, err := dbConn.Query(ctx, query)
 	if err != nil {
-		return "", fmt.Errorf(
-			"failed to query database for rows with query %s: %w", query, err)
+		return "", wrapNotFound(fmt.Errorf(
+			"failed to query database for rows with query %s: %w", query, err))
 	}
 	defer func() { rows.Close() }()
 
@@ -92,6 +123,69 @@ func (c Client) Read(ctx context.Context, tableName string) (string, error) {
 	return strings.Join(table, "\n"), nil
 }
 
+func (c Client) WriteDataSet(ctx context.Context, ds dataset.DataSet) (err error) {
+	dbConn, err := c.connectToDB(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { closeConnection(ctx, dbConn) }()
+
+	tx, err := dbConn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin a transaction: %w", err)
+	}
+	defer func() { err = endTransaction(ctx, tx, err) }()
+
+	query := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s(id bigint PRIMARY KEY, name text NOT NULL, "+
+		"amount bigint NOT NULL, active boolean NOT NULL, created_at timestamptz NOT NULL);",
+		QuoteIdentifier(ds.Entity))
+	if _, err := tx.Exec(ctx, query); err != nil {
+		return fmt.Errorf("failed to create table with %s: %w", query, err)
+	}
+
+	rows := make([][]any, 0, len(ds.Records))
+	for _, r := range ds.Records {
+		rows = append(rows, []any{r.ID, r.Name, r.Amount, r.Active, r.CreatedAt})
+	}
+	table := pgx.Identifier{ds.Entity}
+	if _, err := tx.CopyFrom(ctx, table, dataSetColumns, pgx.CopyFromRows(rows)); err != nil {
+		return fmt.Errorf("failed to copy %d records into table %s: %w", len(rows),
+			QuoteIdentifier(ds.Entity), err)
+	}
+	return nil
+}
+
+func (c Client) ReadDataSet(ctx context.Context, entity string) (dataset.DataSet, error) {
+	dbConn, err := c.connectToDB(ctx)
+	if err != nil {
+		return dataset.DataSet{}, err
+	}
+	defer func() { closeConnection(ctx, dbConn) }()
+
+	query := fmt.Sprintf("SELECT %s FROM %s ORDER BY id;", strings.Join(dataSetColumns, ", "),
+		QuoteIdentifier(entity))
+	rows, err := dbConn.Query(ctx, query)
+	if err != nil {
+		return dataset.DataSet{}, wrapNotFound(fmt.Errorf(
+			"failed to query database for rows with query %s: %w", query, err))
+	}
+	defer func() { rows.Close() }()
+
+	ds := dataset.DataSet{Entity: entity}
+	for rows.Next() {
+		var r dataset.Record
+		if err := rows.Scan(&r.ID, &r.Name, &r.Amount, &r.Active, &r.CreatedAt); err != nil {
+			return dataset.DataSet{}, fmt.Errorf("failed to scan row: %w", err)
+		}
+		r.CreatedAt = r.CreatedAt.UTC()
+		ds.Records = append(ds.Records, r)
+	}
+	if err := rows.Err(); err != nil {
+		return dataset.DataSet{}, fmt.Errorf("failed to read rows with query %s: %w", query, err)
+	}
+	return ds, nil
+}
+
 func (c Client) UserExists(ctx context.Context, username string) (bool, error) {
 	dbConn, err := c.connectToDB(ctx)
 	if err != nil {
@@ -124,51 +218,168 @@ func (c Client) CollectionExists(ctx context.Context, collection string) bool {
 	return success == 1
 }
 
+// CheckParameter returns an error if parameter isn't set to expectedValue. It compares them via
+// Parameter.Equal, so expectedValue can be given in any unit PostgreSQL accepts, e.g. "10s" for
+// archive_timeout. The error wraps dsi.ErrNotFound if PostgreSQL has no such parameter.
 func (c Client) CheckParameter(ctx context.Context, parameter, expectedValue string) error {
+	p, err := c.Parameter(ctx, parameter)
+	if err != nil {
+		return err
+	}
+	equal, err := p.Equal(expectedValue)
+	if err != nil {
+		return fmt.Errorf("failed to check parameter %s: %w", parameter, err)
+	}
+	if !equal {
+		return fmt.Errorf("parameter %v isn't the expected %s", p, expectedValue)
+	}
+	return nil
+}
+
+func (c Client) Update(ctx context.Context, tableName, oldData, newData string) error {
+	dbConn, err := c.connectToDB(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { closeConnection(ctx, dbConn) }()
+
+	query := fmt.Sprintf("UPDATE %s SET input = $1 WHERE input = $2;",
+		QuoteIdentifier(tableName))
+	tag, err := dbConn.Exec(ctx, query, newData, oldData)
+	if err != nil {
+		return wrapNotFound(fmt.Errorf("failed to update data with query %s: %w", query, err))
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("failed to update data %s of table %s: %w", oldData, tableName,
+			dsi.ErrNotFound)
+	}
+	return nil
+}
+
+func (c Client) Upsert(ctx context.Context, tableName, oldData, newData string) (err error) {
 	dbConn, err := c.connectToDB(ctx)
 	if err != nil {
 		return err
 	}
+	defer func() { closeConnection(ctx, dbConn) }()
+
+	if err := createTableIfNotExists(ctx, dbConn, tableName); err != nil {
+		return err
+	}
 
-	var retrievedValue string
-	query := fmt.Sprintf("SHOW %s", parameter)
-	err = dbConn.QueryRow(ctx, query).Scan(&retrievedValue)
+	tx, err := dbConn.Begin(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to check configured parameter with query %s: %w",
-			query, err)
+		return fmt.Errorf("failed to begin a transaction: %w", err)
 	}
+	defer func() { err = endTransaction(ctx, tx, err) }()
 
-	if retrievedValue != expectedValue {
-		return fmt.Errorf("parameter %s is set to %s and not the expected %s",
-			parameter,
-			retrievedValue,
-			expectedValue)
+	// Block concurrent writes until the end of the transaction, otherwise newData could be
+	// inserted after another transaction inserted oldData and we checked that it didn't exist.
+	query := fmt.Sprintf("LOCK TABLE %s IN SHARE ROW EXCLUSIVE MODE;",
+		QuoteIdentifier(tableName))
+	if _, err := tx.Exec(ctx, query); err != nil {
+		return fmt.Errorf("failed to lock table with query %s: %w", query, err)
+	}
+
+	query = fmt.Sprintf("UPDATE %s SET input = $1 WHERE input = $2;",
+		QuoteIdentifier(tableName))
+	tag, err := tx.Exec(ctx, query, newData, oldData)
+	if err != nil {
+		return fmt.Errorf("failed to update data with query %s: %w", query, err)
+	}
+	if tag.RowsAffected() > 0 {
+		return nil
+	}
+
+	query = fmt.Sprintf("INSERT INTO %s(input) VALUES ($1);", QuoteIdentifier(tableName))
+	if _, err := tx.Exec(ctx, query, newData); err != nil {
+		return fmt.Errorf("failed to insert data with query %s: %w", query, err)
 	}
 	return nil
 }
 
-func (c Client) Delete(ctx context.Context, entity, data string) error {
-	return errors.New("not implemented")
+func (c Client) Delete(ctx context.Context, tableName, data string) error {
+	dbConn, err := c.connectToDB(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { closeConnection(ctx, dbConn) }()
+
+	query := fmt.Sprintf("DELETE FROM %s WHERE input = $1;", QuoteIdentifier(tableName))
+	tag, err := dbConn.Exec(ctx, query, data)
+	if err != nil {
+		return wrapNotFound(fmt.Errorf("failed to delete data with query %s: %w", query, err))
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("failed to delete data %s of table %s: %w", data, tableName,
+			dsi.ErrNotFound)
+	}
+	return nil
 }
 
-func insertData(ctx context.Context, dbConn *pgx.Conn, tableName, input string) error {
+func (c Client) DeleteEntity(ctx context.Context, tableName string) error {
+	dbConn, err := c.connectToDB(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { closeConnection(ctx, dbConn) }()
+
+	query := fmt.Sprintf("DROP TABLE %s;", QuoteIdentifier(tableName))
+	if _, err := dbConn.Exec(ctx, query); err != nil {
+		return wrapNotFound(fmt.Errorf("failed to drop table with query %s: %w", query, err))
+	}
+	return nil
+}
+
+// QuoteIdentifier quotes name so that PostgreSQL interprets it as an identifier (e.g. a table
+// name) exactly as it is, even if it has upper case letters, dashes, quotes or is a keyword.
+func QuoteIdentifier(name string) string {
+	return pgx.Identifier{name}.Sanitize()
+}
+
+// wrapNotFound makes err wrap dsi.ErrNotFound if PostgreSQL returned it because a table doesn't
+// exist.
+func wrapNotFound(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == undefinedTableCode {
+		return fmt.Errorf("%w: %w", dsi.ErrNotFound, err)
+	}
+	return err
+}
+
+// wrapReadOnly makes err wrap dsi.ErrReadOnly if PostgreSQL returned it because it's a replica.
+func wrapReadOnly(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == readOnlySQLTransactionCode {
+		return fmt.Errorf("%w: %w", dsi.ErrReadOnly, err)
+	}
+	return err
+}
+
+// txBeginner begins transactions, e.g. a *pgx.Conn.
+type txBeginner interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+func insertData(ctx context.Context, dbConn txBeginner, tableName, input string) (err error) {
 	tx, err := dbConn.Begin(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to begin a transaction: %w", err)
 	}
 	defer func() { err = endTransaction(ctx, tx, err) }()
 
-	query := fmt.Sprintf("INSERT INTO %s(input) VALUES ($1);", tableName)
+	query := fmt.Sprintf("INSERT INTO %s(input) VALUES ($1);", QuoteIdentifier(tableName))
 	_, err = tx.Exec(ctx, query, input)
 	if err != nil {
 		return fmt.Errorf(
-			"failed transaction for query %s with input %s: %v", query, input, err)
+			"failed transaction for query %s with input %s: %w", query, input, err)
 	}
 	return nil
 }
 
 func createTableIfNotExists(ctx context.Context, dbConn *pgx.Conn, tableName string) error {
-	createSqlTable := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s(input text);", tableName)
+	createSqlTable := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s(input text);",
+		QuoteIdentifier(tableName))
 	if _, err := dbConn.Exec(ctx, createSqlTable); err != nil {
 		return fmt.Errorf("failed to create table with %s: %w", createSqlTable, err)
 	}
@@ -177,13 +388,41 @@ func createTableIfNotExists(ctx context.Context, dbConn *pgx.Conn, tableName str
 
 func (c Client) connectToDB(ctx context.Context) (*pgx.Conn, error) {
 	dbURL := c.dbURL()
-	conn, err := pgx.Connect(ctx, dbURL)
+	config, err := pgx.ParseConfig(dbURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse database URL %s: %w", dbURL, err)
+	}
+	if err := c.configureTLS(config); err != nil {
+		return nil, err
+	}
+
+	conn, err := pgx.ConnectConfig(ctx, config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database with %s: %w", dbURL, err)
 	}
 	return conn, nil
 }
 
+// configureTLS makes config use c.tls, if set. Over port forwards TLS is negotiated by dialTLS.
+func (c Client) configureTLS(config *pgx.ConnConfig) error {
+	if c.tls == nil {
+		return nil
+	}
+	tlsConfig, err := c.tls.Config(c.hostname)
+	if err != nil {
+		return fmt.Errorf("failed to configure TLS: %w", err)
+	}
+
+	// pgx falls back to other SSL modes only for "prefer" and "allow", which TLS doesn't support.
+	config.Fallbacks = nil
+	config.TLSConfig = tlsConfig
+	if c.portForward && tlsConfig != nil {
+		config.DialFunc = dialTLS(config.DialFunc, tlsConfig)
+		config.TLSConfig = nil
+	}
+	return nil
+}
+
 func closeConnection(ctx context.Context, conn *pgx.Conn) {
 	if err := conn.Close(ctx); err != nil {
 		log.Println(err, "failed to close connection")
@@ -218,14 +457,11 @@ func (c Client) dbURL() string {
 	// and this PostgreSQL behaviour when SSLMODE is enabled causes the port forward to be
 	// closed when an RST packet is read from server side in the connection established via the
 	// port forward. https://github.com/kubernetes/kubectl/issues/1169#issuecomment-1165140134
-	// So for now we disable SSLMODE for our PostgreSQL client which fixes the port forward
-	// closing.
-	//
-	// SSL can be enabled by overwriting c.sslmode for testing exposed instances where a port
-	// forward is not necessary
+	// So by default we disable SSLMODE for clients over port forwards.
 	//
-	// TODO: We may want to perform tests involving SSL in future. Find alternative approach so
-	// that we can have SSLMODE enabled and reliable port forwards.
+	// SSL can be enabled via WithTLS. Over port forwards the client then negotiates TLS itself
+	// and closes connections without the SSL Shutdown packet (see dialTLS), which keeps the port
+	// forward open.
 	return strings.Join([]string{
 		protocol, "://", user, ":", password, "@", c.hostname, ":", c.port, "/", database, "?", "sslmode=", c.sslmode,
 	},
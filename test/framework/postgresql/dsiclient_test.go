@@ -0,0 +1,132 @@
+package postgresql_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+
+	"github.com/anynines/a8s-deployment/test/framework/postgresql"
+)
+
+func TestQuoteIdentifier(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		name string
+		want string
+	}{
+		"lower_case": {
+			name: "test_entity",
+			want: `"test_entity"`,
+		},
+		"upper_case_is_preserved": {
+			name: "TestEntity",
+			want: `"TestEntity"`,
+		},
+		"dashes": {
+			name: "test-entity-1",
+			want: `"test-entity-1"`,
+		},
+		"keyword": {
+			name: "select",
+			want: `"select"`,
+		},
+		"double_quotes_are_escaped": {
+			name: `test"; DROP TABLE users; --`,
+			want: `"test""; DROP TABLE users; --"`,
+		},
+		"single_quotes_and_dots_are_kept": {
+			name: "o'brien.table",
+			want: `"o'brien.table"`,
+		},
+		"null_bytes_are_removed": {
+			name: "test\x00entity",
+			want: `"testentity"`,
+		},
+	}
+
+	for tcName, tc := range testCases {
+		// Rebind tc into this lexical scope. Details on the why at
+		// https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		tc := tc
+		t.Run(tcName, func(t *testing.T) {
+			t.Parallel()
+
+			if got := postgresql.QuoteIdentifier(tc.name); got != tc.want {
+				t.Fatalf("Expected %s, got %s", tc.want, got)
+			}
+		})
+	}
+}
+
+// fakeConn begins fakeTxs.
+type fakeConn struct {
+	tx *fakeTx
+}
+
+func (c fakeConn) Begin(ctx context.Context) (pgx.Tx, error) {
+	return c.tx, nil
+}
+
+// fakeTx is a transaction whose statements fail with execErr and that fails to commit with
+// commitErr. Its other methods aren't implemented.
+type fakeTx struct {
+	pgx.Tx
+	execErr    error
+	commitErr  error
+	rolledBack bool
+}
+
+func (tx *fakeTx) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	if tx.execErr != nil {
+		return nil, tx.execErr
+	}
+	return pgconn.CommandTag("INSERT 0 1"), nil
+}
+
+func (tx *fakeTx) Commit(ctx context.Context) error {
+	return tx.commitErr
+}
+
+func (tx *fakeTx) Rollback(ctx context.Context) error {
+	tx.rolledBack = true
+	return nil
+}
+
+func TestInsertDataReturnsCommitError(t *testing.T) {
+	t.Parallel()
+
+	commitErr := errors.New("connection reset by peer")
+	tx := &fakeTx{commitErr: commitErr}
+	err := postgresql.InsertData(context.Background(), fakeConn{tx: tx}, "test_entity", "input")
+	if !errors.Is(err, commitErr) {
+		t.Fatalf("Expected the error of the commit, got: \"%v\"", err)
+	}
+
+	tx = &fakeTx{}
+	if err := postgresql.InsertData(context.Background(), fakeConn{tx: tx}, "test_entity",
+		"input"); err != nil {
+		t.Fatalf("Expected no error, got: \"%v\"", err)
+	}
+	if tx.rolledBack {
+		t.Fatalf("Expected the committed transaction not to be rolled back")
+	}
+}
+
+func TestInsertDataWrapsErrorOfStatement(t *testing.T) {
+	t.Parallel()
+
+	tx := &fakeTx{execErr: &pgconn.PgError{Code: "25006",
+		Message: "cannot execute INSERT in a read-only transaction"}}
+	err := postgresql.InsertData(context.Background(), fakeConn{tx: tx}, "test_entity", "input")
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "25006" {
+		t.Fatalf("Expected an error wrapping the PgError with code 25006, got: \"%v\"", err)
+	}
+	if !tx.rolledBack {
+		t.Fatalf("Expected the failed transaction to be rolled back")
+	}
+}
@@ -0,0 +1,4 @@
+package postgresql
+
+// InsertData exports insertData to test its transaction handling without a database.
+var InsertData = insertData
@@ -0,0 +1,124 @@
+package postgresql
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	k8sresource "k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/utils/pointer"
+
+	pgv1beta3 "github.com/anynines/postgresql-operator/api/v1beta3"
+)
+
+// Option represents a functional option for Postgresql objects. To learn what a functional option
+// is, read here: https://dave.cheney.net/2014/10/17/functional-options-for-friendly-apis
+type Option func(*Postgresql)
+
+// WithVersion sets the major version of PostgreSQL.
+func WithVersion(version int) Option {
+	return func(p *Postgresql) {
+		p.Spec.Version = version
+	}
+}
+
+// WithReplicas sets the number of replicas, overriding the one passed to New.
+func WithReplicas(replicas int32) Option {
+	return func(p *Postgresql) {
+		p.Spec.Replicas = pointer.Int32(replicas)
+	}
+}
+
+// WithVolumeSize sets the size of the persistent volume of each replica, e.g. "1Gi".
+func WithVolumeSize(s string) Option {
+	return func(p *Postgresql) {
+		p.Spec.VolumeSize = k8sresource.MustParse(s)
+	}
+}
+
+// WithCPURequest sets the CPU request of the PostgreSQL container, e.g. "100m".
+func WithCPURequest(cpu string) Option {
+	return withResource(corev1.ResourceCPU, cpu, false)
+}
+
+// WithCPULimit sets the CPU limit of the PostgreSQL container, e.g. "100m".
+func WithCPULimit(cpu string) Option {
+	return withResource(corev1.ResourceCPU, cpu, true)
+}
+
+// WithMemoryRequest sets the memory request of the PostgreSQL container, e.g. "100Mi".
+func WithMemoryRequest(memory string) Option {
+	return withResource(corev1.ResourceMemory, memory, false)
+}
+
+// WithMemoryLimit sets the memory limit of the PostgreSQL container, e.g. "100Mi".
+func WithMemoryLimit(memory string) Option {
+	return withResource(corev1.ResourceMemory, memory, true)
+}
+
+// WithExtensions sets the PostgreSQL extensions to install.
+func WithExtensions(extensions ...string) Option {
+	return func(p *Postgresql) {
+		p.Spec.Extensions = extensions
+	}
+}
+
+// WithExpose sets where the DSI can be accessed from, "Internal" or "LoadBalancer".
+func WithExpose(expose pgv1beta3.ExposeOption) Option {
+	return func(p *Postgresql) {
+		p.Spec.Expose = expose
+	}
+}
+
+// WithReadOnlyService enables the read-only service. targetNodes sets the cluster members that it
+// points to, "replicas" or "all"; if it's empty the operator's default is used.
+func WithReadOnlyService(targetNodes string) Option {
+	return func(p *Postgresql) {
+		p.Spec.EnableReadOnlyService = true
+		p.Spec.ReadOnlyTargetNodes = targetNodes
+	}
+}
+
+// WithLabels sets the labels of the Postgresql, replacing any label that it already has.
+func WithLabels(labels map[string]string) Option {
+	return func(p *Postgresql) {
+		p.Labels = labels
+	}
+}
+
+// WithParameters sets the PostgreSQL parameters that the operator configures via Patroni.
+func WithParameters(parameters pgv1beta3.PostgresqlParameters) Option {
+	return func(p *Postgresql) {
+		p.Spec.Parameters = parameters
+	}
+}
+
+// WithTolerations sets the tolerations of the pods.
+func WithTolerations(tolerations ...corev1.Toleration) Option {
+	return func(p *Postgresql) {
+		p.SetTolerations(tolerations...)
+	}
+}
+
+// WithAffinity sets the node affinity, pod affinity and pod anti-affinity of the pods.
+func WithAffinity(affinity *corev1.Affinity) Option {
+	return func(p *Postgresql) {
+		if p.Spec.SchedulingConstraints == nil {
+			p.Spec.SchedulingConstraints = &pgv1beta3.PostgresqlSchedulingConstraints{}
+		}
+		p.Spec.SchedulingConstraints.Affinity = affinity
+	}
+}
+
+func withResource(name corev1.ResourceName, quantity string, limit bool) Option {
+	return func(p *Postgresql) {
+		if p.Spec.Resources == nil {
+			p.Spec.Resources = &corev1.ResourceRequirements{}
+		}
+		list := &p.Spec.Resources.Requests
+		if limit {
+			list = &p.Spec.Resources.Limits
+		}
+		if *list == nil {
+			*list = corev1.ResourceList{}
+		}
+		(*list)[name] = k8sresource.MustParse(quantity)
+	}
+}
@@ -0,0 +1,141 @@
+package postgresql_test
+
+import (
+	"fmt"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	k8sresource "k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/anynines/a8s-deployment/test/framework/postgresql"
+	"github.com/anynines/postgresql-operator/api/v1beta3"
+)
+
+func TestOptions(t *testing.T) {
+	t.Parallel()
+
+	toleration := corev1.Toleration{Key: "k", Operator: corev1.TolerationOpExists}
+	affinity := &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{}}
+
+	testCases := map[string]struct {
+		opts []postgresql.Option
+		// check returns a description of what's wrong with pg, or "" if nothing is.
+		check func(pg *postgresql.Postgresql) string
+	}{
+		"version": {
+			opts: []postgresql.Option{postgresql.WithVersion(16)},
+			check: func(pg *postgresql.Postgresql) string {
+				return expect(pg.Spec.Version == 16, "version 16", pg.Spec.Version)
+			},
+		},
+		"replicas_override_the_ones_passed_to_new": {
+			opts: []postgresql.Option{postgresql.WithReplicas(5)},
+			check: func(pg *postgresql.Postgresql) string {
+				return expect(*pg.Spec.Replicas == 5, "5 replicas", *pg.Spec.Replicas)
+			},
+		},
+		"volume_size": {
+			opts: []postgresql.Option{postgresql.WithVolumeSize("3Gi")},
+			check: func(pg *postgresql.Postgresql) string {
+				return expectQuantity(pg.Spec.VolumeSize, "3Gi")
+			},
+		},
+		"resources_only_change_the_given_ones": {
+			opts: []postgresql.Option{
+				postgresql.WithCPURequest("100m"),
+				postgresql.WithCPULimit("200m"),
+				postgresql.WithMemoryRequest("100Mi"),
+			},
+			check: func(pg *postgresql.Postgresql) string {
+				r := pg.Spec.Resources
+				for _, problem := range []string{
+					expectQuantity(r.Requests[corev1.ResourceCPU], "100m"),
+					expectQuantity(r.Limits[corev1.ResourceCPU], "200m"),
+					expectQuantity(r.Requests[corev1.ResourceMemory], "100Mi"),
+					expect(!r.Limits.Memory().IsZero(), "the default memory limit",
+						r.Limits.Memory()),
+				} {
+					if problem != "" {
+						return problem
+					}
+				}
+				return ""
+			},
+		},
+		"extensions": {
+			opts: []postgresql.Option{postgresql.WithExtensions("postgis", "pg-qualstats")},
+			check: func(pg *postgresql.Postgresql) string {
+				want := []string{"postgis", "pg-qualstats"}
+				return expect(equality.Semantic.DeepEqual(pg.Spec.Extensions, want),
+					want, pg.Spec.Extensions)
+			},
+		},
+		"expose_and_read_only_service": {
+			opts: []postgresql.Option{
+				postgresql.WithExpose("LoadBalancer"),
+				postgresql.WithReadOnlyService("all"),
+			},
+			check: func(pg *postgresql.Postgresql) string {
+				return expect(pg.Spec.Expose == "LoadBalancer" && pg.Spec.EnableReadOnlyService &&
+					pg.Spec.ReadOnlyTargetNodes == "all",
+					"exposed via load balancer with read-only service to all nodes", pg.Spec)
+			},
+		},
+		"labels": {
+			opts: []postgresql.Option{postgresql.WithLabels(map[string]string{"a": "b"})},
+			check: func(pg *postgresql.Postgresql) string {
+				return expect(len(pg.Labels) == 1 && pg.Labels["a"] == "b", "label a=b", pg.Labels)
+			},
+		},
+		"parameters": {
+			opts: []postgresql.Option{
+				postgresql.WithParameters(v1beta3.PostgresqlParameters{MaxConnections: 101}),
+			},
+			check: func(pg *postgresql.Postgresql) string {
+				return expect(pg.Spec.Parameters.MaxConnections == 101, "101 max connections",
+					pg.Spec.Parameters.MaxConnections)
+			},
+		},
+		"scheduling_constraints": {
+			opts: []postgresql.Option{
+				postgresql.WithTolerations(toleration),
+				postgresql.WithAffinity(affinity),
+			},
+			check: func(pg *postgresql.Postgresql) string {
+				sc := pg.Spec.SchedulingConstraints
+				return expect(sc != nil && len(sc.Tolerations) == 1 &&
+					sc.Tolerations[0] == toleration && sc.Affinity == affinity,
+					"the toleration and affinity", sc)
+			},
+		},
+	}
+
+	for tcName, tc := range testCases {
+		// Rebind tc into this lexical scope. Details on the why at
+		// https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		tc := tc
+		t.Run(tcName, func(t *testing.T) {
+			t.Parallel()
+
+			pg := postgresql.New("ns0", "pg0", 3, tc.opts...)
+			if problem := tc.check(pg); problem != "" {
+				t.Fatal(problem)
+			}
+			if pg.Namespace != "ns0" || pg.Name != "pg0" {
+				t.Fatalf("Expected namespace and name ns0/pg0, got %s/%s", pg.Namespace, pg.Name)
+			}
+		})
+	}
+}
+
+func expect(ok bool, want, got any) string {
+	if ok {
+		return ""
+	}
+	return fmt.Sprintf("Expected %v, got %v", want, got)
+}
+
+func expectQuantity(got k8sresource.Quantity, want string) string {
+	return expect(got.Cmp(k8sresource.MustParse(want)) == 0, want, got.String())
+}
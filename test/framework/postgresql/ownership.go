@@ -0,0 +1,258 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	runtimeClient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	pgv1beta3 "github.com/anynines/postgresql-operator/api/v1beta3"
+)
+
+const pvcNamePrefix = "pgdata"
+
+// ErrNotOwned is returned (wrapped) when an object that should belong to a Postgresql is not
+// controlled by the Postgresql currently stored in the API server, e.g. because it was left over
+// by a deleted Postgresql with the same namespace and name.
+var ErrNotOwned = errors.New("not owned by the current instance")
+
+// Owned splits the objects found for a Postgresql into the ones that belong to the current
+// Postgresql, i.e. the one currently stored in the API server, and the stale ones, which belong to
+// a previous Postgresql with the same namespace and name (or to none at all).
+type Owned[T any] struct {
+	Current []T
+	Stale   []T
+}
+
+// StatefulSet returns the StatefulSet of pg. It returns an error wrapping ErrNotOwned if the
+// StatefulSet with the name of pg is not controlled by the current Postgresql with the namespace
+// and name of pg.
+// TODO: make the K8s client a field of pg rather than something to pass to its functions.
+func (pg Postgresql) StatefulSet(ctx context.Context,
+	k8sClient runtimeClient.Client,
+) (*appsv1.StatefulSet, error) {
+	current, err := pg.current(ctx, k8sClient)
+	if err != nil {
+		return nil, err
+	}
+	return ownedStatefulSet(ctx, k8sClient, current)
+}
+
+// Pods uses `k8sClient` to retrieve all the pods that belong to the current Postgresql with the
+// namespace and name of `pg`, that is the pods controlled by its StatefulSet. Use OwnedPods to
+// get the stale pods too.
+func (pg Postgresql) Pods(ctx context.Context,
+	k8sClient runtimeClient.Client,
+) ([]corev1.Pod, error) {
+	pods, err := pg.OwnedPods(ctx, k8sClient)
+	if err != nil {
+		return nil, err
+	}
+	return pods.Current, nil
+}
+
+// OwnedPods returns the pods with the labels of the DSI `pg`, split into the ones controlled by
+// the StatefulSet of the current Postgresql with the namespace and name of `pg` and the stale
+// ones. If the current Postgresql has no StatefulSet yet, all the pods are stale.
+func (pg Postgresql) OwnedPods(ctx context.Context,
+	k8sClient runtimeClient.Client,
+) (Owned[corev1.Pod], error) {
+	current, err := pg.current(ctx, k8sClient)
+	if err != nil {
+		return Owned[corev1.Pod]{}, err
+	}
+	var ssUID types.UID
+	ss, err := ownedStatefulSet(ctx, k8sClient, current)
+	switch {
+	case err == nil:
+		ssUID = ss.UID
+	case !noStatefulSet(err):
+		return Owned[corev1.Pod]{}, err
+	}
+
+	podsSelector, err := pg.podsLabels().AsValidatedSelector()
+	if err != nil {
+		return Owned[corev1.Pod]{}, fmt.Errorf("failed to generate label selector for pods of "+
+			"%s/%s: %w", pg.Namespace, pg.Name, err)
+	}
+
+	pods := &corev1.PodList{}
+	if err := k8sClient.List(ctx, pods, &runtimeClient.ListOptions{
+		LabelSelector: podsSelector,
+		Namespace:     pg.Namespace,
+	}); err != nil {
+		return Owned[corev1.Pod]{}, fmt.Errorf("failed to list pods for %s/%s: %w",
+			pg.Namespace, pg.Name, err)
+	}
+
+	owned := Owned[corev1.Pod]{}
+	for _, pod := range pods.Items {
+		if ssUID != "" && isControlledBy(&pod, ssUID) {
+			owned.Current = append(owned.Current, pod)
+		} else {
+			owned.Stale = append(owned.Stale, pod)
+		}
+	}
+	return owned, nil
+}
+
+// OwnedPVCs returns the persistent volume claims of the replicas of `pg`, split into the ones
+// that belong to the current Postgresql with the namespace and name of `pg` and the stale ones.
+// A claim belongs to the current Postgresql if it's controlled by it or by its StatefulSet or, for
+// claims without controller (the default for claims created by a StatefulSet), if it was created
+// after the current Postgresql. A stale claim without controller means that the current
+// Postgresql has adopted the volume of a deleted one.
+func (pg Postgresql) OwnedPVCs(ctx context.Context,
+	k8sClient runtimeClient.Client,
+) (Owned[corev1.PersistentVolumeClaim], error) {
+	current, err := pg.current(ctx, k8sClient)
+	if err != nil {
+		return Owned[corev1.PersistentVolumeClaim]{}, err
+	}
+	ownerUIDs := map[types.UID]bool{current.UID: true}
+	ss, err := ownedStatefulSet(ctx, k8sClient, current)
+	switch {
+	case err == nil:
+		ownerUIDs[ss.UID] = true
+	case !noStatefulSet(err):
+		return Owned[corev1.PersistentVolumeClaim]{}, err
+	}
+
+	pvcs := &corev1.PersistentVolumeClaimList{}
+	if err := k8sClient.List(ctx, pvcs, runtimeClient.InNamespace(pg.Namespace)); err != nil {
+		return Owned[corev1.PersistentVolumeClaim]{}, fmt.Errorf("failed to list persistent "+
+			"volume claims for %s/%s: %w", pg.Namespace, pg.Name, err)
+	}
+
+	owned := Owned[corev1.PersistentVolumeClaim]{}
+	for _, pvc := range pvcs.Items {
+		if !isReplicaPVCName(pvc.Name, pg.Name) {
+			continue
+		}
+
+		var isCurrent bool
+		if ref := metav1.GetControllerOf(&pvc); ref != nil {
+			isCurrent = ownerUIDs[ref.UID]
+		} else {
+			isCurrent = !pvc.CreationTimestamp.Before(&current.CreationTimestamp)
+		}
+
+		if isCurrent {
+			owned.Current = append(owned.Current, pvc)
+		} else {
+			owned.Stale = append(owned.Stale, pvc)
+		}
+	}
+	return owned, nil
+}
+
+// StaleObjects returns the StatefulSet, pods and persistent volume claims found for `pg` that
+// don't belong to the current Postgresql with the namespace and name of `pg`. Tests use it to
+// assert that a recreated instance doesn't adopt leftovers of a deleted one.
+func (pg Postgresql) StaleObjects(ctx context.Context,
+	k8sClient runtimeClient.Client,
+) ([]runtimeClient.Object, error) {
+	stale := []runtimeClient.Object{}
+
+	_, err := pg.StatefulSet(ctx, k8sClient)
+	if errors.Is(err, ErrNotOwned) {
+		ss := &appsv1.StatefulSet{}
+		if err := k8sClient.Get(ctx, pg.key(), ss); err == nil {
+			stale = append(stale, ss)
+		}
+	}
+
+	pods, err := pg.OwnedPods(ctx, k8sClient)
+	if err != nil {
+		return nil, err
+	}
+	for i := range pods.Stale {
+		stale = append(stale, &pods.Stale[i])
+	}
+
+	pvcs, err := pg.OwnedPVCs(ctx, k8sClient)
+	if err != nil {
+		return nil, err
+	}
+	for i := range pvcs.Stale {
+		stale = append(stale, &pvcs.Stale[i])
+	}
+
+	return stale, nil
+}
+
+// current returns the Postgresql currently stored in the API server with the namespace and name
+// of pg, whose UID might differ from the one of pg.
+func (pg Postgresql) current(ctx context.Context,
+	k8sClient runtimeClient.Client,
+) (*pgv1beta3.Postgresql, error) {
+	current := &pgv1beta3.Postgresql{}
+	if err := k8sClient.Get(ctx, pg.key(), current); err != nil {
+		return nil, fmt.Errorf("failed to get instance %s: %w", pg.key(), err)
+	}
+	return current, nil
+}
+
+// podsLabels returns the labels of the pods (and of other secondary objects) of pg, and of the ones
+// of the previous Postgresqls with the same namespace and name.
+func (pg Postgresql) podsLabels() labels.Set {
+	return labels.Set{
+		pgv1beta3.DSINameLabelKey:  pg.Name,
+		pgv1beta3.DSIGroupLabelKey: pgv1beta3.GroupVersion.Group,
+		pgv1beta3.DSIKindLabelKey:  kind,
+	}
+}
+
+func (pg Postgresql) key() types.NamespacedName {
+	return types.NamespacedName{Namespace: pg.Namespace, Name: pg.Name}
+}
+
+// ownedStatefulSet returns the StatefulSet of current, or an error wrapping ErrNotOwned if the
+// StatefulSet with the name of current is not controlled by it.
+func ownedStatefulSet(ctx context.Context,
+	k8sClient runtimeClient.Client,
+	current *pgv1beta3.Postgresql,
+) (*appsv1.StatefulSet, error) {
+	nsn := types.NamespacedName{Namespace: current.Namespace, Name: current.Name}
+	ss := &appsv1.StatefulSet{}
+	if err := k8sClient.Get(ctx, nsn, ss); err != nil {
+		return nil, fmt.Errorf("failed to get statefulset for instance %s: %w", nsn, err)
+	}
+
+	if !isControlledBy(ss, current.UID) {
+		return nil, fmt.Errorf("statefulset %s with controller %v: %w", nsn,
+			metav1.GetControllerOf(ss), ErrNotOwned)
+	}
+	return ss, nil
+}
+
+// noStatefulSet returns true if err, returned by ownedStatefulSet, means that the current
+// Postgresql has no StatefulSet (yet).
+func noStatefulSet(err error) bool {
+	return errors.Is(err, ErrNotOwned) || apierrors.IsNotFound(err)
+}
+
+func isControlledBy(o metav1.Object, ownerUID types.UID) bool {
+	ref := metav1.GetControllerOf(o)
+	return ref != nil && ref.UID == ownerUID
+}
+
+// isReplicaPVCName returns true if name is the name of the persistent volume claim of a replica of
+// the instance named instanceName (see PvcName).
+func isReplicaPVCName(name, instanceName string) bool {
+	ordinal, found := strings.CutPrefix(name, fmt.Sprintf("%s-%s-", pvcNamePrefix, instanceName))
+	if !found {
+		return false
+	}
+	_, err := strconv.Atoi(ordinal)
+	return err == nil
+}
@@ -0,0 +1,286 @@
+package postgresql_test
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/anynines/a8s-deployment/test/framework/postgresql"
+	"github.com/anynines/postgresql-operator/api/v1beta3"
+)
+
+func TestOwnedPodsSeparatesStalePods(t *testing.T) {
+	t.Parallel()
+
+	pg := newCreatedPostgresql("pg-uid", time.Now())
+	ss := newStatefulSet(pg, "ss-uid")
+	oldSS := newStatefulSet(pg, "old-ss-uid")
+	k8sClient := newFakeClient(pg.Postgresql, ss,
+		newPod(withName("pg0-0"), withNamespace("ns0"), withLabels(dsiPodLabels()),
+			withController(ss)),
+		newPod(withName("pg0-1"), withNamespace("ns0"), withLabels(dsiPodLabels()),
+			withController(oldSS)),
+		newPod(withName("pg0-2"), withNamespace("ns0"), withLabels(dsiPodLabels())),
+	)
+
+	owned, err := pg.OwnedPods(context.Background(), k8sClient)
+	if err != nil {
+		t.Fatalf("Expected no error, got: \"%v\"", err)
+	}
+	if got := podNames(owned.Current); !equalNames(got, "pg0-0") {
+		t.Fatalf("Expected current pods [pg0-0], got %v", got)
+	}
+	if got := podNames(owned.Stale); !equalNames(got, "pg0-1", "pg0-2") {
+		t.Fatalf("Expected stale pods [pg0-1 pg0-2], got %v", got)
+	}
+}
+
+func TestOwnedPodsAreAllStaleWithoutCurrentStatefulSet(t *testing.T) {
+	t.Parallel()
+
+	pg := newCreatedPostgresql("pg-uid", time.Now())
+	// The StatefulSet was left over by a previous DSI with the same name.
+	oldPG := newCreatedPostgresql("old-pg-uid", time.Now())
+	oldSS := newStatefulSet(oldPG, "old-ss-uid")
+	k8sClient := newFakeClient(pg.Postgresql, oldSS,
+		newPod(withName("pg0-0"), withNamespace("ns0"), withLabels(dsiPodLabels()),
+			withController(oldSS)),
+	)
+
+	owned, err := pg.OwnedPods(context.Background(), k8sClient)
+	if err != nil {
+		t.Fatalf("Expected no error, got: \"%v\"", err)
+	}
+	if len(owned.Current) != 0 {
+		t.Fatalf("Expected no current pods, got %v", podNames(owned.Current))
+	}
+	if got := podNames(owned.Stale); !equalNames(got, "pg0-0") {
+		t.Fatalf("Expected stale pods [pg0-0], got %v", got)
+	}
+
+	_, err = pg.StatefulSet(context.Background(), k8sClient)
+	if !errors.Is(err, postgresql.ErrNotOwned) {
+		t.Fatalf("Expected error wrapping ErrNotOwned, got: \"%v\"", err)
+	}
+}
+
+func TestOwnedPVCs(t *testing.T) {
+	t.Parallel()
+
+	created := time.Now().Truncate(time.Second)
+	before, after := created.Add(-time.Hour), created.Add(time.Hour)
+
+	testCases := map[string]struct {
+		pvc       *corev1.PersistentVolumeClaim
+		wantStale bool
+		// ignored means that the claim is not one of the DSI's.
+		ignored bool
+	}{
+		"claim_controlled_by_the_current_statefulset_is_current": {
+			pvc: newPVC(postgresql.PvcName("pg0", 0), before, "ss-uid"),
+		},
+		"claim_controlled_by_the_current_dsi_is_current": {
+			pvc: newPVC(postgresql.PvcName("pg0", 0), before, "pg-uid"),
+		},
+		"claim_controlled_by_an_old_statefulset_is_stale": {
+			pvc:       newPVC(postgresql.PvcName("pg0", 1), after, "old-ss-uid"),
+			wantStale: true,
+		},
+		"claim_without_controller_created_after_the_dsi_is_current": {
+			pvc: newPVC(postgresql.PvcName("pg0", 2), after, ""),
+		},
+		"claim_without_controller_created_before_the_dsi_is_stale": {
+			pvc:       newPVC(postgresql.PvcName("pg0", 2), before, ""),
+			wantStale: true,
+		},
+		"claim_of_a_dsi_with_a_name_with_the_same_prefix_is_ignored": {
+			pvc:     newPVC(postgresql.PvcName("pg0-1", 0), before, ""),
+			ignored: true,
+		},
+		"claim_in_another_namespace_is_ignored": {
+			pvc: func() *corev1.PersistentVolumeClaim {
+				pvc := newPVC(postgresql.PvcName("pg0", 0), before, "")
+				pvc.Namespace = "ns1"
+				return pvc
+			}(),
+			ignored: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			// Rebind tc into this lexical scope. Details on the why at
+			// https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+			tc := tc
+
+			t.Parallel()
+
+			pg := newCreatedPostgresql("pg-uid", created)
+			k8sClient := newFakeClient(pg.Postgresql, newStatefulSet(pg, "ss-uid"), tc.pvc)
+
+			owned, err := pg.OwnedPVCs(context.Background(), k8sClient)
+			if err != nil {
+				t.Fatalf("Expected no error, got: \"%v\"", err)
+			}
+
+			wantCurrent, wantStale := 1, 0
+			switch {
+			case tc.ignored:
+				wantCurrent = 0
+			case tc.wantStale:
+				wantCurrent, wantStale = 0, 1
+			}
+			if len(owned.Current) != wantCurrent || len(owned.Stale) != wantStale {
+				t.Fatalf("Expected %d current and %d stale claims, got %d current and %d stale",
+					wantCurrent, wantStale, len(owned.Current), len(owned.Stale))
+			}
+		})
+	}
+}
+
+func TestStaleObjectsOfRecreatedInstance(t *testing.T) {
+	t.Parallel()
+
+	created := time.Now().Truncate(time.Second)
+	pg := newCreatedPostgresql("pg-uid", created)
+	ss := newStatefulSet(pg, "ss-uid")
+	k8sClient := newFakeClient(pg.Postgresql, ss,
+		newPod(withName("pg0-0"), withNamespace("ns0"), withLabels(dsiPodLabels()),
+			withController(ss)),
+		newPVC(postgresql.PvcName("pg0", 0), created.Add(time.Hour), ""),
+	)
+
+	stale, err := pg.StaleObjects(context.Background(), k8sClient)
+	if err != nil {
+		t.Fatalf("Expected no error, got: \"%v\"", err)
+	}
+	if len(stale) != 0 {
+		t.Fatalf("Expected no stale objects, got %d", len(stale))
+	}
+
+	// The claim of a second replica was adopted from a deleted DSI with the same name.
+	oldPVC := newPVC(postgresql.PvcName("pg0", 1), created.Add(-time.Hour), "")
+	if err := k8sClient.Create(context.Background(), oldPVC); err != nil {
+		t.Fatalf("Expected no error creating claim, got: \"%v\"", err)
+	}
+
+	stale, err = pg.StaleObjects(context.Background(), k8sClient)
+	if err != nil {
+		t.Fatalf("Expected no error, got: \"%v\"", err)
+	}
+	if len(stale) != 1 || stale[0].GetName() != oldPVC.Name {
+		t.Fatalf("Expected the claim %s to be the only stale object, got %d stale objects",
+			oldPVC.Name, len(stale))
+	}
+}
+
+func newCreatedPostgresql(uid types.UID, created time.Time) *postgresql.Postgresql {
+	pg := postgresql.New("ns0", "pg0", 3)
+	pg.UID = uid
+	pg.CreationTimestamp = metav1.NewTime(created)
+	return pg
+}
+
+func newStatefulSet(pg *postgresql.Postgresql, uid types.UID) *appsv1.StatefulSet {
+	return &appsv1.StatefulSet{
+		TypeMeta: metav1.TypeMeta{APIVersion: "apps/v1", Kind: "StatefulSet"},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: pg.Namespace,
+			Name:      pg.Name,
+			UID:       uid,
+			OwnerReferences: []metav1.OwnerReference{
+				controllerRef(pg.GetClientObject()),
+			},
+		},
+	}
+}
+
+func newPVC(name string, created time.Time,
+	controllerUID types.UID) *corev1.PersistentVolumeClaim {
+
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:         "ns0",
+			Name:              name,
+			CreationTimestamp: metav1.NewTime(created),
+		},
+	}
+	if controllerUID != "" {
+		pvc.OwnerReferences = []metav1.OwnerReference{{
+			APIVersion: "apps/v1",
+			Kind:       "StatefulSet",
+			Name:       "pg0",
+			UID:        controllerUID,
+			Controller: pointer.Bool(true),
+		}}
+	}
+	return pvc
+}
+
+func controllerRef(owner client.Object) metav1.OwnerReference {
+	return metav1.OwnerReference{
+		APIVersion: owner.GetObjectKind().GroupVersionKind().GroupVersion().String(),
+		Kind:       owner.GetObjectKind().GroupVersionKind().Kind,
+		Name:       owner.GetName(),
+		UID:        owner.GetUID(),
+		Controller: pointer.Bool(true),
+	}
+}
+
+func withController(owner client.Object) func(*corev1.Pod) {
+	return func(p *corev1.Pod) {
+		p.OwnerReferences = []metav1.OwnerReference{controllerRef(owner)}
+	}
+}
+
+func dsiPodLabels() map[string]string {
+	return map[string]string{
+		v1beta3.DSINameLabelKey:  "pg0",
+		v1beta3.DSIKindLabelKey:  "Postgresql",
+		v1beta3.DSIGroupLabelKey: "postgresql.anynines.com",
+	}
+}
+
+func newFakeClient(objs ...client.Object) client.Client {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		panic(err)
+	}
+	if err := v1beta3.AddToScheme(scheme); err != nil {
+		panic(err)
+	}
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()
+}
+
+func podNames(pods []corev1.Pod) []string {
+	names := make([]string, 0, len(pods))
+	for _, p := range pods {
+		names = append(names, p.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func equalNames(got []string, want ...string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
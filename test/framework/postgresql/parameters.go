@@ -0,0 +1,269 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/jackc/pgx/v4"
+
+	"github.com/anynines/a8s-deployment/test/framework/dsi"
+)
+
+// The types of parameters in pg_settings.vartype.
+const (
+	VarTypeBool    = "bool"
+	VarTypeEnum    = "enum"
+	VarTypeInteger = "integer"
+	VarTypeReal    = "real"
+	VarTypeString  = "string"
+)
+
+// memoryUnits are the memory units of PostgreSQL in bytes.
+var memoryUnits = map[string]float64{
+	"B":  1,
+	"kB": 1 << 10,
+	"MB": 1 << 20,
+	"GB": 1 << 30,
+	"TB": 1 << 40,
+}
+
+// timeUnits are the time units of PostgreSQL in microseconds.
+var timeUnits = map[string]float64{
+	"us":  1,
+	"ms":  1e3,
+	"s":   1e6,
+	"min": 60e6,
+	"h":   3600e6,
+	"d":   86400e6,
+}
+
+// The kinds of units of PostgreSQL.
+const (
+	memory   = "memory"
+	duration = "time"
+)
+
+// units are the units of PostgreSQL in their base unit, by kind.
+var units = map[string]map[string]float64{
+	memory:   memoryUnits,
+	duration: timeUnits,
+}
+
+// boolValues are the spellings of booleans that PostgreSQL accepts, without unique prefixes.
+var boolValues = map[string]bool{
+	"on":    true,
+	"true":  true,
+	"yes":   true,
+	"1":     true,
+	"off":   false,
+	"false": false,
+	"no":    false,
+	"0":     false,
+}
+
+// Parameter is a configuration parameter of PostgreSQL as reported by pg_settings.
+type Parameter struct {
+	Name string
+	// Setting is the current value in Unit, e.g. "16384" for shared_buffers with the unit "8kB".
+	Setting string
+	// Unit is the implicit unit of Setting, e.g. "8kB", "ms" or "" for parameters without unit.
+	Unit string
+	// VarType is the type of the parameter, one of the VarType constants.
+	VarType string
+	// PendingRestart is true if the parameter was changed in the configuration files but the
+	// change only takes effect after a restart, i.e. Setting is still the old value.
+	PendingRestart bool
+}
+
+func (p Parameter) String() string {
+	s := fmt.Sprintf("%s = %s", p.Name, p.Setting)
+	if p.Unit != "" {
+		s += fmt.Sprintf(" (unit %s)", p.Unit)
+	}
+	if p.PendingRestart {
+		s += ", pending restart"
+	}
+	return s
+}
+
+// Equal returns whether the parameter is set to value. Unlike comparing the output of SHOW,
+// it compares in the semantics of the type of the parameter:
+//   - Integers and reals may have a memory or time unit, e.g. "100MB" or "10s", which is converted
+//     to the unit of the parameter and rounded like PostgreSQL does. Without a unit they're in the
+//     unit of the parameter.
+//   - Booleans may be spelled in any way PostgreSQL accepts, e.g. "on", "true" or "1".
+//   - Enums are compared case-insensitively.
+//
+// It returns an error if value isn't valid for the type of the parameter.
+func (p Parameter) Equal(value string) (bool, error) {
+	switch p.VarType {
+	case VarTypeBool:
+		setting, err := parseBool(p.Setting)
+		if err != nil {
+			return false, err
+		}
+		expected, err := parseBool(value)
+		return setting == expected, err
+	case VarTypeEnum:
+		return strings.EqualFold(p.Setting, strings.TrimSpace(value)), nil
+	case VarTypeInteger, VarTypeReal:
+		setting, err := strconv.ParseFloat(p.Setting, 64)
+		if err != nil {
+			return false, fmt.Errorf("failed to parse setting %s of parameter %s: %w", p.Setting,
+				p.Name, err)
+		}
+		expected, err := p.inUnit(value)
+		if err != nil {
+			return false, err
+		}
+		if p.VarType == VarTypeInteger {
+			return setting == math.Round(expected), nil
+		}
+		return math.Abs(setting-expected) <= 1e-9*math.Max(1, math.Abs(setting)), nil
+	default:
+		return p.Setting == value, nil
+	}
+}
+
+// Bytes returns the setting of a parameter with a memory unit in bytes, e.g. 134217728 for
+// shared_buffers set to 128MB. Settings such as -1 that disable a limit are converted as well.
+func (p Parameter) Bytes() (int64, error) {
+	v, err := p.normalised(memory)
+	return int64(v), err
+}
+
+// Duration returns the setting of a parameter with a time unit, e.g. 200ms for wal_writer_delay.
+// Settings such as -1 that disable a timeout are converted as well.
+func (p Parameter) Duration() (time.Duration, error) {
+	v, err := p.normalised(duration)
+	return time.Duration(v) * time.Microsecond, err
+}
+
+// normalised returns the setting of p in the base unit of kind, or an error if the unit of p
+// isn't of kind.
+func (p Parameter) normalised(kind string) (float64, error) {
+	scale, kindOfP, err := parseUnit(p.Unit)
+	if err != nil {
+		return 0, err
+	}
+	if kindOfP != kind {
+		return 0, fmt.Errorf("parameter %s has unit %q and not a %s unit", p.Name, p.Unit, kind)
+	}
+	setting, err := strconv.ParseFloat(p.Setting, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse setting %s of parameter %s: %w", p.Setting, p.Name,
+			err)
+	}
+	return setting * scale, nil
+}
+
+// inUnit converts value, e.g. "100MB" or "64", to the unit of p.
+func (p Parameter) inUnit(value string) (float64, error) {
+	scale, kind, err := parseUnit(p.Unit)
+	if err != nil {
+		return 0, err
+	}
+	value = strings.TrimSpace(value)
+	i := strings.IndexFunc(value, unicode.IsLetter)
+	if i < 0 {
+		i = len(value)
+	}
+	number, unit := strings.TrimSpace(value[:i]), value[i:]
+	v, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q for parameter %s: %w", value, p.Name, err)
+	}
+	if unit == "" {
+		return v, nil
+	}
+	if kind == "" {
+		return 0, fmt.Errorf("invalid value %q for parameter %s: parameter has no unit", value,
+			p.Name)
+	}
+	s, ok := units[kind][unit]
+	if !ok {
+		return 0, fmt.Errorf("invalid value %q for parameter %s: unit %s isn't one of %v", value,
+			p.Name, unit, sortedUnits(units[kind]))
+	}
+	return v * s / scale, nil
+}
+
+// parseUnit parses a unit of pg_settings, e.g. "8kB", into its size in the base unit of its kind
+// and its kind. Both are zero for the empty unit.
+func parseUnit(unit string) (float64, string, error) {
+	if unit == "" {
+		return 0, "", nil
+	}
+	i := strings.IndexFunc(unit, unicode.IsLetter)
+	if i < 0 {
+		return 0, "", fmt.Errorf("invalid unit %q", unit)
+	}
+	multiplier := 1.0
+	if i > 0 {
+		m, err := strconv.ParseFloat(unit[:i], 64)
+		if err != nil {
+			return 0, "", fmt.Errorf("invalid unit %q: %w", unit, err)
+		}
+		multiplier = m
+	}
+	for _, kind := range []string{memory, duration} {
+		if s, ok := units[kind][unit[i:]]; ok {
+			return multiplier * s, kind, nil
+		}
+	}
+	return 0, "", fmt.Errorf("unknown unit %q", unit)
+}
+
+func parseBool(value string) (bool, error) {
+	b, ok := boolValues[strings.ToLower(strings.TrimSpace(value))]
+	if !ok {
+		return false, fmt.Errorf("invalid boolean %q", value)
+	}
+	return b, nil
+}
+
+// sortedUnits returns the names of the units of a kind, sorted by size.
+func sortedUnits(ofKind map[string]float64) []string {
+	sorted := make([]string, 0, len(ofKind))
+	for u := range ofKind {
+		sorted = append(sorted, u)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return ofKind[sorted[i]] < ofKind[sorted[j]] })
+	return sorted
+}
+
+// Parameter returns the configuration parameter name as reported by pg_settings. Parameter
+// names are case-insensitive. It returns an error wrapping dsi.ErrNotFound if there's no such
+// parameter.
+func (c Client) Parameter(ctx context.Context, name string) (Parameter, error) {
+	dbConn, err := c.connectToDB(ctx)
+	if err != nil {
+		return Parameter{}, err
+	}
+	defer func() { closeConnection(ctx, dbConn) }()
+	return parameter(ctx, dbConn, name)
+}
+
+func parameter(ctx context.Context, dbConn *pgx.Conn, name string) (Parameter, error) {
+	// pg_settings has the names in lower case.
+	var p Parameter
+	query := "SELECT name, setting, coalesce(unit, ''), vartype, pending_restart " +
+		"FROM pg_settings WHERE name = lower($1);"
+	err := dbConn.QueryRow(ctx, query, name).Scan(&p.Name, &p.Setting, &p.Unit, &p.VarType,
+		&p.PendingRestart)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Parameter{}, fmt.Errorf("failed to get parameter %s: %w", name, dsi.ErrNotFound)
+	}
+	if err != nil {
+		return Parameter{}, fmt.Errorf("failed to get parameter %s with query %s: %w", name,
+			query, err)
+	}
+	return p, nil
+}
@@ -0,0 +1,226 @@
+package postgresql_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/anynines/a8s-deployment/test/framework/postgresql"
+)
+
+func TestParameterEqual(t *testing.T) {
+	t.Parallel()
+
+	sharedBuffers := postgresql.Parameter{Name: "shared_buffers", Setting: "12800", Unit: "8kB",
+		VarType: postgresql.VarTypeInteger}
+	archiveTimeout := postgresql.Parameter{Name: "archive_timeout", Setting: "10", Unit: "s",
+		VarType: postgresql.VarTypeInteger}
+	walWriterDelay := postgresql.Parameter{Name: "wal_writer_delay", Setting: "200", Unit: "ms",
+		VarType: postgresql.VarTypeInteger}
+	tempFileLimit := postgresql.Parameter{Name: "temp_file_limit", Setting: "-1", Unit: "kB",
+		VarType: postgresql.VarTypeInteger}
+	maxConnections := postgresql.Parameter{Name: "max_connections", Setting: "100",
+		VarType: postgresql.VarTypeInteger}
+	costDelay := postgresql.Parameter{Name: "vacuum_cost_delay", Setting: "2", Unit: "ms",
+		VarType: postgresql.VarTypeReal}
+	trackIOTiming := postgresql.Parameter{Name: "track_io_timing", Setting: "off",
+		VarType: postgresql.VarTypeBool}
+	logErrorVerbosity := postgresql.Parameter{Name: "log_error_verbosity", Setting: "default",
+		VarType: postgresql.VarTypeEnum}
+	sslCiphers := postgresql.Parameter{Name: "ssl_ciphers", Setting: "HIGH:!aNULL",
+		VarType: postgresql.VarTypeString}
+
+	testCases := map[string]struct {
+		parameter postgresql.Parameter
+		value     string
+		want      bool
+		wantErr   bool
+	}{
+		"memory in the unit of the parameter": {
+			parameter: sharedBuffers, value: "12800", want: true,
+		},
+		"memory in another unit": {
+			parameter: sharedBuffers, value: "100MB", want: true,
+		},
+		"memory with a space before the unit": {
+			parameter: sharedBuffers, value: "100 MB", want: true,
+		},
+		"different memory": {
+			parameter: sharedBuffers, value: "200MB", want: false,
+		},
+		"memory rounded to the unit of the parameter": {
+			parameter: sharedBuffers, value: "102401kB", want: true,
+		},
+		"time in seconds": {
+			parameter: archiveTimeout, value: "10s", want: true,
+		},
+		"time in milliseconds": {
+			parameter: archiveTimeout, value: "10000ms", want: true,
+		},
+		"time in the unit of the parameter": {
+			parameter: walWriterDelay, value: "200", want: true,
+		},
+		"different time": {
+			parameter: walWriterDelay, value: "201ms", want: false,
+		},
+		"disabled limit": {
+			parameter: tempFileLimit, value: "-1", want: true,
+		},
+		"integer without unit": {
+			parameter: maxConnections, value: "100", want: true,
+		},
+		"real in another unit": {
+			parameter: costDelay, value: "2000us", want: true,
+		},
+		"boolean spelled differently": {
+			parameter: trackIOTiming, value: "false", want: true,
+		},
+		"different boolean": {
+			parameter: trackIOTiming, value: "on", want: false,
+		},
+		"enum in upper case": {
+			parameter: logErrorVerbosity, value: "DEFAULT", want: true,
+		},
+		"string": {
+			parameter: sslCiphers, value: "HIGH:!aNULL", want: true,
+		},
+		"string in another case": {
+			parameter: sslCiphers, value: "high:!aNULL", want: false,
+		},
+		"memory with a time unit": {
+			parameter: sharedBuffers, value: "10s", wantErr: true,
+		},
+		"unit for a parameter without unit": {
+			parameter: maxConnections, value: "100MB", wantErr: true,
+		},
+		"invalid number": {
+			parameter: maxConnections, value: "many", wantErr: true,
+		},
+		"invalid boolean": {
+			parameter: trackIOTiming, value: "maybe", wantErr: true,
+		},
+	}
+
+	for tcName, tc := range testCases {
+		// Rebind tc into this lexical scope. Details on the why at
+		// https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		tc := tc
+		t.Run(tcName, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := tc.parameter.Equal(tc.value)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("Expected an error for %q, got none", tc.value)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got: \"%v\"", err)
+			}
+			if got != tc.want {
+				t.Fatalf("Expected %v to equal %q to be %t, got %t", tc.parameter, tc.value,
+					tc.want, got)
+			}
+		})
+	}
+}
+
+func TestParameterBytes(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		parameter postgresql.Parameter
+		want      int64
+		wantErr   bool
+	}{
+		"pages": {
+			parameter: postgresql.Parameter{Setting: "16384", Unit: "8kB"},
+			want:      128 << 20,
+		},
+		"kilobytes": {
+			parameter: postgresql.Parameter{Setting: "-1", Unit: "kB"},
+			want:      -1 << 10,
+		},
+		"time unit": {
+			parameter: postgresql.Parameter{Setting: "200", Unit: "ms"},
+			wantErr:   true,
+		},
+		"no unit": {
+			parameter: postgresql.Parameter{Setting: "100"},
+			wantErr:   true,
+		},
+	}
+
+	for tcName, tc := range testCases {
+		// Rebind tc into this lexical scope. Details on the why at
+		// https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		tc := tc
+		t.Run(tcName, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := tc.parameter.Bytes()
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("Expected an error for %v, got %d bytes", tc.parameter, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got: \"%v\"", err)
+			}
+			if got != tc.want {
+				t.Fatalf("Expected %d bytes, got %d", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestParameterDuration(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		parameter postgresql.Parameter
+		want      time.Duration
+		wantErr   bool
+	}{
+		"milliseconds": {
+			parameter: postgresql.Parameter{Setting: "200", Unit: "ms"},
+			want:      200 * time.Millisecond,
+		},
+		"minutes": {
+			parameter: postgresql.Parameter{Setting: "5", Unit: "min"},
+			want:      5 * time.Minute,
+		},
+		"memory unit": {
+			parameter: postgresql.Parameter{Setting: "16384", Unit: "8kB"},
+			wantErr:   true,
+		},
+		"unknown unit": {
+			parameter: postgresql.Parameter{Setting: "1", Unit: "fortnight"},
+			wantErr:   true,
+		},
+	}
+
+	for tcName, tc := range testCases {
+		// Rebind tc into this lexical scope. Details on the why at
+		// https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		tc := tc
+		t.Run(tcName, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := tc.parameter.Duration()
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("Expected an error for %v, got %s", tc.parameter, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got: \"%v\"", err)
+			}
+			if got != tc.want {
+				t.Fatalf("Expected %s, got %s", tc.want, got)
+			}
+		})
+	}
+}
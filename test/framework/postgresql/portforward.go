@@ -0,0 +1,31 @@
+package postgresql
+
+import (
+	"fmt"
+
+	"github.com/anynines/a8s-deployment/test/framework"
+)
+
+// MetricsPort is the port of the Prometheus exporter in the pods of a Postgresql.
+const MetricsPort = 9187
+
+// PrimaryTarget targets ports of the primary of pg, i.e. the pod that its master service selects,
+// e.g. Port, patroni.Port and MetricsPort. Without ports it targets Port. A framework.PortForwarder
+// with this target follows the primary across failovers and switchovers.
+func (pg Postgresql) PrimaryTarget(ports ...int) framework.Target {
+	if len(ports) == 0 {
+		ports = []int{Port}
+	}
+	t := framework.ServiceTarget(pg.Namespace, MasterService(pg.Name), ports...)
+	t.Description = fmt.Sprintf("primary of %s/%s", pg.Namespace, pg.Name)
+	return t
+}
+
+// ReplicaTarget targets ports of the pod of the replica of pg with the given index, e.g. the pod
+// pg-1 for index 1, regardless of whether it's the primary. Without ports it targets Port.
+func (pg Postgresql) ReplicaTarget(index int, ports ...int) framework.Target {
+	if len(ports) == 0 {
+		ports = []int{Port}
+	}
+	return framework.PodTarget(pg.Namespace, PodName(pg.Name, index), ports...)
+}
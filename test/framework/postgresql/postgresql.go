@@ -4,30 +4,22 @@ import (
 	"context"
 	"fmt"
 
-	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	k8sresource "k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/labels"
-	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/client-go/kubernetes/scheme"
-	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/util/retry"
 	"k8s.io/utils/pointer"
 	runtimeClient "sigs.k8s.io/controller-runtime/pkg/client"
 
-	backupv1beta3 "github.com/anynines/a8s-backup-manager/api/v1beta3"
-	sbv1beta3 "github.com/anynines/a8s-service-binding-controller/api/v1beta3"
 	pgv1beta3 "github.com/anynines/postgresql-operator/api/v1beta3"
+
+	"github.com/anynines/a8s-deployment/test/framework"
 )
 
-const (
-	resourceCPU = "500m"
-	resourceMem = "500Mi"
-	volumeSize  = "1G"
-	version     = 14
+const kind = "Postgresql"
 
-	kind = "Postgresql"
-)
+// Port is the port that PostgreSQL listens on in the pods of a Postgresql.
+const Port = 5432
 
 type Postgresql struct {
 	*pgv1beta3.Postgresql
@@ -37,51 +29,6 @@ func (pg Postgresql) ClusterStatus() string {
 	return pg.Status.ClusterStatus
 }
 
-// TODO: make the K8s client a field of pg rather than something to pass to its functions.
-func (pg Postgresql) StatefulSet(ctx context.Context,
-	k8sClient runtimeClient.Client,
-) (*appsv1.StatefulSet, error) {
-	nsn := types.NamespacedName{Namespace: pg.Namespace, Name: pg.Name}
-	ss := &appsv1.StatefulSet{}
-
-	if err := k8sClient.Get(ctx, nsn, ss); err != nil {
-		return nil, fmt.Errorf("failed to get statefulset for instance %s: %w", nsn, err)
-	}
-
-	return ss, nil
-}
-
-// Pods uses `k8sClient` to retrieve all the pods that belong to `pg`. The retrieval is based
-// entirely on metadata labels, and doesn't check whether the pods actually belong to `pg` or to an
-// older DSI with same namespace, name and kind.
-// TODO: Improve robustness by checking that the pods actually belong to DSI.
-func (pg Postgresql) Pods(ctx context.Context,
-	k8sClient runtimeClient.Client,
-) ([]corev1.Pod, error) {
-	podsLabels := labels.Set{
-		pgv1beta3.DSINameLabelKey:  pg.Name,
-		pgv1beta3.DSIGroupLabelKey: "postgresql.anynines.com",
-		pgv1beta3.DSIKindLabelKey:  "Postgresql",
-	}
-	podsSelector, err := podsLabels.AsValidatedSelector()
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate label selector for pods of %#+v: %w",
-			pg.Postgresql, err)
-	}
-
-	listOpts := &runtimeClient.ListOptions{
-		LabelSelector: podsSelector,
-		Namespace:     pg.Namespace,
-	}
-
-	pods := &corev1.PodList{}
-	if err := k8sClient.List(ctx, pods, listOpts); err != nil {
-		return nil, fmt.Errorf("failed to list pods for %#+v: %w", pg.Postgresql, err)
-	}
-
-	return pods.Items, nil
-}
-
 func (pg Postgresql) SetTolerations(ts ...corev1.Toleration) {
 	if pg.Postgresql.Spec.SchedulingConstraints == nil {
 		pg.Postgresql.Spec.SchedulingConstraints = &pgv1beta3.PostgresqlSchedulingConstraints{}
@@ -126,6 +73,22 @@ func (pg Postgresql) GetClientObject() runtimeClient.Object {
 	return pg.Postgresql
 }
 
+// update applies mutate to the spec of the current pg and updates pg with it, retrying on
+// conflicts. pg reflects the current Postgresql afterwards, with the spec mutated even if the
+// update failed.
+func (pg Postgresql) update(ctx context.Context,
+	c runtimeClient.Client,
+	mutate func(*pgv1beta3.PostgresqlSpec),
+) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := c.Get(ctx, pg.key(), pg.Postgresql); err != nil {
+			return err
+		}
+		mutate(&pg.Spec)
+		return c.Update(ctx, pg.Postgresql)
+	})
+}
+
 func (pg Postgresql) GetReplicaLabels() map[string]string {
 	return map[string]string{
 		pgv1beta3.DSINameLabelKey:         pg.GetName(),
@@ -161,24 +124,11 @@ func hasReplicationRole(l []corev1.Pod) bool {
 	return true
 }
 
-func NewK8sClient(kubeconfig string) (runtimeClient.Client, error) {
-	cfg, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
-	if err != nil {
-		return nil, fmt.Errorf("unable to build config from kubeconfig path: %w", err)
-	}
-
-	pgv1beta3.AddToScheme(scheme.Scheme)
-	sbv1beta3.AddToScheme(scheme.Scheme)
-	backupv1beta3.AddToScheme(scheme.Scheme)
-
-	k8sClient, err := runtimeClient.New(cfg, runtimeClient.Options{Scheme: scheme.Scheme})
-	if err != nil {
-		return nil, fmt.Errorf("unable to create new Kubernetes client for tests: %w", err)
-	}
-	return k8sClient, nil
-}
-
-func New(namespace, name string, replicas int32, opts ...func(*Postgresql)) *Postgresql {
+// New returns a Postgresql with the given namespace, name and replicas. The version, resources and
+// volume size are taken from the PostgreSQL section of the active framework configuration, and can
+// be overridden via opts, like any other field of the spec.
+func New(namespace, name string, replicas int32, opts ...Option) *Postgresql {
+	defaults := framework.ActiveConfig().PostgreSQL
 	p := &Postgresql{&pgv1beta3.Postgresql{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      name,
@@ -190,16 +140,16 @@ func New(namespace, name string, replicas int32, opts ...func(*Postgresql)) *Pos
 		},
 		Spec: pgv1beta3.PostgresqlSpec{
 			Replicas:   pointer.Int32Ptr(replicas),
-			VolumeSize: k8sresource.MustParse(volumeSize),
-			Version:    version,
+			VolumeSize: k8sresource.MustParse(defaults.VolumeSize),
+			Version:    defaults.Version,
 			Resources: &corev1.ResourceRequirements{
 				Limits: map[corev1.ResourceName]k8sresource.Quantity{
-					corev1.ResourceCPU:    k8sresource.MustParse(resourceCPU),
-					corev1.ResourceMemory: k8sresource.MustParse(resourceMem),
+					corev1.ResourceCPU:    k8sresource.MustParse(defaults.CPU),
+					corev1.ResourceMemory: k8sresource.MustParse(defaults.Memory),
 				},
 				Requests: map[corev1.ResourceName]k8sresource.Quantity{
-					corev1.ResourceCPU:    k8sresource.MustParse(resourceCPU),
-					corev1.ResourceMemory: k8sresource.MustParse(resourceMem),
+					corev1.ResourceCPU:    k8sresource.MustParse(defaults.CPU),
+					corev1.ResourceMemory: k8sresource.MustParse(defaults.Memory),
 				},
 			},
 		},
@@ -212,12 +162,6 @@ func New(namespace, name string, replicas int32, opts ...func(*Postgresql)) *Pos
 	return p
 }
 
-func WithVolumeSize(s string) func(*Postgresql) {
-	return func(p *Postgresql) {
-		p.Spec.VolumeSize = k8sresource.MustParse(s)
-	}
-}
-
 func NewEmpty() Postgresql {
 	return Postgresql{&pgv1beta3.Postgresql{
 		TypeMeta: metav1.TypeMeta{
@@ -243,8 +187,14 @@ func StandbyRoleSecretName(instanceName string) string {
 	return fmt.Sprintf("%s.%s", "standby.credentials", instanceName)
 }
 
+// PodName returns the name of the pod of the replica with the given index of the Postgresql
+// instanceName.
+func PodName(instanceName string, index int) string {
+	return fmt.Sprintf("%s-%d", instanceName, index)
+}
+
 func PvcName(instanceName string, index int) string {
-	return fmt.Sprintf("%s-%s-%d", "pgdata", instanceName, index)
+	return fmt.Sprintf("%s-%s-%d", pvcNamePrefix, instanceName, index)
 }
 
 func IsMaster(pod *corev1.Pod) bool {
@@ -10,7 +10,6 @@ import (
 	"k8s.io/apimachinery/pkg/api/equality"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
 	"github.com/anynines/a8s-deployment/test/framework/postgresql"
 	"github.com/anynines/postgresql-operator/api/v1beta3"
@@ -246,11 +245,17 @@ func TestPodsRetrievalHappyPaths(t *testing.T) {
 
 			t.Parallel()
 
-			// Generate a fake K8s client pre-populated with the pods of the test case.
-			k8sClient := fake.NewClientBuilder().
-				WithObjects(tc.dsiPods...).
-				WithObjects(tc.nonDSIPods...).
-				Build()
+			// The pods of the DSI are controlled by the StatefulSet of the current DSI.
+			tc.dsi.UID = "pg-uid"
+			ss := newStatefulSet(tc.dsi, "ss-uid")
+			for _, pod := range tc.dsiPods {
+				pod.SetOwnerReferences([]metav1.OwnerReference{controllerRef(ss)})
+			}
+
+			// Generate a fake K8s client pre-populated with the DSI, its StatefulSet and the pods
+			// of the test case.
+			objs := append([]client.Object{tc.dsi.Postgresql, ss}, tc.dsiPods...)
+			k8sClient := newFakeClient(append(objs, tc.nonDSIPods...)...)
 
 			// Invoke the method under test
 			gotPods, err := tc.dsi.Pods(context.Background(), k8sClient)
@@ -0,0 +1,333 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	// insufficientPrivilegeCode is the code of the errors that PostgreSQL returns when a role
+	// lacks a privilege, e.g. to read a table or to connect to a database.
+	insufficientPrivilegeCode = "42501"
+	// invalidAuthorizationCode is the code of the errors that PostgreSQL returns when
+	// pg_hba.conf rejects a connection.
+	invalidAuthorizationCode = "28000"
+
+	// roleProbe is the role that CheckCannotCreateRoles tries to create.
+	roleProbe = "a8s_isolation_probe"
+)
+
+// Privileges are the privileges of a role, e.g. the one of a service binding.
+type Privileges struct {
+	// Role is the name of the role.
+	Role string
+	// Attributes are the attributes of the role.
+	Attributes RoleAttributes
+	// MemberOf are the names of the roles that the role is a member of, sorted.
+	MemberOf []string
+	// Databases are the privileges of the role on the databases that accept connections, sorted
+	// by name.
+	Databases []DatabasePrivileges
+	// Schemas are the privileges of the role on the schemas of the database that it's connected
+	// to, without the system schemas, sorted by name.
+	Schemas []SchemaPrivileges
+	// Owned are the objects of the database that it's connected to that the role owns, sorted by
+	// schema and name.
+	Owned []OwnedObject
+}
+
+// RoleAttributes are the attributes of a role that grant privileges beyond the ones of its
+// database objects.
+type RoleAttributes struct {
+	Superuser   bool
+	CreateRole  bool
+	CreateDB    bool
+	Replication bool
+	BypassRLS   bool
+	Login       bool
+}
+
+// DatabasePrivileges are the privileges of a role on a database.
+type DatabasePrivileges struct {
+	Name                       string
+	Owner                      bool
+	Connect, Create, Temporary bool
+}
+
+// SchemaPrivileges are the privileges of a role on a schema.
+type SchemaPrivileges struct {
+	Name          string
+	Owner         bool
+	Usage, Create bool
+}
+
+// OwnedObject is a relation owned by a role, e.g. a table.
+type OwnedObject struct {
+	// Kind is the kind of the relation, e.g. "table", "view" or "sequence".
+	Kind   string
+	Schema string
+	Name   string
+}
+
+// CheckUnprivileged returns an error if the role has any attribute that grants privileges beyond
+// the ones on its database objects, i.e. any attribute other than Login.
+func (p Privileges) CheckUnprivileged() error {
+	var attrs []string
+	a := p.Attributes
+	for _, attr := range []struct {
+		name string
+		set  bool
+	}{
+		{"SUPERUSER", a.Superuser},
+		{"CREATEROLE", a.CreateRole},
+		{"CREATEDB", a.CreateDB},
+		{"REPLICATION", a.Replication},
+		{"BYPASSRLS", a.BypassRLS},
+	} {
+		if attr.set {
+			attrs = append(attrs, attr.name)
+		}
+	}
+	if len(attrs) > 0 {
+		return fmt.Errorf("role %s has attributes %s", p.Role, strings.Join(attrs, ", "))
+	}
+	return nil
+}
+
+func (p Privileges) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "role %s with attributes %+v, member of %v", p.Role, p.Attributes,
+		p.MemberOf)
+	for _, db := range p.Databases {
+		fmt.Fprintf(&b, "; database %s: owner %t, connect %t, create %t, temporary %t", db.Name,
+			db.Owner, db.Connect, db.Create, db.Temporary)
+	}
+	for _, s := range p.Schemas {
+		fmt.Fprintf(&b, "; schema %s: owner %t, usage %t, create %t", s.Name, s.Owner, s.Usage,
+			s.Create)
+	}
+	for _, o := range p.Owned {
+		fmt.Fprintf(&b, "; owns %s %s.%s", o.Kind, o.Schema, o.Name)
+	}
+	return b.String()
+}
+
+// relationKinds maps the kinds of pg_class to the names of OwnedObject.Kind.
+var relationKinds = map[string]string{
+	"r": "table",
+	"p": "partitioned table",
+	"v": "view",
+	"m": "materialized view",
+	"S": "sequence",
+	"f": "foreign table",
+}
+
+// Privileges returns the privileges of the role that c connects as, e.g. the role of a service
+// binding if c uses its credentials. Unlike UserExists it reports what the role can do.
+func (c Client) Privileges(ctx context.Context) (Privileges, error) {
+	dbConn, err := c.connectToDB(ctx)
+	if err != nil {
+		return Privileges{}, err
+	}
+	defer func() { closeConnection(ctx, dbConn) }()
+
+	var p Privileges
+	a := &p.Attributes
+	query := "SELECT rolname, rolsuper, rolcreaterole, rolcreatedb, rolreplication, " +
+		"rolbypassrls, rolcanlogin FROM pg_roles WHERE rolname = current_user;"
+	if err := dbConn.QueryRow(ctx, query).Scan(&p.Role, &a.Superuser, &a.CreateRole,
+		&a.CreateDB, &a.Replication, &a.BypassRLS, &a.Login); err != nil {
+		return Privileges{}, fmt.Errorf("failed to query role attributes with query %s: %w",
+			query, err)
+	}
+
+	query = "SELECT r.rolname FROM pg_auth_members m JOIN pg_roles r ON r.oid = m.roleid " +
+		"WHERE m.member = (SELECT oid FROM pg_roles WHERE rolname = current_user) " +
+		"ORDER BY r.rolname;"
+	if err := queryRows(ctx, dbConn, query, func(rows pgx.Rows) error {
+		var role string
+		err := rows.Scan(&role)
+		p.MemberOf = append(p.MemberOf, role)
+		return err
+	}); err != nil {
+		return Privileges{}, err
+	}
+
+	query = "SELECT datname, pg_get_userbyid(datdba) = current_user, " +
+		"has_database_privilege(oid, 'CONNECT'), has_database_privilege(oid, 'CREATE'), " +
+		"has_database_privilege(oid, 'TEMPORARY') FROM pg_database WHERE datallowconn " +
+		"ORDER BY datname;"
+	if err := queryRows(ctx, dbConn, query, func(rows pgx.Rows) error {
+		var db DatabasePrivileges
+		err := rows.Scan(&db.Name, &db.Owner, &db.Connect, &db.Create, &db.Temporary)
+		p.Databases = append(p.Databases, db)
+		return err
+	}); err != nil {
+		return Privileges{}, err
+	}
+
+	query = "SELECT nspname, pg_get_userbyid(nspowner) = current_user, " +
+		"has_schema_privilege(oid, 'USAGE'), has_schema_privilege(oid, 'CREATE') " +
+		"FROM pg_namespace WHERE nspname NOT LIKE 'pg\\_%' " +
+		"AND nspname <> 'information_schema' ORDER BY nspname;"
+	if err := queryRows(ctx, dbConn, query, func(rows pgx.Rows) error {
+		var s SchemaPrivileges
+		err := rows.Scan(&s.Name, &s.Owner, &s.Usage, &s.Create)
+		p.Schemas = append(p.Schemas, s)
+		return err
+	}); err != nil {
+		return Privileges{}, err
+	}
+
+	query = "SELECT c.relkind::text, n.nspname, c.relname FROM pg_class c " +
+		"JOIN pg_namespace n ON n.oid = c.relnamespace " +
+		"WHERE pg_get_userbyid(c.relowner) = current_user AND c.relkind IN " +
+		"('r', 'p', 'v', 'm', 'S', 'f') ORDER BY n.nspname, c.relname;"
+	if err := queryRows(ctx, dbConn, query, func(rows pgx.Rows) error {
+		var o OwnedObject
+		err := rows.Scan(&o.Kind, &o.Schema, &o.Name)
+		o.Kind = relationKinds[o.Kind]
+		p.Owned = append(p.Owned, o)
+		return err
+	}); err != nil {
+		return Privileges{}, err
+	}
+	return p, nil
+}
+
+// CheckCannotCreateRoles returns an error if the role that c connects as can create roles. It
+// tries to create one and drops it if that succeeds.
+func (c Client) CheckCannotCreateRoles(ctx context.Context) error {
+	dbConn, err := c.connectToDB(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { closeConnection(ctx, dbConn) }()
+
+	query := fmt.Sprintf("CREATE ROLE %s;", QuoteIdentifier(roleProbe))
+	_, err = dbConn.Exec(ctx, query)
+	if err == nil {
+		drop := fmt.Sprintf("DROP ROLE %s;", QuoteIdentifier(roleProbe))
+		if _, err := dbConn.Exec(ctx, drop); err != nil {
+			return fmt.Errorf("created role %s and failed to drop it with query %s: %w",
+				roleProbe, drop, err)
+		}
+		return fmt.Errorf("created role %s", roleProbe)
+	}
+	if !isInsufficientPrivilege(err) {
+		return fmt.Errorf("failed to create role with query %s for other reasons than "+
+			"insufficient privileges: %w", query, err)
+	}
+	return nil
+}
+
+// CheckCannotRead returns an error if the role that c connects as can read entity, e.g. a table
+// created via the credentials of another service binding.
+func (c Client) CheckCannotRead(ctx context.Context, entity string) error {
+	dbConn, err := c.connectToDB(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { closeConnection(ctx, dbConn) }()
+
+	var one int
+	query := fmt.Sprintf("SELECT 1 FROM %s LIMIT 1;", QuoteIdentifier(entity))
+	err = dbConn.QueryRow(ctx, query).Scan(&one)
+	if err == nil || errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("read table %s", QuoteIdentifier(entity))
+	}
+	if !isInsufficientPrivilege(err) {
+		return fmt.Errorf("failed to read with query %s for other reasons than insufficient "+
+			"privileges: %w", query, err)
+	}
+	return nil
+}
+
+// CheckCannotConnectToOtherDatabases returns an error if the role that c connects as can connect
+// to any database other than the one of its credentials. Template databases are skipped.
+func (c Client) CheckCannotConnectToOtherDatabases(ctx context.Context) error {
+	dbConn, err := c.connectToDB(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { closeConnection(ctx, dbConn) }()
+
+	var databases []string
+	query := "SELECT datname FROM pg_database WHERE datallowconn AND NOT datistemplate " +
+		"AND datname <> current_database() ORDER BY datname;"
+	if err := queryRows(ctx, dbConn, query, func(rows pgx.Rows) error {
+		var db string
+		err := rows.Scan(&db)
+		databases = append(databases, db)
+		return err
+	}); err != nil {
+		return err
+	}
+
+	var connected []string
+	for _, db := range databases {
+		other, err := c.withDatabase(db).connectToDB(ctx)
+		if err == nil {
+			closeConnection(ctx, other)
+			connected = append(connected, db)
+			continue
+		}
+		if !isInsufficientPrivilege(err) && !isRejected(err) {
+			return fmt.Errorf("failed to connect to database %s for other reasons than "+
+				"insufficient privileges: %w", db, err)
+		}
+	}
+	if len(connected) > 0 {
+		return fmt.Errorf("connected to databases %s", strings.Join(connected, ", "))
+	}
+	return nil
+}
+
+// withDatabase returns a copy of c that connects to database.
+func (c Client) withDatabase(database string) Client {
+	credentials := make(map[string]string, len(c.credentials))
+	for k, v := range c.credentials {
+		credentials[k] = v
+	}
+	credentials[DBKey] = database
+	c.credentials = credentials
+	return c
+}
+
+// queryRows runs query and calls scan for each of the rows.
+func queryRows(ctx context.Context,
+	dbConn *pgx.Conn,
+	query string,
+	scan func(pgx.Rows) error,
+) error {
+	rows, err := dbConn.Query(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to query with %s: %w", query, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return fmt.Errorf("failed to scan row of query %s: %w", query, err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read rows of query %s: %w", query, err)
+	}
+	return nil
+}
+
+func isInsufficientPrivilege(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == insufficientPrivilegeCode
+}
+
+// isRejected returns true if err is pg_hba.conf rejecting a connection.
+func isRejected(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == invalidAuthorizationCode
+}
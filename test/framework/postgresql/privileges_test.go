@@ -0,0 +1,69 @@
+package postgresql_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/anynines/a8s-deployment/test/framework/postgresql"
+)
+
+func TestPrivilegesCheckUnprivileged(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		attributes postgresql.RoleAttributes
+		wantErr    string
+	}{
+		"login_only": {
+			attributes: postgresql.RoleAttributes{Login: true},
+		},
+		"create_role": {
+			attributes: postgresql.RoleAttributes{Login: true, CreateRole: true},
+			wantErr:    "role sb0 has attributes CREATEROLE",
+		},
+		"superuser_with_replication": {
+			attributes: postgresql.RoleAttributes{Superuser: true, Replication: true},
+			wantErr:    "role sb0 has attributes SUPERUSER, REPLICATION",
+		},
+	}
+
+	for tcName, tc := range testCases {
+		// Rebind tc into this lexical scope. Details on the why at
+		// https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		tc := tc
+		t.Run(tcName, func(t *testing.T) {
+			t.Parallel()
+
+			p := postgresql.Privileges{Role: "sb0", Attributes: tc.attributes}
+			err := p.CheckUnprivileged()
+			if tc.wantErr == "" && err != nil {
+				t.Fatalf("Expected no error, got: \"%v\"", err)
+			}
+			if tc.wantErr != "" && (err == nil || err.Error() != tc.wantErr) {
+				t.Fatalf("Expected error \"%s\", got: \"%v\"", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestPrivilegesString(t *testing.T) {
+	t.Parallel()
+
+	p := postgresql.Privileges{
+		Role:      "sb0",
+		MemberOf:  []string{"a9s_apps_default_db_owner"},
+		Databases: []postgresql.DatabasePrivileges{{Name: "a9s_apps_default_db", Connect: true}},
+		Schemas:   []postgresql.SchemaPrivileges{{Name: "public", Usage: true, Create: true}},
+		Owned:     []postgresql.OwnedObject{{Kind: "table", Schema: "public", Name: "t0"}},
+	}
+	for _, want := range []string{
+		"member of [a9s_apps_default_db_owner]",
+		"database a9s_apps_default_db: owner false, connect true",
+		"schema public: owner false, usage true, create true",
+		"owns table public.t0",
+	} {
+		if !strings.Contains(p.String(), want) {
+			t.Fatalf("Expected %q to contain %q", p.String(), want)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/rest"
+	runtimeClient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	pgv1beta3 "github.com/anynines/postgresql-operator/api/v1beta3"
+
+	"github.com/anynines/a8s-deployment/test/framework"
+	"github.com/anynines/a8s-deployment/test/framework/dsi"
+)
+
+// readOnlyProbeEntity is the entity that CheckReadOnlyService tries to write to via the
+// read-only service.
+const readOnlyProbeEntity = "read_only_probe"
+
+// Server identifies the PostgreSQL server that a connection landed on.
+type Server struct {
+	// Addr is the IP address of the server, as seen by the server.
+	Addr string
+	// InRecovery is true if the server is a replica.
+	InRecovery bool
+}
+
+// Server returns the PostgreSQL server that a new connection of the client lands on, e.g. to find
+// out where a service routes connections to.
+func (c Client) Server(ctx context.Context) (Server, error) {
+	dbConn, err := c.connectToDB(ctx)
+	if err != nil {
+		return Server{}, err
+	}
+	defer func() { closeConnection(ctx, dbConn) }()
+
+	var s Server
+	query := "SELECT COALESCE(host(inet_server_addr()), ''), pg_is_in_recovery();"
+	if err := dbConn.QueryRow(ctx, query).Scan(&s.Addr, &s.InRecovery); err != nil {
+		return Server{}, fmt.Errorf("failed to identify server with query %s: %w", query, err)
+	}
+	return s, nil
+}
+
+// ReadOnlyRouting is how a service routed new connections to the PostgreSQL servers.
+type ReadOnlyRouting struct {
+	// Connections is the number of connections.
+	Connections int
+	// Replicas is the number of connections per address of the replicas that they landed on.
+	Replicas map[string]int
+	// Primaries is the number of connections per address of the primaries that they landed on.
+	Primaries map[string]int
+}
+
+// CheckReplicasOnly returns an error if connections landed on a primary or on fewer than
+// minReplicas replicas.
+func (r ReadOnlyRouting) CheckReplicasOnly(minReplicas int) error {
+	if len(r.Primaries) > 0 {
+		return fmt.Errorf("connections landed on primaries: %s", r)
+	}
+	if len(r.Replicas) < minReplicas {
+		return fmt.Errorf("connections landed on fewer than %d replicas: %s", minReplicas, r)
+	}
+	return nil
+}
+
+func (r ReadOnlyRouting) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d connections", r.Connections)
+	for _, role := range []struct {
+		name  string
+		count map[string]int
+	}{{"replica", r.Replicas}, {"primary", r.Primaries}} {
+		addrs := make([]string, 0, len(role.count))
+		for addr := range role.count {
+			addrs = append(addrs, addr)
+		}
+		sort.Strings(addrs)
+		for _, addr := range addrs {
+			fmt.Fprintf(&b, ", %d to %s %s", role.count[addr], role.name, addr)
+		}
+	}
+	return b.String()
+}
+
+// MeasureReadOnlyRouting opens the given number of connections via client, one after the other,
+// and returns where they landed. Use a client that connects to the read-only service directly,
+// e.g. to its load balancer, since a port forward always lands on the same pod.
+func MeasureReadOnlyRouting(ctx context.Context,
+	client Client,
+	connections int,
+) (ReadOnlyRouting, error) {
+	r := ReadOnlyRouting{Replicas: map[string]int{}, Primaries: map[string]int{}}
+	for i := 0; i < connections; i++ {
+		s, err := client.Server(ctx)
+		if err != nil {
+			return r, err
+		}
+		r.Connections++
+		if s.InRecovery {
+			r.Replicas[s.Addr]++
+		} else {
+			r.Primaries[s.Addr]++
+		}
+	}
+	return r, nil
+}
+
+// ReadOnlyService returns the read-only service of pg: the service with the labels of pg that
+// selects pods and is neither its master nor its Patroni service.
+func (pg Postgresql) ReadOnlyService(ctx context.Context,
+	c runtimeClient.Client,
+) (corev1.Service, error) {
+	var services corev1.ServiceList
+	if err := c.List(ctx, &services,
+		runtimeClient.InNamespace(pg.Namespace),
+		runtimeClient.MatchingLabels{pgv1beta3.DSINameLabelKey: pg.Name},
+	); err != nil {
+		return corev1.Service{}, fmt.Errorf("failed to list services of %s/%s: %w", pg.Namespace,
+			pg.Name, err)
+	}
+
+	var found []corev1.Service
+	for _, svc := range services.Items {
+		if svc.Name == MasterService(pg.Name) || svc.Name == PatroniService(pg.Name) ||
+			len(svc.Spec.Selector) == 0 {
+			continue
+		}
+		found = append(found, svc)
+	}
+	if len(found) != 1 {
+		return corev1.Service{}, fmt.Errorf("found %d read-only services of %s/%s, expected 1",
+			len(found), pg.Namespace, pg.Name)
+	}
+	return found[0], nil
+}
+
+// ReadOnlyServicePods returns the pods that the read-only service of pg selects.
+func (pg Postgresql) ReadOnlyServicePods(ctx context.Context,
+	c runtimeClient.Client,
+) ([]corev1.Pod, error) {
+	svc, err := pg.ReadOnlyService(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	var pods corev1.PodList
+	if err := c.List(ctx, &pods,
+		runtimeClient.InNamespace(pg.Namespace),
+		runtimeClient.MatchingLabelsSelector{
+			Selector: labels.SelectorFromSet(svc.Spec.Selector),
+		},
+	); err != nil {
+		return nil, fmt.Errorf("failed to list pods selected by read-only service %s/%s: %w",
+			svc.Namespace, svc.Name, err)
+	}
+	return pods.Items, nil
+}
+
+// CheckReadOnlyService checks, via a port forward to each pod that the read-only service of pg
+// selects, that the pod is a replica and rejects writes. It connects with credentials, e.g. the
+// ones of a service binding. It's meant for read-only services that target replicas only, and
+// works for instances that aren't exposed outside the Kubernetes cluster.
+func (pg Postgresql) CheckReadOnlyService(ctx context.Context,
+	c runtimeClient.Client,
+	config *rest.Config,
+	credentials map[string]string,
+) error {
+	pods, err := pg.ReadOnlyServicePods(ctx, c)
+	if err != nil {
+		return err
+	}
+	if len(pods) == 0 {
+		return fmt.Errorf("read-only service of %s/%s selects no pods", pg.Namespace, pg.Name)
+	}
+	for i := range pods {
+		if err := checkReadOnlyPod(ctx, config, &pods[i], credentials); err != nil {
+			return fmt.Errorf("pod %s/%s selected by the read-only service: %w", pods[i].Namespace,
+				pods[i].Name, err)
+		}
+	}
+	return nil
+}
+
+func checkReadOnlyPod(ctx context.Context,
+	config *rest.Config,
+	pod *corev1.Pod,
+	credentials map[string]string,
+) error {
+	stopCh, localPort, err := framework.PortForwardPod(ctx, Port, config, pod)
+	if err != nil {
+		return err
+	}
+	defer close(stopCh)
+
+	client := NewClientOverPortForwarding(credentials, strconv.Itoa(localPort))
+	s, err := client.Server(ctx)
+	if err != nil {
+		return err
+	}
+	if !s.InRecovery {
+		return errors.New("it's a primary")
+	}
+	err = client.Write(ctx, readOnlyProbeEntity, "probe")
+	if err == nil {
+		return errors.New("it accepted a write")
+	}
+	if !errors.Is(err, dsi.ErrReadOnly) {
+		return fmt.Errorf("failed to write for other reasons than being read-only: %w", err)
+	}
+	return nil
+}
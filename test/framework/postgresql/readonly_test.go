@@ -0,0 +1,171 @@
+package postgresql_test
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/anynines/a8s-deployment/test/framework/postgresql"
+	"github.com/anynines/postgresql-operator/api/v1beta3"
+)
+
+func TestReadOnlyRoutingCheckReplicasOnly(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		routing     postgresql.ReadOnlyRouting
+		minReplicas int
+		wantErr     bool
+	}{
+		"spread_across_replicas": {
+			routing: postgresql.ReadOnlyRouting{
+				Connections: 3,
+				Replicas:    map[string]int{"10.0.0.2": 2, "10.0.0.3": 1},
+			},
+			minReplicas: 2,
+		},
+		"landed_on_primary": {
+			routing: postgresql.ReadOnlyRouting{
+				Connections: 3,
+				Replicas:    map[string]int{"10.0.0.2": 1, "10.0.0.3": 1},
+				Primaries:   map[string]int{"10.0.0.1": 1},
+			},
+			minReplicas: 2,
+			wantErr:     true,
+		},
+		"landed_on_too_few_replicas": {
+			routing: postgresql.ReadOnlyRouting{
+				Connections: 3,
+				Replicas:    map[string]int{"10.0.0.2": 3},
+			},
+			minReplicas: 2,
+			wantErr:     true,
+		},
+	}
+
+	for tcName, tc := range testCases {
+		// Rebind tc into this lexical scope. Details on the why at
+		// https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		tc := tc
+		t.Run(tcName, func(t *testing.T) {
+			t.Parallel()
+
+			err := tc.routing.CheckReplicasOnly(tc.minReplicas)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Expected error %t, got: \"%v\"", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestReadOnlyRoutingString(t *testing.T) {
+	t.Parallel()
+
+	r := postgresql.ReadOnlyRouting{
+		Connections: 4,
+		Replicas:    map[string]int{"10.0.0.3": 1, "10.0.0.2": 2},
+		Primaries:   map[string]int{"10.0.0.1": 1},
+	}
+	want := "4 connections, 2 to replica 10.0.0.2, 1 to replica 10.0.0.3, 1 to primary 10.0.0.1"
+	if got := r.String(); got != want {
+		t.Fatalf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestReadOnlyService(t *testing.T) {
+	t.Parallel()
+
+	pg := postgresql.New("ns0", "pg0", 3)
+	selector := map[string]string{"a8s.a9s/replication-role": "replica"}
+
+	testCases := map[string]struct {
+		services []client.Object
+		want     string
+		wantErr  bool
+	}{
+		"read_only_service_among_others": {
+			services: []client.Object{
+				newService("ns0", "pg0-master", selector),
+				newService("ns0", "pg0-patroni", selector),
+				newService("ns0", "pg0-config", nil),
+				newService("ns0", "pg0-replicas", selector),
+			},
+			want: "pg0-replicas",
+		},
+		"services_of_other_instances_are_ignored": {
+			services: []client.Object{
+				newService("ns0", "pg0-replicas", selector),
+				newService("ns1", "pg0-replicas", selector),
+			},
+			want: "pg0-replicas",
+		},
+		"no_read_only_service": {
+			services: []client.Object{
+				newService("ns0", "pg0-master", selector),
+				newService("ns0", "pg0-config", nil),
+			},
+			wantErr: true,
+		},
+		"ambiguous_read_only_services": {
+			services: []client.Object{
+				newService("ns0", "pg0-replicas", selector),
+				newService("ns0", "pg0-readonly", selector),
+			},
+			wantErr: true,
+		},
+	}
+
+	for tcName, tc := range testCases {
+		// Rebind tc into this lexical scope. Details on the why at
+		// https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		tc := tc
+		t.Run(tcName, func(t *testing.T) {
+			t.Parallel()
+
+			svc, err := pg.ReadOnlyService(context.Background(), newFakeClient(tc.services...))
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Expected error %t, got: \"%v\"", tc.wantErr, err)
+			}
+			if svc.Name != tc.want || (!tc.wantErr && svc.Namespace != "ns0") {
+				t.Fatalf("Expected service ns0/%s, got %s/%s", tc.want, svc.Namespace, svc.Name)
+			}
+		})
+	}
+}
+
+func TestReadOnlyServicePods(t *testing.T) {
+	t.Parallel()
+
+	pg := postgresql.New("ns0", "pg0", 3)
+	replicaLabels := map[string]string{v1beta3.DSINameLabelKey: "pg0", "role": "replica"}
+	c := newFakeClient(
+		newService("ns0", "pg0-replicas", replicaLabels),
+		newPod(withName("pg0-0"), withNamespace("ns0"),
+			withLabels(map[string]string{v1beta3.DSINameLabelKey: "pg0", "role": "master"})),
+		newPod(withName("pg0-1"), withNamespace("ns0"), withLabels(replicaLabels)),
+		newPod(withName("pg0-2"), withNamespace("ns0"), withLabels(replicaLabels)),
+		newPod(withName("pg0-1"), withNamespace("ns1"), withLabels(replicaLabels)),
+	)
+
+	pods, err := pg.ReadOnlyServicePods(context.Background(), c)
+	if err != nil {
+		t.Fatalf("Expected no error, got: \"%v\"", err)
+	}
+	if got := podNames(pods); !equalNames(got, "pg0-1", "pg0-2") {
+		t.Fatalf("Expected pods pg0-1 and pg0-2, got %v", got)
+	}
+}
+
+func newService(namespace, name string, selector map[string]string) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+			Labels:    map[string]string{v1beta3.DSINameLabelKey: "pg0"},
+		},
+		Spec: corev1.ServiceSpec{Selector: selector},
+	}
+}
@@ -0,0 +1,55 @@
+package postgresql
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+
+	backupv1beta3 "github.com/anynines/a8s-backup-manager/api/v1beta3"
+	sbv1beta3 "github.com/anynines/a8s-service-binding-controller/api/v1beta3"
+	pgv1beta3 "github.com/anynines/postgresql-operator/api/v1beta3"
+
+	"github.com/anynines/a8s-deployment/test/framework/dsi"
+)
+
+// DataServiceName is the name under which PostgreSQL is registered in the dsi package.
+const DataServiceName = "PostgreSQL"
+
+func init() {
+	dsi.MustRegister(dsi.DataService{
+		Name: DataServiceName,
+		Kind: kind,
+		New: func(namespace, name string, replicas int32) dsi.Object {
+			return New(namespace, name, replicas)
+		},
+		NewEmpty: func() dsi.Object {
+			return NewEmpty()
+		},
+		FromClientObject: func(o runtime.Object) (dsi.Object, error) {
+			pg, ok := o.(*pgv1beta3.Postgresql)
+			if !ok {
+				return nil, fmt.Errorf("%T is not a %s", o, kind)
+			}
+			return Postgresql{pg}, nil
+		},
+		NewClient: func(port string, sbData map[string]string) dsi.DSIClient {
+			return NewClientOverPortForwarding(sbData, port)
+		},
+		NewClientForURL: func(host, port, sslmode string, sbData map[string]string) dsi.DSIClient {
+			return NewClient(sbData, host, port, sslmode)
+		},
+		SchemeInstallers: []func(*runtime.Scheme) error{
+			pgv1beta3.AddToScheme,
+			sbv1beta3.AddToScheme,
+			backupv1beta3.AddToScheme,
+		},
+		Capabilities: []dsi.Capability{
+			dsi.CapabilityBackup,
+			dsi.CapabilityRestore,
+			dsi.CapabilityServiceBinding,
+			dsi.CapabilityTolerations,
+			dsi.CapabilityPodAntiAffinity,
+			dsi.CapabilityReplication,
+		},
+	})
+}
@@ -0,0 +1,330 @@
+package postgresql
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/rest"
+	runtimeClient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/anynines/a8s-deployment/test/framework"
+	"github.com/anynines/a8s-deployment/test/framework/secret"
+	"github.com/anynines/a8s-deployment/test/framework/wait"
+)
+
+// LSN is a PostgreSQL log sequence number, a byte position in the write-ahead log (WAL).
+type LSN uint64
+
+// ParseLSN parses an LSN in PostgreSQL's textual format, e.g. "16/B374D848".
+func ParseLSN(s string) (LSN, error) {
+	high, low, ok := strings.Cut(s, "/")
+	if !ok {
+		return 0, fmt.Errorf("invalid LSN %q: no \"/\"", s)
+	}
+	h, err := strconv.ParseUint(high, 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid LSN %q: %w", s, err)
+	}
+	l, err := strconv.ParseUint(low, 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid LSN %q: %w", s, err)
+	}
+	return LSN(h<<32 | l), nil
+}
+
+func (l LSN) String() string {
+	return fmt.Sprintf("%X/%X", uint64(l)>>32, uint64(l)&0xFFFFFFFF)
+}
+
+// ReplicationState is the replication state of a Postgresql, as seen by its primary and its
+// replicas.
+type ReplicationState struct {
+	// Primary is the name of the pod of the primary.
+	Primary string
+	// CurrentLSN is the position up to which the primary wrote the WAL.
+	CurrentLSN LSN
+	// Replicas are the states of the replicas, ordered by pod name.
+	Replicas []ReplicaState
+}
+
+// ReplicaState is the replication state of a replica of a Postgresql.
+type ReplicaState struct {
+	// Pod is the name of the pod of the replica.
+	Pod string
+
+	// State is the state of the connection of the replica to the primary, e.g. "streaming", or
+	// "" if it isn't connected.
+	State string
+	// SyncState is the synchronous state of the replica, e.g. "async", or "" if it isn't
+	// connected.
+	SyncState string
+	// SentLSN, WriteLSN, FlushLSN and ReplayLSN are the positions up to which the primary sent the
+	// WAL to the replica and the replica reported to have written, flushed and replayed it. They
+	// are 0 if the replica isn't connected.
+	SentLSN, WriteLSN, FlushLSN, ReplayLSN LSN
+	// ReplayLag is the time between the primary writing the WAL and the replica reporting to have
+	// replayed it, as measured by the primary.
+	ReplayLag time.Duration
+
+	// Slot is the name of the replication slot of the replica on the primary, or "" if it has
+	// none. SlotActive is true if the replica is using it.
+	Slot       string
+	SlotActive bool
+	// SlotRestartLSN is the oldest position of the WAL that the primary retains for the replica.
+	SlotRestartLSN LSN
+
+	// ReplayedLSN is the position up to which the replica replayed the WAL, as reported by the
+	// replica itself. It's 0 if Err isn't nil.
+	ReplayedLSN LSN
+	// Err is the error querying the replica itself, e.g. because it's stopped. The fields that
+	// the primary reports are set anyway.
+	Err error
+}
+
+// LagBytes returns how many bytes of the WAL written by the primary are known not to be replayed
+// by the replica. It uses the most recent replay position of the replica, as reported by either
+// the primary or the replica. SlotRestartLSN isn't one: the primary retains the WAL from there on
+// for the replica, which doesn't say how far the replica replayed it.
+func (r ReplicaState) LagBytes(s ReplicationState) int64 {
+	reached := r.ReplayedLSN
+	if r.ReplayLSN > reached {
+		reached = r.ReplayLSN
+	}
+	if reached >= s.CurrentLSN {
+		return 0
+	}
+	return int64(s.CurrentLSN - reached)
+}
+
+// Replica returns the state of the replica with pod name pod, and whether there's such replica.
+func (s ReplicationState) Replica(pod string) (ReplicaState, bool) {
+	for _, r := range s.Replicas {
+		if r.Pod == pod {
+			return r, true
+		}
+	}
+	return ReplicaState{}, false
+}
+
+// CaughtUp returns true if all the replicas reported to have replayed the WAL up to lsn.
+func (s ReplicationState) CaughtUp(lsn LSN) bool {
+	for _, r := range s.Replicas {
+		if r.Err != nil || r.ReplayedLSN < lsn {
+			return false
+		}
+	}
+	return true
+}
+
+func (s ReplicationState) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "primary %s at %s", s.Primary, s.CurrentLSN)
+	for _, r := range s.Replicas {
+		fmt.Fprintf(&b, "; replica %s: state %q, replayed %s, lag %d bytes", r.Pod, r.State,
+			r.ReplayedLSN, r.LagBytes(s))
+		if r.Err != nil {
+			fmt.Fprintf(&b, " (%v)", r.Err)
+		}
+	}
+	return b.String()
+}
+
+// Replication returns the replication state of pg. It queries pg_stat_replication,
+// pg_replication_slots and pg_current_wal_lsn on the primary and pg_last_wal_replay_lsn on each
+// replica, via a port forward to each pod and with the credentials of the admin role. A replica
+// that can't be queried has the error in its Err field.
+func (pg Postgresql) Replication(ctx context.Context,
+	c runtimeClient.Client,
+	config *rest.Config,
+) (ReplicationState, error) {
+	pods, err := pg.Pods(ctx, c)
+	if err != nil {
+		return ReplicationState{}, fmt.Errorf("failed to get pods of %s/%s: %w", pg.Namespace,
+			pg.Name, err)
+	}
+	credentials, err := secret.AdminSecretData(ctx, c, pg.Name, pg.Namespace)
+	if err != nil {
+		return ReplicationState{}, err
+	}
+	sort.Slice(pods, func(i, j int) bool { return pods[i].Name < pods[j].Name })
+
+	var primary *corev1.Pod
+	for i := range pods {
+		if IsMaster(&pods[i]) {
+			primary = &pods[i]
+		}
+	}
+	if primary == nil {
+		return ReplicationState{}, fmt.Errorf("%s/%s has no primary pod", pg.Namespace, pg.Name)
+	}
+
+	s := ReplicationState{Primary: primary.Name}
+	var senders map[string]ReplicaState
+	err = queryPod(ctx, config, primary, credentials, func(conn *pgx.Conn) error {
+		var err error
+		if s.CurrentLSN, err = queryLSN(ctx, conn, "SELECT pg_current_wal_lsn()::text;"); err != nil {
+			return err
+		}
+		senders, err = queryReplicationStats(ctx, conn)
+		return err
+	})
+	if err != nil {
+		return ReplicationState{}, fmt.Errorf("failed to query primary %s: %w", primary.Name, err)
+	}
+
+	for i := range pods {
+		pod := &pods[i]
+		if pod.Name == primary.Name {
+			continue
+		}
+		r := senders[pod.Name]
+		r.Pod = pod.Name
+		r.Err = queryPod(ctx, config, pod, credentials, func(conn *pgx.Conn) error {
+			var err error
+			r.ReplayedLSN, err = queryLSN(ctx, conn,
+				"SELECT COALESCE(pg_last_wal_replay_lsn()::text, '0/0');")
+			return err
+		})
+		s.Replicas = append(s.Replicas, r)
+	}
+	return s, nil
+}
+
+// AwaitReplicasCaughtUp waits until all the replicas of pg replayed the WAL that the primary wrote
+// before the call. If that doesn't happen within the configured timeout it returns a
+// *wait.TimeoutError with the last observed replication state.
+func (pg Postgresql) AwaitReplicasCaughtUp(ctx context.Context,
+	c runtimeClient.Client,
+	config *rest.Config,
+) error {
+	var target LSN
+	return wait.Poll(ctx, framework.AsyncOpsTimeout(), framework.PollingPeriod(),
+		fmt.Sprintf("replicas of instance %s/%s catching up", pg.Namespace, pg.Name),
+		func(ctx context.Context) (bool, any, error) {
+			s, err := pg.Replication(ctx, c, config)
+			if err != nil {
+				return false, nil, err
+			}
+			if target == 0 {
+				target = s.CurrentLSN
+			}
+			replicas := 0
+			if pg.Spec.Replicas != nil {
+				replicas = int(*pg.Spec.Replicas) - 1
+			}
+			return len(s.Replicas) == replicas && s.CaughtUp(target),
+				fmt.Sprintf("target %s, %s", target, s), nil
+		},
+	)
+}
+
+// WaitForReplicasCaughtUp is the Gomega adapter of AwaitReplicasCaughtUp.
+func (pg Postgresql) WaitForReplicasCaughtUp(ctx context.Context,
+	c runtimeClient.Client,
+	config *rest.Config,
+) {
+	ExpectWithOffset(1, pg.AwaitReplicasCaughtUp(ctx, c, config)).To(Succeed())
+}
+
+// queryPod calls query with a connection to PostgreSQL in pod via a port forward, which connects
+// to the API server with config.
+func queryPod(ctx context.Context,
+	config *rest.Config,
+	pod *corev1.Pod,
+	credentials map[string]string,
+	query func(*pgx.Conn) error,
+) error {
+	stopCh, localPort, err := framework.PortForwardPod(ctx, Port, config, pod)
+	if err != nil {
+		return err
+	}
+	defer close(stopCh)
+
+	client := NewClientOverPortForwarding(credentials, strconv.Itoa(localPort))
+	conn, err := client.connectToDB(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { closeConnection(ctx, conn) }()
+	return query(conn)
+}
+
+func queryLSN(ctx context.Context, conn *pgx.Conn, query string) (LSN, error) {
+	var lsn string
+	if err := conn.QueryRow(ctx, query).Scan(&lsn); err != nil {
+		return 0, fmt.Errorf("failed to query LSN with query %s: %w", query, err)
+	}
+	return ParseLSN(lsn)
+}
+
+// queryReplicationStats returns the states of the replicas as seen by the primary, by pod name.
+// Patroni names the replication connections after the pods, and their replication slots after the
+// pods with "_" instead of "-".
+func queryReplicationStats(ctx context.Context, conn *pgx.Conn) (map[string]ReplicaState, error) {
+	states := map[string]ReplicaState{}
+
+	query := "SELECT application_name, state, sync_state, " +
+		"COALESCE(sent_lsn::text, '0/0'), COALESCE(write_lsn::text, '0/0'), " +
+		"COALESCE(flush_lsn::text, '0/0'), COALESCE(replay_lsn::text, '0/0'), " +
+		"COALESCE(EXTRACT(EPOCH FROM replay_lag), 0)::float8 FROM pg_stat_replication;"
+	rows, err := conn.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query replication stats with query %s: %w", query, err)
+	}
+	for rows.Next() {
+		var name string
+		var r ReplicaState
+		var lsns [4]string
+		var lag float64
+		if err := rows.Scan(&name, &r.State, &r.SyncState, &lsns[0], &lsns[1], &lsns[2],
+			&lsns[3], &lag); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan replication stats: %w", err)
+		}
+		for i, target := range []*LSN{&r.SentLSN, &r.WriteLSN, &r.FlushLSN, &r.ReplayLSN} {
+			if *target, err = ParseLSN(lsns[i]); err != nil {
+				rows.Close()
+				return nil, err
+			}
+		}
+		r.ReplayLag = time.Duration(lag * float64(time.Second))
+		states[name] = r
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read replication stats: %w", err)
+	}
+
+	query = "SELECT slot_name, active, COALESCE(restart_lsn::text, '0/0') " +
+		"FROM pg_replication_slots WHERE slot_type = 'physical';"
+	rows, err = conn.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query replication slots with query %s: %w", query, err)
+	}
+	defer func() { rows.Close() }()
+	for rows.Next() {
+		var slot, restartLSN string
+		var active bool
+		if err := rows.Scan(&slot, &active, &restartLSN); err != nil {
+			return nil, fmt.Errorf("failed to scan replication slot: %w", err)
+		}
+		pod := strings.ReplaceAll(slot, "_", "-")
+		r := states[pod]
+		r.Slot, r.SlotActive = slot, active
+		if r.SlotRestartLSN, err = ParseLSN(restartLSN); err != nil {
+			return nil, err
+		}
+		states[pod] = r
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read replication slots: %w", err)
+	}
+	return states, nil
+}
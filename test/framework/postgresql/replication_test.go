@@ -0,0 +1,137 @@
+package postgresql_test
+
+import (
+	"testing"
+
+	"github.com/anynines/a8s-deployment/test/framework/postgresql"
+)
+
+func TestParseLSN(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		lsn     string
+		want    postgresql.LSN
+		wantErr bool
+	}{
+		"zero": {
+			lsn:  "0/0",
+			want: 0,
+		},
+		"low_half_only": {
+			lsn:  "0/3000060",
+			want: 0x3000060,
+		},
+		"both_halves": {
+			lsn:  "16/B374D848",
+			want: 0x16B374D848,
+		},
+		"lower_case": {
+			lsn:  "16/b374d848",
+			want: 0x16B374D848,
+		},
+		"max": {
+			lsn:  "FFFFFFFF/FFFFFFFF",
+			want: 0xFFFFFFFFFFFFFFFF,
+		},
+		"no_slash": {
+			lsn:     "16B374D848",
+			wantErr: true,
+		},
+		"not_hexadecimal": {
+			lsn:     "16/XYZ",
+			wantErr: true,
+		},
+		"half_too_long": {
+			lsn:     "1/100000000",
+			wantErr: true,
+		},
+		"empty": {
+			lsn:     "",
+			wantErr: true,
+		},
+	}
+
+	for tcName, tc := range testCases {
+		// Rebind tc into this lexical scope. Details on the why at
+		// https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		tc := tc
+		t.Run(tcName, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := postgresql.ParseLSN(tc.lsn)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("Expected an error parsing %q, got LSN %s", tc.lsn, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got: \"%v\"", err)
+			}
+			if got != tc.want {
+				t.Fatalf("Expected LSN %d, got %d", tc.want, got)
+			}
+			if back, err := postgresql.ParseLSN(got.String()); err != nil || back != got {
+				t.Fatalf("Expected %s to parse back to the same LSN, got %d and \"%v\"", got,
+					back, err)
+			}
+		})
+	}
+}
+
+func TestReplicationStateLag(t *testing.T) {
+	t.Parallel()
+
+	s := postgresql.ReplicationState{
+		Primary:    "pg-0",
+		CurrentLSN: 1000,
+		Replicas: []postgresql.ReplicaState{
+			{Pod: "pg-1", ReplayLSN: 400, ReplayedLSN: 600, SlotRestartLSN: 300},
+			{Pod: "pg-2", ReplayLSN: 1000, ReplayedLSN: 1000},
+			{Pod: "pg-3", ReplayLSN: 600, ReplayedLSN: 650, SlotRestartLSN: 900},
+		},
+	}
+
+	testCases := map[string]struct {
+		pod          string
+		wantLagBytes int64
+	}{
+		"most_recent_position_is_used": {
+			pod:          "pg-1",
+			wantLagBytes: 400,
+		},
+		"caught_up_replica_has_no_lag": {
+			pod:          "pg-2",
+			wantLagBytes: 0,
+		},
+		"restart_position_of_slot_is_not_replayed": {
+			pod:          "pg-3",
+			wantLagBytes: 350,
+		},
+	}
+
+	for tcName, tc := range testCases {
+		// Rebind tc into this lexical scope. Details on the why at
+		// https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		tc := tc
+		t.Run(tcName, func(t *testing.T) {
+			t.Parallel()
+
+			r, ok := s.Replica(tc.pod)
+			if !ok {
+				t.Fatalf("Expected replica %s", tc.pod)
+			}
+			if got := r.LagBytes(s); got != tc.wantLagBytes {
+				t.Fatalf("Expected lag of %d bytes, got %d", tc.wantLagBytes, got)
+			}
+		})
+	}
+
+	if !s.CaughtUp(600) || s.CaughtUp(601) {
+		t.Fatalf("Expected replicas to have caught up to LSN 600 but not 601")
+	}
+	if _, ok := s.Replica("pg-0"); ok {
+		t.Fatalf("Expected no replica state for the primary")
+	}
+}
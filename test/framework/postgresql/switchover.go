@@ -0,0 +1,196 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	runtimeClient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/anynines/a8s-deployment/test/framework"
+	"github.com/anynines/a8s-deployment/test/framework/dataset"
+	"github.com/anynines/a8s-deployment/test/framework/patroni"
+	"github.com/anynines/a8s-deployment/test/framework/wait"
+)
+
+// Switchover is the outcome of a switchover of a Postgresql.
+type Switchover struct {
+	// OldPrimary and NewPrimary are the names of the pods of the primary before and after the
+	// switchover.
+	OldPrimary, NewPrimary string
+	// Duration is the time between triggering the switchover and the master service selecting the
+	// new primary.
+	Duration time.Duration
+}
+
+// AwaitSwitchover switches the primary of pg over to the pod candidate via the REST API of
+// Patroni, or to the healthy replica with the least lag if candidate is empty. It waits until the
+// master service selects the new primary and then checks that the new primary has all the records
+// of each data set in want, e.g. data sets written before the switchover. It reads them with
+// credentials, e.g. the ones of the service binding that wrote them. If the master service
+// doesn't select the new primary within the configured timeout it returns a *wait.TimeoutError.
+// A missing data set or a *dataset.Difference is returned as error too.
+func (pg Postgresql) AwaitSwitchover(ctx context.Context,
+	c runtimeClient.Client,
+	config *rest.Config,
+	candidate string,
+	credentials map[string]string,
+	want ...dataset.DataSet,
+) (Switchover, error) {
+	pods, err := pg.Pods(ctx, c)
+	if err != nil {
+		return Switchover{}, fmt.Errorf("failed to get pods of %s/%s: %w", pg.Namespace, pg.Name,
+			err)
+	}
+	var primary *corev1.Pod
+	for i := range pods {
+		if IsMaster(&pods[i]) {
+			primary = &pods[i]
+		}
+	}
+	if primary == nil {
+		return Switchover{}, fmt.Errorf("%s/%s has no primary pod", pg.Namespace, pg.Name)
+	}
+
+	s := Switchover{OldPrimary: primary.Name}
+	err = func() error {
+		patroniClient, stopCh, err := patroni.PortForward(ctx, config, primary)
+		if err != nil {
+			return err
+		}
+		defer close(stopCh)
+
+		if candidate == "" {
+			cluster, err := patroniClient.Cluster(ctx)
+			if err != nil {
+				return err
+			}
+			replica, ok := cluster.HealthiestReplica()
+			if !ok {
+				return errors.New("there's no healthy replica to switch over to")
+			}
+			candidate = replica.Name
+		}
+		s.NewPrimary = candidate
+		if err := patroniClient.Switchover(ctx, primary.Name, candidate); err != nil {
+			return fmt.Errorf("failed to switch over from %s to %s: %w", primary.Name, candidate,
+				err)
+		}
+		return nil
+	}()
+	if err != nil {
+		return s, err
+	}
+	start := time.Now()
+
+	err = wait.Poll(ctx, framework.AsyncOpsTimeout(), framework.PollingPeriod(),
+		fmt.Sprintf("master service of instance %s/%s selecting %s", pg.Namespace, pg.Name,
+			s.NewPrimary),
+		func(ctx context.Context) (bool, any, error) {
+			selected, err := pg.masterServicePods(ctx, c)
+			if err != nil {
+				return false, nil, err
+			}
+			return len(selected) == 1 && selected[0] == s.NewPrimary,
+				fmt.Sprintf("selected pods %v", selected), nil
+		},
+	)
+	if err != nil {
+		return s, err
+	}
+	s.Duration = time.Since(start)
+
+	if len(want) > 0 {
+		err := pg.checkDataSets(ctx, c, config, s.NewPrimary, credentials, want)
+		if err != nil {
+			return s, fmt.Errorf("failed to find data written before the switchover on new "+
+				"primary %s: %w", s.NewPrimary, err)
+		}
+	}
+	return s, nil
+}
+
+// WaitForSwitchover is the Gomega adapter of AwaitSwitchover.
+func (pg Postgresql) WaitForSwitchover(ctx context.Context,
+	c runtimeClient.Client,
+	config *rest.Config,
+	candidate string,
+	credentials map[string]string,
+	want ...dataset.DataSet,
+) Switchover {
+	s, err := pg.AwaitSwitchover(ctx, c, config, candidate, credentials, want...)
+	ExpectWithOffset(1, err).To(Succeed())
+	return s
+}
+
+// masterServicePods returns the names of the pods that the master service of pg selects.
+func (pg Postgresql) masterServicePods(ctx context.Context,
+	c runtimeClient.Client,
+) ([]string, error) {
+	var svc corev1.Service
+	if err := c.Get(ctx, types.NamespacedName{
+		Namespace: pg.Namespace,
+		Name:      MasterService(pg.Name),
+	}, &svc); err != nil {
+		return nil, fmt.Errorf("failed to get master service of %s/%s: %w", pg.Namespace, pg.Name,
+			err)
+	}
+	if len(svc.Spec.Selector) == 0 {
+		return nil, nil
+	}
+	var pods corev1.PodList
+	if err := c.List(ctx, &pods,
+		runtimeClient.InNamespace(pg.Namespace),
+		runtimeClient.MatchingLabelsSelector{
+			Selector: labels.SelectorFromSet(svc.Spec.Selector),
+		},
+	); err != nil {
+		return nil, fmt.Errorf("failed to list pods selected by master service of %s/%s: %w",
+			pg.Namespace, pg.Name, err)
+	}
+	names := make([]string, 0, len(pods.Items))
+	for _, p := range pods.Items {
+		names = append(names, p.Name)
+	}
+	return names, nil
+}
+
+// checkDataSets compares each data set of want with the one in the pod named podName, read via a
+// port forward with credentials.
+func (pg Postgresql) checkDataSets(ctx context.Context,
+	c runtimeClient.Client,
+	config *rest.Config,
+	podName string,
+	credentials map[string]string,
+	want []dataset.DataSet,
+) error {
+	var pod corev1.Pod
+	if err := c.Get(ctx, types.NamespacedName{Namespace: pg.Namespace, Name: podName},
+		&pod); err != nil {
+		return fmt.Errorf("failed to get pod %s/%s: %w", pg.Namespace, podName, err)
+	}
+	stopCh, localPort, err := framework.PortForwardPod(ctx, Port, config, &pod)
+	if err != nil {
+		return err
+	}
+	defer close(stopCh)
+
+	client := NewClientOverPortForwarding(credentials, strconv.Itoa(localPort))
+	for _, ds := range want {
+		got, err := client.ReadDataSet(ctx, ds.Entity)
+		if err != nil {
+			return err
+		}
+		if err := dataset.Compare(ds, got); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package postgresql
+
+import (
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	runtimeClient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	pgv1beta3 "github.com/anynines/postgresql-operator/api/v1beta3"
+
+	"github.com/anynines/a8s-deployment/test/framework/dsi"
+	"github.com/anynines/a8s-deployment/test/framework/timeline"
+)
+
+// Kinds and state keys of the objects of a Postgresql in a timeline.
+const (
+	PodKind = "Pod"
+
+	// StateClusterStatus is the cluster status of a Postgresql.
+	StateClusterStatus = "clusterStatus"
+	// StateGeneration is the generation of a Postgresql, which changes when its spec is updated.
+	StateGeneration = "generation"
+
+	// StatePhase is the phase of a pod.
+	StatePhase = "phase"
+	// StateReady is "true" if all the containers of a pod are ready, "false" otherwise.
+	StateReady = "ready"
+	// StateRole is the replication role of a pod, e.g. "master" or "replica".
+	StateRole = "role"
+	// StateDeleting is "true" if a pod is being deleted.
+	StateDeleting = "deleting"
+
+	masterRole = "master"
+)
+
+// TimelineSources returns the sources to record the timeline of pg: the status of the Postgresql
+// and the readiness and replication role of its pods.
+func (pg Postgresql) TimelineSources() []timeline.Source {
+	return []timeline.Source{
+		{
+			Kind:        kind,
+			NewList:     func() runtimeClient.ObjectList { return &pgv1beta3.PostgresqlList{} },
+			ListOptions: []runtimeClient.ListOption{runtimeClient.InNamespace(pg.Namespace)},
+			Filter: func(o runtimeClient.Object) bool {
+				return o.GetName() == pg.Name
+			},
+			State: func(o runtimeClient.Object) timeline.State {
+				current, ok := o.(*pgv1beta3.Postgresql)
+				if !ok {
+					return nil
+				}
+				return timeline.State{
+					StateClusterStatus: current.Status.ClusterStatus,
+					StateGeneration:    strconv.FormatInt(current.Generation, 10),
+				}
+			},
+		},
+		{
+			Kind:    PodKind,
+			NewList: func() runtimeClient.ObjectList { return &corev1.PodList{} },
+			ListOptions: []runtimeClient.ListOption{
+				runtimeClient.InNamespace(pg.Namespace),
+				runtimeClient.MatchingLabels(pg.podsLabels()),
+			},
+			State: func(o runtimeClient.Object) timeline.State {
+				pod, ok := o.(*corev1.Pod)
+				if !ok {
+					return nil
+				}
+				state := timeline.State{
+					StatePhase: string(pod.Status.Phase),
+					StateReady: strconv.FormatBool(dsi.IsPodReady(pod)),
+					StateRole:  pod.Labels[pgv1beta3.ReplicationRoleLabelKey],
+				}
+				if pod.DeletionTimestamp != nil {
+					state[StateDeleting] = "true"
+				}
+				return state
+			},
+		},
+	}
+}
+
+// HasReadyMaster returns true if in s a pod of a Postgresql is ready and has the master
+// replication role. Use it with timeline.Recorder.LongestWhile to measure the downtime of the
+// primary, e.g. during a failover.
+func HasReadyMaster(s timeline.Snapshot) bool {
+	return s.Any(PodKind, isReadyMaster)
+}
+
+// BecameReadyMaster matches the events after which a pod of a Postgresql is the ready master.
+var BecameReadyMaster = timeline.Matcher{
+	Description: "pod became ready master",
+	Match: func(e timeline.Event) bool {
+		return e.Object.Kind == PodKind && !e.Deleted() && isReadyMaster(e.State)
+	},
+}
+
+func isReadyMaster(s timeline.State) bool {
+	return s[StateRole] == masterRole && s[StateReady] == "true" && s[StateDeleting] == ""
+}
@@ -0,0 +1,208 @@
+package postgresql
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/jackc/pgconn"
+	corev1 "k8s.io/api/core/v1"
+	runtimeClient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/anynines/a8s-deployment/test/framework/secret"
+)
+
+// SSL modes of the connections of a Client, see
+// https://www.postgresql.org/docs/current/libpq-ssl.html#LIBPQ-SSL-PROTECTION
+const (
+	SSLModeDisable    = "disable"
+	SSLModeRequire    = "require"
+	SSLModeVerifyCA   = "verify-ca"
+	SSLModeVerifyFull = "verify-full"
+)
+
+// CACertKey is the key of the CA bundle in the secrets read by TLSFromSecret, as in the secrets of
+// cert-manager.
+const CACertKey = "ca.crt"
+
+// sslRequest is the message that asks PostgreSQL to switch the connection to TLS: the length of
+// the message followed by the SSLRequest code.
+var sslRequest = []byte{0, 0, 0, 8, 0x04, 0xd2, 0x16, 0x2f}
+
+// TLS configures the TLS connections of a Client.
+type TLS struct {
+	// Mode is the SSL mode of the connections, one of the SSLMode constants.
+	Mode string
+	// CACert is the PEM-encoded bundle of the CAs that verify the certificate of PostgreSQL.
+	// Required by SSLModeVerifyCA and SSLModeVerifyFull.
+	CACert []byte
+	// ClientCert and ClientKey are the PEM-encoded certificate and key that the client
+	// authenticates with. Optional.
+	ClientCert, ClientKey []byte
+	// ServerName is the name that SSLModeVerifyFull checks the certificate of PostgreSQL against.
+	// If it's empty the host that the client connects to is used, so set it when connecting via a
+	// port forward.
+	ServerName string
+}
+
+// TLSFromSecret returns the TLS configuration with the given mode and the CA bundle, client
+// certificate and client key of the secret namespace/name. The secret must have the CA bundle
+// under CACertKey if mode verifies the certificate of PostgreSQL. The client certificate and key
+// are optional and read from the keys of the secrets of type kubernetes.io/tls.
+func TLSFromSecret(ctx context.Context,
+	c runtimeClient.Client,
+	namespace, name, mode string,
+) (TLS, error) {
+	s, err := secret.Get(ctx, c, name, namespace)
+	if err != nil {
+		return TLS{}, err
+	}
+	t := TLS{
+		Mode:       mode,
+		CACert:     s.Data[CACertKey],
+		ClientCert: s.Data[corev1.TLSCertKey],
+		ClientKey:  s.Data[corev1.TLSPrivateKeyKey],
+	}
+	if t.verifiesCert() && len(t.CACert) == 0 {
+		return TLS{}, fmt.Errorf("secret %s/%s has no CA bundle under key %s, required by SSL "+
+			"mode %s", namespace, name, CACertKey, mode)
+	}
+	return t, nil
+}
+
+// WithTLS makes the client connect as configured by t, overriding the SSL mode passed to
+// NewClient. With NewClientOverPortForwarding the client closes the TLS connections in a way that
+// doesn't break the port forward.
+func WithTLS(t TLS) ClientOption {
+	return func(c *Client) {
+		c.tls = &t
+		c.sslmode = t.Mode
+	}
+}
+
+// Config returns the configuration of crypto/tls for connections to host, or nil if t.Mode is
+// SSLModeDisable.
+func (t TLS) Config(host string) (*tls.Config, error) {
+	if t.Mode == SSLModeDisable {
+		return nil, nil
+	}
+
+	config := &tls.Config{}
+	if len(t.ClientCert) > 0 || len(t.ClientKey) > 0 {
+		cert, err := tls.X509KeyPair(t.ClientCert, t.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	var roots *x509.CertPool
+	if t.verifiesCert() {
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(t.CACert) {
+			return nil, fmt.Errorf("SSL mode %s requires a CA bundle, but there are no PEM "+
+				"certificates in it", t.Mode)
+		}
+	}
+
+	switch t.Mode {
+	case SSLModeRequire:
+		config.InsecureSkipVerify = true
+	case SSLModeVerifyCA:
+		// The chain is verified by verifyChain rather than by crypto/tls, which would also verify
+		// the host name.
+		config.InsecureSkipVerify = true
+		config.VerifyConnection = func(cs tls.ConnectionState) error {
+			return verifyChain(cs, roots)
+		}
+	case SSLModeVerifyFull:
+		config.RootCAs = roots
+		config.ServerName = host
+		if t.ServerName != "" {
+			config.ServerName = t.ServerName
+		}
+	default:
+		return nil, fmt.Errorf("unsupported SSL mode %q", t.Mode)
+	}
+	return config, nil
+}
+
+func (t TLS) verifiesCert() bool {
+	return t.Mode == SSLModeVerifyCA || t.Mode == SSLModeVerifyFull
+}
+
+func verifyChain(cs tls.ConnectionState, roots *x509.CertPool) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("PostgreSQL presented no certificate")
+	}
+	opts := x509.VerifyOptions{Roots: roots, Intermediates: x509.NewCertPool()}
+	for _, cert := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	if _, err := cs.PeerCertificates[0].Verify(opts); err != nil {
+		return fmt.Errorf("failed to verify the certificate of PostgreSQL: %w", err)
+	}
+	return nil
+}
+
+// dialTLS returns a DialFunc that negotiates TLS with PostgreSQL itself, rather than letting pgx
+// do it, so that closing a connection doesn't send a TLS close_notify alert. PostgreSQL exits
+// without reading the alert after it reads the Terminate message that precedes it, so its kernel
+// answers the alert with a RST, and client-go closes a port forward that reads a RST. See
+// https://github.com/kubernetes/kubectl/issues/1169#issuecomment-1165140134
+func dialTLS(dial pgconn.DialFunc, config *tls.Config) pgconn.DialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		tlsConn, err := startTLS(ctx, conn, config)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return noCloseNotifyConn{Conn: tlsConn, raw: conn}, nil
+	}
+}
+
+func startTLS(ctx context.Context, conn net.Conn, config *tls.Config) (*tls.Conn, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return nil, fmt.Errorf("failed to set deadline of TLS negotiation: %w", err)
+		}
+		defer func() { conn.SetDeadline(time.Time{}) }()
+	}
+
+	if _, err := conn.Write(sslRequest); err != nil {
+		return nil, fmt.Errorf("failed to request TLS: %w", err)
+	}
+	response := make([]byte, 1)
+	if _, err := io.ReadFull(conn, response); err != nil {
+		return nil, fmt.Errorf("failed to read response to TLS request: %w", err)
+	}
+	if response[0] != 'S' {
+		return nil, errors.New("PostgreSQL refused TLS")
+	}
+
+	tlsConn := tls.Client(conn, config)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return nil, fmt.Errorf("failed TLS handshake: %w", err)
+	}
+	return tlsConn, nil
+}
+
+// noCloseNotifyConn is a TLS connection that closes the underlying connection without sending a
+// close_notify alert.
+type noCloseNotifyConn struct {
+	*tls.Conn
+	raw net.Conn
+}
+
+func (c noCloseNotifyConn) Close() error {
+	return c.raw.Close()
+}
@@ -0,0 +1,299 @@
+package postgresql_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/anynines/a8s-deployment/test/framework/postgresql"
+)
+
+const serverName = "pg0-master.ns0.svc.cluster.local"
+
+func TestTLSConfig(t *testing.T) {
+	t.Parallel()
+
+	ca := newTestCA(t, "ca")
+	otherCA := newTestCA(t, "other-ca")
+	serverCert := ca.issue(t, serverName)
+	clientCertPEM, clientKeyPEM := ca.issuePEM(t, "client")
+
+	testCases := map[string]struct {
+		tls  postgresql.TLS
+		host string
+		// wantConfigErr is true if building the configuration must fail.
+		wantConfigErr bool
+		// wantHandshakeErr is true if the handshake with the server must fail.
+		wantHandshakeErr bool
+	}{
+		"require_accepts_any_certificate": {
+			tls:  postgresql.TLS{Mode: postgresql.SSLModeRequire},
+			host: "localhost",
+		},
+		"verify_ca_accepts_certificate_of_the_ca_for_another_host": {
+			tls:  postgresql.TLS{Mode: postgresql.SSLModeVerifyCA, CACert: ca.certPEM},
+			host: "localhost",
+		},
+		"verify_ca_rejects_certificate_of_another_ca": {
+			tls:              postgresql.TLS{Mode: postgresql.SSLModeVerifyCA, CACert: otherCA.certPEM},
+			host:             serverName,
+			wantHandshakeErr: true,
+		},
+		"verify_full_accepts_certificate_of_the_ca_for_the_host": {
+			tls:  postgresql.TLS{Mode: postgresql.SSLModeVerifyFull, CACert: ca.certPEM},
+			host: serverName,
+		},
+		"verify_full_rejects_certificate_for_another_host": {
+			tls:              postgresql.TLS{Mode: postgresql.SSLModeVerifyFull, CACert: ca.certPEM},
+			host:             "localhost",
+			wantHandshakeErr: true,
+		},
+		"verify_full_checks_server_name_instead_of_host": {
+			tls: postgresql.TLS{
+				Mode:       postgresql.SSLModeVerifyFull,
+				CACert:     ca.certPEM,
+				ServerName: serverName,
+			},
+			host: "localhost",
+		},
+		"verify_full_rejects_certificate_of_another_ca": {
+			tls:              postgresql.TLS{Mode: postgresql.SSLModeVerifyFull, CACert: otherCA.certPEM},
+			host:             serverName,
+			wantHandshakeErr: true,
+		},
+		"client_certificate_is_presented": {
+			tls: postgresql.TLS{
+				Mode:       postgresql.SSLModeVerifyFull,
+				CACert:     ca.certPEM,
+				ClientCert: clientCertPEM,
+				ClientKey:  clientKeyPEM,
+			},
+			host: serverName,
+		},
+		"verify_requires_ca_bundle": {
+			tls:           postgresql.TLS{Mode: postgresql.SSLModeVerifyCA},
+			wantConfigErr: true,
+		},
+		"invalid_client_certificate": {
+			tls: postgresql.TLS{
+				Mode:       postgresql.SSLModeRequire,
+				ClientCert: []byte("not a certificate"),
+			},
+			wantConfigErr: true,
+		},
+		"unsupported_mode": {
+			tls:           postgresql.TLS{Mode: "prefer"},
+			wantConfigErr: true,
+		},
+	}
+
+	for tcName, tc := range testCases {
+		// Rebind tc into this lexical scope. Details on the why at
+		// https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		tc := tc
+		t.Run(tcName, func(t *testing.T) {
+			t.Parallel()
+
+			config, err := tc.tls.Config(tc.host)
+			if tc.wantConfigErr {
+				if err == nil {
+					t.Fatalf("Expected an error building the TLS configuration")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error building the TLS configuration, got: \"%v\"", err)
+			}
+
+			clientCerts, err := handshake(config, ca, serverCert)
+			if tc.wantHandshakeErr {
+				if err == nil {
+					t.Fatalf("Expected the handshake to fail")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no handshake error, got: \"%v\"", err)
+			}
+			if wantClientCert := len(tc.tls.ClientCert) > 0; (clientCerts > 0) != wantClientCert {
+				t.Fatalf("Expected client certificate presented to be %t, got %d certificates",
+					wantClientCert, clientCerts)
+			}
+		})
+	}
+}
+
+func TestTLSConfigIsNilWhenDisabled(t *testing.T) {
+	t.Parallel()
+
+	config, err := postgresql.TLS{Mode: postgresql.SSLModeDisable}.Config("localhost")
+	if config != nil || err != nil {
+		t.Fatalf("Expected no configuration and no error, got %v and \"%v\"", config, err)
+	}
+}
+
+func TestTLSFromSecret(t *testing.T) {
+	t.Parallel()
+
+	c := fake.NewClientBuilder().WithObjects(
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns0", Name: "pg0-tls"},
+			Data: map[string][]byte{
+				postgresql.CACertKey:      []byte("ca"),
+				corev1.TLSCertKey:         []byte("cert"),
+				corev1.TLSPrivateKeyKey:   []byte("key"),
+				"unrelated-key-is-unused": []byte("unused"),
+			},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns0", Name: "no-ca"},
+			Data:       map[string][]byte{corev1.TLSCertKey: []byte("cert")},
+		},
+	).Build()
+
+	got, err := postgresql.TLSFromSecret(context.Background(), c, "ns0", "pg0-tls",
+		postgresql.SSLModeVerifyFull)
+	if err != nil {
+		t.Fatalf("Expected no error, got: \"%v\"", err)
+	}
+	if got.Mode != postgresql.SSLModeVerifyFull || string(got.CACert) != "ca" ||
+		string(got.ClientCert) != "cert" || string(got.ClientKey) != "key" {
+		t.Fatalf("Expected TLS with the data of the secret, got %+v", got)
+	}
+
+	if _, err := postgresql.TLSFromSecret(context.Background(), c, "ns0", "no-ca",
+		postgresql.SSLModeVerifyCA); err == nil {
+		t.Fatalf("Expected an error for a secret without CA bundle and SSL mode verify-ca")
+	}
+	if _, err := postgresql.TLSFromSecret(context.Background(), c, "ns0", "no-ca",
+		postgresql.SSLModeRequire); err != nil {
+		t.Fatalf("Expected no error for a secret without CA bundle and SSL mode require, "+
+			"got: \"%v\"", err)
+	}
+	if _, err := postgresql.TLSFromSecret(context.Background(), c, "ns0", "missing",
+		postgresql.SSLModeRequire); err == nil {
+		t.Fatalf("Expected an error for a secret that doesn't exist")
+	}
+}
+
+// handshake performs a TLS handshake between a client configured by config and a server with
+// serverCert that requests client certificates issued by ca. It returns the number of
+// certificates that the client presented.
+func handshake(config *tls.Config, ca testCA, serverCert tls.Certificate) (int, error) {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	defer serverConn.Close()
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.cert)
+	server := tls.Server(serverConn, &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.VerifyClientCertIfGiven,
+		ClientCAs:    clientCAs,
+	})
+	serverDone := make(chan int, 1)
+	go func() {
+		// The error of the server is also returned to the client.
+		_ = server.Handshake()
+		serverDone <- len(server.ConnectionState().PeerCertificates)
+		// Unblock the client if the server failed.
+		serverConn.Close()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client := tls.Client(clientConn, config)
+	if err := client.HandshakeContext(ctx); err != nil {
+		return 0, err
+	}
+	return <-serverDone, nil
+}
+
+type testCA struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+}
+
+func newTestCA(t *testing.T, name string) testCA {
+	t.Helper()
+	key := newKey(t)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Expected no error creating CA certificate, got: \"%v\"", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Expected no error parsing CA certificate, got: \"%v\"", err)
+	}
+	return testCA{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}
+}
+
+// issuePEM returns a PEM-encoded certificate for name signed by ca, and its key.
+func (ca testCA) issuePEM(t *testing.T, name string) ([]byte, []byte) {
+	t.Helper()
+	key := newKey(t)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("Expected no error creating certificate, got: \"%v\"", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Expected no error marshaling key, got: \"%v\"", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func (ca testCA) issue(t *testing.T, name string) tls.Certificate {
+	t.Helper()
+	cert, err := tls.X509KeyPair(ca.issuePEM(t, name))
+	if err != nil {
+		t.Fatalf("Expected no error loading certificate, got: \"%v\"", err)
+	}
+	return cert
+}
+
+func newKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Expected no error generating key, got: \"%v\"", err)
+	}
+	return key
+}
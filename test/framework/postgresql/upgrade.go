@@ -0,0 +1,174 @@
+package postgresql
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	. "github.com/onsi/gomega"
+	"k8s.io/client-go/rest"
+	runtimeClient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	pgv1beta3 "github.com/anynines/postgresql-operator/api/v1beta3"
+
+	"github.com/anynines/a8s-deployment/test/framework"
+	"github.com/anynines/a8s-deployment/test/framework/dataset"
+	"github.com/anynines/a8s-deployment/test/framework/secret"
+	"github.com/anynines/a8s-deployment/test/framework/wait"
+)
+
+// Upgrade is the outcome of a major version upgrade of a Postgresql.
+type Upgrade struct {
+	// From and To are the major versions of PostgreSQL before and after the upgrade.
+	From, To int
+	// Duration is the time between updating the version in the spec and every pod running the
+	// new version.
+	Duration time.Duration
+	// ServerVersions are the server_version_num of each pod after the upgrade, by pod name.
+	ServerVersions map[string]int
+}
+
+// MajorVersion returns the major version of a server_version_num of PostgreSQL 10 or later, e.g.
+// 14 for 140005.
+func MajorVersion(serverVersionNum int) int {
+	return serverVersionNum / 10000
+}
+
+// SetVersion updates the major version of PostgreSQL in the spec of pg, retrying on conflicts. It
+// returns the error of the update as is, so that callers can tell a rejection of the version (e.g.
+// via apierrors.IsInvalid) from other errors.
+func (pg Postgresql) SetVersion(ctx context.Context, c runtimeClient.Client, version int) error {
+	return pg.update(ctx, c, func(spec *pgv1beta3.PostgresqlSpec) {
+		spec.Version = version
+	})
+}
+
+// ServerVersions returns the server_version_num of each pod of pg, by pod name. It queries each
+// pod via a port forward with the credentials of the admin role.
+func (pg Postgresql) ServerVersions(ctx context.Context,
+	c runtimeClient.Client,
+	config *rest.Config,
+) (map[string]int, error) {
+	pods, err := pg.Pods(ctx, c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pods of %s/%s: %w", pg.Namespace, pg.Name, err)
+	}
+	credentials, err := secret.AdminSecretData(ctx, c, pg.Name, pg.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make(map[string]int, len(pods))
+	for i := range pods {
+		pod := &pods[i]
+		err := queryPod(ctx, config, pod, credentials, func(conn *pgx.Conn) error {
+			var v int
+			query := "SELECT current_setting('server_version_num')::int;"
+			if err := conn.QueryRow(ctx, query).Scan(&v); err != nil {
+				return fmt.Errorf("failed to query server version with query %s: %w", query, err)
+			}
+			versions[pod.Name] = v
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to query pod %s: %w", pod.Name, err)
+		}
+	}
+	return versions, nil
+}
+
+// AwaitMajorVersionUpgrade updates the major version of PostgreSQL of pg to version and waits
+// until every pod of pg runs it and the replicas caught up with the primary. Then it checks
+// that every pod has all the records of each data set in want, e.g. data sets written before the
+// upgrade. It reads them with credentials, e.g. the ones of the service binding that wrote them.
+// If the update of the spec fails, e.g. because the version is rejected, it returns that
+// error right away. If the pods don't run the new version within the configured timeout it
+// returns a *wait.TimeoutError with the last observed versions.
+func (pg Postgresql) AwaitMajorVersionUpgrade(ctx context.Context,
+	c runtimeClient.Client,
+	config *rest.Config,
+	version int,
+	credentials map[string]string,
+	want ...dataset.DataSet,
+) (Upgrade, error) {
+	u := Upgrade{From: pg.Spec.Version, To: version}
+	if err := pg.SetVersion(ctx, c, version); err != nil {
+		return u, fmt.Errorf("failed to update version of %s/%s from %d to %d: %w",
+			pg.Namespace, pg.Name, u.From, version, err)
+	}
+	start := time.Now()
+
+	replicas := 1
+	if pg.Spec.Replicas != nil {
+		replicas = int(*pg.Spec.Replicas)
+	}
+	err := wait.Poll(ctx, framework.AsyncOpsTimeout(), framework.PollingPeriod(),
+		fmt.Sprintf("pods of instance %s/%s running PostgreSQL %d", pg.Namespace, pg.Name,
+			version),
+		func(ctx context.Context) (bool, any, error) {
+			versions, err := pg.ServerVersions(ctx, c, config)
+			if err != nil {
+				return false, nil, err
+			}
+			u.ServerVersions = versions
+			done := len(versions) == replicas
+			for _, v := range versions {
+				done = done && MajorVersion(v) == version
+			}
+			return done, formatServerVersions(versions), nil
+		},
+	)
+	if err != nil {
+		return u, err
+	}
+	u.Duration = time.Since(start)
+
+	if err := pg.AwaitReplicasCaughtUp(ctx, c, config); err != nil {
+		return u, err
+	}
+	if len(want) == 0 {
+		return u, nil
+	}
+	for _, pod := range sortedKeys(u.ServerVersions) {
+		err := pg.checkDataSets(ctx, c, config, pod, credentials, want)
+		if err != nil {
+			return u, fmt.Errorf("failed to find data written before the upgrade on pod %s: %w",
+				pod, err)
+		}
+	}
+	return u, nil
+}
+
+// WaitForMajorVersionUpgrade is the Gomega adapter of AwaitMajorVersionUpgrade.
+func (pg Postgresql) WaitForMajorVersionUpgrade(ctx context.Context,
+	c runtimeClient.Client,
+	config *rest.Config,
+	version int,
+	credentials map[string]string,
+	want ...dataset.DataSet,
+) Upgrade {
+	u, err := pg.AwaitMajorVersionUpgrade(ctx, c, config, version, credentials,
+		want...)
+	ExpectWithOffset(1, err).To(Succeed())
+	return u
+}
+
+func formatServerVersions(versions map[string]int) string {
+	s := make([]string, 0, len(versions))
+	for _, pod := range sortedKeys(versions) {
+		s = append(s, fmt.Sprintf("%s: %d", pod, versions[pod]))
+	}
+	return "server versions " + strings.Join(s, ", ")
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,58 @@
+package postgresql_test
+
+import (
+	"context"
+	"testing"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/anynines/a8s-deployment/test/framework/postgresql"
+)
+
+func TestMajorVersion(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		serverVersionNum int
+		want             int
+	}{
+		"13": {serverVersionNum: 130012, want: 13},
+		"14": {serverVersionNum: 140005, want: 14},
+		"16": {serverVersionNum: 160000, want: 16},
+	}
+
+	for tcName, tc := range testCases {
+		// Rebind tc into this lexical scope. Details on the why at
+		// https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		tc := tc
+		t.Run(tcName, func(t *testing.T) {
+			t.Parallel()
+
+			if got := postgresql.MajorVersion(tc.serverVersionNum); got != tc.want {
+				t.Fatalf("Expected major version %d, got %d", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestSetVersion(t *testing.T) {
+	t.Parallel()
+
+	pg := postgresql.New("ns0", "pg0", 3, postgresql.WithVersion(13))
+	c := newFakeClient(pg.GetClientObject())
+
+	stale := postgresql.New("ns0", "pg0", 3)
+	if err := stale.SetVersion(context.Background(), c, 14); err != nil {
+		t.Fatalf("Expected no error, got: \"%v\"", err)
+	}
+	if stale.Spec.Version != 14 || *stale.Spec.Replicas != 3 {
+		t.Fatalf("Expected the current Postgresql with version 14, got %+v", stale.Spec)
+	}
+	if err := c.Get(context.Background(), client.ObjectKeyFromObject(pg.GetClientObject()),
+		pg.Postgresql); err != nil {
+		t.Fatalf("Expected no error, got: \"%v\"", err)
+	}
+	if pg.Spec.Version != 14 {
+		t.Fatalf("Expected version 14 to be stored, got %d", pg.Spec.Version)
+	}
+}
@@ -0,0 +1,276 @@
+package postgresql
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	k8sresource "k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	runtimeClient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	pgv1beta3 "github.com/anynines/postgresql-operator/api/v1beta3"
+
+	"github.com/anynines/a8s-deployment/test/framework"
+	"github.com/anynines/a8s-deployment/test/framework/secret"
+	"github.com/anynines/a8s-deployment/test/framework/wait"
+)
+
+// Filesystem is the size of a filesystem, in bytes.
+type Filesystem struct {
+	Size, Used, Available int64
+}
+
+func (f Filesystem) String() string {
+	return fmt.Sprintf("size %d, used %d, available %d bytes", f.Size, f.Used, f.Available)
+}
+
+// filesystemOverhead is the share of a volume that a filesystem may use for itself, e.g. for its
+// metadata, rather than report as its size.
+const filesystemOverhead = 0.1
+
+// Expanded returns whether f is the filesystem before after its volume was expanded to size bytes:
+// f must be larger than before and than size minus the overhead of the filesystem, and have more
+// space available than before. Comparing with before matters because provisioners may round the
+// size of volumes up, so the filesystem could have been large enough before the expansion.
+func (f Filesystem) Expanded(before Filesystem, size int64) bool {
+	return f.Size > before.Size &&
+		float64(f.Size) >= float64(size)*(1-filesystemOverhead) &&
+		f.Available > before.Available
+}
+
+// ParseDF parses the output of "df -Pk" for a single file, e.g. the data directory of PostgreSQL.
+func ParseDF(output string) (Filesystem, error) {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) != 2 {
+		return Filesystem{}, fmt.Errorf("invalid df output %q: expected a header and one line",
+			output)
+	}
+	// The name of the filesystem is first and can't have spaces in the POSIX format, but the
+	// mount point is last and can, so the sizes are counted from the start.
+	fields := strings.Fields(lines[1])
+	if len(fields) < 6 {
+		return Filesystem{}, fmt.Errorf("invalid df output %q: expected at least 6 fields",
+			output)
+	}
+	var kbs [3]int64
+	for i := range kbs {
+		kb, err := strconv.ParseInt(fields[i+1], 10, 64)
+		if err != nil {
+			return Filesystem{}, fmt.Errorf("invalid df output %q: %w", output, err)
+		}
+		kbs[i] = kb * 1024
+	}
+	return Filesystem{Size: kbs[0], Used: kbs[1], Available: kbs[2]}, nil
+}
+
+// DataDirectoryFilesystem returns the filesystem of the data directory of the PostgreSQL server
+// that c connects to, as seen by the server. It runs df via COPY FROM PROGRAM, so c must connect
+// as a superuser, e.g. with the credentials of the admin role.
+func (c Client) DataDirectoryFilesystem(ctx context.Context) (Filesystem, error) {
+	dbConn, err := c.connectToDB(ctx)
+	if err != nil {
+		return Filesystem{}, err
+	}
+	defer func() { closeConnection(ctx, dbConn) }()
+	return dataDirectoryFilesystem(ctx, dbConn)
+}
+
+func dataDirectoryFilesystem(ctx context.Context, dbConn *pgx.Conn) (Filesystem, error) {
+	tx, err := dbConn.Begin(ctx)
+	if err != nil {
+		return Filesystem{}, fmt.Errorf("failed to begin a transaction: %w", err)
+	}
+	// The transaction only creates a temporary table, so it's always rolled back.
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil {
+			log.Println(err, "failed to rollback transaction")
+		}
+	}()
+
+	// Backends run in the data directory, so that's what "." is.
+	for _, query := range []string{
+		"CREATE TEMPORARY TABLE df(line text) ON COMMIT DROP;",
+		"COPY df FROM PROGRAM 'df -Pk .';",
+	} {
+		if _, err := tx.Exec(ctx, query); err != nil {
+			return Filesystem{}, fmt.Errorf("failed to run df with query %s: %w", query, err)
+		}
+	}
+	var output string
+	query := "SELECT string_agg(line, E'\\n') FROM df;"
+	if err := tx.QueryRow(ctx, query).Scan(&output); err != nil {
+		return Filesystem{}, fmt.Errorf("failed to read df output with query %s: %w", query, err)
+	}
+	return ParseDF(output)
+}
+
+// VolumeExpansion is the outcome of an expansion of the volumes of a Postgresql.
+type VolumeExpansion struct {
+	// From and To are the volume sizes in the spec before and after the expansion.
+	From, To k8sresource.Quantity
+	// Duration is the time between updating the volume size in the spec and every persistent
+	// volume claim reporting the new capacity.
+	Duration time.Duration
+	// Capacities are the capacities of the persistent volume claims after the expansion, by name.
+	Capacities map[string]k8sresource.Quantity
+	// FilesystemsBefore and Filesystems are the filesystems of the data directories before and
+	// after the expansion, by pod name.
+	FilesystemsBefore, Filesystems map[string]Filesystem
+}
+
+// SetVolumeSize updates the volume size in the spec of pg, retrying on conflicts. It returns the
+// error of the update as is, so that callers can tell a rejection of the size (e.g. via
+// apierrors.IsInvalid) from other errors.
+func (pg Postgresql) SetVolumeSize(ctx context.Context,
+	c runtimeClient.Client,
+	size k8sresource.Quantity,
+) error {
+	return pg.update(ctx, c, func(spec *pgv1beta3.PostgresqlSpec) {
+		spec.VolumeSize = size
+	})
+}
+
+// PVCCapacities returns the capacity of the persistent volume claim of each replica of pg, by
+// name. The capacity of a claim is updated once its volume, including the filesystem, has been
+// expanded.
+func (pg Postgresql) PVCCapacities(ctx context.Context,
+	c runtimeClient.Client,
+) (map[string]k8sresource.Quantity, error) {
+	replicas := 1
+	if pg.Spec.Replicas != nil {
+		replicas = int(*pg.Spec.Replicas)
+	}
+	capacities := make(map[string]k8sresource.Quantity, replicas)
+	for i := 0; i < replicas; i++ {
+		var pvc corev1.PersistentVolumeClaim
+		key := types.NamespacedName{Namespace: pg.Namespace, Name: PvcName(pg.Name, i)}
+		if err := c.Get(ctx, key, &pvc); err != nil {
+			return nil, fmt.Errorf("failed to get persistent volume claim %s: %w", key, err)
+		}
+		capacities[pvc.Name] = pvc.Status.Capacity[corev1.ResourceStorage]
+	}
+	return capacities, nil
+}
+
+// DataDirectoryFilesystems returns the filesystem of the data directory of each pod of pg, by pod
+// name. It queries each pod via a port forward with the credentials of the admin role.
+func (pg Postgresql) DataDirectoryFilesystems(ctx context.Context,
+	c runtimeClient.Client,
+	config *rest.Config,
+) (map[string]Filesystem, error) {
+	pods, err := pg.Pods(ctx, c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pods of %s/%s: %w", pg.Namespace, pg.Name, err)
+	}
+	credentials, err := secret.AdminSecretData(ctx, c, pg.Name, pg.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	filesystems := make(map[string]Filesystem, len(pods))
+	for i := range pods {
+		pod := &pods[i]
+		err := queryPod(ctx, config, pod, credentials, func(conn *pgx.Conn) error {
+			fs, err := dataDirectoryFilesystem(ctx, conn)
+			filesystems[pod.Name] = fs
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to query pod %s: %w", pod.Name, err)
+		}
+	}
+	return filesystems, nil
+}
+
+// AwaitVolumeExpansion updates the volume size of pg to size and waits until the persistent
+// volume claim of every replica reports a capacity of at least size. Then it waits until the
+// filesystem of the data directory of every pod, as reported by df run by PostgreSQL, has been
+// expanded to size (see Filesystem.Expanded), i.e. it has more free space than before. It doesn't
+// write into the new space. If the update of the spec fails, e.g. because the size is rejected,
+// it returns that error right away. If either wait doesn't succeed within the configured timeout
+// it returns a *wait.TimeoutError with the last observed sizes.
+func (pg Postgresql) AwaitVolumeExpansion(ctx context.Context,
+	c runtimeClient.Client,
+	config *rest.Config,
+	size k8sresource.Quantity,
+) (VolumeExpansion, error) {
+	e := VolumeExpansion{From: pg.Spec.VolumeSize, To: size}
+	before, err := pg.DataDirectoryFilesystems(ctx, c, config)
+	if err != nil {
+		return e, fmt.Errorf("failed to get filesystems of %s/%s before the expansion: %w",
+			pg.Namespace, pg.Name, err)
+	}
+	e.FilesystemsBefore = before
+	if err := pg.SetVolumeSize(ctx, c, size); err != nil {
+		return e, fmt.Errorf("failed to update volume size of %s/%s from %s to %s: %w",
+			pg.Namespace, pg.Name, e.From.String(), size.String(), err)
+	}
+	start := time.Now()
+
+	err = wait.Poll(ctx, framework.AsyncOpsTimeout(), framework.PollingPeriod(),
+		fmt.Sprintf("persistent volume claims of instance %s/%s having a capacity of %s",
+			pg.Namespace, pg.Name, size.String()),
+		func(ctx context.Context) (bool, any, error) {
+			capacities, err := pg.PVCCapacities(ctx, c)
+			if err != nil {
+				return false, nil, err
+			}
+			e.Capacities = capacities
+			done := true
+			for _, capacity := range capacities {
+				done = done && capacity.Cmp(size) >= 0
+			}
+			return done, fmt.Sprintf("capacities %v", formatQuantities(capacities)), nil
+		},
+	)
+	if err != nil {
+		return e, err
+	}
+	e.Duration = time.Since(start)
+
+	err = wait.Poll(ctx, framework.AsyncOpsTimeout(), framework.PollingPeriod(),
+		fmt.Sprintf("filesystems of instance %s/%s being expanded to %s", pg.Namespace, pg.Name,
+			size.String()),
+		func(ctx context.Context) (bool, any, error) {
+			filesystems, err := pg.DataDirectoryFilesystems(ctx, c, config)
+			if err != nil {
+				return false, nil, err
+			}
+			e.Filesystems = filesystems
+			done := len(filesystems) > 0
+			for pod, fs := range filesystems {
+				// A pod that didn't report a filesystem before is only compared with size.
+				done = done && fs.Expanded(before[pod], size.Value())
+			}
+			return done, fmt.Sprintf("filesystems %v", filesystems), nil
+		},
+	)
+	return e, err
+}
+
+// WaitForVolumeExpansion is the Gomega adapter of AwaitVolumeExpansion.
+func (pg Postgresql) WaitForVolumeExpansion(ctx context.Context,
+	c runtimeClient.Client,
+	config *rest.Config,
+	size k8sresource.Quantity,
+) VolumeExpansion {
+	e, err := pg.AwaitVolumeExpansion(ctx, c, config, size)
+	ExpectWithOffset(1, err).To(Succeed())
+	return e
+}
+
+func formatQuantities(quantities map[string]k8sresource.Quantity) map[string]string {
+	formatted := make(map[string]string, len(quantities))
+	for name, q := range quantities {
+		formatted[name] = q.String()
+	}
+	return formatted
+}
@@ -0,0 +1,176 @@
+package postgresql_test
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	k8sresource "k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/anynines/a8s-deployment/test/framework/postgresql"
+)
+
+func TestParseDF(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		output  string
+		want    postgresql.Filesystem
+		wantErr bool
+	}{
+		"volume": {
+			output: "Filesystem     1024-blocks  Used Available Capacity Mounted on\n" +
+				"/dev/sdb           2031440 48920   1966136       3% /home/postgres/pgdata\n",
+			want: postgresql.Filesystem{
+				Size:      2031440 * 1024,
+				Used:      48920 * 1024,
+				Available: 1966136 * 1024,
+			},
+		},
+		"mount_point_with_spaces": {
+			output: "Filesystem 1024-blocks Used Available Capacity Mounted on\n" +
+				"overlay 10 4 6 40% /mnt/pg data",
+			want: postgresql.Filesystem{Size: 10240, Used: 4096, Available: 6144},
+		},
+		"no_filesystem": {
+			output:  "Filesystem 1024-blocks Used Available Capacity Mounted on",
+			wantErr: true,
+		},
+		"not_a_number": {
+			output: "Filesystem 1024-blocks Used Available Capacity Mounted on\n" +
+				"overlay - 4 6 40% /",
+			wantErr: true,
+		},
+	}
+
+	for tcName, tc := range testCases {
+		// Rebind tc into this lexical scope. Details on the why at
+		// https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		tc := tc
+		t.Run(tcName, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := postgresql.ParseDF(tc.output)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Expected error %t, got: \"%v\"", tc.wantErr, err)
+			}
+			if got != tc.want {
+				t.Fatalf("Expected %s, got %s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestFilesystemExpanded(t *testing.T) {
+	t.Parallel()
+
+	const gi = 1 << 30
+	// A provisioner that rounds 1Gi up to 2Gi.
+	before := postgresql.Filesystem{Size: 2 * gi, Used: gi / 10, Available: 2*gi - gi/10}
+
+	testCases := map[string]struct {
+		after postgresql.Filesystem
+		size  int64
+		want  bool
+	}{
+		"grown_to_size_minus_overhead": {
+			after: postgresql.Filesystem{Size: 4*gi - gi/10, Used: gi / 10,
+				Available: 4*gi - gi/5},
+			size: 4 * gi,
+			want: true,
+		},
+		"large_enough_but_not_grown": {
+			after: before,
+			size:  2 * gi,
+			want:  false,
+		},
+		"grown_but_smaller_than_size": {
+			after: postgresql.Filesystem{Size: 3 * gi, Used: gi / 10, Available: 3*gi - gi/10},
+			size:  4 * gi,
+			want:  false,
+		},
+		"grown_without_more_free_space": {
+			after: postgresql.Filesystem{Size: 4 * gi, Used: 2 * gi, Available: gi},
+			size:  4 * gi,
+			want:  false,
+		},
+	}
+
+	for tcName, tc := range testCases {
+		// Rebind tc into this lexical scope. Details on the why at
+		// https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		tc := tc
+		t.Run(tcName, func(t *testing.T) {
+			t.Parallel()
+
+			if got := tc.after.Expanded(before, tc.size); got != tc.want {
+				t.Fatalf("Expected %s expanded from %s to %d bytes to be %t, got %t", tc.after,
+					before, tc.size, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestPVCCapacities(t *testing.T) {
+	t.Parallel()
+
+	pg := postgresql.New("ns0", "pg0", 2)
+	c := newFakeClient(
+		newPVCWithCapacity(postgresql.PvcName("pg0", 0), "2Gi"),
+		newPVCWithCapacity(postgresql.PvcName("pg0", 1), "1Gi"),
+		newPVCWithCapacity(postgresql.PvcName("pg1", 0), "5Gi"),
+	)
+
+	capacities, err := pg.PVCCapacities(context.Background(), c)
+	if err != nil {
+		t.Fatalf("Expected no error, got: \"%v\"", err)
+	}
+	want := map[string]string{"pgdata-pg0-0": "2Gi", "pgdata-pg0-1": "1Gi"}
+	if len(capacities) != len(want) {
+		t.Fatalf("Expected capacities %v, got %v", want, capacities)
+	}
+	for name, capacity := range want {
+		if got := capacities[name]; got.Cmp(k8sresource.MustParse(capacity)) != 0 {
+			t.Fatalf("Expected capacity %s of %s, got %s", capacity, name, got.String())
+		}
+	}
+
+	if _, err := postgresql.New("ns0", "pg0", 3).PVCCapacities(context.Background(),
+		c); err == nil {
+		t.Fatalf("Expected an error for the missing claim of the third replica")
+	}
+}
+
+func TestSetVolumeSize(t *testing.T) {
+	t.Parallel()
+
+	pg := postgresql.New("ns0", "pg0", 1, postgresql.WithVolumeSize("1Gi"))
+	c := newFakeClient(pg.GetClientObject())
+
+	if err := pg.SetVolumeSize(context.Background(), c,
+		k8sresource.MustParse("2Gi")); err != nil {
+		t.Fatalf("Expected no error, got: \"%v\"", err)
+	}
+	stored := postgresql.NewEmpty()
+	if err := c.Get(context.Background(), client.ObjectKeyFromObject(pg.GetClientObject()),
+		stored.Postgresql); err != nil {
+		t.Fatalf("Expected no error, got: \"%v\"", err)
+	}
+	if stored.Spec.VolumeSize.Cmp(k8sresource.MustParse("2Gi")) != 0 {
+		t.Fatalf("Expected volume size 2Gi to be stored, got %s",
+			stored.Spec.VolumeSize.String())
+	}
+}
+
+func newPVCWithCapacity(name, capacity string) *corev1.PersistentVolumeClaim {
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns0", Name: name},
+		Status: corev1.PersistentVolumeClaimStatus{
+			Capacity: corev1.ResourceList{
+				corev1.ResourceStorage: k8sresource.MustParse(capacity),
+			},
+		},
+	}
+}
@@ -0,0 +1,29 @@
+package framework
+
+import (
+	"fmt"
+
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// RESTConfig returns the config for the API server of the cluster to test: the one of the
+// kubeconfig at kubeconfigPath or, if kubeconfigPath is empty, the in-cluster config of the
+// service account of the pod that the tests run in, e.g. as a Kubernetes Job. The Kubernetes
+// client and the port forwards of a test suite should share it.
+func RESTConfig(kubeconfigPath string) (*rest.Config, error) {
+	if kubeconfigPath == "" {
+		config, err := rest.InClusterConfig()
+		if err != nil {
+			return nil, fmt.Errorf("failed to build in-cluster config: %w", err)
+		}
+		return config, nil
+	}
+
+	config, err := clientcmd.BuildConfigFromFlags("", kubeconfigPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build config from kubeconfig %s: %w", kubeconfigPath,
+			err)
+	}
+	return config, nil
+}
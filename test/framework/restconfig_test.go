@@ -0,0 +1,52 @@
+package framework_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/anynines/a8s-deployment/test/framework"
+)
+
+const kubeconfig = `
+apiVersion: v1
+kind: Config
+clusters:
+- name: test
+  cluster:
+    server: https://api.example.com:6443/prefix
+contexts:
+- name: test
+  context:
+    cluster: test
+    user: test
+current-context: test
+users:
+- name: test
+  user:
+    token: secret
+`
+
+func TestRESTConfig(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "kubeconfig")
+	if err := os.WriteFile(path, []byte(kubeconfig), 0o600); err != nil {
+		t.Fatalf("Expected no error writing kubeconfig, got: \"%v\"", err)
+	}
+
+	config, err := framework.RESTConfig(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got: \"%v\"", err)
+	}
+	if config.Host != "https://api.example.com:6443/prefix" {
+		t.Fatalf("Expected host of the kubeconfig, got %q", config.Host)
+	}
+	if config.BearerToken != "secret" {
+		t.Fatalf("Expected token of the kubeconfig, got %q", config.BearerToken)
+	}
+
+	if _, err := framework.RESTConfig(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Fatalf("Expected an error for a missing kubeconfig, got none")
+	}
+}
@@ -3,25 +3,18 @@ package restore
 import (
 	"context"
 	"fmt"
-	"time"
 
 	. "github.com/onsi/gomega"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/types"
 	runtimeClient "sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/anynines/a8s-backup-manager/api/v1beta3"
 	"github.com/anynines/a8s-deployment/test/framework"
+	"github.com/anynines/a8s-deployment/test/framework/timeline"
+	"github.com/anynines/a8s-deployment/test/framework/wait"
 )
 
-const (
-	// asyncOpsTimeoutMins is the amount of minutes after which assertions fail if the condition
-	// they check has not become true. Needed because some conditions might become true only after
-	// some time, so we need to check them asynchronously.
-	// TODO: Make asyncOpsTimeoutMins an invocation parameter.
-	asyncOpsTimeoutMins = time.Minute * 5
-	suffixLength        = 6
-)
+const suffixLength = 6
 
 // Option represents a functional option for restore objects. To learn what a functional option is,
 // read here: https://dave.cheney.net/2014/10/17/functional-options-for-friendly-apis
@@ -62,34 +55,55 @@ func restorePrefix(dsiName string) string {
 	return fmt.Sprintf("%s-restore", dsiName)
 }
 
-func WaitForReadiness(ctx context.Context, restore *v1beta3.Restore, c runtimeClient.Client) {
-	var err error
-	EventuallyWithOffset(1, func() bool {
-		restoreCreated := New()
-		if err = c.Get(
-			ctx,
-			types.NamespacedName{
-				Name:      restore.GetName(),
-				Namespace: restore.GetNamespace(),
-			},
-			restoreCreated,
-		); err != nil {
-			return false
-		}
-
-		for _, c := range restoreCreated.Status.Conditions {
-			if c.Type == "Complete" && c.Status == v1.ConditionTrue {
-				return true
-			}
-		}
-
-		return false
-	}, asyncOpsTimeoutMins, 1*time.Second).Should(BeTrue(),
-		fmt.Sprintf("timeout reached waiting for restore %s/%s readiness at %s: %s",
+// AwaitReadiness waits for the restore object status condition of type "Complete" to indicate
+// true. It returns a *wait.TimeoutError carrying the last observed conditions if that doesn't
+// happen within the configured timeout.
+func AwaitReadiness(ctx context.Context, restore *v1beta3.Restore, c runtimeClient.Client) error {
+	target := New()
+	target.Name, target.Namespace = restore.GetName(), restore.GetNamespace()
+	return wait.ForObject(ctx, c, target, framework.AsyncOpsTimeout(), framework.PollingPeriod(),
+		fmt.Sprintf("restore %s/%s readiness at %s",
 			restore.GetNamespace(),
 			restore.GetName(),
 			restore.GetCreationTimestamp().String(),
-			err,
 		),
+		func(o runtimeClient.Object) (bool, any, error) {
+			restoreCreated, ok := o.(*v1beta3.Restore)
+			if !ok {
+				return false, "not found", nil
+			}
+
+			for _, c := range restoreCreated.Status.Conditions {
+				if c.Type == "Complete" && c.Status == v1.ConditionTrue {
+					return true, nil, nil
+				}
+			}
+			return false, restoreCreated.Status.Conditions, nil
+		},
 	)
 }
+
+// WaitForReadiness is the Gomega adapter of AwaitReadiness.
+func WaitForReadiness(ctx context.Context, restore *v1beta3.Restore, c runtimeClient.Client) {
+	ExpectWithOffset(1, AwaitReadiness(ctx, restore, c)).To(Succeed())
+}
+
+// TimelineSource returns the source to record the conditions of the restores of dsi in a timeline.
+func TimelineSource(dsi runtimeClient.Object) timeline.Source {
+	return timeline.Source{
+		Kind:        "Restore",
+		NewList:     func() runtimeClient.ObjectList { return &v1beta3.RestoreList{} },
+		ListOptions: []runtimeClient.ListOption{runtimeClient.InNamespace(dsi.GetNamespace())},
+		Filter: func(o runtimeClient.Object) bool {
+			rst, ok := o.(*v1beta3.Restore)
+			return ok && rst.Spec.ServiceInstance.Name == dsi.GetName()
+		},
+		State: func(o runtimeClient.Object) timeline.State {
+			rst, ok := o.(*v1beta3.Restore)
+			if !ok {
+				return nil
+			}
+			return timeline.ConditionsState(rst.Status.Conditions)
+		},
+	}
+}
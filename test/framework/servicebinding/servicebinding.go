@@ -3,20 +3,16 @@ package servicebinding
 import (
 	"context"
 	"fmt"
-	"time"
 
 	. "github.com/onsi/gomega"
-	"k8s.io/apimachinery/pkg/types"
 	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/anynines/a8s-deployment/test/framework"
+	"github.com/anynines/a8s-deployment/test/framework/wait"
 	"github.com/anynines/a8s-service-binding-controller/api/v1beta3"
 )
 
-const (
-	asyncOpsTimeoutMins = time.Minute * 5
-	suffixLength        = 6
-)
+const suffixLength = 6
 
 // Option represents a functional option for service binding objects. To learn what a functional
 // option is, read here: https://dave.cheney.net/2014/10/17/functional-options-for-friendly-apis
@@ -59,23 +55,26 @@ func SecretName(sbName string) string {
 	return fmt.Sprintf("%s-%s", sbName, "service-binding")
 }
 
-func WaitForReadiness(ctx context.Context, sb *v1beta3.ServiceBinding, c runtimeclient.Client) {
-	var err error
-	EventuallyWithOffset(1, func() bool {
-		sbCreated := New()
-		if err = c.Get(
-			ctx,
-			types.NamespacedName{Name: sb.GetName(), Namespace: sb.GetNamespace()},
-			sbCreated,
-		); err != nil {
-			return false
-		}
-		return sbCreated.Status.Implemented
-	}, asyncOpsTimeoutMins, 1*time.Second).Should(BeTrue(),
-		fmt.Sprintf("timeout reached waiting for servicebinding %s/%s readiness: %s",
-			sb.GetNamespace(),
-			sb.GetName(),
-			err,
-		),
+// AwaitReadiness waits until sb reports that it's implemented. It returns a *wait.TimeoutError
+// carrying the last observed status if that doesn't happen within the configured timeout.
+func AwaitReadiness(ctx context.Context, sb *v1beta3.ServiceBinding,
+	c runtimeclient.Client,
+) error {
+	target := New()
+	target.Name, target.Namespace = sb.GetName(), sb.GetNamespace()
+	return wait.ForObject(ctx, c, target, framework.AsyncOpsTimeout(), framework.PollingPeriod(),
+		fmt.Sprintf("servicebinding %s/%s readiness", sb.GetNamespace(), sb.GetName()),
+		func(o runtimeclient.Object) (bool, any, error) {
+			sbCreated, ok := o.(*v1beta3.ServiceBinding)
+			if !ok {
+				return false, "not found", nil
+			}
+			return sbCreated.Status.Implemented, fmt.Sprintf("%+v", sbCreated.Status), nil
+		},
 	)
 }
+
+// WaitForReadiness is the Gomega adapter of AwaitReadiness.
+func WaitForReadiness(ctx context.Context, sb *v1beta3.ServiceBinding, c runtimeclient.Client) {
+	ExpectWithOffset(1, AwaitReadiness(ctx, sb, c)).To(Succeed())
+}
@@ -0,0 +1,477 @@
+// Package timeline records how the state of Kubernetes objects changes over time, e.g. the status
+// of a DSI and the readiness of its pods during a failover, so that tests can assert on the
+// sequence of states rather than only on the final one, and print it when they fail.
+package timeline
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8serrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/watch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// restartDelay is how long a Recorder waits before restarting a watch that failed.
+const restartDelay = time.Second
+
+// State is the state of an object as a set of named values, e.g. {"ready": "true"}.
+type State map[string]string
+
+// String returns the values of s sorted by name, e.g. "phase=Running ready=true".
+func (s State) String() string {
+	keys := make([]string, 0, len(s))
+	for k := range s {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", k, s[k]))
+	}
+	return strings.Join(pairs, " ")
+}
+
+func (s State) equal(other State) bool {
+	if len(s) != len(other) || (s == nil) != (other == nil) {
+		return false
+	}
+	for k, v := range s {
+		if ov, ok := other[k]; !ok || ov != v {
+			return false
+		}
+	}
+	return true
+}
+
+// ConditionsState returns the state made of the status of each condition, by condition type, e.g.
+// {"Complete": "True"}.
+func ConditionsState(conditions []metav1.Condition) State {
+	s := State{}
+	for _, c := range conditions {
+		s[c.Type] = string(c.Status)
+	}
+	return s
+}
+
+// Source is a set of objects of the same type whose state a Recorder records.
+type Source struct {
+	// Kind names the objects of the source in the timeline, e.g. "Pod".
+	Kind string
+	// NewList returns an empty list of the objects of the source, used to list and watch them.
+	NewList func() client.ObjectList
+	// ListOptions select the objects of the source, e.g. by namespace and labels.
+	ListOptions []client.ListOption
+	// Filter, if set, selects the objects of the source among the ones selected by ListOptions.
+	Filter func(client.Object) bool
+	// State extracts the state to record from an object of the source.
+	State func(client.Object) State
+}
+
+// state returns the state of o, which is never nil as that would mean that o doesn't exist.
+func (src Source) state(o client.Object) State {
+	if s := src.State(o); s != nil {
+		return s
+	}
+	return State{}
+}
+
+// ObjectRef identifies an object in a timeline.
+type ObjectRef struct {
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+func (r ObjectRef) String() string {
+	return fmt.Sprintf("%s %s/%s", r.Kind, r.Namespace, r.Name)
+}
+
+// Event is a change of the state of an object.
+type Event struct {
+	// Time is when the change was observed, which can be slightly later than when it happened.
+	Time time.Time
+	// Object is the object whose state changed.
+	Object ObjectRef
+	// State is the state of the object after the change, nil if the object was deleted.
+	State State
+}
+
+// Deleted returns true if the event is the deletion of its object.
+func (e Event) Deleted() bool {
+	return e.State == nil
+}
+
+func (e Event) String() string {
+	if e.Deleted() {
+		return fmt.Sprintf("%s: deleted", e.Object)
+	}
+	return fmt.Sprintf("%s: %s", e.Object, e.State)
+}
+
+// Snapshot is the state of all the recorded objects that exist at a moment.
+type Snapshot map[ObjectRef]State
+
+// Any returns true if an object of the given kind has a state that satisfies match.
+func (s Snapshot) Any(kind string, match func(State) bool) bool {
+	for ref, state := range s {
+		if ref.Kind == kind && match(state) {
+			return true
+		}
+	}
+	return false
+}
+
+// Recorder records the changes of the state of the objects of a set of sources, from when it's
+// started to when it's stopped. It's safe for concurrent use.
+type Recorder struct {
+	mu     sync.Mutex
+	start  time.Time
+	end    time.Time
+	events []Event
+	last   map[ObjectRef]State
+	errs   []error
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// Start lists the objects of sources, records their current state and starts watching them to
+// record every change of their state until Stop is invoked or ctx is done. c must implement
+// client.WithWatch, as polling could miss short-lived states.
+func Start(ctx context.Context, c client.Client, sources ...Source) (*Recorder, error) {
+	wc, ok := c.(client.WithWatch)
+	if !ok {
+		return nil, fmt.Errorf("failed to start recording a timeline: %T can't watch objects", c)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	r := &Recorder{
+		start:  time.Now(),
+		last:   map[ObjectRef]State{},
+		cancel: cancel,
+	}
+
+	watches := make([]watch.Interface, 0, len(sources))
+	for _, src := range sources {
+		// The watch is started before listing the objects, so no change can go unnoticed.
+		w, err := r.watchAndList(ctx, wc, src, r.start)
+		if err != nil {
+			cancel()
+			for _, w := range watches {
+				w.Stop()
+			}
+			return nil, err
+		}
+		watches = append(watches, w)
+	}
+
+	for i := range sources {
+		r.wg.Add(1)
+		go r.record(ctx, wc, sources[i], watches[i])
+	}
+	return r, nil
+}
+
+// Stop stops recording. It can be invoked more than once.
+func (r *Recorder) Stop() {
+	r.cancel()
+	r.wg.Wait()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.end.IsZero() {
+		r.end = time.Now()
+	}
+}
+
+// Err returns the errors met restarting the watches of the sources, during which changes might
+// have gone unrecorded.
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return k8serrors.NewAggregate(r.errs)
+}
+
+// Events returns the events recorded so far, in the order in which they were observed. The
+// initial states of the objects are recorded as events at the time the recording started.
+func (r *Recorder) Events() []Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Event(nil), r.events...)
+}
+
+// String returns the timeline as text, one event per line with the time elapsed since the
+// recording started.
+func (r *Recorder) String() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "timeline started at %s\n", r.start.Format(time.RFC3339Nano))
+	for _, e := range r.events {
+		fmt.Fprintf(&b, "%+10.3fs %s\n", e.Time.Sub(r.start).Seconds(), e)
+	}
+	if !r.end.IsZero() {
+		fmt.Fprintf(&b, "%+10.3fs timeline stopped\n", r.end.Sub(r.start).Seconds())
+	}
+	return b.String()
+}
+
+// LongestWhile returns the longest stretch of time during which the snapshot of the recorded
+// objects satisfied cond, e.g. the longest time without a ready primary. A stretch that's still
+// ongoing lasts until Stop was invoked or, if it wasn't, until now.
+func (r *Recorder) LongestWhile(cond func(Snapshot) bool) time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	end := r.end
+	if end.IsZero() {
+		end = time.Now()
+	}
+
+	var longest time.Duration
+	var since time.Time
+	holding := false
+	update := func(now time.Time, holds bool) {
+		switch {
+		case holds && !holding:
+			holding, since = true, now
+		case !holds && holding:
+			holding = false
+			longest = max(longest, now.Sub(since))
+		}
+	}
+
+	// Events with the same time (e.g. the initial states) are applied together, so that the
+	// condition is only evaluated on snapshots that actually existed.
+	snapshot := Snapshot{}
+	i := 0
+	for at := r.start; ; at = r.events[i].Time {
+		for i < len(r.events) && !r.events[i].Time.After(at) {
+			snapshot.apply(r.events[i])
+			i++
+		}
+		update(at, cond(snapshot))
+		if i == len(r.events) {
+			break
+		}
+	}
+	if holding {
+		longest = max(longest, end.Sub(since))
+	}
+	return longest
+}
+
+// Matcher selects events, e.g. for InOrder.
+type Matcher struct {
+	// Description describes the selected events in error messages.
+	Description string
+	// Match returns true for the selected events.
+	Match func(Event) bool
+}
+
+// HasState matches the events after which an object of the given kind has value for key.
+func HasState(kind, key, value string) Matcher {
+	return Matcher{
+		Description: fmt.Sprintf("%s with %s=%s", kind, key, value),
+		Match: func(e Event) bool {
+			if e.Object.Kind != kind || e.Deleted() {
+				return false
+			}
+			v, ok := e.State[key]
+			return ok && v == value
+		},
+	}
+}
+
+// Deleted matches the deletion of an object of the given kind.
+func Deleted(kind string) Matcher {
+	return Matcher{
+		Description: fmt.Sprintf("%s deleted", kind),
+		Match: func(e Event) bool {
+			return e.Object.Kind == kind && e.Deleted()
+		},
+	}
+}
+
+// Named restricts m to the events of the objects with the given name.
+func (m Matcher) Named(name string) Matcher {
+	return Matcher{
+		Description: fmt.Sprintf("%s (%s)", m.Description, name),
+		Match: func(e Event) bool {
+			return e.Object.Name == name && m.Match(e)
+		},
+	}
+}
+
+// InOrder returns an error, which includes the timeline, unless the recorded events include, in
+// the given order, an event selected by each of the matchers. Other events can occur in between.
+func (r *Recorder) InOrder(matchers ...Matcher) error {
+	events := r.Events()
+
+	next := 0
+	for i, m := range matchers {
+		for next < len(events) && !m.Match(events[next]) {
+			next++
+		}
+		if next == len(events) {
+			after := ""
+			if i > 0 {
+				after = fmt.Sprintf(" after %q", matchers[i-1].Description)
+			}
+			return fmt.Errorf("no event %q%s in the %s", m.Description, after, r)
+		}
+		next++
+	}
+	return nil
+}
+
+func (s Snapshot) apply(e Event) {
+	if e.Deleted() {
+		delete(s, e.Object)
+		return
+	}
+	s[e.Object] = e.State
+}
+
+// watchAndList starts a watch on the objects of src, then lists them and records their state as
+// observed at time t, including the deletion of the objects that are no longer listed.
+func (r *Recorder) watchAndList(ctx context.Context,
+	c client.WithWatch,
+	src Source,
+	t time.Time,
+) (watch.Interface, error) {
+	w, err := c.Watch(ctx, src.NewList(), src.ListOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to watch objects of kind %s: %w", src.Kind, err)
+	}
+
+	list := src.NewList()
+	if err := c.List(ctx, list, src.ListOptions...); err != nil {
+		w.Stop()
+		return nil, fmt.Errorf("failed to list objects of kind %s: %w", src.Kind, err)
+	}
+	objs, err := objects(list)
+	if err != nil {
+		w.Stop()
+		return nil, fmt.Errorf("failed to list objects of kind %s: %w", src.Kind, err)
+	}
+
+	listed := map[ObjectRef]bool{}
+	for _, o := range objs {
+		if src.Filter != nil && !src.Filter(o) {
+			continue
+		}
+		ref := ObjectRef{Kind: src.Kind, Namespace: o.GetNamespace(), Name: o.GetName()}
+		listed[ref] = true
+		r.observe(t, ref, src.state(o))
+	}
+
+	r.mu.Lock()
+	var gone []ObjectRef
+	for ref := range r.last {
+		if ref.Kind == src.Kind && !listed[ref] {
+			gone = append(gone, ref)
+		}
+	}
+	r.mu.Unlock()
+	for _, ref := range gone {
+		r.observe(t, ref, nil)
+	}
+
+	return w, nil
+}
+
+// record records the changes of the objects of src observed via w, restarting the watch when it
+// ends, until ctx is done.
+func (r *Recorder) record(ctx context.Context, c client.WithWatch, src Source, w watch.Interface) {
+	defer r.wg.Done()
+	defer func() {
+		if w != nil {
+			w.Stop()
+		}
+	}()
+
+	for {
+		if w == nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(restartDelay):
+			}
+
+			var err error
+			if w, err = r.watchAndList(ctx, c, src, time.Now()); err != nil {
+				if ctx.Err() == nil {
+					r.mu.Lock()
+					r.errs = append(r.errs, err)
+					r.mu.Unlock()
+				}
+				continue
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case e, open := <-w.ResultChan():
+			if !open || e.Type == watch.Error {
+				w.Stop()
+				w = nil
+				continue
+			}
+			o, ok := e.Object.(client.Object)
+			if !ok || e.Type == watch.Bookmark || (src.Filter != nil && !src.Filter(o)) {
+				continue
+			}
+			ref := ObjectRef{Kind: src.Kind, Namespace: o.GetNamespace(), Name: o.GetName()}
+			if e.Type == watch.Deleted {
+				r.observe(time.Now(), ref, nil)
+			} else {
+				r.observe(time.Now(), ref, src.state(o))
+			}
+		}
+	}
+}
+
+// observe records that the object ref has state at time t, if that's a change. A nil state means
+// that the object doesn't exist.
+func (r *Recorder) observe(t time.Time, ref ObjectRef, state State) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	last, known := r.last[ref]
+	if (state == nil && !known) || (known && last.equal(state)) {
+		return
+	}
+	if state == nil {
+		delete(r.last, ref)
+	} else {
+		r.last[ref] = state
+	}
+	r.events = append(r.events, Event{Time: t, Object: ref, State: state})
+}
+
+func objects(list client.ObjectList) ([]client.Object, error) {
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return nil, err
+	}
+	objs := make([]client.Object, 0, len(items))
+	for _, item := range items {
+		o, ok := item.(client.Object)
+		if !ok {
+			return nil, fmt.Errorf("%T is not a client.Object", item)
+		}
+		objs = append(objs, o)
+	}
+	return objs, nil
+}
@@ -0,0 +1,210 @@
+package timeline_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/anynines/a8s-deployment/test/framework/timeline"
+)
+
+const (
+	namespace = "test-ns"
+	kind      = "ConfigMap"
+	stateKey  = "state"
+)
+
+func TestRecorderRecordsChanges(t *testing.T) {
+	t.Parallel()
+
+	c := newFakeClient(newConfigMap("cm0", "Provisioning"))
+	r := startRecorder(t, c)
+
+	setState(t, c, "cm0", "Running")
+	// Updates that don't change the recorded state are not events.
+	setLabel(t, c, "cm0", "irrelevant")
+	if err := c.Create(context.Background(), newConfigMap("cm1", "Provisioning")); err != nil {
+		t.Fatalf("Expected no error creating config map, got: \"%v\"", err)
+	}
+	if err := c.Delete(context.Background(), newConfigMap("cm0", "")); err != nil {
+		t.Fatalf("Expected no error deleting config map, got: \"%v\"", err)
+	}
+
+	want := []string{
+		"ConfigMap test-ns/cm0: state=Provisioning",
+		"ConfigMap test-ns/cm0: state=Running",
+		"ConfigMap test-ns/cm1: state=Provisioning",
+		"ConfigMap test-ns/cm0: deleted",
+	}
+	got := awaitEvents(t, r, len(want))
+	r.Stop()
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Expected events %v, got %v", want, got)
+		}
+	}
+
+	if err := r.InOrder(
+		timeline.HasState(kind, stateKey, "Running"),
+		timeline.Deleted(kind).Named("cm0"),
+	); err != nil {
+		t.Fatalf("Expected no error, got: \"%v\"", err)
+	}
+	err := r.InOrder(
+		timeline.Deleted(kind),
+		timeline.HasState(kind, stateKey, "Running"),
+	)
+	if err == nil || !strings.Contains(err.Error(), got[len(got)-1]) {
+		t.Fatalf("Expected an error including the timeline, got: \"%v\"", err)
+	}
+	if err := r.Err(); err != nil {
+		t.Fatalf("Expected no watch error, got: \"%v\"", err)
+	}
+}
+
+func TestRecorderIgnoresFilteredObjects(t *testing.T) {
+	t.Parallel()
+
+	c := newFakeClient(newConfigMap("cm0", "Provisioning"), newConfigMap("other", "Provisioning"))
+	r := startRecorder(t, c)
+
+	setState(t, c, "other", "Running")
+	setState(t, c, "cm0", "Running")
+
+	got := awaitEvents(t, r, 2)
+	r.Stop()
+	for _, e := range got {
+		if strings.Contains(e, "other") {
+			t.Fatalf("Expected no events of filtered out objects, got %v", got)
+		}
+	}
+}
+
+func TestLongestWhile(t *testing.T) {
+	t.Parallel()
+
+	const failingFor = 200 * time.Millisecond
+	c := newFakeClient(newConfigMap("cm0", "Running"), newConfigMap("cm1", "Running"))
+	r := startRecorder(t, c)
+
+	setState(t, c, "cm0", "Failed")
+	awaitEvents(t, r, 3)
+	time.Sleep(failingFor)
+	setState(t, c, "cm0", "Running")
+	awaitEvents(t, r, 4)
+	r.Stop()
+
+	noneFailed := func(s timeline.Snapshot) bool {
+		return !s.Any(kind, func(st timeline.State) bool { return st[stateKey] == "Failed" })
+	}
+	someFailed := func(s timeline.Snapshot) bool { return !noneFailed(s) }
+	if got := r.LongestWhile(someFailed); got < failingFor/2 || got > 10*failingFor {
+		t.Fatalf("Expected a config map to be failed for about %s, got %s", failingFor, got)
+	}
+
+	events := r.Events()
+	total := events[len(events)-1].Time.Sub(events[0].Time)
+	if got := r.LongestWhile(noneFailed); got > total {
+		t.Fatalf("Expected no config map to be failed for at most %s, got %s", total, got)
+	}
+	never := func(timeline.Snapshot) bool { return false }
+	if got := r.LongestWhile(never); got != 0 {
+		t.Fatalf("Expected 0 for a condition that never holds, got %s", got)
+	}
+}
+
+func TestStartFailsIfClientCannotWatch(t *testing.T) {
+	t.Parallel()
+
+	c := struct{ client.Client }{newFakeClient()}
+	if _, err := timeline.Start(context.Background(), c, configMaps()); err == nil {
+		t.Fatalf("Expected an error starting a recorder with a client that can't watch")
+	}
+}
+
+func startRecorder(t *testing.T, c client.Client) *timeline.Recorder {
+	t.Helper()
+	r, err := timeline.Start(context.Background(), c, configMaps())
+	if err != nil {
+		t.Fatalf("Expected no error starting the recorder, got: \"%v\"", err)
+	}
+	t.Cleanup(r.Stop)
+	return r
+}
+
+// configMaps is a source that records the state of the config maps of the test namespace, except
+// the one named "other".
+func configMaps() timeline.Source {
+	return timeline.Source{
+		Kind:        kind,
+		NewList:     func() client.ObjectList { return &corev1.ConfigMapList{} },
+		ListOptions: []client.ListOption{client.InNamespace(namespace)},
+		Filter:      func(o client.Object) bool { return o.GetName() != "other" },
+		State: func(o client.Object) timeline.State {
+			return timeline.State{stateKey: o.(*corev1.ConfigMap).Data[stateKey]}
+		},
+	}
+}
+
+// awaitEvents waits for the recorder to record n events and returns them as strings.
+func awaitEvents(t *testing.T, r *timeline.Recorder, n int) []string {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		events := r.Events()
+		if len(events) >= n {
+			got := make([]string, 0, len(events))
+			for _, e := range events {
+				got = append(got, e.String())
+			}
+			return got
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d events, got %d: %v", n, len(events), r)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func newConfigMap(name, state string) *corev1.ConfigMap {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+	}
+	if state != "" {
+		cm.Data = map[string]string{stateKey: state}
+	}
+	return cm
+}
+
+func newFakeClient(objs ...client.Object) client.WithWatch {
+	return fake.NewClientBuilder().WithObjects(objs...).Build()
+}
+
+func setState(t *testing.T, c client.Client, name, state string) {
+	t.Helper()
+	update(t, c, name, func(cm *corev1.ConfigMap) { cm.Data = map[string]string{stateKey: state} })
+}
+
+func setLabel(t *testing.T, c client.Client, name, label string) {
+	t.Helper()
+	update(t, c, name, func(cm *corev1.ConfigMap) { cm.Labels = map[string]string{label: ""} })
+}
+
+func update(t *testing.T, c client.Client, name string, mutate func(*corev1.ConfigMap)) {
+	t.Helper()
+	cm := &corev1.ConfigMap{}
+	if err := c.Get(context.Background(), client.ObjectKey{Namespace: namespace, Name: name},
+		cm); err != nil {
+		t.Fatalf("Expected no error getting config map, got: \"%v\"", err)
+	}
+	mutate(cm)
+	if err := c.Update(context.Background(), cm); err != nil {
+		t.Fatalf("Expected no error updating config map, got: \"%v\"", err)
+	}
+}
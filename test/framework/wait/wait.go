@@ -0,0 +1,137 @@
+// Package wait provides context-driven helpers to wait for asynchronous conditions. Unlike
+// Gomega's Eventually, they return errors rather than failing the running spec, so they can be used
+// from plain `go test`, CLIs and controllers. The Ginkgo-oriented WaitFor* functions of the other
+// framework packages are thin Gomega adapters around the error-returning variants built on this
+// package.
+package wait
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ConditionFunc checks a condition once. It returns whether the condition is met, the state that
+// was observed (reported in a TimeoutError if the condition is never met) and an error if the
+// check failed. A failed check doesn't end the wait unless its error is wrapped via Terminal: the
+// condition is checked again after the polling period.
+type ConditionFunc func(ctx context.Context) (done bool, state any, err error)
+
+// TimeoutError is returned when a condition is not met before the wait ends.
+type TimeoutError struct {
+	// Condition describes what was waited for (e.g. "instance ns/pg-0 readiness").
+	Condition string
+	// Elapsed is how long the wait lasted.
+	Elapsed time.Duration
+	// Attempts is the number of times the condition was checked.
+	Attempts int
+	// LastState is the state observed by the last check of the condition. It's nil if the last
+	// check observed no state.
+	LastState any
+	// LastErr is the error returned by the last check of the condition, nil if it succeeded.
+	LastErr error
+	// Cause is the reason why the wait ended: context.DeadlineExceeded if the timeout expired,
+	// context.Canceled if the context was canceled.
+	Cause error
+}
+
+func (e *TimeoutError) Error() string {
+	msg := fmt.Sprintf("timeout reached waiting for %s after %s (%d attempts)", e.Condition,
+		e.Elapsed.Round(time.Millisecond), e.Attempts)
+	if e.LastState != nil {
+		msg += fmt.Sprintf(", last observed state: %v", e.LastState)
+	}
+	if e.LastErr != nil {
+		msg += fmt.Sprintf(", last error: %v", e.LastErr)
+	}
+	if e.Cause != nil && !errors.Is(e.Cause, context.DeadlineExceeded) {
+		msg += fmt.Sprintf(", wait ended because: %v", e.Cause)
+	}
+	return msg
+}
+
+// Unwrap makes errors.Is and errors.As see both the cause of the timeout and the last error.
+func (e *TimeoutError) Unwrap() []error {
+	errs := make([]error, 0, 2)
+	if e.Cause != nil {
+		errs = append(errs, e.Cause)
+	}
+	if e.LastErr != nil {
+		errs = append(errs, e.LastErr)
+	}
+	return errs
+}
+
+// IsTimeout returns true if err is or wraps a TimeoutError.
+func IsTimeout(err error) bool {
+	var timeoutErr *TimeoutError
+	return errors.As(err, &timeoutErr)
+}
+
+type terminalError struct {
+	err error
+}
+
+func (e terminalError) Error() string {
+	return e.err.Error()
+}
+
+func (e terminalError) Unwrap() error {
+	return e.err
+}
+
+// Terminal wraps err so that, when returned by a ConditionFunc, the wait ends immediately and
+// returns err instead of checking the condition again. Use it for unrecoverable errors.
+func Terminal(err error) error {
+	return terminalError{err: err}
+}
+
+// Poll checks the condition described by condition every pollingPeriod until it's met, check
+// returns a Terminal error, timeout expires or ctx is done. The condition is checked for the first
+// time right away. If timeout is 0 the wait is bounded only by ctx.
+// It returns nil if the condition was met, the unwrapped error if check returned a Terminal error
+// and a *TimeoutError otherwise.
+func Poll(ctx context.Context,
+	timeout, pollingPeriod time.Duration,
+	condition string,
+	check ConditionFunc,
+) error {
+	if pollingPeriod <= 0 {
+		return fmt.Errorf("invalid polling period %s waiting for %s: it MUST be positive",
+			pollingPeriod, condition)
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	ticker := time.NewTicker(pollingPeriod)
+	defer ticker.Stop()
+
+	start := time.Now()
+	timeoutErr := &TimeoutError{Condition: condition}
+	for {
+		done, state, err := check(ctx)
+		timeoutErr.Attempts++
+
+		var terminal terminalError
+		if errors.As(err, &terminal) {
+			return fmt.Errorf("failed waiting for %s: %w", condition, terminal.err)
+		}
+		if done && err == nil {
+			return nil
+		}
+		timeoutErr.LastState, timeoutErr.LastErr = state, err
+
+		select {
+		case <-ctx.Done():
+			timeoutErr.Elapsed = time.Since(start)
+			timeoutErr.Cause = ctx.Err()
+			return timeoutErr
+		case <-ticker.C:
+		}
+	}
+}
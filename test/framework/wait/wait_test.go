@@ -0,0 +1,184 @@
+package wait_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anynines/a8s-deployment/test/framework/wait"
+)
+
+const (
+	pollingPeriod = time.Millisecond
+	timeout       = 50 * time.Millisecond
+)
+
+var errCheck = errors.New("check failed")
+
+func TestPollSucceedsWhenConditionIsMet(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		// results are returned by the check one per attempt; the last one is repeated.
+		results      []result
+		wantAttempts int
+	}{
+		"condition_met_at_first_check": {
+			results:      []result{{done: true}},
+			wantAttempts: 1,
+		},
+		"condition_met_after_some_checks": {
+			results:      []result{{}, {}, {done: true}},
+			wantAttempts: 3,
+		},
+		"condition_met_after_failed_checks": {
+			results:      []result{{err: errCheck}, {err: errCheck}, {done: true}},
+			wantAttempts: 3,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			// Rebind tc into this lexical scope. Details on the why at
+			// https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+			tc := tc
+
+			t.Parallel()
+
+			check, attempts := newCheck(tc.results)
+			if err := wait.Poll(context.Background(), time.Minute, pollingPeriod, "test",
+				check); err != nil {
+				t.Fatalf("Expected no error, got: \"%v\"", err)
+			}
+			if *attempts != tc.wantAttempts {
+				t.Fatalf("Expected %d attempts, got %d", tc.wantAttempts, *attempts)
+			}
+		})
+	}
+}
+
+func TestPollReturnsTimeoutErrorWithLastStateAndError(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		results       []result
+		wantLastState any
+		wantLastErr   error
+	}{
+		"last_check_observed_a_state": {
+			results:       []result{{err: errCheck}, {state: "Provisioning"}},
+			wantLastState: "Provisioning",
+		},
+		"last_check_failed": {
+			results:     []result{{state: "Provisioning"}, {err: errCheck}},
+			wantLastErr: errCheck,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			// Rebind tc into this lexical scope. Details on the why at
+			// https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+			tc := tc
+
+			t.Parallel()
+
+			check, attempts := newCheck(tc.results)
+			err := wait.Poll(context.Background(), timeout, pollingPeriod, "test condition",
+				check)
+
+			var timeoutErr *wait.TimeoutError
+			if !errors.As(err, &timeoutErr) {
+				t.Fatalf("Expected *wait.TimeoutError, got: \"%v\"", err)
+			}
+			if !wait.IsTimeout(err) {
+				t.Fatalf("Expected IsTimeout to be true for \"%v\"", err)
+			}
+			if timeoutErr.LastState != tc.wantLastState {
+				t.Fatalf("Expected last state %v, got %v", tc.wantLastState,
+					timeoutErr.LastState)
+			}
+			if timeoutErr.LastErr != tc.wantLastErr {
+				t.Fatalf("Expected last error %v, got %v", tc.wantLastErr, timeoutErr.LastErr)
+			}
+			if tc.wantLastErr != nil && !errors.Is(err, tc.wantLastErr) {
+				t.Fatalf("Expected error to wrap the last error, got: \"%v\"", err)
+			}
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("Expected error to wrap context.DeadlineExceeded, got: \"%v\"", err)
+			}
+			if timeoutErr.Attempts != *attempts {
+				t.Fatalf("Expected %d attempts in error, got %d", *attempts,
+					timeoutErr.Attempts)
+			}
+			if !strings.Contains(err.Error(), "test condition") {
+				t.Fatalf("Expected error message to describe the condition, got: \"%v\"", err)
+			}
+		})
+	}
+}
+
+func TestPollStopsAtTerminalError(t *testing.T) {
+	t.Parallel()
+
+	check, attempts := newCheck([]result{{}, {err: wait.Terminal(errCheck)}})
+	err := wait.Poll(context.Background(), time.Minute, pollingPeriod, "test", check)
+	if !errors.Is(err, errCheck) {
+		t.Fatalf("Expected error wrapping %v, got: \"%v\"", errCheck, err)
+	}
+	if wait.IsTimeout(err) {
+		t.Fatalf("Expected error not to be a timeout, got: \"%v\"", err)
+	}
+	if *attempts != 2 {
+		t.Fatalf("Expected 2 attempts, got %d", *attempts)
+	}
+}
+
+func TestPollStopsWhenContextIsDone(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	check, _ := newCheck([]result{{state: "Provisioning"}})
+	go func() {
+		time.Sleep(10 * pollingPeriod)
+		cancel()
+	}()
+
+	// No timeout: only ctx bounds the wait.
+	err := wait.Poll(ctx, 0, pollingPeriod, "test", check)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected error wrapping context.Canceled, got: \"%v\"", err)
+	}
+	if !wait.IsTimeout(err) {
+		t.Fatalf("Expected *wait.TimeoutError, got: \"%v\"", err)
+	}
+}
+
+func TestPollRejectsInvalidPollingPeriod(t *testing.T) {
+	t.Parallel()
+
+	check, attempts := newCheck([]result{{done: true}})
+	if err := wait.Poll(context.Background(), time.Minute, 0, "test", check); err == nil {
+		t.Fatalf("Expected error for polling period 0, got none")
+	}
+	if *attempts != 0 {
+		t.Fatalf("Expected no attempts, got %d", *attempts)
+	}
+}
+
+type result struct {
+	done  bool
+	state any
+	err   error
+}
+
+func newCheck(results []result) (wait.ConditionFunc, *int) {
+	attempts := 0
+	return func(context.Context) (bool, any, error) {
+		r := results[min(attempts, len(results)-1)]
+		attempts++
+		return r.done, r.state, r.err
+	}, &attempts
+}
@@ -0,0 +1,224 @@
+package wait
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/watch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+const (
+	// DefaultMaxWatches is the default maximum number of watches that ForObject keeps open at
+	// the same time in a process.
+	DefaultMaxWatches = 10
+
+	// resyncPeriod is how often ForObject re-reads the object while it's watching it, as a safety
+	// net against missed events.
+	resyncPeriod = 30 * time.Second
+)
+
+var (
+	watchesMu     sync.Mutex
+	maxWatches    = DefaultMaxWatches
+	activeWatches int
+)
+
+// SetMaxWatches sets the maximum number of watches that ForObject keeps open at the same time in
+// the process. Waits that would exceed it poll instead. 0 disables watches.
+func SetMaxWatches(n int) {
+	watchesMu.Lock()
+	defer watchesMu.Unlock()
+	maxWatches = n
+}
+
+// ActiveWatches returns the number of watches currently open by ForObject.
+func ActiveWatches() int {
+	watchesMu.Lock()
+	defer watchesMu.Unlock()
+	return activeWatches
+}
+
+func acquireWatch() bool {
+	watchesMu.Lock()
+	defer watchesMu.Unlock()
+	if activeWatches >= maxWatches {
+		return false
+	}
+	activeWatches++
+	return true
+}
+
+func releaseWatch() {
+	watchesMu.Lock()
+	defer watchesMu.Unlock()
+	activeWatches--
+}
+
+// ObjectConditionFunc checks a condition on the current version of an object. obj is nil if the
+// object doesn't exist. See ConditionFunc for the meaning of the return values.
+type ObjectConditionFunc func(obj client.Object) (done bool, state any, err error)
+
+// ForObject waits until check is satisfied by the object with the type, namespace and name of obj,
+// until check returns a Terminal error, timeout expires or ctx is done. If timeout is 0 the wait
+// is bounded only by ctx.
+// Changes of the object are observed via a watch, so that short-lived states are not missed and
+// the API server isn't polled. If c can't watch (it doesn't implement client.WithWatch), the
+// maximum number of watches is reached (see SetMaxWatches) or the watch fails, the object is
+// polled every pollingPeriod instead. The return values are the same of Poll.
+func ForObject(ctx context.Context,
+	c client.Client,
+	obj client.Object,
+	timeout, pollingPeriod time.Duration,
+	condition string,
+	check ObjectConditionFunc,
+) error {
+	if pollingPeriod <= 0 {
+		return fmt.Errorf("invalid polling period %s waiting for %s: it MUST be positive",
+			pollingPeriod, condition)
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	key := client.ObjectKeyFromObject(obj)
+	get := func(ctx context.Context) (bool, any, error) {
+		current, ok := obj.DeepCopyObject().(client.Object)
+		if !ok {
+			return false, nil, Terminal(fmt.Errorf("deep copy of %T is not a client.Object", obj))
+		}
+		if err := c.Get(ctx, key, current); err != nil {
+			if apierrors.IsNotFound(err) {
+				return check(nil)
+			}
+			return false, nil, err
+		}
+		return check(current)
+	}
+
+	var events <-chan watch.Event
+	w := startWatch(ctx, c, obj)
+	period := pollingPeriod
+	if w != nil {
+		defer w.Stop()
+		events = w.ResultChan()
+		period = max(resyncPeriod, pollingPeriod)
+	}
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	start := time.Now()
+	timeoutErr := &TimeoutError{Condition: condition}
+	// record registers the result of a check of the condition and returns true if the wait is
+	// over, together with the error to return.
+	record := func(done bool, state any, err error) (bool, error) {
+		timeoutErr.Attempts++
+		var terminal terminalError
+		if errors.As(err, &terminal) {
+			return true, fmt.Errorf("failed waiting for %s: %w", condition, terminal.err)
+		}
+		if done && err == nil {
+			return true, nil
+		}
+		timeoutErr.LastState, timeoutErr.LastErr = state, err
+		return false, nil
+	}
+
+	// The watch is started before reading the object, so no change can go unnoticed.
+	if over, err := record(get(ctx)); over {
+		return err
+	}
+	for {
+		var over bool
+		var err error
+
+		select {
+		case <-ctx.Done():
+			timeoutErr.Elapsed = time.Since(start)
+			timeoutErr.Cause = ctx.Err()
+			return timeoutErr
+		case <-ticker.C:
+			over, err = record(get(ctx))
+		case e, open := <-events:
+			if !open || e.Type == watch.Error {
+				// The watch ended, e.g. because the API server closed it: fall back to polling.
+				w.Stop()
+				events = nil
+				ticker.Reset(pollingPeriod)
+				over, err = record(get(ctx))
+				break
+			}
+			o, ok := e.Object.(client.Object)
+			if !ok || e.Type == watch.Bookmark || client.ObjectKeyFromObject(o) != key {
+				continue
+			}
+			if e.Type == watch.Deleted {
+				over, err = record(check(nil))
+			} else {
+				over, err = record(check(o))
+			}
+		}
+
+		if over {
+			return err
+		}
+	}
+}
+
+// objectWatch is a watch that holds one of the watch slots until it's stopped.
+type objectWatch struct {
+	watch.Interface
+	stopOnce sync.Once
+}
+
+func (w *objectWatch) Stop() {
+	w.stopOnce.Do(func() {
+		w.Interface.Stop()
+		releaseWatch()
+	})
+}
+
+// startWatch starts a watch on obj, or returns nil if that's not possible.
+func startWatch(ctx context.Context, c client.Client, obj client.Object) *objectWatch {
+	wc, ok := c.(client.WithWatch)
+	if !ok || !acquireWatch() {
+		return nil
+	}
+
+	w, err := watchObject(ctx, wc, obj)
+	if err != nil {
+		releaseWatch()
+		return nil
+	}
+	return &objectWatch{Interface: w}
+}
+
+func watchObject(ctx context.Context, c client.WithWatch, obj client.Object) (watch.Interface,
+	error) {
+
+	gvk, err := apiutil.GVKForObject(obj, c.Scheme())
+	if err != nil {
+		return nil, err
+	}
+	list, err := c.Scheme().New(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+	if err != nil {
+		return nil, err
+	}
+	objList, ok := list.(client.ObjectList)
+	if !ok {
+		return nil, fmt.Errorf("%T is not a client.ObjectList", list)
+	}
+
+	return c.Watch(ctx, objList,
+		client.InNamespace(obj.GetNamespace()),
+		client.MatchingFields{"metadata.name": obj.GetName()},
+	)
+}
@@ -0,0 +1,198 @@
+package wait_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/anynines/a8s-deployment/test/framework/wait"
+)
+
+// The tests of ForObject are not parallel because the number of watches is global to the process.
+
+const (
+	namespace = "test-ns"
+	name      = "test-cm"
+
+	// noPolling is used as polling period to make sure that a change can only be observed via the
+	// watch.
+	noPolling = time.Hour
+)
+
+func TestForObjectObservesChangesViaWatch(t *testing.T) {
+	c := newFakeClient(t, newConfigMap(name, "Provisioning"))
+
+	update := func() { setState(t, c, name, "Running") }
+	if err := forConfigMap(c, name, time.Minute, noPolling, stateIs("Running"), update); err != nil {
+		t.Fatalf("Expected no error, got: \"%v\"", err)
+	}
+	if n := wait.ActiveWatches(); n != 0 {
+		t.Fatalf("Expected no active watches after the wait, got %d", n)
+	}
+}
+
+func TestForObjectObservesDeletionViaWatch(t *testing.T) {
+	c := newFakeClient(t, newConfigMap(name, "Running"))
+
+	deleteCM := func() {
+		if err := c.Delete(context.Background(), newConfigMap(name, "")); err != nil {
+			t.Errorf("Expected no error deleting config map, got: \"%v\"", err)
+		}
+	}
+	deleted := func(o client.Object) (bool, any, error) { return o == nil, nil, nil }
+	if err := forConfigMap(c, name, time.Minute, noPolling, deleted, deleteCM); err != nil {
+		t.Fatalf("Expected no error, got: \"%v\"", err)
+	}
+}
+
+func TestForObjectIgnoresEventsOfOtherObjects(t *testing.T) {
+	const other = "other-cm"
+	c := newFakeClient(t, newConfigMap(name, "Provisioning"), newConfigMap(other, "Provisioning"))
+
+	updateOther := func() { setState(t, c, other, "Running") }
+	err := forConfigMap(c, name, 200*time.Millisecond, noPolling, stateIs("Running"), updateOther)
+	if !wait.IsTimeout(err) {
+		t.Fatalf("Expected *wait.TimeoutError, got: \"%v\"", err)
+	}
+}
+
+func TestForObjectPollsWhenWatchesAreNotAvailable(t *testing.T) {
+	testCases := map[string]struct {
+		maxWatches int
+		// hideWatch makes the client not implement client.WithWatch.
+		hideWatch bool
+	}{
+		"max_watches_reached": {maxWatches: 0},
+		"client_cannot_watch": {maxWatches: wait.DefaultMaxWatches, hideWatch: true},
+	}
+
+	for tcName, tc := range testCases {
+		t.Run(tcName, func(t *testing.T) {
+			wait.SetMaxWatches(tc.maxWatches)
+			t.Cleanup(func() { wait.SetMaxWatches(wait.DefaultMaxWatches) })
+
+			fakeClient := newFakeClient(t, newConfigMap(name, "Provisioning"))
+			var c client.Client = fakeClient
+			if tc.hideWatch {
+				c = struct{ client.Client }{fakeClient}
+			}
+
+			check := func(o client.Object) (bool, any, error) {
+				if n := wait.ActiveWatches(); n != 0 {
+					return false, nil, wait.Terminal(
+						errors.New("unexpected watch while polling"))
+				}
+				return stateIs("Running")(o)
+			}
+			update := func() { setState(t, fakeClient, name, "Running") }
+			if err := forConfigMap(c, name, time.Minute, time.Millisecond, check,
+				update); err != nil {
+				t.Fatalf("Expected no error, got: \"%v\"", err)
+			}
+		})
+	}
+}
+
+func TestForObjectReturnsTimeoutErrorWithLastState(t *testing.T) {
+	c := newFakeClient(t, newConfigMap(name, "Provisioning"))
+
+	err := forConfigMap(c, name, 50*time.Millisecond, time.Millisecond, stateIs("Running"), nil)
+	var timeoutErr *wait.TimeoutError
+	if !errors.As(err, &timeoutErr) {
+		t.Fatalf("Expected *wait.TimeoutError, got: \"%v\"", err)
+	}
+	if timeoutErr.LastState != "Provisioning" {
+		t.Fatalf("Expected last state Provisioning, got %v", timeoutErr.LastState)
+	}
+	if n := wait.ActiveWatches(); n != 0 {
+		t.Fatalf("Expected no active watches after the wait, got %d", n)
+	}
+}
+
+func TestForObjectStopsAtTerminalError(t *testing.T) {
+	c := newFakeClient(t, newConfigMap(name, "Provisioning"))
+
+	check := func(client.Object) (bool, any, error) { return false, nil, wait.Terminal(errCheck) }
+	err := forConfigMap(c, name, time.Minute, noPolling, check, nil)
+	if !errors.Is(err, errCheck) {
+		t.Fatalf("Expected error wrapping %v, got: \"%v\"", errCheck, err)
+	}
+}
+
+// forConfigMap waits for check on the config map named cmName. If change is not nil, it's invoked
+// once the wait has begun.
+func forConfigMap(c client.Client, cmName string, timeout, pollingPeriod time.Duration,
+	check wait.ObjectConditionFunc, change func()) error {
+
+	started := make(chan struct{})
+	done := make(chan struct{})
+	var once bool
+	wrapped := func(o client.Object) (bool, any, error) {
+		if !once {
+			once = true
+			close(started)
+		}
+		return check(o)
+	}
+	if change != nil {
+		go func() {
+			defer close(done)
+			select {
+			case <-started:
+				change()
+			case <-time.After(timeout):
+			}
+		}()
+	} else {
+		close(done)
+	}
+
+	err := wait.ForObject(context.Background(), c, newConfigMap(cmName, ""), timeout,
+		pollingPeriod, "test condition", wrapped)
+	<-done
+	return err
+}
+
+func stateIs(want string) wait.ObjectConditionFunc {
+	return func(o client.Object) (bool, any, error) {
+		if o == nil {
+			return false, "not found", nil
+		}
+		state := o.(*corev1.ConfigMap).Data["state"]
+		return state == want, state, nil
+	}
+}
+
+func newConfigMap(cmName, state string) *corev1.ConfigMap {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: cmName},
+	}
+	if state != "" {
+		cm.Data = map[string]string{"state": state}
+	}
+	return cm
+}
+
+func newFakeClient(t *testing.T, objs ...client.Object) client.WithWatch {
+	t.Helper()
+	return fake.NewClientBuilder().WithObjects(objs...).Build()
+}
+
+func setState(t *testing.T, c client.Client, cmName, state string) {
+	cm := &corev1.ConfigMap{}
+	if err := c.Get(context.Background(), client.ObjectKey{Namespace: namespace, Name: cmName},
+		cm); err != nil {
+		t.Errorf("Expected no error getting config map, got: \"%v\"", err)
+		return
+	}
+	cm.Data = map[string]string{"state": state}
+	if err := c.Update(context.Background(), cm); err != nil {
+		t.Errorf("Expected no error updating config map, got: \"%v\"", err)
+	}
+}
@@ -0,0 +1,346 @@
+// Package workload provides a driver that continuously writes to a DSI in the background, e.g.
+// while a spec disrupts it, and reports when the DSI couldn't be written to and which acknowledged
+// writes it lost. Specs use the report to assert budgets for the recovery time objective (RTO)
+// and the recovery point objective (RPO) of a disruption.
+package workload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/rest"
+	runtimeClient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/anynines/a8s-deployment/test/framework"
+	"github.com/anynines/a8s-deployment/test/framework/dsi"
+	"github.com/anynines/a8s-deployment/test/framework/wait"
+)
+
+const (
+	defaultRate         = 10
+	defaultWriteTimeout = 5 * time.Second
+)
+
+// ConnectFunc returns a client to write to the DSI with. The driver calls it before the first
+// write and after every failed write, so it should connect to the current primary.
+type ConnectFunc func(ctx context.Context) (dsi.DSIClient, error)
+
+// Options configures a Driver.
+type Options struct {
+	// Entity is the entity that the records are written to. It must not exist yet. Required.
+	Entity string
+	// Connect returns the client to write with. Required.
+	Connect ConnectFunc
+	// Rate is the number of writes per second. Defaults to 10. Writes are sequential, so the
+	// actual rate is lower while writes take longer than 1/Rate.
+	Rate int
+	// WriteTimeout is the timeout of a single write. Defaults to 5s.
+	WriteTimeout time.Duration
+}
+
+// Write is an attempt to write a record.
+type Write struct {
+	// Seq is the sequence number of the record, starting at 1. It's also the data of the record.
+	Seq int64
+	// Time is when the attempt ended.
+	Time time.Time
+	// Err is the error of the attempt, nil if the write was acknowledged.
+	Err error
+}
+
+// Window is a time window during which the DSI couldn't be written to.
+type Window struct {
+	// Start is when the first write of the window failed.
+	Start time.Time
+	// End is when the first write after the window was acknowledged, or when the driver stopped
+	// if no write was acknowledged after the window.
+	End time.Time
+	// Recovered is false if no write was acknowledged after the window.
+	Recovered bool
+	// Failures is the number of writes that failed during the window.
+	Failures int
+}
+
+// Duration returns the length of the window.
+func (w Window) Duration() time.Duration {
+	return w.End.Sub(w.Start)
+}
+
+// Report is the outcome of a run of a Driver.
+type Report struct {
+	// Start and End are when the driver started and stopped.
+	Start, End time.Time
+	// Acknowledged is the number of acknowledged writes.
+	Acknowledged int
+	// Failures are the failed writes, in order.
+	Failures []Write
+	// Windows are the windows during which the DSI couldn't be written to, in order.
+	Windows []Window
+	// Lost are the acknowledged writes whose records the DSI didn't have after the driver
+	// stopped, in order.
+	Lost []Write
+}
+
+// RTO returns the recovery time observed by the driver: the length of the longest window during
+// which the DSI couldn't be written to. It's 0 if no write failed.
+func (r Report) RTO() time.Duration {
+	var rto time.Duration
+	for _, w := range r.Windows {
+		if d := w.Duration(); d > rto {
+			rto = d
+		}
+	}
+	return rto
+}
+
+// RPO returns the data loss observed by the driver: the time from the acknowledgment of the first
+// lost write to the start of the following window during which the DSI couldn't be written to,
+// i.e. the writes the DSI lost when it went down. If no window follows, the loss lasts until the
+// driver stopped. It's 0 if no write was lost.
+func (r Report) RPO() time.Duration {
+	if len(r.Lost) == 0 {
+		return 0
+	}
+	first := r.Lost[0].Time
+	for _, w := range r.Windows {
+		if w.Start.After(first) {
+			return w.Start.Sub(first)
+		}
+	}
+	return r.End.Sub(first)
+}
+
+func (r Report) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d acknowledged and %d failed writes in %s, %d lost acknowledged writes "+
+		"(RPO %s), %d windows of unavailability (RTO %s)", r.Acknowledged, len(r.Failures),
+		r.End.Sub(r.Start).Round(time.Millisecond), len(r.Lost), r.RPO(), len(r.Windows), r.RTO())
+	for _, w := range r.Windows {
+		fmt.Fprintf(&b, "\n- %s to %s (%s, %d failed writes", w.Start.Format(time.RFC3339Nano),
+			w.End.Format(time.RFC3339Nano), w.Duration(), w.Failures)
+		if !w.Recovered {
+			b.WriteString(", not recovered")
+		}
+		b.WriteString(")")
+	}
+	return b.String()
+}
+
+// Driver writes sequence-numbered records to a DSI in the background until it's stopped.
+type Driver struct {
+	opts   Options
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu     sync.Mutex
+	start  time.Time
+	writes []Write
+}
+
+// Start starts a driver that writes records as configured by opts until Stop is called or ctx is
+// done.
+func Start(ctx context.Context, opts Options) *Driver {
+	if opts.Rate <= 0 {
+		opts.Rate = defaultRate
+	}
+	if opts.WriteTimeout <= 0 {
+		opts.WriteTimeout = defaultWriteTimeout
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	d := &Driver{
+		opts:   opts,
+		cancel: cancel,
+		done:   make(chan struct{}),
+		start:  time.Now(),
+	}
+	go d.run(ctx)
+	return d
+}
+
+// Writes returns the number of writes attempted so far.
+func (d *Driver) Writes() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.writes)
+}
+
+// Stop stops the driver and reads the records back via a client returned by Connect to find the
+// lost acknowledged writes. It retries reading the records within the configured timeout, e.g.
+// while the DSI is still recovering. If it can't read them it returns the report without lost
+// writes and the error.
+func (d *Driver) Stop(ctx context.Context) (Report, error) {
+	d.cancel()
+	<-d.done
+
+	d.mu.Lock()
+	r := report(d.start, time.Now(), d.writes)
+	d.mu.Unlock()
+
+	var stored map[int64]bool
+	err := wait.Poll(ctx, framework.AsyncOpsTimeout(), framework.PollingPeriod(),
+		fmt.Sprintf("records of workload entity %s", d.opts.Entity),
+		func(ctx context.Context) (bool, any, error) {
+			var err error
+			stored, err = d.read(ctx)
+			return err == nil, nil, err
+		},
+	)
+	if err != nil {
+		return r, err
+	}
+	for _, w := range d.writes {
+		if w.Err == nil && !stored[w.Seq] {
+			r.Lost = append(r.Lost, w)
+		}
+	}
+	return r, nil
+}
+
+func (d *Driver) run(ctx context.Context) {
+	defer close(d.done)
+	ticker := time.NewTicker(time.Second / time.Duration(d.opts.Rate))
+	defer ticker.Stop()
+
+	var client dsi.DSIClient
+	for seq := int64(1); ; seq++ {
+		var err error
+		if client == nil {
+			client, err = d.opts.Connect(ctx)
+		}
+		if err == nil {
+			writeCtx, cancel := context.WithTimeout(ctx, d.opts.WriteTimeout)
+			err = client.Write(writeCtx, d.opts.Entity, strconv.FormatInt(seq, 10))
+			cancel()
+		}
+		if err != nil {
+			// The driver was stopped during the attempt, which tells nothing about the DSI.
+			if ctx.Err() != nil {
+				return
+			}
+			client = nil
+		}
+
+		d.mu.Lock()
+		d.writes = append(d.writes, Write{Seq: seq, Time: time.Now(), Err: err})
+		d.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *Driver) read(ctx context.Context) (map[int64]bool, error) {
+	client, err := d.opts.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	data, err := client.Read(ctx, d.opts.Entity)
+	if errors.Is(err, dsi.ErrNotFound) {
+		// No write was ever acknowledged.
+		return map[int64]bool{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	stored := map[int64]bool{}
+	for _, line := range strings.Split(data, "\n") {
+		if line == "" {
+			continue
+		}
+		seq, err := strconv.ParseInt(line, 10, 64)
+		if err != nil {
+			return nil, wait.Terminal(fmt.Errorf("entity %s has unexpected record %q",
+				d.opts.Entity, line))
+		}
+		stored[seq] = true
+	}
+	return stored, nil
+}
+
+// report returns the report of writes without the lost ones.
+func report(start, end time.Time, writes []Write) Report {
+	r := Report{Start: start, End: end}
+	var window *Window
+	for _, w := range writes {
+		if w.Err != nil {
+			r.Failures = append(r.Failures, w)
+			if window == nil {
+				window = &Window{Start: w.Time}
+			}
+			window.Failures++
+			continue
+		}
+		r.Acknowledged++
+		if window != nil {
+			window.End, window.Recovered = w.Time, true
+			r.Windows = append(r.Windows, *window)
+			window = nil
+		}
+	}
+	if window != nil {
+		window.End = end
+		r.Windows = append(r.Windows, *window)
+	}
+	return r
+}
+
+// PrimaryServiceConnector connects to the primary of a DSI, the pod selected by its primary
+// service, via a port forward. Its Connect method is a ConnectFunc that replaces the port forward
+// on every call.
+type PrimaryServiceConnector struct {
+	// Client is the Kubernetes client used to find the primary.
+	Client runtimeClient.Client
+	// RESTConfig is the config of the API server used to port forward to the primary, e.g. the
+	// one that Client was created with.
+	RESTConfig *rest.Config
+	// Instance is the DSI.
+	Instance runtimeClient.Object
+	// DataService is the name of the data service of the DSI.
+	DataService string
+	// Port is the port of the DSI to forward to.
+	Port int
+	// Credentials are the credentials that the clients connect with.
+	Credentials map[string]string
+
+	mu     sync.Mutex
+	stopCh chan struct{}
+}
+
+// Connect replaces the port forward to the primary and returns a client that connects through
+// it.
+func (p *PrimaryServiceConnector) Connect(ctx context.Context) (dsi.DSIClient, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.close()
+
+	stopCh, localPort, err := framework.PortForward(ctx, p.Port, p.RESTConfig, p.Instance,
+		p.Client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to port forward to the primary of DSI %s/%s: %w",
+			p.Instance.GetNamespace(), p.Instance.GetName(), err)
+	}
+	p.stopCh = stopCh
+	return dsi.NewClient(p.DataService, strconv.Itoa(localPort), p.Credentials)
+}
+
+// Close closes the port forward.
+func (p *PrimaryServiceConnector) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.close()
+}
+
+func (p *PrimaryServiceConnector) close() {
+	if p.stopCh != nil {
+		close(p.stopCh)
+		p.stopCh = nil
+	}
+}
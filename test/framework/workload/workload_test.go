@@ -0,0 +1,183 @@
+package workload_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/anynines/a8s-deployment/test/framework/dsi"
+	"github.com/anynines/a8s-deployment/test/framework/workload"
+)
+
+func TestDriver(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		// unavailable are the sequence numbers whose writes fail.
+		unavailable map[int64]bool
+		// lost are the sequence numbers whose writes are acknowledged but not stored.
+		lost         map[int64]bool
+		wantWindows  int
+		wantFailures int
+		wantLost     []int64
+	}{
+		"no_disruption": {
+			wantLost: []int64{},
+		},
+		"unavailability_and_lost_writes": {
+			unavailable:  map[int64]bool{5: true, 6: true, 7: true, 12: true},
+			lost:         map[int64]bool{3: true, 4: true},
+			wantWindows:  2,
+			wantFailures: 4,
+			wantLost:     []int64{3, 4},
+		},
+		"single_lost_write": {
+			unavailable:  map[int64]bool{5: true},
+			lost:         map[int64]bool{4: true},
+			wantWindows:  1,
+			wantFailures: 1,
+			wantLost:     []int64{4},
+		},
+	}
+
+	for tcName, tc := range testCases {
+		// Rebind tc into this lexical scope. Details on the why at
+		// https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		tc := tc
+		t.Run(tcName, func(t *testing.T) {
+			t.Parallel()
+
+			db := &fakeDSI{
+				unavailable: func(seq int64) bool { return tc.unavailable[seq] },
+				lost:        tc.lost,
+			}
+			d := workload.Start(context.Background(), workload.Options{
+				Entity:  "workload",
+				Connect: db.connect,
+				Rate:    1000,
+			})
+			for d.Writes() < 20 {
+				time.Sleep(time.Millisecond)
+			}
+			r, err := d.Stop(context.Background())
+			if err != nil {
+				t.Fatalf("Expected no error, got: \"%v\"", err)
+			}
+
+			if len(r.Windows) != tc.wantWindows || len(r.Failures) != tc.wantFailures {
+				t.Fatalf("Expected %d windows and %d failures, got: %s", tc.wantWindows,
+					tc.wantFailures, r)
+			}
+			if r.Acknowledged+len(r.Failures) < 20 {
+				t.Fatalf("Expected at least 20 writes, got: %s", r)
+			}
+			lost := []int64{}
+			for _, w := range r.Lost {
+				lost = append(lost, w.Seq)
+			}
+			if !reflect.DeepEqual(lost, tc.wantLost) {
+				t.Fatalf("Expected lost writes %v, got %v", tc.wantLost, lost)
+			}
+			var longest time.Duration
+			for _, w := range r.Windows {
+				if !w.Recovered {
+					t.Fatalf("Expected all windows to be recovered, got: %s", r)
+				}
+				if w.Duration() > longest {
+					longest = w.Duration()
+				}
+			}
+			if r.RTO() != longest {
+				t.Fatalf("Expected the RTO to be the longest window %s, got: %s", longest, r)
+			}
+			var wantRPO time.Duration
+			if len(r.Lost) > 0 {
+				// The windows of the test cases follow the lost writes.
+				wantRPO = r.Windows[0].Start.Sub(r.Lost[0].Time)
+			}
+			if r.RPO() != wantRPO || (len(r.Lost) > 0 && r.RPO() <= 0) {
+				t.Fatalf("Expected the RPO to last from the first lost write to the following "+
+					"window, %s, got: %s", wantRPO, r)
+			}
+			if wantConnects := 1 + tc.wantWindows; db.connects < wantConnects {
+				t.Fatalf("Expected the driver to reconnect after failures, got %d connects",
+					db.connects)
+			}
+		})
+	}
+}
+
+func TestDriverReportsWindowThatDidNotRecover(t *testing.T) {
+	t.Parallel()
+
+	db := &fakeDSI{unavailable: func(seq int64) bool { return seq > 5 }}
+	d := workload.Start(context.Background(), workload.Options{
+		Entity:  "workload",
+		Connect: db.connect,
+		Rate:    1000,
+	})
+	for d.Writes() < 10 {
+		time.Sleep(time.Millisecond)
+	}
+	r, err := d.Stop(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got: \"%v\"", err)
+	}
+	if len(r.Windows) != 1 || r.Windows[0].Recovered || !r.Windows[0].End.Equal(r.End) {
+		t.Fatalf("Expected one window that didn't recover and ends with the run, got: %s", r)
+	}
+	if !strings.Contains(r.String(), "not recovered") {
+		t.Fatalf("Expected the report to mention the window that didn't recover, got: %s", r)
+	}
+}
+
+// fakeDSI stores the records written by the driver in memory.
+type fakeDSI struct {
+	// unavailable returns true if the write of seq fails.
+	unavailable func(seq int64) bool
+	// lost are the sequence numbers whose writes are acknowledged but not stored.
+	lost map[int64]bool
+
+	mu       sync.Mutex
+	records  []string
+	connects int
+}
+
+func (f *fakeDSI) connect(ctx context.Context) (dsi.DSIClient, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.connects++
+	return fakeClient{fakeDSI: f}, nil
+}
+
+// fakeClient implements the methods of dsi.DSIClient used by the driver. The other ones panic via
+// the nil embedded interface.
+type fakeClient struct {
+	dsi.DSIClient
+	*fakeDSI
+}
+
+func (c fakeClient) Write(ctx context.Context, entity, data string) error {
+	seq, _ := strconv.ParseInt(data, 10, 64)
+	if c.unavailable != nil && c.unavailable(seq) {
+		return errors.New("unavailable")
+	}
+	if c.lost[seq] {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.records = append(c.records, data)
+	return nil
+}
+
+func (c fakeClient) Read(ctx context.Context, entity string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return strings.Join(c.records, "\n"), nil
+}